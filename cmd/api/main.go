@@ -2,10 +2,8 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
 	"crypto/x509"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
@@ -15,9 +13,17 @@ import (
 	customMiddleware "github.com/yordanos-habtamu/b2b-payments/internal/server/middleware"
 	"github.com/yordanos-habtamu/b2b-payments/internal/service"
 	"github.com/yordanos-habtamu/b2b-payments/internal/handler"
+	"github.com/yordanos-habtamu/b2b-payments/internal/event"
+	"github.com/yordanos-habtamu/b2b-payments/internal/metrics"
+	"github.com/yordanos-habtamu/b2b-payments/internal/repository"
+	"github.com/yordanos-habtamu/b2b-payments/internal/resilience"
+	"github.com/yordanos-habtamu/b2b-payments/internal/scheduler"
+	"github.com/yordanos-habtamu/b2b-payments/internal/worker"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
 
 	"github.com/yordanos-habtamu/b2b-payments/internal/config"
 )
@@ -28,16 +34,74 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// mTLS: clients must present a certificate issued by CA_FILE
+	tlsConfig, err := cfg.ServerTLSConfig()
+	if err != nil {
+		log.Fatalf("Failed to load TLS config: %v", err)
+	}
+
+	// Proxies listed in TRUSTED_PROXY_CNS may forward their client's certificate
+	proxyTrust := customMiddleware.NewProxyTrust(cfg.TrustedProxyCommonNames(), tlsConfig.ClientCAs)
+
 	e := echo.New()
 
 	// Basic middleware
 	e.Use(middleware.Recover())
 	e.Use(middleware.Logger())
 
+	// Initialize Redis client for events
+	redisOpts, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		log.Fatalf("Failed to parse Redis URL: %v", err)
+	}
+	rdb := redis.NewClient(redisOpts)
+
+	// Circuit breakers are listed and operated through /admin/circuit-breakers
+	metricsCollector := metrics.NewMetricsCollector()
+	breakers := resilience.NewCircuitBreakerRegistry()
+	breakers.SetMetrics(metricsCollector)
+	rdb.AddHook(resilience.NewRedisCircuitBreakerHook(breakers.Register(resilience.CircuitBreakerConfig{
+		Name:      "redis",
+		IsFailure: resilience.IsRetryableError,
+		OnStateChange: func(name string, from, to resilience.CircuitState) {
+			log.Printf("Circuit breaker %s: %s -> %s", name, from, to)
+		},
+	})))
+	circuitBreakerHandler := handler.NewCircuitBreakerHandler(breakers)
+
+	// Payments live in Postgres, shared with the worker
+	db, err := config.NewDatabasePool(config.NewDatabaseConfig())
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	// Each database call is admitted by the limiter and gets its own deadline,
+	// bounded by the request's, and slow reads are hedged and retried within
+	// DB_RETRY_BUDGET_PERCENT. Rejected calls are answered with 503 and
+	// Retry-After.
+	dbConfig := config.NewDependencyConfig("DB")
+	paymentRepository := repository.NewPaymentRepository(db,
+		config.NewLimiter("postgres", config.NewLimiterConfig("DB")),
+		config.NewDeadline("postgres", dbConfig), config.NewHedger("postgres", dbConfig),
+		config.NewRetryBudget("postgres", dbConfig, metricsCollector))
+
 	// Initialize services
-	paymentService := service.NewPaymentService()
+	eventPublisher := event.NewEventPublisher(rdb, "")
+	paymentService := service.NewPaymentService(paymentRepository, eventPublisher)
 	paymentHandler := handler.NewPaymentHandler(paymentService)
 
+	eventStream := event.NewEventStream(rdb, "")
+	eventStreamHandler := handler.NewEventStreamHandler(eventStream)
+
+	jobQueue := worker.NewJobQueue(rdb, worker.DefaultQueueName, 0)
+	jobQueue.SetJobRetention(config.NewWorkerConfig().JobRetention)
+	deadLetterHandler := handler.NewDeadLetterHandler(jobQueue)
+	jobHandler := handler.NewJobHandler(jobQueue)
+
+	// Read-only view of the worker's periodic tasks; this process never runs them
+	schedulerHandler := handler.NewSchedulerHandler(scheduler.NewScheduler(rdb, "", ""))
+
 	// Health check (no auth required)
 	e.GET("/health", func(c echo.Context) error {
 		return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
@@ -45,7 +109,7 @@ func main() {
 
 	// Protected API group — all routes under /api require tenant auth
 	api := e.Group("/api/v1")
-	api.Use(customMiddleware.TenantExtraction())
+	api.Use(customMiddleware.TenantExtraction(proxyTrust))
 	
 	// Initialize OPA middleware
 	opaMiddleware, err := customMiddleware.NewOPAMiddleware()
@@ -60,12 +124,13 @@ func main() {
 		log.Fatalf("Failed to initialize idempotency: %v", err)
 	}
 
-	api.Use(customMiddleware.TenantExtraction())
+	api.Use(customMiddleware.TenantExtraction(proxyTrust))
 	api.Use(opaMiddleware.Authorize()) // <-- OPA policy-based authorization
 	api.Use(idempotency.Idempotent()) // <-- Idempotency protection
 
-	// Payment routes
+	// Payment routes; database and connector calls made for a request share its deadline
 	payments := api.Group("/payments")
+	payments.Use(customMiddleware.RequestDeadline(cfg.RequestTimeout))
 	payments.GET("", paymentHandler.ListPayments)
 	payments.POST("", paymentHandler.CreatePayment)
 	payments.GET("/stats", paymentHandler.GetPaymentStats)
@@ -74,6 +139,38 @@ func main() {
 	payments.POST("/:id/process", paymentHandler.ProcessPayment)
 	payments.POST("/:id/cancel", paymentHandler.CancelPayment)
 
+	// Event routes
+	api.GET("/events/stream", eventStreamHandler.StreamEvents)
+
+	// Job status routes
+	jobs := api.Group("/jobs")
+	jobs.GET("", jobHandler.ListJobs)
+	jobs.GET("/:id", jobHandler.GetJob)
+
+	// Admin routes — restricted to operator certificates listed in ADMIN_CERT_CNS
+	admin := e.Group("/admin")
+	admin.Use(customMiddleware.AdminAuthorization(cfg.AdminCommonNames(), proxyTrust))
+
+	deadLetters := admin.Group("/dead-letters")
+	deadLetters.GET("", deadLetterHandler.ListDeadLetters)
+	deadLetters.DELETE("", deadLetterHandler.PurgeDeadLetters)
+	deadLetters.POST("/requeue", deadLetterHandler.RequeueDeadLetters)
+	deadLetters.GET("/:id", deadLetterHandler.GetDeadLetter)
+	deadLetters.POST("/:id/requeue", deadLetterHandler.RequeueDeadLetter)
+	deadLetters.DELETE("/:id", deadLetterHandler.PurgeDeadLetter)
+
+	admin.GET("/scheduler/tasks", schedulerHandler.ListTasks)
+
+	circuitBreakers := admin.Group("/circuit-breakers")
+	circuitBreakers.GET("", circuitBreakerHandler.ListCircuitBreakers)
+	circuitBreakers.GET("/:name", circuitBreakerHandler.GetCircuitBreaker)
+	circuitBreakers.POST("/:name/force-open", circuitBreakerHandler.ForceOpen)
+	circuitBreakers.POST("/:name/force-close", circuitBreakerHandler.ForceClose)
+	circuitBreakers.POST("/:name/reset", circuitBreakerHandler.ResetCircuitBreaker)
+
+	// Prometheus metrics, including circuit breaker state and transitions
+	admin.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
+
 	// Legacy endpoint for backward compatibility
 	api.GET("/payments", func(c echo.Context) error {
 		tenantID, err := customMiddleware.GetTenantID(c)
@@ -90,11 +187,12 @@ func main() {
 
 	// Optional: add a simple endpoint to echo cert details (useful for debugging)
 	api.GET("/whoami", func(c echo.Context) error {
-		tlsState := c.Request().TLS
-		if tlsState == nil || len(tlsState.PeerCertificates) == 0 {
+		// The client's certificate, which behind a trusted proxy is the one it forwarded
+		cert, ok := c.Get(customMiddleware.ClientCertContextKey).(*x509.Certificate)
+		if !ok {
 			return c.JSON(http.StatusBadRequest, "no client cert")
 		}
-		cert := tlsState.PeerCertificates[0]
+		tlsState := c.Request().TLS
 
 		tenantID, _ := customMiddleware.GetTenantID(c)
 
@@ -107,32 +205,10 @@ func main() {
 			"not_after":       cert.NotAfter,
 			"dns_names":       cert.DNSNames,
 			"verified_chains": len(tlsState.VerifiedChains) > 0,
+			"via_proxy":       cert != tlsState.PeerCertificates[0],
 		})
 	})
 
-	// Load server cert/key
-	serverCert, err := tls.LoadX509KeyPair(cfg.ServerCert, cfg.ServerKey)
-	if err != nil {
-		log.Fatalf("Failed to load server cert/key: %v", err)
-	}
-
-	// Load CA for client verification
-	caCertPEM, err := ioutil.ReadFile(cfg.CAFile)
-	if err != nil {
-		log.Fatalf("Failed to read CA file: %v", err)
-	}
-	caPool := x509.NewCertPool()
-	if !caPool.AppendCertsFromPEM(caCertPEM) {
-		log.Fatalf("Failed to append CA cert")
-	}
-
-	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{serverCert},
-		ClientCAs:    caPool,
-		ClientAuth:   tls.RequireAndVerifyClientCert, // Zero Trust core
-		MinVersion:   tls.VersionTLS13,
-	}
-
 	server := &http.Server{
 		Addr:      fmt.Sprintf(":%d", cfg.Port),
 		Handler:   e,
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/yordanos-habtamu/b2b-payments/internal/worker"
+)
+
+const usage = `Usage: dlq <command> [flags]
+
+Commands:
+  list      List dead-lettered jobs
+  inspect   Show a dead-lettered job with its full error history (dlq inspect <job-id>)
+  requeue   Requeue one job (dlq requeue <job-id>) or every job matching filters
+  purge     Delete one job (dlq purge <job-id>) or every job matching filters
+  depth     Print the number of dead-lettered jobs
+
+Flags:
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	command := os.Args[1]
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	redisURL := flags.String("redis", getEnv("REDIS_URL", "redis://localhost:6379/0"), "Redis URL")
+	queueName := flags.String("queue", worker.DefaultQueueName, "Job queue name")
+	tenantID := flags.String("tenant", "", "Only match jobs for this tenant")
+	jobType := flags.String("type", "", "Only match jobs of this type")
+	since := flags.Duration("since", 0, "Only match jobs dead-lettered within this duration (e.g. 24h)")
+	limit := flags.Int("limit", 50, "Maximum number of jobs to list")
+	all := flags.Bool("all", false, "Confirm a bulk requeue/purge without filters")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[2:])
+
+	opts, err := redis.ParseURL(*redisURL)
+	if err != nil {
+		log.Fatalf("Failed to parse Redis URL: %v", err)
+	}
+	rdb := redis.NewClient(opts)
+	defer rdb.Close()
+
+	ctx := context.Background()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		log.Fatalf("Failed to connect to Redis: %v", err)
+	}
+
+	queue := worker.NewJobQueue(rdb, *queueName, 0)
+
+	filter := &worker.DeadLetterFilter{
+		TenantID: *tenantID,
+		JobType:  *jobType,
+	}
+	if *since > 0 {
+		from := time.Now().Add(-*since)
+		filter.From = &from
+	}
+	hasFilter := filter.TenantID != "" || filter.JobType != "" || filter.From != nil
+
+	switch command {
+	case "list":
+		filter.Limit = *limit
+		deadLetters, err := queue.ListDeadLetters(ctx, filter)
+		if err != nil {
+			log.Fatalf("Failed to list dead letters: %v", err)
+		}
+		printDeadLetters(deadLetters)
+
+	case "inspect":
+		jobID := flags.Arg(0)
+		if jobID == "" {
+			log.Fatal("inspect requires a job ID")
+		}
+		deadLetter, err := queue.GetDeadLetter(ctx, jobID)
+		if err != nil {
+			log.Fatalf("Failed to get dead letter %s: %v", jobID, err)
+		}
+		out, _ := json.MarshalIndent(deadLetter, "", "  ")
+		fmt.Println(string(out))
+
+	case "requeue":
+		if jobID := flags.Arg(0); jobID != "" {
+			if err := queue.RequeueDeadLetter(ctx, jobID); err != nil {
+				log.Fatalf("Failed to requeue %s: %v", jobID, err)
+			}
+			fmt.Printf("Requeued %s\n", jobID)
+			return
+		}
+		if !hasFilter && !*all {
+			log.Fatal("requeue without a job ID requires a filter or -all")
+		}
+		requeued, err := queue.RequeueDeadLetters(ctx, filter)
+		if err != nil {
+			log.Fatalf("Failed to requeue dead letters after %d: %v", requeued, err)
+		}
+		fmt.Printf("Requeued %d jobs\n", requeued)
+
+	case "purge":
+		if jobID := flags.Arg(0); jobID != "" {
+			if err := queue.PurgeDeadLetter(ctx, jobID); err != nil {
+				log.Fatalf("Failed to purge %s: %v", jobID, err)
+			}
+			fmt.Printf("Purged %s\n", jobID)
+			return
+		}
+		if !hasFilter && !*all {
+			log.Fatal("purge without a job ID requires a filter or -all")
+		}
+		purged, err := queue.PurgeDeadLetters(ctx, filter)
+		if err != nil {
+			log.Fatalf("Failed to purge dead letters after %d: %v", purged, err)
+		}
+		fmt.Printf("Purged %d jobs\n", purged)
+
+	case "depth":
+		depth, err := queue.DeadLetterDepth(ctx)
+		if err != nil {
+			log.Fatalf("Failed to get dead letter depth: %v", err)
+		}
+		fmt.Println(depth)
+
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+func printDeadLetters(deadLetters []*worker.DeadLetter) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "JOB ID\tTYPE\tTENANT\tATTEMPTS\tDEAD AT\tLAST ERROR")
+	for _, deadLetter := range deadLetters {
+		lastError := ""
+		if n := len(deadLetter.Job.Errors); n > 0 {
+			lastError = deadLetter.Job.Errors[n-1].Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n",
+			deadLetter.Job.ID,
+			deadLetter.Job.Type,
+			deadLetter.Job.TenantID,
+			len(deadLetter.Job.Errors),
+			deadLetter.DeadAt.Format(time.RFC3339),
+			lastError,
+		)
+	}
+	w.Flush()
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
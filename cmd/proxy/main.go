@@ -4,15 +4,21 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/yordanos-habtamu/b2b-payments/internal/config"
+	"github.com/yordanos-habtamu/b2b-payments/internal/handler"
+	"github.com/yordanos-habtamu/b2b-payments/internal/metrics"
 	"github.com/yordanos-habtamu/b2b-payments/internal/proxy"
+	customMiddleware "github.com/yordanos-habtamu/b2b-payments/internal/server/middleware"
 )
 
 func main() {
@@ -22,19 +28,73 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	// Get backend servers from environment or use defaults
-	backendServers := getBackendServers()
+	// Backends come from PROXY_BACKENDS_FILE if set, else BACKEND_SERVERS
+	backendsFile := os.Getenv("PROXY_BACKENDS_FILE")
+	backendServers, err := getBackendServers(backendsFile)
+	if err != nil {
+		log.Fatalf("Failed to load backends: %v", err)
+	}
+
+	strategy, err := proxy.ParseStrategy(getEnv("PROXY_STRATEGY", "round_robin"))
+	if err != nil {
+		log.Fatalf("Invalid PROXY_STRATEGY: %v", err)
+	}
 
 	// Initialize load balancer
-	lb, err := proxy.NewLoadBalancer(backendServers, proxy.RoundRobin)
+	lb, err := proxy.NewLoadBalancer(backendServers, strategy)
 	if err != nil {
 		log.Fatalf("Failed to create load balancer: %v", err)
 	}
 
+	// consistent_hash keys requests by client IP, tenant or a header so they stick to one backend
+	hashKey := proxy.HashKeySource(getEnv("PROXY_HASH_KEY", string(proxy.HashByClientIP)))
+	if err := lb.SetHashKey(hashKey, os.Getenv("PROXY_HASH_HEADER")); err != nil {
+		log.Fatalf("Invalid PROXY_HASH_KEY: %v", err)
+	}
+
+	// Bound concurrent upstream calls (PROXY_LIMITER_MODE=bulkhead|adaptive|none)
+	lb.SetLimiter(config.NewLimiter("proxy_upstream", config.NewLimiterConfig("PROXY")))
+
+	// Active health checks (PROXY_HEALTH_*) and passive outlier ejection (PROXY_OUTLIER_*)
+	lb.SetHealthCheck(config.NewHealthCheckConfig())
+	lb.SetOutlierDetection(config.NewOutlierDetectionConfig())
+
+	// Retry idempotent requests on another backend when one cannot be reached
+	// (PROXY_RETRY_*); retries and budget exhaustion are exported as metrics
+	lb.SetRetry(config.NewProxyRetryConfig(metrics.NewMetricsCollector()))
+
+	// Re-originate mTLS to https backends with the proxy's own certificate
+	clientTLSConfig, err := cfg.ProxyClientTLSConfig()
+	if err != nil {
+		log.Fatalf("Failed to load proxy client TLS config: %v", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = clientTLSConfig
+	lb.SetTransport(transport)
+
+	// Removed backends finish their requests within PROXY_DRAIN_TIMEOUT; added
+	// and recovered ones ramp up over PROXY_SLOW_START
+	lb.SetDrainTimeout(getEnvDuration("PROXY_DRAIN_TIMEOUT", proxy.DefaultDrainTimeout))
+	lb.SetSlowStart(getEnvDuration("PROXY_SLOW_START", 30*time.Second))
+
+	// Split traffic between baseline and canary backends (PROXY_CANARY_*)
+	if canaryConfig, ok := config.NewCanaryConfig(); ok {
+		lb.SetCanary(canaryConfig)
+	}
+
 	// Start health checks
 	lb.StartHealthChecks()
 	defer lb.StopHealthChecks()
 
+	// Apply the backends file's weights and metadata, and follow changes to it
+	if backendsFile != "" {
+		watcher := proxy.NewBackendWatcher(lb, backendsFile)
+		if err := watcher.Start(); err != nil {
+			log.Fatalf("Failed to watch backends file: %v", err)
+		}
+		defer watcher.Stop()
+	}
+
 	// Create Echo instance
 	e := echo.New()
 
@@ -59,6 +119,26 @@ func main() {
 		return c.JSON(200, stats)
 	})
 
+	// Admin routes — restricted to operator certificates listed in ADMIN_CERT_CNS.
+	// Mounted under /proxy so the API's own /admin routes are still forwarded.
+	admin := e.Group("/proxy/admin")
+	admin.Use(customMiddleware.AdminAuthorization(cfg.AdminCommonNames(), nil))
+
+	backendHandler := handler.NewBackendHandler(lb)
+	backends := admin.Group("/backends")
+	backends.GET("", backendHandler.ListBackends)
+	backends.POST("", backendHandler.AddBackend)
+	backends.POST("/drain", backendHandler.DrainBackend)
+	backends.DELETE("", backendHandler.RemoveBackend)
+
+	canaryHandler := handler.NewCanaryHandler(lb)
+	canary := admin.Group("/canary")
+	canary.GET("", canaryHandler.GetCanary)
+	canary.POST("/rollback", canaryHandler.RollbackCanary)
+	canary.POST("/resume", canaryHandler.ResumeCanary)
+
+	admin.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
+
 	// Proxy all other requests to backend servers
 	e.Any("/*", lb.ProxyHandler())
 
@@ -68,10 +148,23 @@ func main() {
 		port = 8080 // Default for proxy
 	}
 
+	// Terminate mTLS with the same CA as the API, so client certificates
+	// can be verified here and forwarded to backends
+	tlsConfig, err := cfg.ServerTLSConfig()
+	if err != nil {
+		log.Fatalf("Failed to load TLS config: %v", err)
+	}
+
+	server := &http.Server{
+		Addr:      fmt.Sprintf(":%d", port),
+		Handler:   e,
+		TLSConfig: tlsConfig,
+	}
+
 	// Start server
 	go func() {
-		log.Printf("Starting load balancer on port %d", port)
-		if err := e.Start(fmt.Sprintf(":%d", port)); err != nil {
+		log.Printf("Starting mTLS load balancer on port %d", port)
+		if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
 			log.Printf("Server error: %v", err)
 		}
 	}()
@@ -85,25 +178,59 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := e.Shutdown(ctx); err != nil {
+	if err := server.Shutdown(ctx); err != nil {
 		log.Printf("Server shutdown error: %v", err)
 	}
 
 	log.Println("Proxy server shutdown complete")
 }
 
-func getBackendServers() []string {
+func getBackendServers(backendsFile string) ([]string, error) {
+	if backendsFile != "" {
+		backends, err := proxy.LoadBackendsFile(backendsFile)
+		if err != nil {
+			return nil, err
+		}
+
+		servers := make([]string, len(backends))
+		for i, backend := range backends {
+			servers[i] = backend.URL
+		}
+		return servers, nil
+	}
+
 	// Try to get servers from environment variable
-	if servers := os.Getenv("BACKEND_SERVERS"); servers != "" {
+	if value := os.Getenv("BACKEND_SERVERS"); value != "" {
 		// Parse comma-separated list of servers
-		// This is a simple implementation - in production you might want more sophisticated parsing
-		return []string{servers}
+		var servers []string
+		for _, server := range strings.Split(value, ",") {
+			if server = strings.TrimSpace(server); server != "" {
+				servers = append(servers, server)
+			}
+		}
+		return servers, nil
 	}
 
 	// Default backend servers
 	return []string{
-		"http://localhost:8443", // Default API server
-		"http://localhost:8444", // Additional instance for load balancing
-		"http://localhost:8445", // Additional instance for load balancing
+		"https://localhost:8443", // Default API server
+		"https://localhost:8444", // Additional instance for load balancing
+		"https://localhost:8445", // Additional instance for load balancing
+	}, nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
 	}
-}
\ No newline at end of file
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
@@ -3,13 +3,18 @@ package main
 import (
 	"context"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
 	"github.com/yordanos-habtamu/b2b-payments/internal/config"
+	"github.com/yordanos-habtamu/b2b-payments/internal/event"
+	"github.com/yordanos-habtamu/b2b-payments/internal/metrics"
+	"github.com/yordanos-habtamu/b2b-payments/internal/repository"
+	"github.com/yordanos-habtamu/b2b-payments/internal/scheduler"
 	"github.com/yordanos-habtamu/b2b-payments/internal/service"
 	"github.com/yordanos-habtamu/b2b-payments/internal/worker"
 )
@@ -35,30 +40,69 @@ func main() {
 		log.Fatalf("Failed to connect to Redis: %v", err)
 	}
 
+	// Payments, saga progress and payment events live in Postgres, so a saga
+	// interrupted by a crash resumes from what was last committed
+	db, err := config.NewDatabasePool(config.NewDatabaseConfig())
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	// Every database and connector call is admitted by the dependency's limiter
+	// and gets its own deadline, bounded by the job's. Idempotent reads are
+	// retried within the dependency's budget (<PREFIX>_RETRY_BUDGET_PERCENT).
+	metricsCollector := metrics.NewMetricsCollector()
+	dbConfig := config.NewDependencyConfig("DB")
+	dbLimiter := config.NewLimiter("postgres", config.NewLimiterConfig("DB"))
+	dbDeadline, dbHedger := config.NewDeadline("postgres", dbConfig), config.NewHedger("postgres", dbConfig)
+	dbBudget := config.NewRetryBudget("postgres", dbConfig, metricsCollector)
+	sagaStore := repository.NewSagaRepository(db, dbLimiter, dbDeadline, dbHedger, dbBudget)
+
 	// Initialize services
-	paymentService := service.NewPaymentService()
+	eventPublisher := event.NewEventPublisher(rdb, "")
+	paymentService := service.NewPaymentService(repository.NewPaymentRepository(db, dbLimiter, dbDeadline, dbHedger, dbBudget), eventPublisher)
+
+	processorConfig := config.NewDependencyConfig("PROCESSOR")
+	processor := service.NewResilientProcessor(service.NewSimulatedProcessor(),
+		config.NewLimiter("payment_processor", config.NewLimiterConfig("PROCESSOR")),
+		config.NewDeadline("payment_processor", processorConfig), config.NewHedger("payment_processor", processorConfig),
+		config.NewRetryBudget("payment_processor", processorConfig, metricsCollector))
+
+	paymentSaga, err := worker.NewPaymentSaga(paymentService, processor, sagaStore)
+	if err != nil {
+		log.Fatalf("Failed to build payment saga: %v", err)
+	}
+
+	retryPolicies, err := service.LoadRetryPolicies(getEnv("PAYMENT_RETRY_CONFIG", "config/payment_retry.yaml"))
+	if err != nil {
+		log.Fatalf("Failed to load payment retry policies: %v", err)
+	}
 
 	// Initialize worker
-	paymentWorker := worker.NewPaymentWorker(rdb, paymentService)
+	workerConfig := config.NewWorkerConfig()
+	paymentWorker := worker.NewPaymentWorker(rdb, paymentService, paymentSaga, retryPolicies, eventPublisher, workerConfig)
 
 	// Setup graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Start delayed job processor
+	// Periodic tasks run on the scheduler leader only, so each fires once across replicas
+	taskScheduler := scheduler.NewScheduler(rdb, "", workerConfig.ID)
+	registerPeriodicTasks(taskScheduler, paymentWorker)
+
+	schedulerConfig, err := scheduler.LoadConfig(getEnv("SCHEDULER_CONFIG", "config/scheduler.yaml"))
+	if err != nil {
+		log.Fatalf("Failed to load scheduler config: %v", err)
+	}
+	if err := taskScheduler.Configure(schedulerConfig); err != nil {
+		log.Fatalf("Failed to configure scheduler: %v", err)
+	}
+
+	schedulerDone := make(chan struct{})
 	go func() {
-		ticker := time.NewTicker(30 * time.Second) // Check every 30 seconds
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				if err := paymentWorker.ProcessDelayedJobs(ctx); err != nil {
-					log.Printf("Error processing delayed jobs: %v", err)
-				}
-			}
+		defer close(schedulerDone)
+		if err := taskScheduler.Start(ctx); err != nil && err != context.Canceled {
+			log.Printf("Scheduler stopped with error: %v", err)
 		}
 	}()
 
@@ -69,23 +113,13 @@ func main() {
 		}
 	}()
 
-	// Start stats reporter
+	// Expose Prometheus metrics (queue depths, dead-letter depth, job outcomes)
+	metricsAddr := ":" + getEnv("METRICS_PORT", "9090")
+	metricsServer := &http.Server{Addr: metricsAddr, Handler: promhttp.Handler()}
 	go func() {
-		ticker := time.NewTicker(60 * time.Second) // Report every minute
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				stats, err := paymentWorker.GetQueueStats(ctx)
-				if err != nil {
-					log.Printf("Error getting queue stats: %v", err)
-				} else {
-					log.Printf("Queue stats: %+v", stats)
-				}
-			}
+		log.Printf("Serving worker metrics on %s/metrics", metricsAddr)
+		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Metrics server error: %v", err)
 		}
 	}()
 
@@ -97,9 +131,53 @@ func main() {
 	<-stop
 
 	log.Println("Shutting down worker...")
+
+	// Stop taking jobs and let in-flight ones finish; stragglers are returned to the queue
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), workerConfig.DrainTimeout)
+	drainErr := paymentWorker.Shutdown(drainCtx)
+	cancelDrain()
+
 	cancel()
+	<-schedulerDone
+	metricsServer.Close()
 
-	// Give some time for graceful shutdown
-	time.Sleep(5 * time.Second)
+	if drainErr != nil {
+		log.Printf("Worker shutdown incomplete: %v", drainErr)
+		os.Exit(1)
+	}
 	log.Println("Worker shutdown complete")
-}
\ No newline at end of file
+}
+
+// registerPeriodicTasks registers the worker's periodic tasks with their default
+// schedules; config/scheduler.yaml can override them
+func registerPeriodicTasks(s *scheduler.Scheduler, paymentWorker *worker.PaymentWorker) {
+	tasks := []struct {
+		name     string
+		schedule string
+		run      scheduler.TaskFunc
+	}{
+		{"promote_delayed_jobs", "@every 5s", paymentWorker.ProcessDelayedJobs},
+		{"reap_expired_leases", "@every 15s", paymentWorker.ReapExpiredLeases},
+		{"queue_stats", "@every 1m", func(ctx context.Context) error {
+			stats, err := paymentWorker.GetQueueStats(ctx)
+			if err != nil {
+				return err
+			}
+			log.Printf("Queue stats: %+v", stats)
+			return nil
+		}},
+	}
+
+	for _, task := range tasks {
+		if err := s.Register(task.name, task.schedule, task.run); err != nil {
+			log.Fatalf("Failed to register periodic task: %v", err)
+		}
+	}
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -2,6 +2,9 @@ package config
 
 import (
 	"fmt"
+	"strings"
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -13,6 +16,11 @@ type Config struct {
 	RequireClientCert bool `mapstructure:"REQUIRE_CLIENT_CERT"`
 	RedisURL string `mapstructure:"REDIS_URL"`
 	IdempotencyTTL int `mapstructure:"IDEMPOTENCY_TTL_HOURS"`
+	AdminCertCNs string `mapstructure:"ADMIN_CERT_CNS"`
+	RequestTimeout time.Duration `mapstructure:"REQUEST_TIMEOUT"`
+	TrustedProxyCNs string `mapstructure:"TRUSTED_PROXY_CNS"`
+	ProxyClientCert string `mapstructure:"PROXY_CLIENT_CERT"`
+	ProxyClientKey  string `mapstructure:"PROXY_CLIENT_KEY"`
 }
 
 func Load() (*Config, error) {
@@ -29,6 +37,11 @@ func Load() (*Config, error) {
 	_ = viper.ReadInConfig() 
 	viper.SetDefault("REDIS_URL", "redis://localhost:6379/0")
 	viper.SetDefault("IDEMPOTENCY_TTL_HOURS", 24)// ignore error if no file
+	viper.SetDefault("ADMIN_CERT_CNS", "")
+	viper.SetDefault("REQUEST_TIMEOUT", "30s")
+	viper.SetDefault("TRUSTED_PROXY_CNS", "")
+	viper.SetDefault("PROXY_CLIENT_CERT", "")
+	viper.SetDefault("PROXY_CLIENT_KEY", "")
 
 	cfg := &Config{}
 	if err := viper.Unmarshal(cfg); err != nil {
@@ -44,4 +57,25 @@ func Load() (*Config, error) {
 	}
 
 	return cfg, nil
-}
\ No newline at end of file
+}
+
+// AdminCommonNames returns the client certificate CNs allowed to use admin endpoints
+func (c *Config) AdminCommonNames() []string {
+	return splitNames(c.AdminCertCNs)
+}
+
+// TrustedProxyCommonNames returns the certificate CNs of proxies allowed to
+// forward client certificates
+func (c *Config) TrustedProxyCommonNames() []string {
+	return splitNames(c.TrustedProxyCNs)
+}
+
+func splitNames(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
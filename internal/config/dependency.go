@@ -0,0 +1,75 @@
+package config
+
+import (
+	"time"
+
+	"github.com/yordanos-habtamu/b2b-payments/internal/resilience"
+)
+
+// DependencyConfig configures the deadline, hedging and retries applied to
+// calls to a dependency. Settings are read from environment variables sharing a prefix,
+// e.g. DB_CALL_TIMEOUT and DB_HEDGE_PERCENTILE.
+type DependencyConfig struct {
+	CallTimeout     time.Duration // longest a single call may take
+	CallHeadroom    time.Duration // kept back from the caller's deadline
+	MinCallTimeout  time.Duration // calls with less time left fail fast
+	HedgePercentile int           // latency percentile after which idempotent reads are hedged; 0 disables hedging
+	HedgeDelay      time.Duration // hedge delay used until enough latencies are observed
+	RetryBudget     int           // retries of idempotent reads as a percentage of calls; 0 disables retries
+}
+
+func NewDependencyConfig(prefix string) *DependencyConfig {
+	return &DependencyConfig{
+		CallTimeout:     getEnvDuration(prefix+"_CALL_TIMEOUT", 5*time.Second),
+		CallHeadroom:    getEnvDuration(prefix+"_CALL_HEADROOM", 0),
+		MinCallTimeout:  getEnvDuration(prefix+"_MIN_CALL_TIMEOUT", 10*time.Millisecond),
+		HedgePercentile: getEnvInt(prefix+"_HEDGE_PERCENTILE", 0),
+		HedgeDelay:      getEnvDuration(prefix+"_HEDGE_DELAY", 100*time.Millisecond),
+		RetryBudget:     getEnvInt(prefix+"_RETRY_BUDGET_PERCENT", 10),
+	}
+}
+
+// NewDeadline builds the per-call deadline described by cfg
+func NewDeadline(name string, cfg *DependencyConfig) *resilience.Deadline {
+	return resilience.NewDeadline(resilience.DeadlineConfig{
+		Name:       name,
+		Timeout:    cfg.CallTimeout,
+		Headroom:   cfg.CallHeadroom,
+		MinTimeout: cfg.MinCallTimeout,
+	})
+}
+
+// NewHedger builds the hedger described by cfg. It returns nil when hedging
+// is disabled, which callers treat as never hedging.
+func NewHedger(name string, cfg *DependencyConfig) *resilience.Hedger {
+	if cfg.HedgePercentile <= 0 || cfg.HedgePercentile >= 100 {
+		return nil
+	}
+	return resilience.NewHedger(resilience.HedgeConfig{
+		Name:         name,
+		Percentile:   float64(cfg.HedgePercentile) / 100,
+		InitialDelay: cfg.HedgeDelay,
+	})
+}
+
+// NewRetryBudget builds the retry budget described by cfg, reporting retries
+// and exhaustion to recorder (which may be nil). It returns nil when retries
+// are disabled, which callers treat as never retrying.
+func NewRetryBudget(name string, cfg *DependencyConfig, recorder resilience.RetryMetricsRecorder) *resilience.RetryBudget {
+	return newRetryBudget(name, cfg.RetryBudget, recorder)
+}
+
+func newRetryBudget(name string, percent int, recorder resilience.RetryMetricsRecorder) *resilience.RetryBudget {
+	if percent <= 0 {
+		return nil
+	}
+
+	budget := resilience.NewRetryBudget(resilience.RetryBudgetConfig{
+		Name:  name,
+		Ratio: float64(percent) / 100,
+	})
+	if recorder != nil {
+		budget.SetMetrics(recorder)
+	}
+	return budget
+}
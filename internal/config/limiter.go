@@ -0,0 +1,61 @@
+package config
+
+import (
+	"time"
+
+	"github.com/yordanos-habtamu/b2b-payments/internal/resilience"
+)
+
+// Limiter modes
+const (
+	LimiterModeNone     = "none"
+	LimiterModeBulkhead = "bulkhead"
+	LimiterModeAdaptive = "adaptive"
+)
+
+// LimiterConfig configures the concurrency limiter in front of a dependency.
+// Settings are read from environment variables sharing a prefix, e.g.
+// PROXY_LIMITER_MODE and PROXY_MAX_CONCURRENT.
+type LimiterConfig struct {
+	Mode             string        // none, bulkhead or adaptive
+	MaxConcurrent    int           // bulkhead size, or the adaptive limit's ceiling
+	MinConcurrent    int           // floor of the adaptive limit
+	MaxQueue         int           // bulkhead callers allowed to wait for a slot
+	QueueTimeout     time.Duration // how long a queued bulkhead caller waits
+	LatencyThreshold time.Duration // adaptive congestion latency; 0 derives it from observed latency
+}
+
+func NewLimiterConfig(prefix string) *LimiterConfig {
+	return &LimiterConfig{
+		Mode:             getEnv(prefix+"_LIMITER_MODE", LimiterModeBulkhead),
+		MaxConcurrent:    getEnvInt(prefix+"_MAX_CONCURRENT", 100),
+		MinConcurrent:    getEnvInt(prefix+"_MIN_CONCURRENT", 10),
+		MaxQueue:         getEnvInt(prefix+"_MAX_QUEUE", 50),
+		QueueTimeout:     getEnvDuration(prefix+"_QUEUE_TIMEOUT", 1*time.Second),
+		LatencyThreshold: getEnvDuration(prefix+"_LATENCY_THRESHOLD", 0),
+	}
+}
+
+// NewLimiter builds the limiter described by cfg. It returns nil in "none"
+// mode, which callers treat as unlimited.
+func NewLimiter(name string, cfg *LimiterConfig) resilience.Limiter {
+	switch cfg.Mode {
+	case LimiterModeNone:
+		return nil
+	case LimiterModeAdaptive:
+		return resilience.NewAdaptiveLimiter(resilience.AdaptiveLimiterConfig{
+			Name:             name,
+			InitialLimit:     cfg.MaxConcurrent / 2,
+			MinLimit:         cfg.MinConcurrent,
+			MaxLimit:         cfg.MaxConcurrent,
+			LatencyThreshold: cfg.LatencyThreshold,
+		})
+	default:
+		return resilience.NewBulkhead(resilience.BulkheadConfig{
+			Name:          name,
+			MaxConcurrent: cfg.MaxConcurrent,
+			MaxQueue:      cfg.MaxQueue,
+			QueueTimeout:  cfg.QueueTimeout,
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yordanos-habtamu/b2b-payments/internal/proxy"
+	"github.com/yordanos-habtamu/b2b-payments/internal/resilience"
+)
+
+// NewHealthCheckConfig reads the proxy's active health check settings from
+// PROXY_HEALTH_* environment variables. PROXY_HEALTH_EXPECTED_STATUS is a
+// comma-separated list of status codes.
+func NewHealthCheckConfig() proxy.HealthCheckConfig {
+	defaults := proxy.DefaultHealthCheckConfig()
+	return proxy.HealthCheckConfig{
+		Path:               getEnv("PROXY_HEALTH_PATH", defaults.Path),
+		Interval:           getEnvDuration("PROXY_HEALTH_INTERVAL", defaults.Interval),
+		Timeout:            getEnvDuration("PROXY_HEALTH_TIMEOUT", defaults.Timeout),
+		HealthyThreshold:   getEnvInt("PROXY_HEALTHY_THRESHOLD", defaults.HealthyThreshold),
+		UnhealthyThreshold: getEnvInt("PROXY_UNHEALTHY_THRESHOLD", defaults.UnhealthyThreshold),
+		ExpectedStatuses:   parseStatuses(getEnv("PROXY_HEALTH_EXPECTED_STATUS", ""), defaults.ExpectedStatuses),
+		ExpectedBody:       getEnv("PROXY_HEALTH_EXPECTED_BODY", ""),
+	}
+}
+
+// NewOutlierDetectionConfig reads the proxy's passive health check settings
+// from PROXY_OUTLIER_* environment variables. A negative
+// PROXY_OUTLIER_CONSECUTIVE_FAILURES disables outlier detection.
+func NewOutlierDetectionConfig() proxy.OutlierDetectionConfig {
+	defaults := proxy.DefaultOutlierDetectionConfig()
+	return proxy.OutlierDetectionConfig{
+		ConsecutiveFailures: getEnvInt("PROXY_OUTLIER_CONSECUTIVE_FAILURES", defaults.ConsecutiveFailures),
+		BaseEjectionTime:    getEnvDuration("PROXY_OUTLIER_BASE_EJECTION_TIME", defaults.BaseEjectionTime),
+		MaxEjectionTime:     getEnvDuration("PROXY_OUTLIER_MAX_EJECTION_TIME", defaults.MaxEjectionTime),
+		RecoveryWindow:      getEnvDuration("PROXY_OUTLIER_RECOVERY_WINDOW", defaults.RecoveryWindow),
+		MaxEjectedPercent:   getEnvInt("PROXY_OUTLIER_MAX_EJECTED_PERCENT", defaults.MaxEjectedPercent),
+	}
+}
+
+// NewProxyRetryConfig reads the proxy's retry settings from PROXY_RETRY_*
+// environment variables. PROXY_RETRY_BUDGET_PERCENT caps retries as a
+// percentage of requests; 0 removes the cap. The budget reports retries and
+// exhaustion to recorder, which may be nil.
+func NewProxyRetryConfig(recorder resilience.RetryMetricsRecorder) proxy.RetryConfig {
+	defaults := proxy.DefaultRetryConfig()
+	return proxy.RetryConfig{
+		MaxAttempts: getEnvInt("PROXY_RETRY_MAX_ATTEMPTS", defaults.MaxAttempts),
+		MaxBodySize: int64(getEnvInt("PROXY_RETRY_MAX_BODY_SIZE", int(defaults.MaxBodySize))),
+		Budget:      newRetryBudget("proxy_upstream", getEnvInt("PROXY_RETRY_BUDGET_PERCENT", 10), recorder),
+	}
+}
+
+// NewCanaryConfig reads the proxy's canary routing settings from
+// PROXY_CANARY_* environment variables. Canary routing is enabled by
+// setting PROXY_CANARY_POOL to the "pool" metadata value of canary backends.
+// PROXY_CANARY_HEADER is a header name, optionally followed by =value.
+func NewCanaryConfig() (proxy.CanaryConfig, bool) {
+	pool := getEnv("PROXY_CANARY_POOL", "")
+	if pool == "" {
+		return proxy.CanaryConfig{}, false
+	}
+
+	header, headerValue, _ := strings.Cut(getEnv("PROXY_CANARY_HEADER", ""), "=")
+	maxErrorRateIncrease := getEnvFloat("PROXY_CANARY_MAX_ERROR_INCREASE_PERCENT", 5) / 100
+	return proxy.CanaryConfig{
+		Pool:                 pool,
+		Percent:              getEnvFloat("PROXY_CANARY_PERCENT", 0),
+		Tenants:              splitNames(getEnv("PROXY_CANARY_TENANTS", "")),
+		Header:               strings.TrimSpace(header),
+		HeaderValue:          strings.TrimSpace(headerValue),
+		MaxErrorRateIncrease: &maxErrorRateIncrease,
+		MinRequests:          getEnvInt("PROXY_CANARY_MIN_REQUESTS", 100),
+		Window:               getEnvDuration("PROXY_CANARY_WINDOW", 5*time.Minute),
+	}, true
+}
+
+func parseStatuses(value string, defaultValue []int) []int {
+	var statuses []int
+	for _, field := range strings.Split(value, ",") {
+		if status, err := strconv.Atoi(strings.TrimSpace(field)); err == nil {
+			statuses = append(statuses, status)
+		}
+	}
+	if len(statuses) == 0 {
+		return defaultValue
+	}
+	return statuses
+}
@@ -0,0 +1,25 @@
+package config
+
+import "testing"
+
+func TestNewCanaryConfig(t *testing.T) {
+	t.Setenv("PROXY_CANARY_POOL", "canary")
+	t.Setenv("PROXY_CANARY_PERCENT", "2.5")
+	t.Setenv("PROXY_CANARY_MAX_ERROR_INCREASE_PERCENT", "0")
+
+	cfg, ok := NewCanaryConfig()
+	if !ok {
+		t.Fatal("expected canary routing to be enabled")
+	}
+	if cfg.Percent != 2.5 {
+		t.Errorf("Percent = %v, want 2.5", cfg.Percent)
+	}
+	if cfg.MaxErrorRateIncrease == nil || *cfg.MaxErrorRateIncrease != 0 {
+		t.Errorf("MaxErrorRateIncrease = %v, want an explicit 0", cfg.MaxErrorRateIncrease)
+	}
+
+	t.Setenv("PROXY_CANARY_MAX_ERROR_INCREASE_PERCENT", "")
+	if cfg, _ := NewCanaryConfig(); cfg.MaxErrorRateIncrease == nil || *cfg.MaxErrorRateIncrease != 0.05 {
+		t.Errorf("MaxErrorRateIncrease = %v, want the 5%% default", cfg.MaxErrorRateIncrease)
+	}
+}
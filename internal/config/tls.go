@@ -0,0 +1,70 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// LoadCAPool reads the PEM certificates in caFile into a pool
+func LoadCAPool(caFile string) (*x509.CertPool, error) {
+	caCertPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caCertPEM) {
+		return nil, fmt.Errorf("failed to append CA cert from %s", caFile)
+	}
+	return caPool, nil
+}
+
+// ServerTLSConfig returns the mTLS configuration shared by the API and the
+// proxy: the server certificate, and client certificates required and
+// verified against CA_FILE
+func (c *Config) ServerTLSConfig() (*tls.Config, error) {
+	serverCert, err := tls.LoadX509KeyPair(c.ServerCert, c.ServerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server cert/key: %w", err)
+	}
+
+	caPool, err := LoadCAPool(c.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    caPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert, // Zero Trust core
+		MinVersion:   tls.VersionTLS13,
+	}, nil
+}
+
+// ProxyClientTLSConfig returns the configuration the proxy uses to
+// re-originate mTLS to backends: it presents PROXY_CLIENT_CERT (the server
+// certificate if unset) and verifies backends against CA_FILE
+func (c *Config) ProxyClientTLSConfig() (*tls.Config, error) {
+	certFile, keyFile := c.ProxyClientCert, c.ProxyClientKey
+	if certFile == "" {
+		certFile, keyFile = c.ServerCert, c.ServerKey
+	}
+
+	clientCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load proxy client cert/key: %w", err)
+	}
+
+	caPool, err := LoadCAPool(c.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		RootCAs:      caPool,
+		MinVersion:   tls.VersionTLS13,
+	}, nil
+}
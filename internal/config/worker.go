@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type WorkerConfig struct {
+	ID            string         `mapstructure:"WORKER_ID"`
+	QueueName     string         `mapstructure:"WORKER_QUEUE_NAME"`
+	Concurrency   int            `mapstructure:"WORKER_CONCURRENCY"`
+	JobTypeLimits map[string]int `mapstructure:"WORKER_JOB_TYPE_LIMITS"`
+	MaxRetries    int            `mapstructure:"WORKER_MAX_RETRIES"`
+	RetryDelay    time.Duration  `mapstructure:"WORKER_RETRY_DELAY"`
+	BatchSize     int            `mapstructure:"WORKER_BATCH_SIZE"`
+	PollInterval  time.Duration  `mapstructure:"WORKER_POLL_INTERVAL"`
+	LeaseDuration time.Duration  `mapstructure:"WORKER_LEASE_DURATION"`
+	JobRetention  time.Duration  `mapstructure:"WORKER_JOB_RETENTION"`
+	DrainTimeout  time.Duration  `mapstructure:"WORKER_DRAIN_TIMEOUT"`
+}
+
+func NewWorkerConfig() *WorkerConfig {
+	return &WorkerConfig{
+		ID:            getEnv("WORKER_ID", defaultWorkerID()),
+		QueueName:     getEnv("WORKER_QUEUE_NAME", "payment_jobs"),
+		Concurrency:   getEnvInt("WORKER_CONCURRENCY", 4),
+		JobTypeLimits: parseJobTypeLimits(getEnv("WORKER_JOB_TYPE_LIMITS", "")),
+		MaxRetries:    getEnvInt("WORKER_MAX_RETRIES", 3),
+		RetryDelay:    getEnvDuration("WORKER_RETRY_DELAY", 5*time.Second),
+		BatchSize:     getEnvInt("WORKER_BATCH_SIZE", 10),
+		PollInterval:  getEnvDuration("WORKER_POLL_INTERVAL", 1*time.Second),
+		LeaseDuration: getEnvDuration("WORKER_LEASE_DURATION", 30*time.Second),
+		JobRetention:  getEnvDuration("WORKER_JOB_RETENTION", 7*24*time.Hour),
+		DrainTimeout:  getEnvDuration("WORKER_DRAIN_TIMEOUT", 30*time.Second),
+	}
+}
+
+// defaultWorkerID identifies this process's processing list. Pinning WORKER_ID
+// lets a restarted worker immediately recover its own in-flight jobs.
+func defaultWorkerID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "worker"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// parseJobTypeLimits parses "process_payment=8,payment_notification=2" into
+// per-job-type concurrency caps. Malformed entries are ignored.
+func parseJobTypeLimits(value string) map[string]int {
+	limits := make(map[string]int)
+	for _, entry := range strings.Split(value, ",") {
+		jobType, limit, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(limit))
+		if err != nil || n <= 0 {
+			continue
+		}
+		limits[strings.TrimSpace(jobType)] = n
+	}
+	return limits
+}
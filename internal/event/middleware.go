@@ -0,0 +1,263 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrSkipEvent is returned by a handler that deliberately did not process an
+	// event. It short-circuits chains but is not treated as a failure.
+	ErrSkipEvent = errors.New("event skipped")
+
+	ErrHandlerTimeout = errors.New("event handler timed out")
+	ErrHandlerPanic   = errors.New("event handler panicked")
+)
+
+// Predicate decides whether an event should be handled
+type Predicate func(event *Event) bool
+
+// Middleware wraps an EventHandler with additional behaviour
+type Middleware func(next EventHandler) EventHandler
+
+// HandlerMetricsRecorder is the subset of metrics.MetricsCollector used by WithMetrics
+type HandlerMetricsRecorder interface {
+	RecordEventHandled(eventType, handler, outcome string, duration float64)
+}
+
+// IsSkipped reports whether err signals a skipped event rather than a failure
+func IsSkipped(err error) bool {
+	return errors.Is(err, ErrSkipEvent)
+}
+
+// Chain wraps handler with middlewares. The first middleware is the outermost,
+// so Chain(h, WithRecover(), WithTimeout(d)) recovers panics raised under the timeout.
+func Chain(handler EventHandler, middlewares ...Middleware) EventHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// Predicates
+
+// TenantPredicate matches events belonging to any of the given tenants
+func TenantPredicate(tenantIDs ...string) Predicate {
+	return func(event *Event) bool {
+		for _, tenantID := range tenantIDs {
+			if event.TenantID == tenantID {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// EventTypePredicate matches events of any of the given types
+func EventTypePredicate(eventTypes ...string) Predicate {
+	return func(event *Event) bool {
+		for _, eventType := range eventTypes {
+			if event.Type == eventType {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// And matches when every predicate matches
+func And(predicates ...Predicate) Predicate {
+	return func(event *Event) bool {
+		for _, predicate := range predicates {
+			if !predicate(event) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or matches when at least one predicate matches
+func Or(predicates ...Predicate) Predicate {
+	return func(event *Event) bool {
+		for _, predicate := range predicates {
+			if predicate(event) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not inverts a predicate
+func Not(predicate Predicate) Predicate {
+	return func(event *Event) bool {
+		return !predicate(event)
+	}
+}
+
+// Filters
+
+// Filter only calls the next handler for events matching predicate; other
+// events return ErrSkipEvent.
+func Filter(predicate Predicate) Middleware {
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, event *Event) error {
+			if !predicate(event) {
+				return ErrSkipEvent
+			}
+			return next(ctx, event)
+		}
+	}
+}
+
+// CreateFilterHandler turns a predicate into a handler for use with
+// CreateChainedHandler: it returns nil for matching events and ErrSkipEvent otherwise.
+func CreateFilterHandler(predicate Predicate) EventHandler {
+	return func(ctx context.Context, event *Event) error {
+		if !predicate(event) {
+			return ErrSkipEvent
+		}
+		return nil
+	}
+}
+
+// CreateTenantFilter skips events for other tenants
+func CreateTenantFilter(tenantID string) EventHandler {
+	return CreateFilterHandler(TenantPredicate(tenantID))
+}
+
+// CreateEventTypeFilter skips events whose type is not in the allowed list
+func CreateEventTypeFilter(eventTypes ...string) EventHandler {
+	return CreateFilterHandler(EventTypePredicate(eventTypes...))
+}
+
+// CreateChainedHandler runs handlers in order. It stops at the first error;
+// a handler returning ErrSkipEvent stops the chain and the skip is propagated.
+func CreateChainedHandler(handlers ...EventHandler) EventHandler {
+	return func(ctx context.Context, event *Event) error {
+		for _, handler := range handlers {
+			if err := handler(ctx, event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Behavioural middleware
+
+// WithRetry retries failed handlers up to maxAttempts times in total, waiting
+// backoff between attempts and doubling it each time. Skips are not retried.
+func WithRetry(maxAttempts int, backoff time.Duration) Middleware {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, event *Event) error {
+			delay := backoff
+			var lastErr error
+
+			for attempt := 1; attempt <= maxAttempts; attempt++ {
+				err := next(ctx, event)
+				if err == nil || IsSkipped(err) {
+					return err
+				}
+				lastErr = err
+
+				if attempt == maxAttempts {
+					break
+				}
+
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(delay):
+				}
+				delay *= 2
+			}
+
+			return fmt.Errorf("handler failed after %d attempts: %w", maxAttempts, lastErr)
+		}
+	}
+}
+
+// WithTimeout bounds handler execution. The handler receives a context with
+// the deadline; if it does not return in time ErrHandlerTimeout is returned
+// without waiting for it. Panics are re-raised on the caller's goroutine so
+// an outer WithRecover still sees them.
+func WithTimeout(timeout time.Duration) Middleware {
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, event *Event) error {
+			timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			type outcome struct {
+				err       error
+				panicked  bool
+				recovered interface{}
+			}
+			done := make(chan outcome, 1)
+
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						done <- outcome{panicked: true, recovered: r}
+					}
+				}()
+				done <- outcome{err: next(timeoutCtx, event)}
+			}()
+
+			select {
+			case result := <-done:
+				if result.panicked {
+					panic(result.recovered)
+				}
+				return result.err
+			case <-timeoutCtx.Done():
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return fmt.Errorf("%w after %s", ErrHandlerTimeout, timeout)
+			}
+		}
+	}
+}
+
+// WithRecover converts a panicking handler into an ErrHandlerPanic error
+func WithRecover() Middleware {
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, event *Event) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("%w: %v", ErrHandlerPanic, r)
+				}
+			}()
+			return next(ctx, event)
+		}
+	}
+}
+
+// WithMetrics records the outcome ("success", "skipped" or "error") and
+// duration of every invocation under the given handler name.
+func WithMetrics(recorder HandlerMetricsRecorder, name string) Middleware {
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, event *Event) error {
+			start := time.Now()
+			err := next(ctx, event)
+
+			outcome := "success"
+			if IsSkipped(err) {
+				outcome = "skipped"
+			} else if err != nil {
+				outcome = "error"
+			}
+
+			recorder.RecordEventHandled(event.Type, name, outcome, time.Since(start).Seconds())
+			return err
+		}
+	}
+}
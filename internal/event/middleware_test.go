@@ -0,0 +1,318 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordedResult struct {
+	eventType, handler, outcome string
+}
+
+type fakeRecorder struct {
+	mu      sync.Mutex
+	results []recordedResult
+}
+
+func (r *fakeRecorder) RecordEventHandled(eventType, handler, outcome string, duration float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, recordedResult{eventType, handler, outcome})
+}
+
+func testEvent(tenantID, eventType string) *Event {
+	return &Event{ID: "evt_test", Type: eventType, TenantID: tenantID}
+}
+
+func countingHandler(calls *int, err error) EventHandler {
+	return func(ctx context.Context, event *Event) error {
+		*calls++
+		return err
+	}
+}
+
+func TestCreateTenantFilter(t *testing.T) {
+	filter := CreateTenantFilter("acme")
+
+	if err := filter(context.Background(), testEvent("acme", "payment.created")); err != nil {
+		t.Fatalf("expected matching tenant to pass, got %v", err)
+	}
+	if err := filter(context.Background(), testEvent("globex", "payment.created")); !IsSkipped(err) {
+		t.Fatalf("expected other tenant to be skipped, got %v", err)
+	}
+}
+
+func TestCreateEventTypeFilter(t *testing.T) {
+	filter := CreateEventTypeFilter("payment.completed", "payment.failed")
+
+	tests := []struct {
+		eventType string
+		skipped   bool
+	}{
+		{"payment.completed", false},
+		{"payment.failed", false},
+		{"payment.created", true},
+	}
+
+	for _, tt := range tests {
+		err := filter(context.Background(), testEvent("acme", tt.eventType))
+		if IsSkipped(err) != tt.skipped {
+			t.Errorf("type %s: expected skipped=%v, got err=%v", tt.eventType, tt.skipped, err)
+		}
+	}
+}
+
+func TestCreateChainedHandlerShortCircuitsOnSkip(t *testing.T) {
+	calls := 0
+	chained := CreateChainedHandler(CreateTenantFilter("acme"), countingHandler(&calls, nil))
+
+	if err := chained(context.Background(), testEvent("globex", "payment.created")); !IsSkipped(err) {
+		t.Fatalf("expected skip, got %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("expected downstream handler not to run, ran %d times", calls)
+	}
+
+	if err := chained(context.Background(), testEvent("acme", "payment.created")); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected downstream handler to run once, ran %d times", calls)
+	}
+}
+
+func TestCreateChainedHandlerStopsOnError(t *testing.T) {
+	boom := errors.New("boom")
+	calls := 0
+	chained := CreateChainedHandler(countingHandler(new(int), boom), countingHandler(&calls, nil))
+
+	if err := chained(context.Background(), testEvent("acme", "payment.created")); !errors.Is(err, boom) {
+		t.Fatalf("expected boom, got %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("expected chain to stop at the error, later handler ran %d times", calls)
+	}
+}
+
+func TestFilterMiddleware(t *testing.T) {
+	calls := 0
+	handler := Chain(countingHandler(&calls, nil), Filter(And(
+		TenantPredicate("acme"),
+		Not(EventTypePredicate("payment.updated")),
+	)))
+
+	tests := []struct {
+		name      string
+		event     *Event
+		skipped   bool
+		wantCalls int
+	}{
+		{"matching event", testEvent("acme", "payment.created"), false, 1},
+		{"excluded type", testEvent("acme", "payment.updated"), true, 1},
+		{"other tenant", testEvent("globex", "payment.created"), true, 1},
+	}
+
+	for _, tt := range tests {
+		err := handler(context.Background(), tt.event)
+		if IsSkipped(err) != tt.skipped {
+			t.Errorf("%s: expected skipped=%v, got %v", tt.name, tt.skipped, err)
+		}
+		if calls != tt.wantCalls {
+			t.Errorf("%s: expected %d calls, got %d", tt.name, tt.wantCalls, calls)
+		}
+	}
+}
+
+func TestOrPredicate(t *testing.T) {
+	predicate := Or(TenantPredicate("acme"), EventTypePredicate("tenant.created"))
+
+	if !predicate(testEvent("acme", "payment.created")) {
+		t.Error("expected tenant match")
+	}
+	if !predicate(testEvent("globex", "tenant.created")) {
+		t.Error("expected type match")
+	}
+	if predicate(testEvent("globex", "payment.created")) {
+		t.Error("expected no match")
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	tag := func(name string) Middleware {
+		return func(next EventHandler) EventHandler {
+			return func(ctx context.Context, event *Event) error {
+				order = append(order, name)
+				return next(ctx, event)
+			}
+		}
+	}
+
+	handler := Chain(func(ctx context.Context, event *Event) error {
+		order = append(order, "handler")
+		return nil
+	}, tag("outer"), tag("inner"))
+
+	if err := handler(context.Background(), testEvent("acme", "payment.created")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(order, ","); got != "outer,inner,handler" {
+		t.Fatalf("unexpected order %s", got)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	t.Run("succeeds after transient failures", func(t *testing.T) {
+		attempts := 0
+		handler := Chain(func(ctx context.Context, event *Event) error {
+			attempts++
+			if attempts < 3 {
+				return errors.New("transient")
+			}
+			return nil
+		}, WithRetry(3, time.Millisecond))
+
+		if err := handler(context.Background(), testEvent("acme", "payment.created")); err != nil {
+			t.Fatalf("expected success, got %v", err)
+		}
+		if attempts != 3 {
+			t.Fatalf("expected 3 attempts, got %d", attempts)
+		}
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		boom := errors.New("boom")
+		attempts := 0
+		handler := Chain(countingHandler(&attempts, boom), WithRetry(2, time.Millisecond))
+
+		if err := handler(context.Background(), testEvent("acme", "payment.created")); !errors.Is(err, boom) {
+			t.Fatalf("expected wrapped boom, got %v", err)
+		}
+		if attempts != 2 {
+			t.Fatalf("expected 2 attempts, got %d", attempts)
+		}
+	})
+
+	t.Run("does not retry skips", func(t *testing.T) {
+		attempts := 0
+		handler := Chain(countingHandler(&attempts, ErrSkipEvent), WithRetry(5, time.Millisecond))
+
+		if err := handler(context.Background(), testEvent("acme", "payment.created")); !IsSkipped(err) {
+			t.Fatalf("expected skip, got %v", err)
+		}
+		if attempts != 1 {
+			t.Fatalf("expected a single attempt, got %d", attempts)
+		}
+	})
+
+	t.Run("stops when context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		handler := Chain(func(ctx context.Context, event *Event) error {
+			cancel()
+			return errors.New("boom")
+		}, WithRetry(5, time.Hour))
+
+		if err := handler(ctx, testEvent("acme", "payment.created")); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	})
+}
+
+func TestWithTimeout(t *testing.T) {
+	t.Run("returns handler result when fast", func(t *testing.T) {
+		boom := errors.New("boom")
+		handler := Chain(func(ctx context.Context, event *Event) error {
+			return boom
+		}, WithTimeout(time.Second))
+
+		if err := handler(context.Background(), testEvent("acme", "payment.created")); !errors.Is(err, boom) {
+			t.Fatalf("expected boom, got %v", err)
+		}
+	})
+
+	t.Run("times out slow handlers", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+
+		handler := Chain(func(ctx context.Context, event *Event) error {
+			<-release
+			return nil
+		}, WithTimeout(10*time.Millisecond))
+
+		if err := handler(context.Background(), testEvent("acme", "payment.created")); !errors.Is(err, ErrHandlerTimeout) {
+			t.Fatalf("expected ErrHandlerTimeout, got %v", err)
+		}
+	})
+
+	t.Run("passes deadline to handler", func(t *testing.T) {
+		handler := Chain(func(ctx context.Context, event *Event) error {
+			if _, ok := ctx.Deadline(); !ok {
+				return errors.New("missing deadline")
+			}
+			return nil
+		}, WithTimeout(time.Second))
+
+		if err := handler(context.Background(), testEvent("acme", "payment.created")); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestWithRecover(t *testing.T) {
+	handler := Chain(func(ctx context.Context, event *Event) error {
+		panic("kaboom")
+	}, WithRecover())
+
+	err := handler(context.Background(), testEvent("acme", "payment.created"))
+	if !errors.Is(err, ErrHandlerPanic) {
+		t.Fatalf("expected ErrHandlerPanic, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "kaboom") {
+		t.Fatalf("expected panic value in error, got %v", err)
+	}
+}
+
+func TestWithRecoverAroundTimeout(t *testing.T) {
+	handler := Chain(func(ctx context.Context, event *Event) error {
+		panic("kaboom")
+	}, WithRecover(), WithTimeout(time.Second))
+
+	if err := handler(context.Background(), testEvent("acme", "payment.created")); !errors.Is(err, ErrHandlerPanic) {
+		t.Fatalf("expected panic from timeout goroutine to be recovered, got %v", err)
+	}
+}
+
+func TestWithMetrics(t *testing.T) {
+	recorder := &fakeRecorder{}
+	boom := errors.New("boom")
+
+	tests := []struct {
+		err     error
+		outcome string
+	}{
+		{nil, "success"},
+		{ErrSkipEvent, "skipped"},
+		{boom, "error"},
+	}
+
+	for _, tt := range tests {
+		handler := Chain(countingHandler(new(int), tt.err), WithMetrics(recorder, "audit"))
+		if err := handler(context.Background(), testEvent("acme", "payment.created")); !errors.Is(err, tt.err) {
+			t.Fatalf("expected %v to pass through, got %v", tt.err, err)
+		}
+	}
+
+	if len(recorder.results) != len(tests) {
+		t.Fatalf("expected %d recordings, got %d", len(tests), len(recorder.results))
+	}
+	for i, tt := range tests {
+		got := recorder.results[i]
+		if got.outcome != tt.outcome || got.handler != "audit" || got.eventType != "payment.created" {
+			t.Errorf("recording %d: unexpected %+v", i, got)
+		}
+	}
+}
@@ -3,16 +3,28 @@ package event
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
+// defaultReplayLimit is how many missed events GetEventsSince replays
+const defaultReplayLimit = 1000
+
+var (
+	// ErrEventGap is returned by GetEventsSince when the last seen event is no
+	// longer in the log, or more events than the replay limit were missed
+	ErrEventGap = errors.New("events missed since last event ID")
+)
+
 type EventPublisher struct {
 	redisClient *redis.Client
 	prefix      string
+	replayLimit int64
 }
 
 type Event struct {
@@ -41,9 +53,15 @@ func NewEventPublisher(redisClient *redis.Client, prefix string) *EventPublisher
 	return &EventPublisher{
 		redisClient: redisClient,
 		prefix:      prefix,
+		replayLimit: defaultReplayLimit,
 	}
 }
 
+// tenantChannel is the channel carrying every event of one tenant
+func (p *EventPublisher) tenantChannel(tenantID string) string {
+	return fmt.Sprintf("%s.tenant_events:%s", p.prefix, tenantID)
+}
+
 func (p *EventPublisher) Publish(ctx context.Context, eventType string, tenantID string, data map[string]interface{}) error {
 	event := &Event{
 		ID:        generateEventID(),
@@ -87,6 +105,13 @@ func (p *EventPublisher) publishEvent(ctx context.Context, event *Event) error {
 		return fmt.Errorf("failed to publish event to channel %s: %w", channel, err)
 	}
 
+	// Tenant-scoped copy for event streams, which only receive their own tenant's events
+	if event.TenantID != "" {
+		if err := p.redisClient.Publish(ctx, p.tenantChannel(event.TenantID), eventJSON).Err(); err != nil {
+			log.Printf("Failed to publish event to tenant channel: %v", err)
+		}
+	}
+
 	// Also store in event log for replay/audit
 	eventLogKey := fmt.Sprintf("%s.event_log:%s", p.prefix, event.TenantID)
 	if err := p.redisClient.LPush(ctx, eventLogKey, eventJSON).Err(); err != nil {
@@ -216,6 +241,47 @@ func (p *EventPublisher) GetEventLog(ctx context.Context, tenantID string, limit
 	return events, nil
 }
 
+// GetEventsSince returns the tenant's logged events that were published after
+// lastEventID, oldest first. It returns ErrEventGap, and no events, when
+// lastEventID is no longer in the log or more than the replay limit of events
+// followed it; the consumer has then missed events and must resynchronise.
+func (p *EventPublisher) GetEventsSince(ctx context.Context, tenantID, lastEventID string) ([]*Event, error) {
+	eventLogKey := fmt.Sprintf("%s.event_log:%s", p.prefix, tenantID)
+
+	// The replay limit's worth of newer events plus lastEventID itself
+	results, err := p.redisClient.LRange(ctx, eventLogKey, 0, p.replayLimit).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event log: %w", err)
+	}
+
+	// The log is newest-first, so collect until we reach the last seen event
+	var newer []*Event
+	found := false
+	for _, result := range results {
+		var event Event
+		if err := json.Unmarshal([]byte(result), &event); err != nil {
+			log.Printf("Failed to unmarshal event from log: %v", err)
+			continue
+		}
+		if event.ID == lastEventID {
+			found = true
+			break
+		}
+		newer = append(newer, &event)
+	}
+	if !found {
+		return nil, ErrEventGap
+	}
+
+	// Reverse into chronological order for replay
+	for i, j := 0, len(newer)-1; i < j; i, j = i+1, j-1 {
+		newer[i], newer[j] = newer[j], newer[i]
+	}
+
+	return newer, nil
+}
+
+// generateEventID returns a time-ordered, collision-free event ID
 func generateEventID() string {
-	return fmt.Sprintf("evt_%d", time.Now().UnixNano())
+	return "evt_" + uuid.Must(uuid.NewV7()).String()
 }
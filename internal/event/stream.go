@@ -0,0 +1,190 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// EventTypeStreamGap is sent, without an ID, in place of replayed events when
+// a resuming consumer missed more events than the log can replay. The
+// consumer should refetch the state it tracks.
+const EventTypeStreamGap = "stream.gap"
+
+// EventStream delivers a single tenant's payment and job events to long-lived
+// consumers such as SSE connections. It replays missed events from the
+// durable event log and then switches to live delivery from the tenant's
+// pub/sub channel.
+type EventStream struct {
+	redisClient *redis.Client
+	publisher   *EventPublisher
+	prefix      string
+	bufferSize  int
+}
+
+// StreamFilter narrows a stream down to specific event types and/or a single payment
+type StreamFilter struct {
+	EventTypes []string
+	PaymentID  string
+}
+
+func NewEventStream(redisClient *redis.Client, prefix string) *EventStream {
+	if prefix == "" {
+		prefix = "b2b_payments"
+	}
+
+	return &EventStream{
+		redisClient: redisClient,
+		publisher:   NewEventPublisher(redisClient, prefix),
+		prefix:      prefix,
+		bufferSize:  64,
+	}
+}
+
+// Open starts streaming events for tenantID. If lastEventID is set, events
+// logged after it are replayed before live events. The returned channel is
+// closed when ctx is cancelled or the underlying subscription ends.
+func (s *EventStream) Open(ctx context.Context, tenantID, lastEventID string, filter *StreamFilter) (<-chan *Event, error) {
+	if tenantID == "" {
+		return nil, fmt.Errorf("tenant ID is required")
+	}
+
+	// Subscribe before reading the log so nothing published in between is lost
+	channel := s.publisher.tenantChannel(tenantID)
+	pubsub := s.redisClient.Subscribe(ctx, channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", channel, err)
+	}
+
+	var replay []*Event
+	if lastEventID != "" {
+		events, err := s.publisher.GetEventsSince(ctx, tenantID, lastEventID)
+		switch {
+		case errors.Is(err, ErrEventGap):
+			log.Printf("Cannot replay events after %s for tenant %s, signalling a gap", lastEventID, tenantID)
+			replay = []*Event{gapEvent(tenantID, lastEventID)}
+		case err != nil:
+			pubsub.Close()
+			return nil, fmt.Errorf("failed to replay events: %w", err)
+		default:
+			replay = events
+		}
+	}
+
+	out := make(chan *Event, s.bufferSize)
+	go s.pump(ctx, pubsub, tenantID, filter, replay, out)
+
+	return out, nil
+}
+
+func (s *EventStream) pump(ctx context.Context, pubsub *redis.PubSub, tenantID string, filter *StreamFilter, replay []*Event, out chan<- *Event) {
+	defer close(out)
+	defer pubsub.Close()
+
+	// Live events may overlap with the replayed tail of the log
+	replayed := make(map[string]struct{}, len(replay))
+	for _, event := range replay {
+		if event.Type != EventTypeStreamGap && !streamed(event, tenantID, filter) {
+			continue
+		}
+		replayed[event.ID] = struct{}{}
+
+		select {
+		case out <- event:
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			var event Event
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				log.Printf("Failed to unmarshal streamed event: %v", err)
+				continue
+			}
+
+			if !streamed(&event, tenantID, filter) {
+				continue
+			}
+			if _, dup := replayed[event.ID]; dup {
+				continue
+			}
+
+			select {
+			case out <- &event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// streamed reports whether event belongs on tenantID's stream: one of the
+// tenant's payment or job events that passes the filter
+func streamed(event *Event, tenantID string, filter *StreamFilter) bool {
+	// Tenant isolation: never deliver another tenant's events, even if one
+	// reached the tenant's channel
+	if event.TenantID != tenantID {
+		return false
+	}
+	if !strings.HasPrefix(event.Type, "payment.") && !strings.HasPrefix(event.Type, "job.") {
+		return false
+	}
+	return filter.Matches(event)
+}
+
+func gapEvent(tenantID, lastEventID string) *Event {
+	return &Event{
+		Type:      EventTypeStreamGap,
+		Source:    "b2b-payments-api",
+		TenantID:  tenantID,
+		Data:      map[string]interface{}{"last_event_id": lastEventID},
+		Timestamp: time.Now().UTC(),
+		Version:   "1.0",
+	}
+}
+
+// Matches reports whether the event passes the filter. A nil filter matches everything.
+func (f *StreamFilter) Matches(event *Event) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.EventTypes) > 0 {
+		matched := false
+		for _, eventType := range f.EventTypes {
+			if strings.EqualFold(event.Type, eventType) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if f.PaymentID != "" {
+		paymentID, _ := event.Data["payment_id"].(string)
+		if paymentID != f.PaymentID {
+			return false
+		}
+	}
+
+	return true
+}
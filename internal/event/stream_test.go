@@ -0,0 +1,186 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestStream(t *testing.T) (*EventStream, *EventPublisher, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return NewEventStream(client, "test"), NewEventPublisher(client, "test"), mr
+}
+
+func publishTestEvent(t *testing.T, p *EventPublisher, id, eventType, tenantID, paymentID string) {
+	t.Helper()
+
+	err := p.PublishWithMetadata(context.Background(), &Event{
+		ID:       id,
+		Type:     eventType,
+		TenantID: tenantID,
+		Data:     map[string]interface{}{"payment_id": paymentID},
+	})
+	if err != nil {
+		t.Fatalf("failed to publish %s: %v", id, err)
+	}
+}
+
+func openTestStream(t *testing.T, s *EventStream, tenantID, lastEventID string, filter *StreamFilter) <-chan *Event {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	events, err := s.Open(ctx, tenantID, lastEventID, filter)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	return events
+}
+
+// receiveEvents reads n events from the stream and then checks nothing else arrives
+func receiveEvents(t *testing.T, events <-chan *Event, n int) []string {
+	t.Helper()
+
+	var got []string
+	for len(got) < n {
+		select {
+		case event := <-events:
+			got = append(got, fmt.Sprintf("%s:%s", event.Type, event.ID))
+		case <-time.After(time.Second):
+			t.Fatalf("received %v, want %d events", got, n)
+		}
+	}
+
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected event %s:%s after %v", event.Type, event.ID, got)
+	case <-time.After(50 * time.Millisecond):
+	}
+	return got
+}
+
+func assertEvents(t *testing.T, got []string, want ...string) {
+	t.Helper()
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestEventStreamSubscribesPerTenant(t *testing.T) {
+	stream, publisher, mr := newTestStream(t)
+	events := openTestStream(t, stream, "tenant-a", "", nil)
+
+	// One plain subscription to the tenant's own channel, no patterns
+	if n := mr.PubSubNumPat(); n != 0 {
+		t.Errorf("pattern subscriptions = %d, want 0", n)
+	}
+	channel := publisher.tenantChannel("tenant-a")
+	if subs := mr.PubSubNumSub(channel); subs[channel] != 1 {
+		t.Errorf("subscribers on %s = %d, want 1", channel, subs[channel])
+	}
+
+	publishTestEvent(t, publisher, "evt_1", "payment.created", "tenant-b", "pay_b")
+	publishTestEvent(t, publisher, "evt_2", "payment.created", "tenant-a", "pay_a")
+	publishTestEvent(t, publisher, "evt_3", "tenant.updated", "tenant-a", "")
+	publishTestEvent(t, publisher, "evt_4", "job.completed", "tenant-a", "pay_a")
+
+	assertEvents(t, receiveEvents(t, events, 2), "payment.created:evt_2", "job.completed:evt_4")
+}
+
+func TestEventStreamResumesFromLog(t *testing.T) {
+	stream, publisher, _ := newTestStream(t)
+
+	publishTestEvent(t, publisher, "evt_1", "payment.created", "tenant-a", "pay_1")
+	publishTestEvent(t, publisher, "evt_2", "payment.processed", "tenant-a", "pay_1")
+	publishTestEvent(t, publisher, "evt_3", "payment.completed", "tenant-a", "pay_1")
+
+	events := openTestStream(t, stream, "tenant-a", "evt_1", nil)
+	publishTestEvent(t, publisher, "evt_4", "payment.created", "tenant-a", "pay_2")
+
+	assertEvents(t, receiveEvents(t, events, 3),
+		"payment.processed:evt_2", "payment.completed:evt_3", "payment.created:evt_4")
+}
+
+func TestEventStreamSignalsGap(t *testing.T) {
+	tests := []struct {
+		name        string
+		lastEventID string
+	}{
+		{"last event trimmed from the log", "evt_0"},
+		{"too far behind to replay", "evt_1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, publisher, _ := newTestStream(t)
+			stream.publisher.replayLimit = 2
+
+			for i := 1; i <= 4; i++ {
+				publishTestEvent(t, publisher, fmt.Sprintf("evt_%d", i), "payment.updated", "tenant-a", "pay_1")
+			}
+
+			// The gap bypasses the filter, which would hide it
+			events := openTestStream(t, stream, "tenant-a", tt.lastEventID, &StreamFilter{PaymentID: "pay_2"})
+			publishTestEvent(t, publisher, "evt_5", "payment.created", "tenant-a", "pay_2")
+
+			got := receiveEvents(t, events, 2)
+			assertEvents(t, got, "stream.gap:", "payment.created:evt_5")
+		})
+	}
+}
+
+func TestGetEventsSince(t *testing.T) {
+	_, publisher, _ := newTestStream(t)
+	publisher.replayLimit = 3
+	ctx := context.Background()
+
+	for i := 1; i <= 5; i++ {
+		publishTestEvent(t, publisher, fmt.Sprintf("evt_%d", i), "payment.updated", "tenant-a", "pay_1")
+	}
+
+	tests := []struct {
+		lastEventID string
+		want        []string
+		wantErr     error
+	}{
+		{"evt_5", nil, nil},
+		{"evt_3", []string{"evt_4", "evt_5"}, nil},
+		{"evt_2", []string{"evt_3", "evt_4", "evt_5"}, nil},
+		{"evt_1", nil, ErrEventGap},
+		{"evt_unknown", nil, ErrEventGap},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lastEventID, func(t *testing.T) {
+			events, err := publisher.GetEventsSince(ctx, "tenant-a", tt.lastEventID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+
+			var got []string
+			for _, event := range events {
+				got = append(got, event.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("events = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Another tenant's log never contains the ID
+	if _, err := publisher.GetEventsSince(ctx, "tenant-b", "evt_3"); !errors.Is(err, ErrEventGap) {
+		t.Errorf("other tenant: error = %v, want %v", err, ErrEventGap)
+	}
+}
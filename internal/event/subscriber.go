@@ -116,6 +116,9 @@ func (s *EventSubscriber) handleMessage(ctx context.Context, msg *redis.Message)
 	// Execute all handlers
 	for i, handler := range handlers {
 		if err := handler(ctx, &event); err != nil {
+			if IsSkipped(err) {
+				continue
+			}
 			log.Printf("Handler %d for event %s failed: %v", i, eventType, err)
 			// Continue with other handlers even if one fails
 		}
@@ -188,36 +191,3 @@ func MetricsEventHandler(ctx context.Context, event *Event) error {
 		event.ID, event.Type, event.TenantID)
 	return nil
 }
-
-// Event filter utilities
-
-func CreateTenantFilter(tenantID string) EventHandler {
-	return func(ctx context.Context, event *Event) error {
-		if event.TenantID != tenantID {
-			return nil // Skip events for other tenants
-		}
-		return nil
-	}
-}
-
-func CreateEventTypeFilter(eventTypes ...string) EventHandler {
-	return func(ctx context.Context, event *Event) error {
-		for _, eventType := range eventTypes {
-			if event.Type == eventType {
-				return nil
-			}
-		}
-		return nil // Skip if event type not in allowed list
-	}
-}
-
-func CreateChainedHandler(handlers ...EventHandler) EventHandler {
-	return func(ctx context.Context, event *Event) error {
-		for _, handler := range handlers {
-			if err := handler(ctx, event); err != nil {
-				return err
-			}
-		}
-		return nil
-	}
-}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yordanos-habtamu/b2b-payments/internal/proxy"
+)
+
+type BackendHandler struct {
+	lb *proxy.LoadBalancer
+}
+
+func NewBackendHandler(lb *proxy.LoadBalancer) *BackendHandler {
+	return &BackendHandler{
+		lb: lb,
+	}
+}
+
+// BackendRequest names a backend to drain or remove
+type BackendRequest struct {
+	URL string `json:"url" query:"url"`
+}
+
+// ListBackends lists the proxy's backends
+// @Summary List proxy backends
+// @Description Lists every backend with its weight, metadata, health, draining state and open connections
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Load balancer stats"
+// @Failure 403 {object} ErrorResponse
+// @Router /proxy/admin/backends [get]
+func (h *BackendHandler) ListBackends(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.lb.GetServerStats())
+}
+
+// AddBackend adds a backend to the proxy
+// @Summary Add a proxy backend
+// @Description Adds a backend, which ramps up to its full share of traffic over the slow-start window. A draining backend with the same URL is replaced, which puts it back into rotation. Backends added here are kept across backends file reloads.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param backend body proxy.BackendConfig true "Backend URL, weight and metadata"
+// @Success 201 {object} map[string]interface{} "Load balancer stats"
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /proxy/admin/backends [post]
+func (h *BackendHandler) AddBackend(c echo.Context) error {
+	var req proxy.BackendConfig
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := h.lb.AddBackend(req); err != nil {
+		if errors.Is(err, proxy.ErrServerExists) {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	c.Logger().Warnf("Backend %s added", req.URL)
+
+	return c.JSON(http.StatusCreated, h.lb.GetServerStats())
+}
+
+// DrainBackend takes a backend out of rotation
+// @Summary Drain a proxy backend
+// @Description Stops sending new requests to a backend. Requests in progress may run for the drain timeout before they are cut off. Add the backend again to undo the drain.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param backend body BackendRequest true "Backend URL"
+// @Success 200 {object} map[string]interface{} "Load balancer stats"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /proxy/admin/backends/drain [post]
+func (h *BackendHandler) DrainBackend(c echo.Context) error {
+	serverURL, err := backendURL(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.lb.DrainServer(serverURL); err != nil {
+		return backendError(err)
+	}
+	c.Logger().Warnf("Backend %s draining", serverURL)
+
+	return c.JSON(http.StatusOK, h.lb.GetServerStats())
+}
+
+// RemoveBackend removes a backend from the proxy
+// @Summary Remove a proxy backend
+// @Description Drains a backend and removes it once its requests have finished or the drain timeout has passed
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param backend body BackendRequest true "Backend URL"
+// @Success 200 {object} map[string]interface{} "Load balancer stats"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /proxy/admin/backends [delete]
+func (h *BackendHandler) RemoveBackend(c echo.Context) error {
+	serverURL, err := backendURL(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.lb.RemoveServer(serverURL); err != nil {
+		return backendError(err)
+	}
+	c.Logger().Warnf("Backend %s removed", serverURL)
+
+	return c.JSON(http.StatusOK, h.lb.GetServerStats())
+}
+
+func backendURL(c echo.Context) (string, error) {
+	var req BackendRequest
+	if err := c.Bind(&req); err != nil || req.URL == "" {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "a backend url is required")
+	}
+	return req.URL, nil
+}
+
+func backendError(err error) error {
+	if errors.Is(err, proxy.ErrServerNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yordanos-habtamu/b2b-payments/internal/proxy"
+)
+
+type CanaryHandler struct {
+	lb *proxy.LoadBalancer
+}
+
+func NewCanaryHandler(lb *proxy.LoadBalancer) *CanaryHandler {
+	return &CanaryHandler{
+		lb: lb,
+	}
+}
+
+// GetCanary returns the canary's routing state and per-pool error rates
+// @Summary Inspect canary routing
+// @Description Returns the canary pool, its traffic share, whether it is rolled back, and each pool's requests and error rate over the window
+// @Tags admin
+// @Produce json
+// @Success 200 {object} proxy.CanaryStats
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /proxy/admin/canary [get]
+func (h *CanaryHandler) GetCanary(c echo.Context) error {
+	stats, err := h.lb.CanaryStats()
+	if err != nil {
+		return canaryError(err)
+	}
+
+	return c.JSON(http.StatusOK, stats)
+}
+
+// RollbackCanary sends all traffic to the baseline pool
+// @Summary Roll back the canary
+// @Description Sends all traffic to the baseline pool until the canary is resumed
+// @Tags admin
+// @Produce json
+// @Success 200 {object} proxy.CanaryStats
+// @Failure 404 {object} ErrorResponse
+// @Router /proxy/admin/canary/rollback [post]
+func (h *CanaryHandler) RollbackCanary(c echo.Context) error {
+	if err := h.lb.RollbackCanary(); err != nil {
+		return canaryError(err)
+	}
+	c.Logger().Warn("Canary rolled back")
+
+	return h.GetCanary(c)
+}
+
+// ResumeCanary routes traffic to the canary pool again
+// @Summary Resume the canary
+// @Description Routes traffic to the canary pool again after a rollback, starting its error rate afresh
+// @Tags admin
+// @Produce json
+// @Success 200 {object} proxy.CanaryStats
+// @Failure 404 {object} ErrorResponse
+// @Router /proxy/admin/canary/resume [post]
+func (h *CanaryHandler) ResumeCanary(c echo.Context) error {
+	if err := h.lb.ResumeCanary(); err != nil {
+		return canaryError(err)
+	}
+	c.Logger().Warn("Canary resumed")
+
+	return h.GetCanary(c)
+}
+
+func canaryError(err error) error {
+	if errors.Is(err, proxy.ErrNoCanary) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+}
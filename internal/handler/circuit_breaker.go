@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yordanos-habtamu/b2b-payments/internal/resilience"
+)
+
+type CircuitBreakerHandler struct {
+	registry *resilience.CircuitBreakerRegistry
+}
+
+func NewCircuitBreakerHandler(registry *resilience.CircuitBreakerRegistry) *CircuitBreakerHandler {
+	return &CircuitBreakerHandler{
+		registry: registry,
+	}
+}
+
+// ListCircuitBreakers lists every registered circuit breaker
+// @Summary List circuit breakers
+// @Description Lists every circuit breaker with its state, calls and failures in the current window, and last transition
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Circuit breakers"
+// @Failure 403 {object} ErrorResponse
+// @Router /admin/circuit-breakers [get]
+func (h *CircuitBreakerHandler) ListCircuitBreakers(c echo.Context) error {
+	stats := h.registry.GetStats()
+
+	breakers := make([]resilience.CircuitBreakerStats, 0, len(stats))
+	for _, s := range stats {
+		breakers = append(breakers, s)
+	}
+	sort.Slice(breakers, func(i, j int) bool {
+		return breakers[i].Name < breakers[j].Name
+	})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"circuit_breakers": breakers,
+		"count":            len(breakers),
+	})
+}
+
+// GetCircuitBreaker returns a single circuit breaker's state
+// @Summary Inspect a circuit breaker
+// @Tags admin
+// @Produce json
+// @Param name path string true "Circuit breaker name"
+// @Success 200 {object} resilience.CircuitBreakerStats
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/circuit-breakers/{name} [get]
+func (h *CircuitBreakerHandler) GetCircuitBreaker(c echo.Context) error {
+	cb, err := h.lookup(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, cb.Stats())
+}
+
+// ForceOpen opens a circuit breaker until it is force-closed or reset
+// @Summary Force a circuit breaker open
+// @Description Opens the breaker and holds it open, rejecting every call to the dependency, until it is force-closed or reset
+// @Tags admin
+// @Produce json
+// @Param name path string true "Circuit breaker name"
+// @Success 200 {object} resilience.CircuitBreakerStats
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/circuit-breakers/{name}/force-open [post]
+func (h *CircuitBreakerHandler) ForceOpen(c echo.Context) error {
+	cb, err := h.lookup(c)
+	if err != nil {
+		return err
+	}
+
+	cb.ForceOpen()
+	c.Logger().Warnf("Circuit breaker %s forced open", cb.Name())
+
+	return c.JSON(http.StatusOK, cb.Stats())
+}
+
+// ForceClose closes a circuit breaker until it is force-opened or reset
+// @Summary Force a circuit breaker closed
+// @Description Closes the breaker and holds it closed, admitting every call however many fail, until it is force-opened or reset
+// @Tags admin
+// @Produce json
+// @Param name path string true "Circuit breaker name"
+// @Success 200 {object} resilience.CircuitBreakerStats
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/circuit-breakers/{name}/force-close [post]
+func (h *CircuitBreakerHandler) ForceClose(c echo.Context) error {
+	cb, err := h.lookup(c)
+	if err != nil {
+		return err
+	}
+
+	cb.ForceClose()
+	c.Logger().Warnf("Circuit breaker %s forced closed", cb.Name())
+
+	return c.JSON(http.StatusOK, cb.Stats())
+}
+
+// ResetCircuitBreaker closes a circuit breaker, clears its counts and lifts any forced state
+// @Summary Reset a circuit breaker
+// @Tags admin
+// @Produce json
+// @Param name path string true "Circuit breaker name"
+// @Success 200 {object} resilience.CircuitBreakerStats
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/circuit-breakers/{name}/reset [post]
+func (h *CircuitBreakerHandler) ResetCircuitBreaker(c echo.Context) error {
+	cb, err := h.lookup(c)
+	if err != nil {
+		return err
+	}
+
+	cb.Reset()
+	c.Logger().Infof("Circuit breaker %s reset", cb.Name())
+
+	return c.JSON(http.StatusOK, cb.Stats())
+}
+
+func (h *CircuitBreakerHandler) lookup(c echo.Context) (*resilience.CircuitBreaker, error) {
+	cb, ok := h.registry.Get(c.Param("name"))
+	if !ok {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "circuit breaker not found")
+	}
+	return cb, nil
+}
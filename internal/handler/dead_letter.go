@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yordanos-habtamu/b2b-payments/internal/worker"
+)
+
+type DeadLetterHandler struct {
+	queue *worker.JobQueue
+}
+
+func NewDeadLetterHandler(queue *worker.JobQueue) *DeadLetterHandler {
+	return &DeadLetterHandler{
+		queue: queue,
+	}
+}
+
+// ListDeadLetters lists jobs in the dead-letter queue
+// @Summary List dead letters
+// @Description Lists jobs that exhausted their retries, most recent first
+// @Tags admin
+// @Produce json
+// @Param tenant_id query string false "Tenant filter"
+// @Param type query string false "Job type filter"
+// @Param from_date query string false "Dead-lettered at or after (RFC3339 format)"
+// @Param to_date query string false "Dead-lettered at or before (RFC3339 format)"
+// @Param limit query int false "Limit number of results" default(50)
+// @Success 200 {object} map[string]interface{} "Dead letters"
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/dead-letters [get]
+func (h *DeadLetterHandler) ListDeadLetters(c echo.Context) error {
+	filter := parseDeadLetterFilter(c)
+	if filter.Limit == 0 {
+		filter.Limit = 50 // Default limit
+	}
+
+	deadLetters, err := h.queue.ListDeadLetters(c.Request().Context(), filter)
+	if err != nil {
+		c.Logger().Error("Failed to list dead letters", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list dead letters")
+	}
+
+	depth, err := h.queue.DeadLetterDepth(c.Request().Context())
+	if err != nil {
+		c.Logger().Error("Failed to get dead letter depth", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list dead letters")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"dead_letters": deadLetters,
+		"count":        len(deadLetters),
+		"total":        depth,
+	})
+}
+
+// GetDeadLetter returns a single dead letter with its error history
+// @Summary Inspect a dead letter
+// @Tags admin
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} worker.DeadLetter
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/dead-letters/{id} [get]
+func (h *DeadLetterHandler) GetDeadLetter(c echo.Context) error {
+	jobID := c.Param("id")
+
+	deadLetter, err := h.queue.GetDeadLetter(c.Request().Context(), jobID)
+	if err != nil {
+		if errors.Is(err, worker.ErrDeadLetterNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "dead letter not found")
+		}
+		c.Logger().Error("Failed to get dead letter", "error", err, "job", jobID)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to retrieve dead letter")
+	}
+
+	return c.JSON(http.StatusOK, deadLetter)
+}
+
+// RequeueDeadLetter puts a single dead letter back on the job queue
+// @Summary Requeue a dead letter
+// @Tags admin
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} map[string]string "Success message"
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/dead-letters/{id}/requeue [post]
+func (h *DeadLetterHandler) RequeueDeadLetter(c echo.Context) error {
+	jobID := c.Param("id")
+
+	if err := h.queue.RequeueDeadLetter(c.Request().Context(), jobID); err != nil {
+		if errors.Is(err, worker.ErrDeadLetterNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "dead letter not found")
+		}
+		c.Logger().Error("Failed to requeue dead letter", "error", err, "job", jobID)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to requeue dead letter")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "dead letter requeued"})
+}
+
+// RequeueDeadLetters requeues every dead letter matching the filter
+// @Summary Requeue dead letters by filter
+// @Description Requeues dead letters matching the query filters. Without filters all=true is required.
+// @Tags admin
+// @Produce json
+// @Param tenant_id query string false "Tenant filter"
+// @Param type query string false "Job type filter"
+// @Param all query bool false "Confirm requeueing everything when no filter is given"
+// @Success 200 {object} map[string]interface{} "Number of requeued jobs"
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/dead-letters/requeue [post]
+func (h *DeadLetterHandler) RequeueDeadLetters(c echo.Context) error {
+	filter := parseDeadLetterFilter(c)
+	if err := requireFilterOrAll(c, filter); err != nil {
+		return err
+	}
+
+	requeued, err := h.queue.RequeueDeadLetters(c.Request().Context(), filter)
+	if err != nil {
+		c.Logger().Error("Failed to requeue dead letters", "error", err, "requeued", requeued)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to requeue dead letters")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"requeued": requeued})
+}
+
+// PurgeDeadLetter permanently deletes a dead letter
+// @Summary Purge a dead letter
+// @Tags admin
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} map[string]string "Success message"
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/dead-letters/{id} [delete]
+func (h *DeadLetterHandler) PurgeDeadLetter(c echo.Context) error {
+	jobID := c.Param("id")
+
+	if err := h.queue.PurgeDeadLetter(c.Request().Context(), jobID); err != nil {
+		if errors.Is(err, worker.ErrDeadLetterNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "dead letter not found")
+		}
+		c.Logger().Error("Failed to purge dead letter", "error", err, "job", jobID)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to purge dead letter")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "dead letter purged"})
+}
+
+// PurgeDeadLetters permanently deletes every dead letter matching the filter
+// @Summary Purge dead letters by filter
+// @Description Deletes dead letters matching the query filters. Without filters all=true is required.
+// @Tags admin
+// @Produce json
+// @Param tenant_id query string false "Tenant filter"
+// @Param type query string false "Job type filter"
+// @Param all query bool false "Confirm purging everything when no filter is given"
+// @Success 200 {object} map[string]interface{} "Number of purged jobs"
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/dead-letters [delete]
+func (h *DeadLetterHandler) PurgeDeadLetters(c echo.Context) error {
+	filter := parseDeadLetterFilter(c)
+	if err := requireFilterOrAll(c, filter); err != nil {
+		return err
+	}
+
+	purged, err := h.queue.PurgeDeadLetters(c.Request().Context(), filter)
+	if err != nil {
+		c.Logger().Error("Failed to purge dead letters", "error", err, "purged", purged)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to purge dead letters")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"purged": purged})
+}
+
+func parseDeadLetterFilter(c echo.Context) *worker.DeadLetterFilter {
+	filter := &worker.DeadLetterFilter{
+		TenantID: c.QueryParam("tenant_id"),
+		JobType:  c.QueryParam("type"),
+	}
+
+	if fromDateStr := c.QueryParam("from_date"); fromDateStr != "" {
+		if fromDate, err := time.Parse(time.RFC3339, fromDateStr); err == nil {
+			filter.From = &fromDate
+		}
+	}
+
+	if toDateStr := c.QueryParam("to_date"); toDateStr != "" {
+		if toDate, err := time.Parse(time.RFC3339, toDateStr); err == nil {
+			filter.To = &toDate
+		}
+	}
+
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 {
+			filter.Limit = limit
+		}
+	}
+
+	return filter
+}
+
+// requireFilterOrAll guards bulk operations against accidentally matching everything
+func requireFilterOrAll(c echo.Context, filter *worker.DeadLetterFilter) error {
+	if filter.TenantID != "" || filter.JobType != "" || filter.From != nil || filter.To != nil {
+		return nil
+	}
+	if all, _ := strconv.ParseBool(c.QueryParam("all")); all {
+		return nil
+	}
+	return echo.NewHTTPError(http.StatusBadRequest, "a filter or all=true is required")
+}
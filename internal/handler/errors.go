@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yordanos-habtamu/b2b-payments/internal/resilience"
+)
+
+// defaultRetryAfter is sent when a rejection carries no retry hint
+const defaultRetryAfter = 5 * time.Second
+
+// unavailableError maps a dependency refusing work (bulkhead or limiter
+// rejection, open circuit breaker) to 503 with a Retry-After header. It
+// returns nil for any other error.
+func unavailableError(c echo.Context, err error) error {
+	if !resilience.IsUnavailable(err) {
+		return nil
+	}
+
+	retryAfter, ok := resilience.RetryAfter(err)
+	if !ok {
+		retryAfter = defaultRetryAfter
+	}
+	c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	return echo.NewHTTPError(http.StatusServiceUnavailable, "service temporarily unavailable")
+}
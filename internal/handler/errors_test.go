@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yordanos-habtamu/b2b-payments/internal/resilience"
+)
+
+func TestUnavailableError(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		wantStatus     int
+		wantRetryAfter string
+	}{
+		{"rejection rounds up", fmt.Errorf("failed to get payment: %w", &resilience.RejectedError{Name: "postgres", RetryAfter: 1500 * time.Millisecond}), http.StatusServiceUnavailable, "2"},
+		{"rejection without hint", &resilience.RejectedError{Name: "postgres"}, http.StatusServiceUnavailable, "5"},
+		{"open breaker", resilience.ErrCircuitBreakerOpen, http.StatusServiceUnavailable, "5"},
+		{"other error", errors.New("payment not found"), 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/payments/p1", nil), rec)
+
+			err := unavailableError(c, tt.err)
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Errorf("unavailableError(%v) = %v, want nil", tt.err, err)
+				}
+				return
+			}
+
+			var httpErr *echo.HTTPError
+			if !errors.As(err, &httpErr) || httpErr.Code != tt.wantStatus {
+				t.Fatalf("unavailableError(%v) = %v, want status %d", tt.err, err, tt.wantStatus)
+			}
+			if got := rec.Header().Get(echo.HeaderRetryAfter); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+		})
+	}
+}
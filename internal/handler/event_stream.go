@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yordanos-habtamu/b2b-payments/internal/event"
+	"github.com/yordanos-habtamu/b2b-payments/internal/server/middleware"
+)
+
+type EventStreamHandler struct {
+	eventStream       *event.EventStream
+	heartbeatInterval time.Duration
+	retryInterval     time.Duration
+}
+
+func NewEventStreamHandler(eventStream *event.EventStream) *EventStreamHandler {
+	return &EventStreamHandler{
+		eventStream:       eventStream,
+		heartbeatInterval: 15 * time.Second,
+		retryInterval:     5 * time.Second,
+	}
+}
+
+// StreamEvents streams the tenant's payment and job events over Server-Sent Events
+// @Summary Stream payment events
+// @Description Streams the authenticated tenant's payment and job events as Server-Sent Events. Reconnecting clients resume via the Last-Event-ID header (or last_event_id query parameter); a client that missed more events than can be replayed receives a stream.gap event and should refetch its payments.
+// @Tags events
+// @Produce text/event-stream
+// @Param type query string false "Comma-separated event types to include (e.g. payment.completed,payment.failed)"
+// @Param payment_id query string false "Only stream events for this payment"
+// @Param last_event_id query string false "Resume after this event ID when the Last-Event-ID header cannot be set"
+// @Success 200 {string} string "text/event-stream"
+// @Failure 401 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /events/stream [get]
+// @Security BearerAuth
+func (h *EventStreamHandler) StreamEvents(c echo.Context) error {
+	tenantID, err := middleware.GetTenantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthorized")
+	}
+
+	filter := &event.StreamFilter{
+		EventTypes: parseEventTypes(c.QueryParams()["type"]),
+		PaymentID:  c.QueryParam("payment_id"),
+	}
+
+	lastEventID := c.Request().Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.QueryParam("last_event_id")
+	}
+
+	ctx := c.Request().Context()
+	events, err := h.eventStream.Open(ctx, tenantID, lastEventID, filter)
+	if err != nil {
+		c.Logger().Error("Failed to open event stream", "error", err, "tenant", tenantID)
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "event stream unavailable")
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	// Tell EventSource clients how long to wait before reconnecting
+	fmt.Fprintf(res, "retry: %d\n\n", h.retryInterval.Milliseconds())
+	res.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case evt, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := writeSSEEvent(res, evt); err != nil {
+				c.Logger().Warn("Failed to write event to stream", "error", err, "tenant", tenantID)
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
+
+// writeSSEEvent writes evt as an SSE message. A stream gap has no ID, so its
+// empty id field clears the client's Last-Event-ID and a later reconnect
+// starts from live events instead of hitting the gap again.
+func writeSSEEvent(res *echo.Response, evt *event.Event) error {
+	data, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("failed to serialize event: %w", err)
+	}
+
+	_, err = fmt.Fprintf(res, "id: %s\nevent: %s\ndata: %s\n\n", evt.ID, evt.Type, data)
+	return err
+}
+
+// parseEventTypes accepts both repeated and comma-separated type parameters
+func parseEventTypes(values []string) []string {
+	var eventTypes []string
+	for _, value := range values {
+		for _, eventType := range strings.Split(value, ",") {
+			if eventType = strings.TrimSpace(eventType); eventType != "" {
+				eventTypes = append(eventTypes, eventType)
+			}
+		}
+	}
+	return eventTypes
+}
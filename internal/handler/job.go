@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yordanos-habtamu/b2b-payments/internal/server/middleware"
+	"github.com/yordanos-habtamu/b2b-payments/internal/worker"
+)
+
+type JobHandler struct {
+	queue *worker.JobQueue
+}
+
+func NewJobHandler(queue *worker.JobQueue) *JobHandler {
+	return &JobHandler{
+		queue: queue,
+	}
+}
+
+// GetJob returns the status of an asynchronous job
+// @Summary Get job status
+// @Description Returns the state, attempts, last error and result of a job owned by the authenticated tenant
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} worker.JobStatus
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /jobs/{id} [get]
+// @Security BearerAuth
+func (h *JobHandler) GetJob(c echo.Context) error {
+	tenantID, err := middleware.GetTenantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthorized")
+	}
+
+	jobID := c.Param("id")
+	status, err := h.queue.GetJobStatus(c.Request().Context(), tenantID, jobID)
+	if err != nil {
+		if errors.Is(err, worker.ErrJobNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "job not found")
+		}
+		c.Logger().Error("Failed to get job status", "error", err, "tenant", tenantID, "job", jobID)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to retrieve job")
+	}
+
+	return c.JSON(http.StatusOK, status)
+}
+
+// ListJobs lists the authenticated tenant's jobs
+// @Summary List jobs
+// @Description Lists jobs for the authenticated tenant, most recent first. Finished jobs are retained for a limited period.
+// @Tags jobs
+// @Produce json
+// @Param state query string false "State filter (queued, running, retrying, succeeded, dead_lettered)"
+// @Param type query string false "Job type filter"
+// @Param limit query int false "Limit number of results" default(50)
+// @Success 200 {object} map[string]interface{} "Jobs"
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /jobs [get]
+// @Security BearerAuth
+func (h *JobHandler) ListJobs(c echo.Context) error {
+	tenantID, err := middleware.GetTenantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthorized")
+	}
+
+	filter := &worker.JobStatusFilter{
+		State:   worker.JobState(c.QueryParam("state")),
+		JobType: c.QueryParam("type"),
+		Limit:   50, // Default limit
+	}
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 {
+			filter.Limit = limit
+		}
+	}
+
+	jobs, err := h.queue.ListJobStatuses(c.Request().Context(), tenantID, filter)
+	if err != nil {
+		c.Logger().Error("Failed to list jobs", "error", err, "tenant", tenantID)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list jobs")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"jobs":  jobs,
+		"count": len(jobs),
+	})
+}
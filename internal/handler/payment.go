@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -31,6 +32,7 @@ func NewPaymentHandler(paymentService service.PaymentService) *PaymentHandler {
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
 // @Router /payments [post]
 // @Security BearerAuth
 func (h *PaymentHandler) CreatePayment(c echo.Context) error {
@@ -46,6 +48,9 @@ func (h *PaymentHandler) CreatePayment(c echo.Context) error {
 
 	payment, err := h.paymentService.CreatePayment(c.Request().Context(), tenantID, &req)
 	if err != nil {
+		if httpErr := unavailableError(c, err); httpErr != nil {
+			return httpErr
+		}
 		c.Logger().Error("Failed to create payment", "error", err, "tenant", tenantID)
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create payment")
 	}
@@ -66,6 +71,7 @@ func (h *PaymentHandler) CreatePayment(c echo.Context) error {
 // @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
 // @Router /payments/{id} [get]
 // @Security BearerAuth
 func (h *PaymentHandler) GetPayment(c echo.Context) error {
@@ -87,6 +93,9 @@ func (h *PaymentHandler) GetPayment(c echo.Context) error {
 		if err.Error() == "access denied" {
 			return echo.NewHTTPError(http.StatusForbidden, "access denied")
 		}
+		if httpErr := unavailableError(c, err); httpErr != nil {
+			return httpErr
+		}
 		c.Logger().Error("Failed to get payment", "error", err, "tenant", tenantID, "payment", paymentID)
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to retrieve payment")
 	}
@@ -107,7 +116,9 @@ func (h *PaymentHandler) GetPayment(c echo.Context) error {
 // @Failure 401 {object} ErrorResponse
 // @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
 // @Router /payments/{id} [put]
 // @Security BearerAuth
 func (h *PaymentHandler) UpdatePayment(c echo.Context) error {
@@ -134,6 +145,12 @@ func (h *PaymentHandler) UpdatePayment(c echo.Context) error {
 		if err.Error() == "access denied" {
 			return echo.NewHTTPError(http.StatusForbidden, "access denied")
 		}
+		if errors.Is(err, service.ErrPaymentConflict) {
+			return echo.NewHTTPError(http.StatusConflict, "payment status changed concurrently")
+		}
+		if httpErr := unavailableError(c, err); httpErr != nil {
+			return httpErr
+		}
 		c.Logger().Error("Failed to update payment", "error", err, "tenant", tenantID, "payment", paymentID)
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update payment")
 	}
@@ -160,6 +177,7 @@ func (h *PaymentHandler) UpdatePayment(c echo.Context) error {
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
 // @Router /payments [get]
 // @Security BearerAuth
 func (h *PaymentHandler) ListPayments(c echo.Context) error {
@@ -229,6 +247,9 @@ func (h *PaymentHandler) ListPayments(c echo.Context) error {
 
 	payments, total, err := h.paymentService.ListPayments(c.Request().Context(), tenantID, filter)
 	if err != nil {
+		if httpErr := unavailableError(c, err); httpErr != nil {
+			return httpErr
+		}
 		c.Logger().Error("Failed to list payments", "error", err, "tenant", tenantID)
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to retrieve payments")
 	}
@@ -255,7 +276,9 @@ func (h *PaymentHandler) ListPayments(c echo.Context) error {
 // @Failure 401 {object} ErrorResponse
 // @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
 // @Router /payments/{id}/process [post]
 // @Security BearerAuth
 func (h *PaymentHandler) ProcessPayment(c echo.Context) error {
@@ -277,6 +300,12 @@ func (h *PaymentHandler) ProcessPayment(c echo.Context) error {
 		if err.Error() == "access denied" {
 			return echo.NewHTTPError(http.StatusForbidden, "access denied")
 		}
+		if errors.Is(err, service.ErrPaymentConflict) {
+			return echo.NewHTTPError(http.StatusConflict, "payment status changed concurrently")
+		}
+		if httpErr := unavailableError(c, err); httpErr != nil {
+			return httpErr
+		}
 		c.Logger().Error("Failed to process payment", "error", err, "tenant", tenantID, "payment", paymentID)
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to process payment")
 	}
@@ -296,7 +325,9 @@ func (h *PaymentHandler) ProcessPayment(c echo.Context) error {
 // @Failure 401 {object} ErrorResponse
 // @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
 // @Router /payments/{id}/cancel [post]
 // @Security BearerAuth
 func (h *PaymentHandler) CancelPayment(c echo.Context) error {
@@ -318,6 +349,12 @@ func (h *PaymentHandler) CancelPayment(c echo.Context) error {
 		if err.Error() == "access denied" {
 			return echo.NewHTTPError(http.StatusForbidden, "access denied")
 		}
+		if errors.Is(err, service.ErrPaymentConflict) {
+			return echo.NewHTTPError(http.StatusConflict, "payment status changed concurrently")
+		}
+		if httpErr := unavailableError(c, err); httpErr != nil {
+			return httpErr
+		}
 		c.Logger().Error("Failed to cancel payment", "error", err, "tenant", tenantID, "payment", paymentID)
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to cancel payment")
 	}
@@ -334,6 +371,7 @@ func (h *PaymentHandler) CancelPayment(c echo.Context) error {
 // @Success 200 {object} service.PaymentStats
 // @Failure 401 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
 // @Router /payments/stats [get]
 // @Security BearerAuth
 func (h *PaymentHandler) GetPaymentStats(c echo.Context) error {
@@ -344,6 +382,9 @@ func (h *PaymentHandler) GetPaymentStats(c echo.Context) error {
 
 	stats, err := h.paymentService.GetPaymentStats(c.Request().Context(), tenantID)
 	if err != nil {
+		if httpErr := unavailableError(c, err); httpErr != nil {
+			return httpErr
+		}
 		c.Logger().Error("Failed to get payment stats", "error", err, "tenant", tenantID)
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to retrieve payment statistics")
 	}
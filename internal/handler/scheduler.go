@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yordanos-habtamu/b2b-payments/internal/scheduler"
+)
+
+type SchedulerHandler struct {
+	scheduler *scheduler.Scheduler
+}
+
+func NewSchedulerHandler(scheduler *scheduler.Scheduler) *SchedulerHandler {
+	return &SchedulerHandler{
+		scheduler: scheduler,
+	}
+}
+
+// ListTasks returns the periodic tasks with their last and next runs
+// @Summary List periodic tasks
+// @Description Lists the worker's periodic tasks, their schedules, last-run outcome and next run, plus the current scheduler leader
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Periodic tasks"
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/scheduler/tasks [get]
+func (h *SchedulerHandler) ListTasks(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	tasks, err := h.scheduler.Status(ctx)
+	if err != nil {
+		c.Logger().Error("Failed to get scheduler status", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get scheduler status")
+	}
+
+	leader, err := h.scheduler.Leader(ctx)
+	if err != nil {
+		c.Logger().Error("Failed to get scheduler leader", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get scheduler status")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"leader": leader,
+		"tasks":  tasks,
+	})
+}
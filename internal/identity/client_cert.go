@@ -0,0 +1,49 @@
+package identity
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+)
+
+// HeaderClientCert carries the client certificate chain a proxy verified, as
+// URL-escaped PEM
+const HeaderClientCert = "X-Client-Cert"
+
+// EncodeClientCert encodes a certificate chain for HeaderClientCert
+func EncodeClientCert(chain []*x509.Certificate) string {
+	var chainPEM []byte
+	for _, cert := range chain {
+		chainPEM = append(chainPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return url.QueryEscape(string(chainPEM))
+}
+
+// DecodeClientCert parses a HeaderClientCert value into its certificate
+// chain, leaf first. The chain is not verified.
+func DecodeClientCert(header string) ([]*x509.Certificate, error) {
+	chainPEM, err := url.QueryUnescape(header)
+	if err != nil {
+		return nil, fmt.Errorf("invalid forwarded client certificate: %w", err)
+	}
+
+	var chain []*x509.Certificate
+	rest := []byte(chainPEM)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid forwarded client certificate: %w", err)
+		}
+		chain = append(chain, cert)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("invalid forwarded client certificate: no PEM certificate found")
+	}
+	return chain, nil
+}
@@ -0,0 +1,23 @@
+// Package identity maps client certificates to the identities the API and the
+// proxy act on. It has no dependencies on the server so the proxy can use it
+// without pulling in the API's middleware.
+package identity
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TenantIDFromCommonName extracts the tenant ID from a client certificate
+// Common Name of the form tenant-<tenant_id>.yourorg.com
+func TenantIDFromCommonName(cn string) (string, error) {
+	if !strings.HasPrefix(cn, "tenant-") || !strings.HasSuffix(cn, ".yourorg.com") {
+		return "", fmt.Errorf("invalid client certificate CN: %s", cn)
+	}
+
+	tenantID := strings.TrimSuffix(strings.TrimPrefix(cn, "tenant-"), ".yourorg.com")
+	if tenantID == "" {
+		return "", fmt.Errorf("empty tenant ID in certificate")
+	}
+	return tenantID, nil
+}
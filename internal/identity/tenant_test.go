@@ -0,0 +1,24 @@
+package identity
+
+import "testing"
+
+func TestTenantIDFromCommonName(t *testing.T) {
+	tests := []struct {
+		cn      string
+		want    string
+		wantErr bool
+	}{
+		{"tenant-acme.yourorg.com", "acme", false},
+		{"tenant-.yourorg.com", "", true},
+		{"acme.yourorg.com", "", true},
+		{"tenant-acme.example.com", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := TenantIDFromCommonName(tt.cn)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("TenantIDFromCommonName(%q) = %q, %v, want %q (error: %v)", tt.cn, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
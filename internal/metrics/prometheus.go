@@ -108,6 +108,22 @@ var (
 		[]string{"queue_name"},
 	)
 
+	workerDeadLetterQueueDepth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "worker_dead_letter_queue_depth",
+			Help: "Current number of jobs in the dead-letter queue",
+		},
+		[]string{"queue_name"},
+	)
+
+	workerJobsDeadLetteredTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "worker_jobs_dead_lettered_total",
+			Help: "Total number of jobs moved to the dead-letter queue",
+		},
+		[]string{"job_type"},
+	)
+
 	// OPA metrics
 	opaEvaluationsTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -176,6 +192,56 @@ var (
 		},
 		[]string{"event_type", "tenant_id", "handler"},
 	)
+
+	eventHandlerResultsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "event_handler_results_total",
+			Help: "Total number of event handler invocations by outcome",
+		},
+		[]string{"event_type", "handler", "outcome"},
+	)
+
+	eventHandlerDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "event_handler_duration_seconds",
+			Help:    "Event handler execution duration in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"handler"},
+	)
+
+	// Resilience metrics
+	retryAttemptsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "resilience_retry_attempts_total",
+			Help: "Total number of retries made against a dependency",
+		},
+		[]string{"dependency"},
+	)
+
+	retryBudgetExhaustedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "resilience_retry_budget_exhausted_total",
+			Help: "Total number of retries skipped because the dependency's retry budget was spent",
+		},
+		[]string{"dependency"},
+	)
+
+	circuitBreakerState = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "circuit_breaker_state",
+			Help: "Current state of circuit breakers (0=closed, 1=open, 2=half-open)",
+		},
+		[]string{"name"},
+	)
+
+	circuitBreakerTransitionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "circuit_breaker_transitions_total",
+			Help: "Total number of circuit breaker state transitions",
+		},
+		[]string{"name", "from", "to"},
+	)
 )
 
 // MetricsCollector provides methods to record metrics
@@ -229,6 +295,14 @@ func (m *MetricsCollector) SetWorkerQueueLength(queueName string, length float64
 	workerQueueLength.WithLabelValues(queueName).Set(length)
 }
 
+func (m *MetricsCollector) SetDeadLetterQueueDepth(queueName string, depth float64) {
+	workerDeadLetterQueueDepth.WithLabelValues(queueName).Set(depth)
+}
+
+func (m *MetricsCollector) RecordJobDeadLettered(jobType string) {
+	workerJobsDeadLetteredTotal.WithLabelValues(jobType).Inc()
+}
+
 // OPA metrics
 func (m *MetricsCollector) RecordOPAEvaluation(policy, result string, duration float64) {
 	opaEvaluationsTotal.WithLabelValues(policy, result).Inc()
@@ -265,3 +339,25 @@ func (m *MetricsCollector) RecordEventPublished(eventType, tenantID string) {
 func (m *MetricsCollector) RecordEventProcessed(eventType, tenantID, handler string) {
 	eventsProcessedTotal.WithLabelValues(eventType, tenantID, handler).Inc()
 }
+
+func (m *MetricsCollector) RecordEventHandled(eventType, handler, outcome string, duration float64) {
+	eventHandlerResultsTotal.WithLabelValues(eventType, handler, outcome).Inc()
+	eventHandlerDuration.WithLabelValues(handler).Observe(duration)
+}
+
+// Resilience metrics
+func (m *MetricsCollector) RecordRetryAttempt(dependency string) {
+	retryAttemptsTotal.WithLabelValues(dependency).Inc()
+}
+
+func (m *MetricsCollector) RecordRetryBudgetExhausted(dependency string) {
+	retryBudgetExhaustedTotal.WithLabelValues(dependency).Inc()
+}
+
+func (m *MetricsCollector) SetCircuitBreakerState(name string, state int) {
+	circuitBreakerState.WithLabelValues(name).Set(float64(state))
+}
+
+func (m *MetricsCollector) RecordCircuitBreakerTransition(name, from, to string) {
+	circuitBreakerTransitionsTotal.WithLabelValues(name, from, to).Inc()
+}
@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsCollectorRecordsRetries(t *testing.T) {
+	m := NewMetricsCollector()
+	attempts := testutil.ToFloat64(retryAttemptsTotal.WithLabelValues("postgres"))
+	exhausted := testutil.ToFloat64(retryBudgetExhaustedTotal.WithLabelValues("postgres"))
+
+	m.RecordRetryAttempt("postgres")
+	m.RecordRetryAttempt("postgres")
+	m.RecordRetryBudgetExhausted("postgres")
+
+	if got := testutil.ToFloat64(retryAttemptsTotal.WithLabelValues("postgres")) - attempts; got != 2 {
+		t.Errorf("resilience_retry_attempts_total moved by %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(retryBudgetExhaustedTotal.WithLabelValues("postgres")) - exhausted; got != 1 {
+		t.Errorf("resilience_retry_budget_exhausted_total moved by %v, want 1", got)
+	}
+}
@@ -2,19 +2,18 @@ package policy
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"net/http"
 	"strings"
 
 	"github.com/labstack/echo/v4"
 	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/storage"
 	"github.com/open-policy-agent/opa/storage/inmem"
 )
 
 type OPAClient struct {
 	policy *rego.PreparedEvalQuery
-	store  rego.Store
+	store  storage.Store
 }
 
 type PolicyInput struct {
@@ -80,6 +79,27 @@ allow {
     tenant_can_update_payment
 }
 
+allow {
+    input.method == "GET"
+    input.path == "/api/v1/events/stream"
+    has_tenant_id
+    tenant_active
+}
+
+allow {
+    input.method == "GET"
+    input.path == "/api/v1/jobs"
+    has_tenant_id
+    tenant_active
+}
+
+allow {
+    input.method == "GET"
+    starts_with(input.path, "/api/v1/jobs/")
+    has_tenant_id
+    tenant_active
+}
+
 has_tenant_id {
     input.tenant_id != ""
 }
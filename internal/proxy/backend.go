@@ -0,0 +1,235 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrServerNotFound = errors.New("server not found")
+	ErrServerExists   = errors.New("server already exists")
+)
+
+// Server is a backend the load balancer proxies to. Its URL is fixed; the
+// rest of its state is read by request handlers while health checks and
+// admin operations change it, so it is only reached through atomic accessors.
+type Server struct {
+	URL *url.URL
+
+	weight          atomic.Int64
+	metadata        atomic.Pointer[map[string]string]
+	healthy         atomic.Bool
+	connections     atomic.Int64
+	lastHealthCheck atomic.Int64 // unix nanoseconds, 0 before the first check
+	slowStartFrom   atomic.Int64 // unix nanoseconds the server was added or recovered, 0 for initial servers
+
+	// Draining state (see LoadBalancer.DrainServer): drained is closed once
+	// requests in progress have finished or been cut off by cutOff. removing
+	// is set by RemoveServer. A server never leaves the draining state;
+	// adding the URL again replaces it.
+	draining  atomic.Bool
+	removing  atomic.Bool
+	drained   chan struct{}
+	cutOffCtx context.Context
+	cutOff    context.CancelFunc
+
+	// Consecutive active health check results (see HealthChecker)
+	checkPasses   atomic.Int64
+	checkFailures atomic.Int64
+
+	// Passive outlier detection state (see outlierDetector)
+	passiveFailures atomic.Int64
+	ejections       atomic.Int64
+	ejectedUntil    atomic.Int64 // unix nanoseconds, 0 if never ejected
+}
+
+// NewServer creates a backend, healthy until a health check says otherwise
+func NewServer(serverURL string, weight int) (*Server, error) {
+	parsedURL, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server URL %s: %w", serverURL, err)
+	}
+	if parsedURL.Scheme == "" || parsedURL.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %s: scheme and host are required", serverURL)
+	}
+
+	server := &Server{
+		URL:     parsedURL,
+		drained: make(chan struct{}),
+	}
+	server.cutOffCtx, server.cutOff = context.WithCancel(context.Background())
+	server.SetWeight(weight)
+	server.healthy.Store(true)
+	return server, nil
+}
+
+// Weight returns the server's share of weighted traffic
+func (s *Server) Weight() int {
+	return int(s.weight.Load())
+}
+
+// SetWeight changes the server's weight. Weights below 1 are raised to 1.
+func (s *Server) SetWeight(weight int) {
+	s.weight.Store(int64(max(weight, 1)))
+}
+
+// Metadata returns the labels the backend was configured with. The map must not be modified.
+func (s *Server) Metadata() map[string]string {
+	if metadata := s.metadata.Load(); metadata != nil {
+		return *metadata
+	}
+	return nil
+}
+
+// SetMetadata replaces the backend's labels
+func (s *Server) SetMetadata(metadata map[string]string) {
+	metadata = maps.Clone(metadata)
+	s.metadata.Store(&metadata)
+}
+
+// Draining reports whether the server has been taken out of rotation to be removed
+func (s *Server) Draining() bool {
+	return s.draining.Load()
+}
+
+// Healthy reports whether active health checks consider the server up
+func (s *Server) Healthy() bool {
+	return s.healthy.Load()
+}
+
+// EjectedUntil returns when the server's current or last ejection by outlier
+// detection ends, or the zero time if it was never ejected
+func (s *Server) EjectedUntil() time.Time {
+	until := s.ejectedUntil.Load()
+	if until == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, until)
+}
+
+// Connections returns the number of requests currently proxied to the server
+func (s *Server) Connections() int {
+	return int(s.connections.Load())
+}
+
+// LastHealthCheck returns when the server last passed a health check
+func (s *Server) LastHealthCheck() time.Time {
+	checked := s.lastHealthCheck.Load()
+	if checked == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, checked)
+}
+
+func (s *Server) setHealthy(healthy bool) {
+	s.healthy.Store(healthy)
+}
+
+// SlowStartFrom returns when the server was added or recovered from failing
+// health checks, which starts its slow-start ramp, or the zero time
+func (s *Server) SlowStartFrom() time.Time {
+	from := s.slowStartFrom.Load()
+	if from == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, from)
+}
+
+func (s *Server) startSlowStart(at time.Time) {
+	s.slowStartFrom.Store(at.UnixNano())
+}
+
+func (s *Server) recordHealthCheck(at time.Time) {
+	s.lastHealthCheck.Store(at.UnixNano())
+}
+
+func (s *Server) acquire() {
+	s.connections.Add(1)
+}
+
+func (s *Server) release() {
+	s.connections.Add(-1)
+}
+
+// backendSet is the list of backends shared by request handlers, health
+// checks and admin operations. Readers take an immutable snapshot without
+// locking; writers copy the list under mu and publish the copy, so a
+// snapshot being iterated is never modified.
+type backendSet struct {
+	mu      sync.Mutex
+	servers atomic.Pointer[[]*Server]
+}
+
+func newBackendSet(servers []*Server) *backendSet {
+	set := &backendSet{}
+	set.servers.Store(&servers)
+	return set
+}
+
+// snapshot returns the current backends. The slice must not be modified.
+func (b *backendSet) snapshot() []*Server {
+	return *b.servers.Load()
+}
+
+// add adds server. A server with the same URL that is draining is replaced
+// in place, so a pending removal no longer finds it.
+func (b *backendSet) add(server *Server) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current := b.snapshot()
+	for i, existing := range current {
+		if existing.URL.String() != server.URL.String() {
+			continue
+		}
+		if !existing.Draining() {
+			return fmt.Errorf("%w: %s", ErrServerExists, server.URL)
+		}
+
+		next := slices.Clone(current)
+		next[i] = server
+		b.servers.Store(&next)
+		return nil
+	}
+
+	next := make([]*Server, 0, len(current)+1)
+	next = append(next, current...)
+	next = append(next, server)
+	b.servers.Store(&next)
+	return nil
+}
+
+// remove removes server, reporting whether it was still in the set
+func (b *backendSet) remove(server *Server) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current := b.snapshot()
+	i := slices.Index(current, server)
+	if i < 0 {
+		return false
+	}
+
+	next := make([]*Server, 0, len(current)-1)
+	next = append(next, current[:i]...)
+	next = append(next, current[i+1:]...)
+	b.servers.Store(&next)
+	return true
+}
+
+// get returns the backend with the given URL
+func (b *backendSet) get(serverURL string) (*Server, error) {
+	for _, server := range b.snapshot() {
+		if server.URL.String() == serverURL {
+			return server, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrServerNotFound, serverURL)
+}
@@ -0,0 +1,324 @@
+package proxy
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/yordanos-habtamu/b2b-payments/internal/resilience"
+)
+
+const (
+	// PoolMetadataKey is the backend metadata label naming its pool
+	PoolMetadataKey = "pool"
+
+	// PoolBaseline is the pool of every backend not in the canary pool
+	PoolBaseline = "baseline"
+
+	DefaultCanaryPool = "canary"
+
+	// DefaultMaxErrorRateIncrease is the error rate increase over the
+	// baseline that rolls a canary back when none is configured
+	DefaultMaxErrorRateIncrease = 0.05
+
+	// Error rates are kept over a window of this many buckets
+	canaryWindowBuckets = 10
+)
+
+// ErrNoCanary is returned by canary operations on a load balancer without canary routing
+var ErrNoCanary = errors.New("canary routing is not configured")
+
+// CanaryConfig configures splitting traffic between the baseline backends
+// and a canary pool, with automatic rollback if the canary fails more
+type CanaryConfig struct {
+	// Pool is the "pool" metadata value of canary backends (default
+	// "canary"). Every other backend is in the baseline pool.
+	Pool string
+
+	// Percent of requests sent to the canary. Requests are split by the load
+	// balancer's hash key (see SetHashKey), so a client stays in one pool.
+	Percent float64
+
+	// Tenants whose requests always go to the canary
+	Tenants []string
+
+	// Header, if set, sends requests carrying it to the canary; with
+	// HeaderValue, only those where it has that value
+	Header      string
+	HeaderValue string
+
+	// Once the canary has served MinRequests in Window and its error rate
+	// exceeds the baseline's by more than MaxErrorRateIncrease, all traffic
+	// goes to the baseline until the canary is resumed. Unset (nil) means
+	// DefaultMaxErrorRateIncrease; 0 rolls back on any increase and a
+	// negative value disables automatic rollback.
+	MaxErrorRateIncrease *float64
+	MinRequests          int           // default 100
+	Window               time.Duration // default 5m
+
+	// Now replaces time.Now, for tests
+	Now func() time.Time
+}
+
+func (c CanaryConfig) withDefaults() CanaryConfig {
+	if c.Pool == "" {
+		c.Pool = DefaultCanaryPool
+	}
+	c.Percent = min(max(c.Percent, 0), 100)
+	c.Header = http.CanonicalHeaderKey(c.Header)
+	if c.MaxErrorRateIncrease == nil {
+		increase := DefaultMaxErrorRateIncrease
+		c.MaxErrorRateIncrease = &increase
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = 100
+	}
+	if c.Window <= 0 {
+		c.Window = 5 * time.Minute
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+	return c
+}
+
+// PoolStats is a point-in-time view of a backend pool
+type PoolStats struct {
+	Pool      string  `json:"pool"`
+	Servers   int     `json:"servers"`
+	Requests  int     `json:"requests"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// CanaryStats is a point-in-time view of canary routing. Request and error
+// counts cover the configured window.
+type CanaryStats struct {
+	Pool         string      `json:"pool"`
+	Percent      float64     `json:"percent"`
+	RolledBack   bool        `json:"rolled_back"`
+	RolledBackAt *time.Time  `json:"rolled_back_at,omitempty"`
+	Reason       string      `json:"reason,omitempty"`
+	Pools        []PoolStats `json:"pools"`
+}
+
+// canaryRouter decides which pool a request goes to and tracks each pool's
+// error rate
+type canaryRouter struct {
+	config  CanaryConfig
+	tenants map[string]bool
+
+	mu           sync.Mutex
+	windows      map[string]*resilience.SlidingWindow
+	rolledBack   bool
+	rolledBackAt time.Time
+	reason       string
+}
+
+func newCanaryRouter(config CanaryConfig) *canaryRouter {
+	config = config.withDefaults()
+
+	tenants := make(map[string]bool, len(config.Tenants))
+	for _, tenantID := range config.Tenants {
+		tenants[tenantID] = true
+	}
+
+	return &canaryRouter{
+		config:  config,
+		tenants: tenants,
+		windows: map[string]*resilience.SlidingWindow{
+			PoolBaseline: resilience.NewSlidingWindow(config.Window, canaryWindowBuckets),
+			config.Pool:  resilience.NewSlidingWindow(config.Window, canaryWindowBuckets),
+		},
+	}
+}
+
+// poolOf returns the pool server belongs to
+func (cr *canaryRouter) poolOf(server *Server) string {
+	if server.Metadata()[PoolMetadataKey] == cr.config.Pool {
+		return cr.config.Pool
+	}
+	return PoolBaseline
+}
+
+// route returns the pool r should be sent to. key is r's hash key.
+func (cr *canaryRouter) route(r *http.Request, key string) string {
+	cr.mu.Lock()
+	rolledBack := cr.rolledBack
+	cr.mu.Unlock()
+	if rolledBack {
+		return PoolBaseline
+	}
+
+	if len(cr.tenants) > 0 && cr.tenants[tenantFromRequest(r)] {
+		return cr.config.Pool
+	}
+
+	if cr.config.Header != "" {
+		_, present := r.Header[cr.config.Header]
+		if present && (cr.config.HeaderValue == "" || r.Header.Get(cr.config.Header) == cr.config.HeaderValue) {
+			return cr.config.Pool
+		}
+	}
+
+	if cr.config.Percent > 0 && float64(hashPair(cr.config.Pool, key)%10000) < cr.config.Percent*100 {
+		return cr.config.Pool
+	}
+	return PoolBaseline
+}
+
+// record counts the outcome of a request served by pool and rolls the
+// canary back if it is failing more than the baseline
+func (cr *canaryRouter) record(pool string, failed bool) {
+	now := cr.config.Now()
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	cr.windows[pool].Add(now, failed)
+	if pool != cr.config.Pool || cr.rolledBack || *cr.config.MaxErrorRateIncrease < 0 {
+		return
+	}
+
+	canaryRequests, canaryErrors := cr.windows[cr.config.Pool].Totals(now)
+	if canaryRequests < cr.config.MinRequests {
+		return
+	}
+	canaryRate := errorRate(canaryRequests, canaryErrors)
+	baselineRate := errorRate(cr.windows[PoolBaseline].Totals(now))
+
+	if canaryRate > baselineRate+*cr.config.MaxErrorRateIncrease {
+		cr.rolledBack = true
+		cr.rolledBackAt = now
+		cr.reason = "canary error rate exceeded baseline"
+		log.Printf("Rolled back canary pool %s: error rate %.3f against baseline %.3f over %d requests",
+			cr.config.Pool, canaryRate, baselineRate, canaryRequests)
+	}
+}
+
+// rollback sends all traffic to the baseline until resume
+func (cr *canaryRouter) rollback(reason string) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if !cr.rolledBack {
+		cr.rolledBack = true
+		cr.rolledBackAt = cr.config.Now()
+		cr.reason = reason
+	}
+}
+
+// resume routes traffic to the canary again, forgetting its past errors
+func (cr *canaryRouter) resume() {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	cr.rolledBack = false
+	cr.rolledBackAt = time.Time{}
+	cr.reason = ""
+	cr.windows[cr.config.Pool].Reset()
+}
+
+func (cr *canaryRouter) stats(servers []*Server) CanaryStats {
+	now := cr.config.Now()
+
+	counts := make(map[string]int, 2)
+	for _, server := range servers {
+		counts[cr.poolOf(server)]++
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	stats := CanaryStats{
+		Pool:       cr.config.Pool,
+		Percent:    cr.config.Percent,
+		RolledBack: cr.rolledBack,
+		Reason:     cr.reason,
+	}
+	if cr.rolledBack {
+		rolledBackAt := cr.rolledBackAt
+		stats.RolledBackAt = &rolledBackAt
+	}
+	for _, pool := range []string{PoolBaseline, cr.config.Pool} {
+		requests, failures := cr.windows[pool].Totals(now)
+		stats.Pools = append(stats.Pools, PoolStats{
+			Pool:      pool,
+			Servers:   counts[pool],
+			Requests:  requests,
+			Errors:    failures,
+			ErrorRate: errorRate(requests, failures),
+		})
+	}
+	return stats
+}
+
+func errorRate(requests, failures int) float64 {
+	if requests == 0 {
+		return 0
+	}
+	return float64(failures) / float64(requests)
+}
+
+// SetCanary splits traffic between the baseline backends and the canary
+// pool. Must be called before serving traffic.
+func (lb *LoadBalancer) SetCanary(config CanaryConfig) {
+	lb.canary = newCanaryRouter(config)
+}
+
+// CanaryStats returns the canary's routing state and per-pool error rates
+func (lb *LoadBalancer) CanaryStats() (CanaryStats, error) {
+	if lb.canary == nil {
+		return CanaryStats{}, ErrNoCanary
+	}
+	return lb.canary.stats(lb.backends.snapshot()), nil
+}
+
+// RollbackCanary sends all traffic to the baseline until ResumeCanary
+func (lb *LoadBalancer) RollbackCanary() error {
+	if lb.canary == nil {
+		return ErrNoCanary
+	}
+	lb.canary.rollback("rolled back by operator")
+	log.Printf("Canary pool %s rolled back by operator", lb.canary.config.Pool)
+	return nil
+}
+
+// ResumeCanary routes traffic to the canary again after a rollback
+func (lb *LoadBalancer) ResumeCanary() error {
+	if lb.canary == nil {
+		return ErrNoCanary
+	}
+	lb.canary.resume()
+	log.Printf("Canary pool %s resumed", lb.canary.config.Pool)
+	return nil
+}
+
+// routePool returns the pool r should be sent to, or "" for any backend
+func (lb *LoadBalancer) routePool(r *http.Request) string {
+	if lb.canary == nil {
+		return ""
+	}
+	return lb.canary.route(r, lb.hashKey(r))
+}
+
+// inPool narrows servers to those in pool. If the pool has no healthy
+// servers the others are used, so an empty canary never fails requests.
+func (lb *LoadBalancer) inPool(servers []*Server, pool string) []*Server {
+	if pool == "" {
+		return servers
+	}
+
+	var selected []*Server
+	for _, server := range servers {
+		if lb.canary.poolOf(server) == pool {
+			selected = append(selected, server)
+		}
+	}
+	if len(selected) == 0 {
+		return servers
+	}
+	return selected
+}
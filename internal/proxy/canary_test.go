@@ -0,0 +1,202 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func newCanaryLoadBalancer(t *testing.T, config CanaryConfig, baseline, canary string) *LoadBalancer {
+	t.Helper()
+
+	lb := newTestLoadBalancer(t, RoundRobin, baseline)
+	if err := lb.AddBackend(BackendConfig{URL: canary, Metadata: map[string]string{PoolMetadataKey: "canary"}}); err != nil {
+		t.Fatalf("AddBackend: %v", err)
+	}
+	lb.SetCanary(config)
+	return lb
+}
+
+func TestCanaryRouting(t *testing.T) {
+	lb := newCanaryLoadBalancer(t, CanaryConfig{
+		Tenants:     []string{"acme"},
+		Header:      "x-canary",
+		HeaderValue: "always",
+	}, "http://baseline:1", "http://canary:1")
+
+	request := func(header http.Header, tenant string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/payments", nil)
+		for name, values := range header {
+			r.Header[name] = values
+		}
+		if tenant != "" {
+			r.TLS = &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "tenant-" + tenant + ".yourorg.com"}}},
+				VerifiedChains:   [][]*x509.Certificate{{}},
+			}
+		}
+		return r
+	}
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		want    string
+	}{
+		{"canary tenant", request(nil, "acme"), "canary:1"},
+		{"other tenant", request(nil, "globex"), "baseline:1"},
+		{"canary header", request(http.Header{"X-Canary": {"always"}}, ""), "canary:1"},
+		{"other header value", request(http.Header{"X-Canary": {"never"}}, ""), "baseline:1"},
+		{"no header", request(nil, ""), "baseline:1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				if got := lb.GetNextServer(tt.request); got.URL.Host != tt.want {
+					t.Fatalf("expected %s, got %s", tt.want, got.URL.Host)
+				}
+			}
+		})
+	}
+}
+
+func TestCanaryPercentIsStickyPerClient(t *testing.T) {
+	lb := newCanaryLoadBalancer(t, CanaryConfig{Percent: 20}, "http://baseline:1", "http://canary:1")
+
+	canary := 0
+	for i := 0; i < 10000; i++ {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = fmt.Sprintf("10.%d.%d.%d:5000", i>>16&255, i>>8&255, i&255)
+
+		first := lb.GetNextServer(r)
+		if again := lb.GetNextServer(r); again != first {
+			t.Fatalf("client %s moved between pools", r.RemoteAddr)
+		}
+		if first.URL.Host == "canary:1" {
+			canary++
+		}
+	}
+
+	if share := float64(canary) / 10000; math.Abs(share-0.2) > 0.02 {
+		t.Fatalf("expected about 20%% of clients on the canary, got %.3f", share)
+	}
+}
+
+func TestCanaryFallsBackWithoutHealthyCanary(t *testing.T) {
+	lb := newCanaryLoadBalancer(t, CanaryConfig{Percent: 100}, "http://baseline:1", "http://canary:1")
+	lb.Servers()[1].setHealthy(false)
+
+	if got := lb.GetNextServer(httptest.NewRequest(http.MethodGet, "/", nil)); got.URL.Host != "baseline:1" {
+		t.Fatalf("expected the baseline when no canary is healthy, got %s", got.URL.Host)
+	}
+}
+
+func TestCanaryRollsBackOnErrorRate(t *testing.T) {
+	baseline, baselineRequests := newBackend(t, healthStatus(http.StatusOK))
+	canary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(canary.Close)
+
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	lb := newCanaryLoadBalancer(t, CanaryConfig{
+		Percent:     50,
+		MinRequests: 20,
+		Window:      time.Minute,
+		Now:         clock.Now,
+	}, baseline.URL, canary.URL)
+	lb.SetOutlierDetection(OutlierDetectionConfig{ConsecutiveFailures: -1})
+
+	e := echo.New()
+	e.Any("/*", lb.ProxyHandler())
+	serve := func(i int) {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/payments", nil)
+		r.RemoteAddr = fmt.Sprintf("10.0.%d.%d:5000", i>>8&255, i&255)
+		e.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	for i := 0; i < 200; i++ {
+		serve(i)
+	}
+
+	stats, err := lb.CanaryStats()
+	if err != nil {
+		t.Fatalf("CanaryStats: %v", err)
+	}
+	if !stats.RolledBack {
+		t.Fatalf("expected the failing canary to be rolled back, got %+v", stats)
+	}
+	if canaryPool := stats.Pools[1]; canaryPool.Requests != 20 || canaryPool.ErrorRate != 1 {
+		t.Fatalf("expected rollback after the canary's first 20 requests, got %+v", canaryPool)
+	}
+	if baselinePool := stats.Pools[0]; baselinePool.ErrorRate != 0 || baselinePool.Requests != int(baselineRequests.Load()) {
+		t.Fatalf("unexpected baseline stats %+v", baselinePool)
+	}
+
+	// Rolled back, every client goes to the baseline
+	before := baselineRequests.Load()
+	for i := 0; i < 100; i++ {
+		serve(i)
+	}
+	if got := baselineRequests.Load() - before; got != 100 {
+		t.Fatalf("expected all 100 requests on the baseline after rollback, got %d", got)
+	}
+
+	if err := lb.ResumeCanary(); err != nil {
+		t.Fatalf("ResumeCanary: %v", err)
+	}
+	if stats, _ := lb.CanaryStats(); stats.RolledBack || stats.Pools[1].Requests != 0 {
+		t.Fatalf("expected a fresh canary after resuming, got %+v", stats)
+	}
+}
+
+func TestCanaryMaxErrorRateIncrease(t *testing.T) {
+	zero, disabled := 0.0, -1.0
+	tests := []struct {
+		name       string
+		increase   *float64
+		rolledBack bool
+	}{
+		{"unset uses the default", nil, false},
+		{"zero rolls back on any increase", &zero, true},
+		{"negative disables rollback", &disabled, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr := newCanaryRouter(CanaryConfig{MaxErrorRateIncrease: tt.increase})
+
+			// 10% of baseline requests fail against 12% of canary requests
+			for i := 0; i < 100; i++ {
+				cr.record(PoolBaseline, i < 10)
+			}
+			for i := 0; i < 100; i++ {
+				cr.record(DefaultCanaryPool, i >= 88)
+			}
+
+			if cr.rolledBack != tt.rolledBack {
+				t.Fatalf("expected rolled back = %v, got %v", tt.rolledBack, cr.rolledBack)
+			}
+		})
+	}
+}
+
+func TestCanaryNotConfigured(t *testing.T) {
+	lb := newTestLoadBalancer(t, RoundRobin, "http://a:1")
+
+	if _, err := lb.CanaryStats(); err != ErrNoCanary {
+		t.Fatalf("expected ErrNoCanary, got %v", err)
+	}
+	if err := lb.RollbackCanary(); err != ErrNoCanary {
+		t.Fatalf("expected ErrNoCanary, got %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// reloadDelay lets a burst of file events (write, chmod, rename) settle into one reload
+const reloadDelay = 100 * time.Millisecond
+
+// BackendConfig describes a backend in a backends file or admin request
+type BackendConfig struct {
+	URL      string            `yaml:"url" json:"url"`
+	Weight   int               `yaml:"weight" json:"weight,omitempty"`
+	Metadata map[string]string `yaml:"metadata" json:"metadata,omitempty"`
+}
+
+type backendsFile struct {
+	Backends []BackendConfig `yaml:"backends"`
+}
+
+// LoadBackendsFile reads the backends listed in a YAML or JSON file:
+//
+//	backends:
+//	  - url: https://api-1:8443
+//	    weight: 2
+//	    metadata: {zone: eu-west-1a}
+func LoadBackendsFile(path string) ([]BackendConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backends file: %w", err)
+	}
+
+	// JSON is valid YAML, so one parser reads both
+	var file backendsFile
+	if err := yaml.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse backends file: %w", err)
+	}
+
+	// An empty list is more likely a half-written file than a wish to drop every backend
+	if len(file.Backends) == 0 {
+		return nil, fmt.Errorf("no backends defined in %s", path)
+	}
+
+	seen := make(map[string]bool, len(file.Backends))
+	for i, backend := range file.Backends {
+		server, err := NewServer(backend.URL, backend.Weight)
+		if err != nil {
+			return nil, err
+		}
+
+		// Match servers by their URL as the load balancer prints it
+		backend.URL = server.URL.String()
+		if seen[backend.URL] {
+			return nil, fmt.Errorf("duplicate backend %s in %s", backend.URL, path)
+		}
+		seen[backend.URL] = true
+		file.Backends[i] = backend
+	}
+
+	return file.Backends, nil
+}
+
+// BackendWatcher keeps a load balancer's backends in line with a backends
+// file, reapplying it whenever the file changes. It only updates and removes
+// the backends the file listed, so servers added through the admin API stay
+// until removed there.
+type BackendWatcher struct {
+	lb   *LoadBalancer
+	path string
+
+	// URLs of the file's backends that are in the load balancer, kept in step
+	// with each add and remove so a pass that fails part way loses none
+	mu      sync.Mutex
+	managed map[string]bool
+
+	watcher  *fsnotify.Watcher
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func NewBackendWatcher(lb *LoadBalancer, path string) *BackendWatcher {
+	return &BackendWatcher{
+		lb:      lb,
+		path:    filepath.Clean(path),
+		managed: make(map[string]bool),
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// Reload applies the backends file: listed backends are added or have their
+// weight and metadata updated, and backends dropped from it are drained and
+// removed. A backend listed again while it drains is replaced by a fresh one
+// instead of being removed. An invalid file leaves the backends as they are;
+// if adding a backend fails, the changes made before it stay and are tracked.
+func (w *BackendWatcher) Reload() error {
+	configs, err := LoadBackendsFile(w.path)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	listed := make(map[string]bool, len(configs))
+	for _, config := range configs {
+		listed[config.URL] = true
+
+		server, err := w.lb.backends.get(config.URL)
+		if err != nil || server.removing.Load() {
+			// New, or dropped earlier and still draining: start a fresh server
+			if err := w.lb.AddBackend(config); err != nil {
+				return fmt.Errorf("failed to add backend %s: %w", config.URL, err)
+			}
+			w.managed[config.URL] = true
+			log.Printf("Added backend %s from %s", config.URL, w.path)
+			continue
+		}
+
+		server.SetWeight(config.Weight)
+		server.SetMetadata(config.Metadata)
+		w.managed[config.URL] = true
+	}
+
+	for serverURL := range w.managed {
+		if listed[serverURL] {
+			continue
+		}
+		// A backend already removed through the admin API is no longer ours either
+		if err := w.lb.RemoveServer(serverURL); err == nil {
+			log.Printf("Removing backend %s no longer in %s", serverURL, w.path)
+		}
+		delete(w.managed, serverURL)
+	}
+
+	return nil
+}
+
+// Start applies the backends file and then watches it for changes
+func (w *BackendWatcher) Start() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	// Watch the directory: editors and Kubernetes config maps replace the
+	// file rather than writing to it, which ends a watch on the file itself
+	if err := watcher.Add(filepath.Dir(w.path)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", w.path, err)
+	}
+
+	if err := w.Reload(); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	w.watcher = watcher
+	w.wg.Add(1)
+	go w.watch()
+	return nil
+}
+
+func (w *BackendWatcher) watch() {
+	defer w.wg.Done()
+
+	reload := time.NewTimer(reloadDelay)
+	reload.Stop()
+	defer reload.Stop()
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			// Config maps swap a ..data symlink rather than touching the file
+			if event.Name == w.path || filepath.Base(event.Name) == "..data" {
+				reload.Reset(reloadDelay)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Backends file watcher error: %v", err)
+		case <-reload.C:
+			if err := w.Reload(); err != nil {
+				log.Printf("Failed to reload backends, keeping current ones: %v", err)
+			}
+		case <-w.stopCh:
+			return
+		}
+	}
+}
+
+// Stop stops watching the backends file
+func (w *BackendWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopCh)
+		if w.watcher != nil {
+			w.watcher.Close()
+		}
+	})
+	w.wg.Wait()
+}
@@ -0,0 +1,246 @@
+package proxy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeBackends(t *testing.T, path, content string) {
+	t.Helper()
+
+	// Replace the file the way editors and config management do
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+}
+
+// waitFor polls until condition holds
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func serverURLs(lb *LoadBalancer) map[string]*Server {
+	servers := make(map[string]*Server)
+	for _, server := range lb.Servers() {
+		servers[server.URL.String()] = server
+	}
+	return servers
+}
+
+func TestLoadBackendsFile(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		content string
+		want    int
+	}{
+		{"yaml", "backends:\n  - url: https://a:8443\n    weight: 2\n    metadata: {zone: a}\n  - url: https://b:8443\n", 2},
+		{"json", `{"backends": [{"url": "https://a:8443", "weight": 3, "metadata": {"zone": "a"}}]}`, 1},
+		{"empty", "backends: []\n", 0},
+		{"invalid url", "backends:\n  - url: a:8443\n", 0},
+		{"duplicate", "backends:\n  - url: https://a:8443\n  - url: https://a:8443\n", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "backends")
+			writeBackends(t, path, tt.content)
+
+			backends, err := LoadBackendsFile(path)
+			if tt.want == 0 {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", backends)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadBackendsFile: %v", err)
+			}
+			if len(backends) != tt.want || backends[0].Weight < 2 || backends[0].Metadata["zone"] != "a" {
+				t.Fatalf("unexpected backends: %+v", backends)
+			}
+		})
+	}
+}
+
+func TestBackendWatcherReloadsOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backends.yaml")
+	writeBackends(t, path, "backends:\n  - url: https://a:8443\n    weight: 2\n  - url: https://b:8443\n")
+
+	lb := newTestLoadBalancer(t, RoundRobin, "https://a:8443")
+	watcher := NewBackendWatcher(lb, path)
+	if err := watcher.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer watcher.Stop()
+
+	servers := serverURLs(lb)
+	if len(servers) != 2 || servers["https://a:8443"].Weight() != 2 {
+		t.Fatalf("expected the file's backends and weights, got %v", lb.GetServerStats())
+	}
+
+	// Backends added by an operator survive reloads
+	if err := lb.AddServer("https://admin:8443", 1); err != nil {
+		t.Fatalf("AddServer: %v", err)
+	}
+
+	writeBackends(t, path, "backends:\n  - url: https://a:8443\n    weight: 5\n    metadata: {build: canary}\n  - url: https://c:8443\n")
+	waitFor(t, "c to be added and b removed", func() bool {
+		servers := serverURLs(lb)
+		_, added := servers["https://c:8443"]
+		_, kept := servers["https://b:8443"]
+		return added && !kept
+	})
+
+	servers = serverURLs(lb)
+	if _, ok := servers["https://admin:8443"]; !ok {
+		t.Fatal("expected the operator's backend to be kept")
+	}
+	if a := servers["https://a:8443"]; a.Weight() != 5 || a.Metadata()["build"] != "canary" {
+		t.Fatalf("expected a to be updated, got weight %d metadata %v", a.Weight(), a.Metadata())
+	}
+
+	// A broken file leaves the backends alone
+	writeBackends(t, path, "backends: [")
+	time.Sleep(5 * reloadDelay)
+	if got := len(lb.Servers()); got != 3 {
+		t.Fatalf("expected 3 backends after an invalid file, got %d", got)
+	}
+}
+
+func TestBackendWatcherTracksManagedBackends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backends.yaml")
+	writeBackends(t, path, "backends:\n  - url: https://a:8443\n  - url: https://b:8443\n")
+
+	lb := newTestLoadBalancer(t, RoundRobin, "https://a:8443")
+	watcher := NewBackendWatcher(lb, path)
+	if err := watcher.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if !watcher.managed["https://a:8443"] || !watcher.managed["https://b:8443"] {
+		t.Fatalf("expected a and b to be managed, got %v", watcher.managed)
+	}
+
+	// b is removed through the admin API, then dropped from the file
+	if err := lb.RemoveServer("https://b:8443"); err != nil {
+		t.Fatalf("RemoveServer: %v", err)
+	}
+	waitFor(t, "b to be removed", func() bool { return serverURLs(lb)["https://b:8443"] == nil })
+	writeBackends(t, path, "backends:\n  - url: https://a:8443\n")
+	if err := watcher.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if watcher.managed["https://b:8443"] || len(watcher.managed) != 1 {
+		t.Fatalf("expected only a to be managed, got %v", watcher.managed)
+	}
+
+	// Added again through the admin API, b is no longer the file's to remove
+	if err := lb.AddServer("https://b:8443", 1); err != nil {
+		t.Fatalf("AddServer: %v", err)
+	}
+	if err := watcher.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if serverURLs(lb)["https://b:8443"] == nil {
+		t.Fatal("expected the admin-added b to stay")
+	}
+}
+
+func TestDrainServer(t *testing.T) {
+	lb := newTestLoadBalancer(t, RoundRobin, "http://a:1", "http://b:1")
+	if err := lb.DrainServer("http://a:1"); err != nil {
+		t.Fatalf("DrainServer: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if got := lb.GetNextServer(nil); got.URL.Host != "b:1" {
+			t.Fatalf("expected no new requests to a draining server, got %s", got.URL)
+		}
+	}
+	if err := lb.DrainServer("http://missing:1"); err == nil {
+		t.Fatal("expected draining an unknown server to fail")
+	}
+
+	// Adding a draining server again puts a fresh one back into rotation
+	drained := serverURLs(lb)["http://a:1"]
+	if err := lb.AddBackend(BackendConfig{URL: "http://a:1", Weight: 2}); err != nil {
+		t.Fatalf("AddBackend: %v", err)
+	}
+	undrained := serverURLs(lb)["http://a:1"]
+	if undrained == drained || undrained.Draining() || undrained.Weight() != 2 || len(lb.Servers()) != 2 {
+		t.Fatalf("expected a fresh a with weight 2 in place of the drained one, got %v", lb.GetServerStats())
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		seen[lb.GetNextServer(nil).URL.Host] = true
+	}
+	if !seen["a:1"] {
+		t.Fatal("expected requests to reach a once it was added again")
+	}
+
+	// A server in rotation is not replaced
+	if err := lb.AddBackend(BackendConfig{URL: "http://b:1"}); !errors.Is(err, ErrServerExists) {
+		t.Fatalf("expected ErrServerExists, got %v", err)
+	}
+}
+
+func TestBackendWatcherReaddedBackendSurvivesDrain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backends.yaml")
+	writeBackends(t, path, "backends:\n  - url: https://a:8443\n  - url: https://b:8443\n")
+
+	lb := newTestLoadBalancer(t, RoundRobin, "https://a:8443")
+	watcher := NewBackendWatcher(lb, path)
+	if err := watcher.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	// b has a request in progress, so dropping it leaves it draining
+	old := serverURLs(lb)["https://b:8443"]
+	old.acquire()
+
+	writeBackends(t, path, "backends:\n  - url: https://a:8443\n")
+	if err := watcher.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if !old.Draining() {
+		t.Fatal("expected b to drain once dropped from the file")
+	}
+
+	// Listing b again before the drain ends replaces it with a fresh server
+	writeBackends(t, path, "backends:\n  - url: https://a:8443\n  - url: https://b:8443\n    weight: 3\n")
+	if err := watcher.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	readded := serverURLs(lb)["https://b:8443"]
+	if readded == nil || readded == old || readded.Draining() || readded.Weight() != 3 {
+		t.Fatalf("expected a fresh b with weight 3, got %+v", readded)
+	}
+
+	// The old server's pending removal must not take the new one with it
+	old.release()
+	select {
+	case <-old.drained:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the old b to drain")
+	}
+	time.Sleep(5 * drainPollInterval)
+	if serverURLs(lb)["https://b:8443"] != readded {
+		t.Fatalf("expected the re-added b to stay, got %v", lb.GetServerStats())
+	}
+}
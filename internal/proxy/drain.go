@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	// DefaultDrainTimeout bounds how long a draining backend's requests may run
+	DefaultDrainTimeout = 30 * time.Second
+
+	drainPollInterval = 50 * time.Millisecond
+
+	// minShare is where a ramp starts, so a backend can take its first requests
+	minShare = 0.05
+)
+
+// SetDrainTimeout sets how long requests to a draining backend may run
+// before they are cut off. Must be called before serving traffic.
+func (lb *LoadBalancer) SetDrainTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultDrainTimeout
+	}
+	lb.drainTimeout = timeout
+}
+
+// SetSlowStart makes backends that are added, or recover from failing health
+// checks, ramp up to their full share of traffic over window instead of
+// taking it at once. 0 disables slow start. Must be called before serving traffic.
+func (lb *LoadBalancer) SetSlowStart(window time.Duration) {
+	lb.slowStart = max(window, 0)
+}
+
+// DrainServer stops sending new requests to a backend but keeps it listed.
+// Requests in progress may run for the drain timeout, after which they are
+// cut off; idempotent ones are then retried on another backend. To put the
+// backend back into rotation, add it again (see AddBackend).
+func (lb *LoadBalancer) DrainServer(serverURL string) error {
+	server, err := lb.backends.get(serverURL)
+	if err != nil {
+		return err
+	}
+
+	lb.drain(server)
+	return nil
+}
+
+// RemoveServer drains a backend and removes it once its requests have
+// finished or been cut off. It returns without waiting. Adding the backend
+// again before then puts a fresh server in its place and cancels the removal.
+func (lb *LoadBalancer) RemoveServer(serverURL string) error {
+	server, err := lb.backends.get(serverURL)
+	if err != nil {
+		return err
+	}
+
+	server.removing.Store(true)
+	lb.drain(server)
+	go func() {
+		<-server.drained
+		if lb.backends.remove(server) {
+			log.Printf("Removed backend %s", server.URL)
+		}
+	}()
+	return nil
+}
+
+// drain takes server out of rotation and closes server.drained once it is idle
+func (lb *LoadBalancer) drain(server *Server) {
+	if !server.draining.CompareAndSwap(false, true) {
+		return
+	}
+	log.Printf("Draining backend %s with %d requests in progress", server.URL, server.Connections())
+
+	go func() {
+		defer close(server.drained)
+
+		timeout := time.NewTimer(lb.drainTimeout)
+		defer timeout.Stop()
+		ticker := time.NewTicker(drainPollInterval)
+		defer ticker.Stop()
+
+		for server.Connections() > 0 {
+			select {
+			case <-ticker.C:
+			case <-timeout.C:
+				log.Printf("Drain of backend %s timed out, cutting off %d requests", server.URL, server.Connections())
+				server.cutOff()
+				return
+			}
+		}
+		log.Printf("Backend %s drained", server.URL)
+	}()
+}
+
+// share returns the fraction of its normal traffic server should receive,
+// the lower of its outlier detection recovery and slow-start ramps
+func (lb *LoadBalancer) share(server *Server, now time.Time) float64 {
+	return min(lb.outliers.share(server, now), ramp(server.SlowStartFrom(), lb.slowStart, now))
+}
+
+// ramp returns how far now is through the window starting at start: 0
+// before it, rising linearly from minShare to 1 across it, and 1 after it
+// or if there is no window
+func ramp(start time.Time, window time.Duration, now time.Time) float64 {
+	if start.IsZero() {
+		return 1
+	}
+	if now.Before(start) {
+		return 0
+	}
+
+	elapsed := now.Sub(start)
+	if elapsed >= window {
+		return 1
+	}
+	return max(minShare, float64(elapsed)/float64(window))
+}
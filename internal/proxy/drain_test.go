@@ -0,0 +1,138 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// blockingBackend holds every request until release is closed
+func blockingBackend(t *testing.T, release chan struct{}) *httptest.Server {
+	t.Helper()
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+func TestRemoveServerDrainsRequests(t *testing.T) {
+	tests := []struct {
+		name         string
+		drainTimeout time.Duration
+		status       int
+	}{
+		{"requests finish", time.Minute, http.StatusOK},
+		{"drain times out", 100 * time.Millisecond, http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			slow := blockingBackend(t, release)
+			other, _ := newBackend(t, healthStatus(http.StatusOK))
+
+			lb := newTestLoadBalancer(t, RoundRobin, slow.URL, other.URL)
+			lb.SetDrainTimeout(tt.drainTimeout)
+			e := echo.New()
+			e.Any("/*", lb.ProxyHandler())
+
+			// Round robin sends the first request to the slow backend
+			done := make(chan int)
+			go func() {
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/payments", nil))
+				done <- rec.Code
+			}()
+			slowServer := lb.Servers()[0]
+			waitFor(t, "the request to reach the slow backend", func() bool { return slowServer.Connections() == 1 })
+
+			if err := lb.RemoveServer(slow.URL); err != nil {
+				t.Fatalf("RemoveServer: %v", err)
+			}
+			for i := 0; i < 10; i++ {
+				if got := lb.GetNextServer(nil); got == slowServer {
+					t.Fatal("expected no new requests to a draining server")
+				}
+			}
+			if stats := lb.GetServerStats(); stats["total_servers"] != 2 {
+				t.Fatalf("expected the draining server to stay listed, got %v", stats)
+			}
+
+			if tt.status == http.StatusOK {
+				close(release)
+			}
+			if code := <-done; code != tt.status {
+				t.Fatalf("expected the request in progress to end with %d, got %d", tt.status, code)
+			}
+			waitFor(t, "the drained server to be removed", func() bool { return len(lb.Servers()) == 1 })
+		})
+	}
+}
+
+func TestSlowStartRampsUpNewServer(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	lb := newTestLoadBalancer(t, RoundRobin, "http://a:1")
+	lb.SetOutlierDetection(OutlierDetectionConfig{Now: clock.Now})
+	lb.SetSlowStart(time.Minute)
+
+	if err := lb.AddServer("http://b:1", 1); err != nil {
+		t.Fatalf("AddServer: %v", err)
+	}
+	added := lb.Servers()[1]
+
+	share := func() float64 {
+		count := 0
+		for i := 0; i < 2000; i++ {
+			if lb.GetNextServer(nil) == added {
+				count++
+			}
+		}
+		return float64(count) / 2000
+	}
+
+	// Admitted half the time at the midpoint, and then given every other request
+	clock.Advance(30 * time.Second)
+	if got := share(); got < 0.15 || got > 0.35 {
+		t.Fatalf("expected about a quarter of requests halfway through slow start, got %.3f", got)
+	}
+
+	clock.Advance(30 * time.Second)
+	if got := share(); got != 0.5 {
+		t.Fatalf("expected an even split after slow start, got %.3f", got)
+	}
+}
+
+func TestRamp(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		name   string
+		start  time.Time
+		window time.Duration
+		now    time.Time
+		want   float64
+	}{
+		{"never started", time.Time{}, time.Minute, start, 1},
+		{"not yet started", start, time.Minute, start.Add(-time.Second), 0},
+		{"just started", start, time.Minute, start, minShare},
+		{"halfway", start, time.Minute, start.Add(30 * time.Second), 0.5},
+		{"finished", start, time.Minute, start.Add(time.Minute), 1},
+		{"no window", start, 0, start, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ramp(tt.start, tt.window, tt.now); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yordanos-habtamu/b2b-payments/internal/identity"
+)
+
+// issue creates a client certificate for cn, signed by parent or self-signed if parent is nil
+func issue(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	return cert, key
+}
+
+// verifiedTLS is the connection state of a client that presented cert
+func verifiedTLS(cert *x509.Certificate) *tls.ConnectionState {
+	return &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert},
+		VerifiedChains:   [][]*x509.Certificate{{cert}},
+	}
+}
+
+func TestProxyForwardsVerifiedClientCertificate(t *testing.T) {
+	ca, caKey := issue(t, "Test CA", nil, nil)
+	client, _ := issue(t, "tenant-acme.yourorg.com", ca, caKey)
+
+	var forwarded atomic.Value
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded.Store(r.Header.Get(identity.HeaderClientCert))
+	}))
+	t.Cleanup(backend.Close)
+
+	lb := newTestLoadBalancer(t, RoundRobin, backend.URL)
+	e := echo.New()
+	e.Any("/*", lb.ProxyHandler())
+
+	// A verified client's certificate replaces whatever it put in the header
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/payments", nil)
+	req.TLS = verifiedTLS(client)
+	req.Header.Set(identity.HeaderClientCert, "spoofed")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	if got := forwarded.Load(); got != identity.EncodeClientCert([]*x509.Certificate{client}) {
+		t.Fatalf("expected the verified certificate to be forwarded, got %q", got)
+	}
+
+	// Without a verified certificate the header is dropped
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/payments", nil)
+	req.Header.Set(identity.HeaderClientCert, "spoofed")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	if got := forwarded.Load(); got != "" {
+		t.Fatalf("expected no forwarded certificate, got %q", got)
+	}
+}
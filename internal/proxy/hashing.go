@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net"
+	"net/http"
+
+	"github.com/yordanos-habtamu/b2b-payments/internal/identity"
+)
+
+// HashKeySource selects what the ConsistentHash strategy keys requests by
+type HashKeySource string
+
+const (
+	HashByClientIP HashKeySource = "client_ip"
+	HashByTenant   HashKeySource = "tenant"
+	HashByHeader   HashKeySource = "header"
+)
+
+// SetHashKey configures the key the ConsistentHash strategy routes by. Tenants
+// are read from the verified client certificate; requests without the
+// configured key fall back to their client IP. Must be called before serving
+// traffic.
+func (lb *LoadBalancer) SetHashKey(source HashKeySource, header string) error {
+	switch source {
+	case HashByClientIP, HashByTenant:
+	case HashByHeader:
+		if header == "" {
+			return fmt.Errorf("a header name is required to hash by header")
+		}
+	default:
+		return fmt.Errorf("unknown hash key source %q", source)
+	}
+
+	lb.hashKeySource = source
+	lb.hashHeader = http.CanonicalHeaderKey(header)
+	return nil
+}
+
+// hashKey returns the key that decides which backend r sticks to
+func (lb *LoadBalancer) hashKey(r *http.Request) string {
+	switch lb.hashKeySource {
+	case HashByTenant:
+		if tenantID := tenantFromRequest(r); tenantID != "" {
+			return "tenant:" + tenantID
+		}
+	case HashByHeader:
+		if value := r.Header.Get(lb.hashHeader); value != "" {
+			return "header:" + value
+		}
+	}
+	return "ip:" + clientIP(r)
+}
+
+// tenantFromRequest returns the tenant of the verified client certificate
+// presented to the proxy, or "" without one
+func tenantFromRequest(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 || len(r.TLS.VerifiedChains) == 0 {
+		return ""
+	}
+
+	tenantID, err := identity.TenantIDFromCommonName(r.TLS.PeerCertificates[0].Subject.CommonName)
+	if err != nil {
+		return ""
+	}
+	return tenantID
+}
+
+// clientIP returns the address of the peer connected to the proxy. Forwarding
+// headers are ignored: they are set by the client and would let it pick its backend.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rendezvous picks a backend for key with weighted rendezvous hashing: every
+// server scores the key and the highest score wins. Adding or removing a
+// server (or a health check taking one out) only moves the keys that server
+// wins, and a server's share of keys follows its weight.
+func rendezvous(servers []*Server, key string) *Server {
+	var selected *Server
+	bestScore := math.Inf(-1)
+
+	for _, server := range servers {
+		// Map the hash into (0, 1) and scale it so higher weights win proportionally more keys
+		unit := (float64(hashPair(server.URL.String(), key)>>11) + 0.5) / (1 << 53)
+		score := float64(server.Weight()) / -math.Log(unit)
+		if score > bestScore {
+			bestScore = score
+			selected = server
+		}
+	}
+
+	return selected
+}
+
+// hashPair hashes a server and key together. FNV alone mixes poorly for
+// inputs sharing a long prefix, so its output goes through a 64-bit finaliser.
+func hashPair(server, key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(server))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
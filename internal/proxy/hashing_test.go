@@ -0,0 +1,165 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newServers(t *testing.T, n int) []*Server {
+	t.Helper()
+
+	servers := make([]*Server, n)
+	for i := range servers {
+		server, err := NewServer(fmt.Sprintf("http://backend-%d:8443", i), 1)
+		if err != nil {
+			t.Fatalf("NewServer: %v", err)
+		}
+		servers[i] = server
+	}
+	return servers
+}
+
+func assign(servers []*Server, keys int) map[string]*Server {
+	assignment := make(map[string]*Server, keys)
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("tenant:%d", i)
+		assignment[key] = rendezvous(servers, key)
+	}
+	return assignment
+}
+
+func TestRendezvousIsStableAndBalanced(t *testing.T) {
+	servers := newServers(t, 4)
+	assignment := assign(servers, 20000)
+
+	counts := make(map[*Server]int)
+	for key, server := range assignment {
+		if again := rendezvous(servers, key); again != server {
+			t.Fatalf("key %s moved from %s to %s without a change", key, server.URL, again.URL)
+		}
+		counts[server]++
+	}
+
+	for _, server := range servers {
+		if share := float64(counts[server]) / 20000; math.Abs(share-0.25) > 0.03 {
+			t.Errorf("expected about a quarter of keys on %s, got %.3f", server.URL, share)
+		}
+	}
+}
+
+func TestRendezvousFollowsWeights(t *testing.T) {
+	servers := newServers(t, 2)
+	servers[1].SetWeight(3)
+
+	counts := make(map[*Server]int)
+	for _, server := range assign(servers, 20000) {
+		counts[server]++
+	}
+
+	if share := float64(counts[servers[1]]) / 20000; math.Abs(share-0.75) > 0.03 {
+		t.Fatalf("expected about 75%% of keys on the weight-3 server, got %.3f", share)
+	}
+}
+
+func TestRendezvousMovesOnlyAffectedKeys(t *testing.T) {
+	servers := newServers(t, 5)
+	before := assign(servers[:4], 20000)
+
+	// Adding a server only moves keys to the new server
+	added := assign(servers, 20000)
+	moved := 0
+	for key, server := range added {
+		if server != before[key] {
+			moved++
+			if server != servers[4] {
+				t.Fatalf("key %s moved between existing servers", key)
+			}
+		}
+	}
+	if share := float64(moved) / 20000; math.Abs(share-0.2) > 0.03 {
+		t.Errorf("expected about a fifth of keys to move to the new server, got %.3f", share)
+	}
+
+	// Removing a server only moves the keys it owned
+	removed := assign(servers[1:4], 20000)
+	for key, server := range removed {
+		if before[key] != servers[0] && server != before[key] {
+			t.Fatalf("key %s moved although its server remained", key)
+		}
+	}
+}
+
+func TestConsistentHashKeys(t *testing.T) {
+	lb := newTestLoadBalancer(t, ConsistentHash, "http://a:1", "http://b:1", "http://c:1")
+
+	request := func(remoteAddr string, header http.Header, tenant string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/payments", nil)
+		r.RemoteAddr = remoteAddr
+		for name, values := range header {
+			r.Header[name] = values
+		}
+		if tenant != "" {
+			r.TLS = &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "tenant-" + tenant + ".yourorg.com"}}},
+				VerifiedChains:   [][]*x509.Certificate{{}},
+			}
+		}
+		return r
+	}
+
+	// Client IP by default, ignoring forwarding headers
+	r := request("203.0.113.7:5000", http.Header{"X-Forwarded-For": {"198.51.100.1"}}, "")
+	if got := lb.hashKey(r); got != "ip:203.0.113.7" {
+		t.Fatalf("expected client IP key, got %q", got)
+	}
+
+	if err := lb.SetHashKey(HashByTenant, ""); err != nil {
+		t.Fatalf("SetHashKey: %v", err)
+	}
+	if got := lb.hashKey(request("203.0.113.7:5000", nil, "acme")); got != "tenant:acme" {
+		t.Fatalf("expected tenant key, got %q", got)
+	}
+	if got := lb.hashKey(request("203.0.113.7:5000", nil, "")); got != "ip:203.0.113.7" {
+		t.Fatalf("expected fallback to client IP without a certificate, got %q", got)
+	}
+
+	// A tenant sticks to one backend whichever address it connects from
+	first := lb.GetNextServer(request("203.0.113.7:5000", nil, "acme"))
+	for i := 0; i < 20; i++ {
+		if got := lb.GetNextServer(request(fmt.Sprintf("198.51.100.%d:6000", i), nil, "acme")); got != first {
+			t.Fatalf("expected tenant to stick to %s, got %s", first.URL, got.URL)
+		}
+	}
+
+	if err := lb.SetHashKey(HashByHeader, "x-session-id"); err != nil {
+		t.Fatalf("SetHashKey: %v", err)
+	}
+	if got := lb.hashKey(request("203.0.113.7:5000", http.Header{"X-Session-Id": {"abc"}}, "")); got != "header:abc" {
+		t.Fatalf("expected header key, got %q", got)
+	}
+
+	if err := lb.SetHashKey(HashByHeader, ""); err == nil {
+		t.Fatal("expected hashing by header without a header name to fail")
+	}
+	if err := lb.SetHashKey("cookie", ""); err == nil {
+		t.Fatal("expected an unknown key source to fail")
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	for strategy := range strategyNames {
+		parsed, err := ParseStrategy(strategy.String())
+		if err != nil || parsed != strategy {
+			t.Errorf("%s: expected round trip, got %v, %v", strategy, parsed, err)
+		}
+	}
+	if _, err := ParseStrategy("random"); err == nil {
+		t.Fatal("expected an unknown strategy to fail")
+	}
+}
@@ -0,0 +1,182 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxHealthBodySize caps how much of a health check response is read to match ExpectedBody
+const maxHealthBodySize = 64 << 10
+
+// HealthCheckConfig configures active health checks
+type HealthCheckConfig struct {
+	Path               string        // default /health
+	Interval           time.Duration // default 30s
+	Timeout            time.Duration // default 5s
+	HealthyThreshold   int           // consecutive passes before an unhealthy server is used again (default 2)
+	UnhealthyThreshold int           // consecutive failures before a server is taken out (default 3)
+	ExpectedStatuses   []int         // default 200
+	ExpectedBody       string        // text the response body must contain; empty accepts any body
+}
+
+// DefaultHealthCheckConfig returns the default health check configuration
+func DefaultHealthCheckConfig() HealthCheckConfig {
+	return HealthCheckConfig{
+		Path:               "/health",
+		Interval:           30 * time.Second,
+		Timeout:            5 * time.Second,
+		HealthyThreshold:   2,
+		UnhealthyThreshold: 3,
+		ExpectedStatuses:   []int{http.StatusOK},
+	}
+}
+
+func (c HealthCheckConfig) withDefaults() HealthCheckConfig {
+	defaults := DefaultHealthCheckConfig()
+	if c.Path == "" {
+		c.Path = defaults.Path
+	}
+	if c.Interval <= 0 {
+		c.Interval = defaults.Interval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaults.Timeout
+	}
+	if c.HealthyThreshold <= 0 {
+		c.HealthyThreshold = defaults.HealthyThreshold
+	}
+	if c.UnhealthyThreshold <= 0 {
+		c.UnhealthyThreshold = defaults.UnhealthyThreshold
+	}
+	if len(c.ExpectedStatuses) == 0 {
+		c.ExpectedStatuses = defaults.ExpectedStatuses
+	}
+	return c
+}
+
+// HealthChecker polls every backend in the load balancer's set, so servers
+// added or removed at runtime are picked up on the next round. A server
+// changes state only after HealthyThreshold or UnhealthyThreshold checks in
+// a row agree, so one slow or dropped check does not flip it.
+type HealthChecker struct {
+	backends *backendSet
+	config   HealthCheckConfig
+	client   *http.Client
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func newHealthChecker(backends *backendSet, config HealthCheckConfig, transport http.RoundTripper) *HealthChecker {
+	config = config.withDefaults()
+
+	return &HealthChecker{
+		backends: backends,
+		config:   config,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   config.Timeout,
+		},
+		stopCh: make(chan struct{}),
+	}
+}
+
+func (hc *HealthChecker) Start() {
+	hc.wg.Add(1)
+	go func() {
+		defer hc.wg.Done()
+
+		ticker := time.NewTicker(hc.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				hc.checkAllServers()
+			case <-hc.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops polling and waits for checks in progress to finish
+func (hc *HealthChecker) Stop() {
+	hc.stopOnce.Do(func() { close(hc.stopCh) })
+	hc.wg.Wait()
+}
+
+func (hc *HealthChecker) checkAllServers() {
+	for _, server := range hc.backends.snapshot() {
+		hc.wg.Add(1)
+		go func(server *Server) {
+			defer hc.wg.Done()
+			hc.checkServer(server)
+		}(server)
+	}
+}
+
+func (hc *HealthChecker) checkServer(server *Server) {
+	err := hc.probe(server)
+	if err != nil {
+		server.checkPasses.Store(0)
+		failures := server.checkFailures.Add(1)
+		if failures >= int64(hc.config.UnhealthyThreshold) && server.Healthy() {
+			server.setHealthy(false)
+			log.Printf("Backend %s marked unhealthy after %d failed health checks: %v", server.URL, failures, err)
+		}
+		return
+	}
+
+	server.recordHealthCheck(time.Now())
+	server.checkFailures.Store(0)
+	passes := server.checkPasses.Add(1)
+	if passes >= int64(hc.config.HealthyThreshold) && !server.Healthy() {
+		server.startSlowStart(time.Now())
+		server.setHealthy(true)
+		log.Printf("Backend %s marked healthy after %d passed health checks", server.URL, passes)
+	}
+}
+
+// probe runs one health check, returning why it failed
+func (hc *HealthChecker) probe(server *Server) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hc.config.Timeout)
+	defer cancel()
+
+	healthURL := *server.URL
+	healthURL.Path = hc.config.Path
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if !slices.Contains(hc.config.ExpectedStatuses, resp.StatusCode) {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	if hc.config.ExpectedBody != "" {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxHealthBodySize))
+		if err != nil {
+			return fmt.Errorf("failed to read body: %w", err)
+		}
+		if !strings.Contains(string(body), hc.config.ExpectedBody) {
+			return fmt.Errorf("body does not contain %q", hc.config.ExpectedBody)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// fakeClock is a settable OutlierDetectionConfig.Now
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// checkServers runs one round of health checks and waits for it to finish
+func checkServers(hc *HealthChecker) {
+	hc.checkAllServers()
+	hc.wg.Wait()
+}
+
+func TestHealthCheckThresholds(t *testing.T) {
+	status := healthStatus(http.StatusOK)
+	backend, _ := newBackend(t, status)
+
+	lb := newTestLoadBalancer(t, RoundRobin, backend.URL)
+	lb.SetHealthCheck(HealthCheckConfig{HealthyThreshold: 2, UnhealthyThreshold: 3})
+	server := lb.Servers()[0]
+
+	status.Store(http.StatusInternalServerError)
+	for i := 1; i < 3; i++ {
+		checkServers(lb.healthChecker)
+		if !server.Healthy() {
+			t.Fatalf("expected server to stay healthy after %d failed checks", i)
+		}
+	}
+	checkServers(lb.healthChecker)
+	if server.Healthy() {
+		t.Fatal("expected server to be unhealthy after 3 failed checks")
+	}
+
+	// A pass interrupted by a failure starts the count again
+	status.Store(http.StatusOK)
+	checkServers(lb.healthChecker)
+	status.Store(http.StatusInternalServerError)
+	checkServers(lb.healthChecker)
+	status.Store(http.StatusOK)
+	checkServers(lb.healthChecker)
+	if server.Healthy() {
+		t.Fatal("expected server to need 2 consecutive passes")
+	}
+	checkServers(lb.healthChecker)
+	if !server.Healthy() {
+		t.Fatal("expected server to be healthy after 2 passed checks")
+	}
+	if server.SlowStartFrom().IsZero() {
+		t.Fatal("expected a recovered server to slow start")
+	}
+}
+
+func TestHealthCheckExpectations(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ready" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(backend.Close)
+
+	server, err := NewServer(backend.URL, 1)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	backends := newBackendSet([]*Server{server})
+
+	tests := []struct {
+		name   string
+		config HealthCheckConfig
+		pass   bool
+	}{
+		{"default path", HealthCheckConfig{}, false},
+		{"default status", HealthCheckConfig{Path: "/ready"}, false},
+		{"expected status", HealthCheckConfig{Path: "/ready", ExpectedStatuses: []int{200, 204}}, true},
+		{"expected body", HealthCheckConfig{Path: "/ready", ExpectedStatuses: []int{204}, ExpectedBody: "ok"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newHealthChecker(backends, tt.config, nil).probe(server)
+			if (err == nil) != tt.pass {
+				t.Fatalf("expected pass=%v, got %v", tt.pass, err)
+			}
+		})
+	}
+}
+
+func TestOutlierDetectionEjectsFailingBackend(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(failing.Close)
+	good, requests := newBackend(t, healthStatus(http.StatusOK))
+
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	lb := newTestLoadBalancer(t, RoundRobin, failing.URL, good.URL)
+	lb.SetOutlierDetection(OutlierDetectionConfig{
+		ConsecutiveFailures: 3,
+		BaseEjectionTime:    time.Minute,
+		RecoveryWindow:      time.Minute,
+		Now:                 clock.Now,
+	})
+
+	e := echo.New()
+	e.Any("/*", lb.ProxyHandler())
+	serve := func() {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/payments", nil))
+	}
+
+	// Round robin alternates, so 6 requests give the failing backend 3 in a row
+	for i := 0; i < 6; i++ {
+		serve()
+	}
+	failingServer := lb.Servers()[0]
+	if want := clock.Now().Add(time.Minute); !failingServer.EjectedUntil().Equal(want) {
+		t.Fatalf("expected ejection until %v, got %v", want, failingServer.EjectedUntil())
+	}
+	if stats := lb.GetServerStats(); stats["ejected_servers"] != 1 {
+		t.Fatalf("unexpected stats: %v", stats)
+	}
+
+	before := requests.Load()
+	for i := 0; i < 10; i++ {
+		serve()
+	}
+	if got := requests.Load() - before; got != 10 {
+		t.Fatalf("expected all 10 requests on the good backend while ejected, got %d", got)
+	}
+
+	// Halfway through the recovery window the backend gets about half its share
+	clock.Advance(90 * time.Second)
+	counts := make(map[*Server]int)
+	for i := 0; i < 2000; i++ {
+		counts[lb.GetNextServer(httptest.NewRequest(http.MethodGet, "/", nil))]++
+	}
+	if share := float64(counts[failingServer]) / 2000; share < 0.15 || share > 0.35 {
+		t.Fatalf("expected about a quarter of requests on the recovering backend, got %.3f", share)
+	}
+
+	clock.Advance(time.Minute)
+	if share := lb.outliers.share(failingServer, clock.Now()); share != 1 {
+		t.Fatalf("expected full share after the recovery window, got %v", share)
+	}
+
+	// Ejected again, for twice as long
+	for i := 0; i < 6; i++ {
+		serve()
+	}
+	if want := clock.Now().Add(2 * time.Minute); !failingServer.EjectedUntil().Equal(want) {
+		t.Fatalf("expected second ejection until %v, got %v", want, failingServer.EjectedUntil())
+	}
+}
+
+func TestOutlierDetectionMaxEjectedPercent(t *testing.T) {
+	servers := newServers(t, 4)
+	backends := newBackendSet(servers)
+	od := newOutlierDetector(backends, OutlierDetectionConfig{ConsecutiveFailures: 1, MaxEjectedPercent: 50})
+
+	for _, server := range servers {
+		od.observe(server, true)
+	}
+
+	now := time.Now()
+	ejected := 0
+	for _, server := range servers {
+		if od.ejected(server, now) {
+			ejected++
+		}
+	}
+	if ejected != 2 {
+		t.Fatalf("expected 2 of 4 backends ejected, got %d", ejected)
+	}
+}
+
+func TestOutlierDetectionDisabled(t *testing.T) {
+	servers := newServers(t, 1)
+	od := newOutlierDetector(newBackendSet(servers), OutlierDetectionConfig{ConsecutiveFailures: -1})
+
+	for i := 0; i < 100; i++ {
+		od.observe(servers[0], true)
+	}
+	if !servers[0].EjectedUntil().IsZero() {
+		t.Fatal("expected no ejection with outlier detection disabled")
+	}
+}
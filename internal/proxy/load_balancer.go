@@ -2,30 +2,36 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand/v2"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
-	"sync"
+	"slices"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/yordanos-habtamu/b2b-payments/internal/identity"
+	"github.com/yordanos-habtamu/b2b-payments/internal/resilience"
 )
 
 type LoadBalancer struct {
-	servers       []*Server
-	currentIndex  int
-	mu            sync.RWMutex
+	backends      *backendSet
+	next          atomic.Uint64 // round-robin position
 	healthChecker *HealthChecker
+	outliers      *outlierDetector
+	canary        *canaryRouter // nil unless SetCanary is called
+	retry         RetryConfig
+	transport     http.RoundTripper
+	drainTimeout  time.Duration
+	slowStart     time.Duration
 	strategy      LoadBalancingStrategy
-}
-
-type Server struct {
-	URL          *url.URL
-	Healthy      bool
-	Weight       int
-	Connections  int
-	LastHealthCheck time.Time
+	hashKeySource HashKeySource
+	hashHeader    string
+	limiter       resilience.Limiter
 }
 
 type LoadBalancingStrategy int
@@ -34,14 +40,37 @@ const (
 	RoundRobin LoadBalancingStrategy = iota
 	LeastConnections
 	WeightedRoundRobin
-	IPHash
+	IPHash         // sticks each client IP to a backend
+	ConsistentHash // sticks each client IP, tenant or header value to a backend (see SetHashKey)
 )
 
-type HealthChecker struct {
-	servers  []*Server
-	interval time.Duration
-	client   *http.Client
-	stopCh   chan struct{}
+var strategyNames = map[LoadBalancingStrategy]string{
+	RoundRobin:         "round_robin",
+	LeastConnections:   "least_connections",
+	WeightedRoundRobin: "weighted_round_robin",
+	IPHash:             "ip_hash",
+	ConsistentHash:     "consistent_hash",
+}
+
+func (s LoadBalancingStrategy) String() string {
+	if name, ok := strategyNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+func (s LoadBalancingStrategy) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ParseStrategy returns the strategy with the given name, e.g. "round_robin"
+func ParseStrategy(name string) (LoadBalancingStrategy, error) {
+	for strategy, strategyName := range strategyNames {
+		if strategyName == name {
+			return strategy, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown load balancing strategy %q", name)
 }
 
 func NewLoadBalancer(servers []string, strategy LoadBalancingStrategy) (*LoadBalancer, error) {
@@ -49,105 +78,86 @@ func NewLoadBalancer(servers []string, strategy LoadBalancingStrategy) (*LoadBal
 		return nil, fmt.Errorf("at least one server is required")
 	}
 
-	lb := &LoadBalancer{
-		servers:  make([]*Server, len(servers)),
-		strategy: strategy,
-	}
-
-	// Initialize servers
+	backends := make([]*Server, len(servers))
 	for i, serverURL := range servers {
-		parsedURL, err := url.Parse(serverURL)
+		server, err := NewServer(serverURL, 1)
 		if err != nil {
-			return nil, fmt.Errorf("invalid server URL %s: %w", serverURL, err)
+			return nil, err
 		}
+		backends[i] = server
+	}
 
-		lb.servers[i] = &Server{
-			URL:     parsedURL,
-			Healthy: true, // Assume healthy initially
-			Weight:  1,    // Default weight
-		}
+	lb := &LoadBalancer{
+		backends:      newBackendSet(backends),
+		strategy:      strategy,
+		hashKeySource: HashByClientIP,
+		retry:         DefaultRetryConfig(),
+		drainTimeout:  DefaultDrainTimeout,
 	}
 
-	// Initialize health checker
-	lb.healthChecker = NewHealthChecker(lb.servers, 30*time.Second)
+	// Health checks poll the same set AddServer and RemoveServer change
+	lb.healthChecker = newHealthChecker(lb.backends, DefaultHealthCheckConfig(), nil)
+	lb.outliers = newOutlierDetector(lb.backends, DefaultOutlierDetectionConfig())
 
 	return lb, nil
 }
 
-func NewHealthChecker(servers []*Server, interval time.Duration) *HealthChecker {
-	return &HealthChecker{
-		servers:  servers,
-		interval: interval,
-		client: &http.Client{
-			Timeout: 5 * time.Second,
-		},
-		stopCh: make(chan struct{}),
-	}
+// SetHealthCheck replaces the active health check configuration. Must be
+// called before StartHealthChecks.
+func (lb *LoadBalancer) SetHealthCheck(config HealthCheckConfig) {
+	lb.healthChecker = newHealthChecker(lb.backends, config, lb.transport)
 }
 
-func (lb *LoadBalancer) StartHealthChecks() {
-	lb.healthChecker.Start()
+// SetTransport sets the transport used for proxied requests and health
+// checks, e.g. to present a client certificate to https backends. Must be
+// called before StartHealthChecks.
+func (lb *LoadBalancer) SetTransport(transport http.RoundTripper) {
+	lb.transport = transport
+	lb.healthChecker.client.Transport = transport
 }
 
-func (lb *LoadBalancer) StopHealthChecks() {
-	lb.healthChecker.Stop()
+// SetOutlierDetection replaces the passive health check configuration. Must
+// be called before serving traffic.
+func (lb *LoadBalancer) SetOutlierDetection(config OutlierDetectionConfig) {
+	lb.outliers = newOutlierDetector(lb.backends, config)
 }
 
-func (hc *HealthChecker) Start() {
-	go func() {
-		ticker := time.NewTicker(hc.interval)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				hc.checkAllServers()
-			case <-hc.stopCh:
-				return
-			}
-		}
-	}()
+// SetLimiter bounds concurrent upstream calls. Requests the limiter rejects
+// get 503 with Retry-After. Must be called before serving traffic.
+func (lb *LoadBalancer) SetLimiter(limiter resilience.Limiter) {
+	lb.limiter = limiter
 }
 
-func (hc *HealthChecker) Stop() {
-	close(hc.stopCh)
+// SetRetry configures retrying idempotent requests on another backend. Must
+// be called before serving traffic.
+func (lb *LoadBalancer) SetRetry(config RetryConfig) {
+	lb.retry = config.withDefaults()
 }
 
-func (hc *HealthChecker) checkAllServers() {
-	for _, server := range hc.servers {
-		go hc.checkServer(server)
-	}
+func (lb *LoadBalancer) StartHealthChecks() {
+	lb.healthChecker.Start()
 }
 
-func (hc *HealthChecker) checkServer(server *Server) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	healthURL := *server.URL
-	healthURL.Path = "/health"
-
-	req, err := http.NewRequestWithContext(ctx, "GET", healthURL.String(), nil)
-	if err != nil {
-		server.Healthy = false
-		return
-	}
-
-	resp, err := hc.client.Do(req)
-	if err != nil {
-		server.Healthy = false
-		return
-	}
-	defer resp.Body.Close()
+func (lb *LoadBalancer) StopHealthChecks() {
+	lb.healthChecker.Stop()
+}
 
-	server.Healthy = resp.StatusCode == http.StatusOK
-	server.LastHealthCheck = time.Now()
+// Servers returns the current backends
+func (lb *LoadBalancer) Servers() []*Server {
+	return slices.Clone(lb.backends.snapshot())
 }
 
-func (lb *LoadBalancer) GetNextServer() *Server {
-	lb.mu.RLock()
-	defer lb.mu.RUnlock()
+// GetNextServer picks the backend for r, or nil if none is healthy
+func (lb *LoadBalancer) GetNextServer(r *http.Request) *Server {
+	return lb.pickServer(r, lb.routePool(r), nil)
+}
 
-	healthyServers := lb.getHealthyServers()
+// pickServer picks the backend for r among the healthy servers in pool ("" for
+// any) and not in exclude, or nil if there are none
+func (lb *LoadBalancer) pickServer(r *http.Request, pool string, exclude []*Server) *Server {
+	healthyServers := slices.DeleteFunc(lb.inPool(lb.getHealthyServers(), pool), func(server *Server) bool {
+		return slices.Contains(exclude, server)
+	})
 	if len(healthyServers) == 0 {
 		return nil
 	}
@@ -160,26 +170,45 @@ func (lb *LoadBalancer) GetNextServer() *Server {
 	case WeightedRoundRobin:
 		return lb.weightedRoundRobin(healthyServers)
 	case IPHash:
-		return lb.ipHash(healthyServers)
+		return rendezvous(healthyServers, "ip:"+clientIP(r))
+	case ConsistentHash:
+		return rendezvous(healthyServers, lb.hashKey(r))
 	default:
 		return lb.roundRobin(healthyServers)
 	}
 }
 
+// getHealthyServers returns the servers that may take r: those passing active
+// health checks, not draining and not ejected by outlier detection. A server
+// ramping up after an ejection or in slow start is included with a
+// probability that grows with its share, which scales its effective weight.
+// If every healthy server is ejected they are all used, since an overloaded
+// backend is better than none.
 func (lb *LoadBalancer) getHealthyServers() []*Server {
-	var healthy []*Server
-	for _, server := range lb.servers {
-		if server.Healthy {
-			healthy = append(healthy, server)
+	now := lb.outliers.config.Now()
+
+	var healthy, admitted []*Server
+	for _, server := range lb.backends.snapshot() {
+		if !server.Healthy() || server.Draining() {
+			continue
+		}
+		healthy = append(healthy, server)
+
+		share := lb.share(server, now)
+		if share >= 1 || (share > 0 && rand.Float64() < share) {
+			admitted = append(admitted, server)
 		}
 	}
-	return healthy
+
+	if len(admitted) == 0 {
+		return healthy
+	}
+	return admitted
 }
 
 func (lb *LoadBalancer) roundRobin(servers []*Server) *Server {
-	server := servers[lb.currentIndex%len(servers)]
-	lb.currentIndex++
-	return server
+	position := lb.next.Add(1) - 1
+	return servers[position%uint64(len(servers))]
 }
 
 func (lb *LoadBalancer) leastConnections(servers []*Server) *Server {
@@ -187,8 +216,8 @@ func (lb *LoadBalancer) leastConnections(servers []*Server) *Server {
 	minConnections := int(^uint(0) >> 1) // Max int
 
 	for _, server := range servers {
-		if server.Connections < minConnections {
-			minConnections = server.Connections
+		if connections := server.Connections(); connections < minConnections {
+			minConnections = connections
 			selected = server
 		}
 	}
@@ -197,22 +226,20 @@ func (lb *LoadBalancer) leastConnections(servers []*Server) *Server {
 }
 
 func (lb *LoadBalancer) weightedRoundRobin(servers []*Server) *Server {
+	// Weights can change between reads, so take them once
+	weights := make([]int, len(servers))
 	totalWeight := 0
-	for _, server := range servers {
-		totalWeight += server.Weight
+	for i, server := range servers {
+		weights[i] = server.Weight()
+		totalWeight += weights[i]
 	}
 
-	if totalWeight == 0 {
-		return lb.roundRobin(servers)
-	}
-
-	target := lb.currentIndex % totalWeight
+	target := int((lb.next.Add(1) - 1) % uint64(totalWeight))
 	currentWeight := 0
 
-	for _, server := range servers {
-		currentWeight += server.Weight
+	for i, server := range servers {
+		currentWeight += weights[i]
 		if target < currentWeight {
-			lb.currentIndex++
 			return server
 		}
 	}
@@ -220,118 +247,217 @@ func (lb *LoadBalancer) weightedRoundRobin(servers []*Server) *Server {
 	return servers[0]
 }
 
-func (lb *LoadBalancer) ipHash(servers []*Server) *Server {
-	// This is a simplified IP hash implementation
-	// In a real implementation, you'd use the client's IP address
-	return servers[lb.currentIndex%len(servers)]
-}
-
+// ProxyHandler proxies requests to a backend. Idempotent requests that
+// cannot reach their backend are retried on another one, up to the retry
+// configuration's limits; X-Upstream-Attempts reports how many were tried.
 func (lb *LoadBalancer) ProxyHandler() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		server := lb.GetNextServer()
+		req := c.Request()
+
+		// Retries stay in the pool the request was routed to
+		pool := lb.routePool(req)
+		server := lb.pickServer(req, pool, nil)
 		if server == nil {
 			return echo.NewHTTPError(http.StatusServiceUnavailable, "no healthy servers available")
 		}
 
-		// Increment connection count
-		server.Connections++
-		defer func() {
-			server.Connections--
-		}()
-
-		// Create reverse proxy
-		proxy := httputil.NewSingleHostReverseProxy(server.URL)
-
-		// Set up error handler
-		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-			c.Logger().Error("Proxy error", "error", err, "server", server.URL.String())
-			c.JSON(http.StatusBadGateway, map[string]string{
-				"error": "gateway error",
-			})
+		// Shed load before it reaches the upstreams
+		var upstreamErr error
+		if lb.limiter != nil {
+			done, err := lb.limiter.Acquire(req.Context())
+			if err != nil {
+				if !errors.Is(err, resilience.ErrRejected) {
+					return err
+				}
+				if retryAfter, ok := resilience.RetryAfter(err); ok {
+					c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				}
+				return echo.NewHTTPError(http.StatusServiceUnavailable, "upstream at capacity")
+			}
+			defer func() { done(upstreamErr) }()
+		}
+
+		// Replaying a request needs its body, so only buffer ones small enough to hold
+		retryable := lb.retry.MaxAttempts > 1 && isIdempotent(req)
+		var body []byte
+		if retryable {
+			var err error
+			if body, retryable, err = bufferBody(req, lb.retry.MaxBodySize); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "failed to read request body")
+			}
+		}
+		if lb.retry.Budget != nil {
+			lb.retry.Budget.Deposit()
+		}
+
+		var tried []*Server
+		for {
+			tried = append(tried, server)
+			c.Response().Header().Set(HeaderUpstreamAttempts, strconv.Itoa(len(tried)))
+
+			rewindBody(req, body)
+			upstreamErr = lb.forward(c, server)
+			if upstreamErr == nil || c.Response().Committed {
+				// The backend answered, if with an error status, and the response was relayed
+				return nil
+			}
+
+			// Stop if the client is gone, the request may not be replayed, or a limit is reached
+			if req.Context().Err() != nil || !retryable || len(tried) >= lb.retry.MaxAttempts {
+				break
+			}
+			next := lb.pickServer(req, pool, tried)
+			if next == nil {
+				break
+			}
+			if lb.retry.Budget != nil && !lb.retry.Budget.Withdraw() {
+				break
+			}
+
+			c.Logger().Warn("Retrying on another backend", "error", upstreamErr, "server", server.URL.String(), "next", next.URL.String())
+			server = next
 		}
 
-		// Modify request to include proxy headers
-		proxy.Director = func(req *http.Request) {
-			req.URL.Scheme = server.URL.Scheme
-			req.URL.Host = server.URL.Host
-			req.Host = server.URL.Host
-
-			// Add proxy headers
-			req.Header.Set("X-Forwarded-For", c.Request().RemoteAddr)
-			req.Header.Set("X-Forwarded-Proto", c.Scheme())
-			req.Header.Set("X-Forwarded-Host", c.Request().Host)
-			req.Header.Set("X-Real-IP", c.RealIP())
+		c.Logger().Error("Proxy error", "error", upstreamErr, "server", server.URL.String(), "attempts", len(tried))
+		return c.JSON(http.StatusBadGateway, map[string]string{
+			"error": "gateway error",
+		})
+	}
+}
+
+// forward proxies the request in c to server. If the backend could not be
+// reached it returns the error without writing to the client; otherwise the
+// response is relayed, and a 503 is returned as ErrServiceUnavailable for the limiter.
+func (lb *LoadBalancer) forward(c echo.Context, server *Server) error {
+	server.acquire()
+	defer server.release()
+
+	var upstreamErr error
+	var upstreamStatus int
+
+	// Feed the outcome to outlier detection and the pool's error rate. A
+	// client hanging up is not the backend's fault.
+	defer func() {
+		failed := upstreamStatus >= http.StatusInternalServerError ||
+			(upstreamErr != nil && !errors.Is(upstreamErr, context.Canceled))
+		lb.outliers.observe(server, failed)
+		if lb.canary != nil {
+			lb.canary.record(lb.canary.poolOf(server), failed)
 		}
+	}()
 
-		// Serve the request
-		proxy.ServeHTTP(c.Response(), c.Request())
+	// Create reverse proxy
+	proxy := httputil.NewSingleHostReverseProxy(server.URL)
+	proxy.Transport = lb.transport
+
+	// Leave the response to the caller, which may try another backend
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		upstreamErr = err
+	}
+
+	// An upstream reporting overload tells the limiter to back off
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		upstreamStatus = resp.StatusCode
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			upstreamErr = resilience.ErrServiceUnavailable
+		}
 		return nil
 	}
+
+	// Modify request to include proxy headers
+	proxy.Director = func(req *http.Request) {
+		req.URL.Scheme = server.URL.Scheme
+		req.URL.Host = server.URL.Host
+		req.Host = server.URL.Host
+
+		// Add proxy headers
+		req.Header.Set("X-Forwarded-For", c.Request().RemoteAddr)
+		req.Header.Set("X-Forwarded-Proto", c.Scheme())
+		req.Header.Set("X-Forwarded-Host", c.Request().Host)
+		req.Header.Set("X-Real-IP", c.RealIP())
+
+		// Pass on the client certificate verified here; never one the client supplied
+		req.Header.Del(identity.HeaderClientCert)
+		if tlsState := c.Request().TLS; tlsState != nil && len(tlsState.VerifiedChains) > 0 {
+			req.Header.Set(identity.HeaderClientCert, identity.EncodeClientCert(tlsState.PeerCertificates))
+		}
+	}
+
+	// Requests still running when a drain times out are cut off
+	ctx, cancel := context.WithCancel(c.Request().Context())
+	defer cancel()
+	stop := context.AfterFunc(server.cutOffCtx, cancel)
+	defer stop()
+
+	// Serve the request
+	proxy.ServeHTTP(c.Response(), c.Request().WithContext(ctx))
+	return upstreamErr
 }
 
 func (lb *LoadBalancer) GetServerStats() map[string]interface{} {
-	lb.mu.RLock()
-	defer lb.mu.RUnlock()
+	servers := lb.backends.snapshot()
 
 	stats := make(map[string]interface{})
-	
-	var healthyCount, unhealthyCount int
-	serverStats := make([]map[string]interface{}, len(lb.servers))
 
-	for i, server := range lb.servers {
-		if server.Healthy {
+	now := lb.outliers.config.Now()
+
+	var healthyCount, unhealthyCount, ejectedCount int
+	serverStats := make([]map[string]interface{}, len(servers))
+
+	for i, server := range servers {
+		healthy := server.Healthy()
+		if healthy {
 			healthyCount++
 		} else {
 			unhealthyCount++
 		}
 
+		ejected := lb.outliers.ejected(server, now)
+		if ejected {
+			ejectedCount++
+		}
+
 		serverStats[i] = map[string]interface{}{
-			"url":        server.URL.String(),
-			"healthy":    server.Healthy,
-			"weight":     server.Weight,
-			"connections": server.Connections,
-			"last_check": server.LastHealthCheck,
+			"url":              server.URL.String(),
+			"metadata":         server.Metadata(),
+			"healthy":          healthy,
+			"draining":         server.Draining(),
+			"ejected":          ejected,
+			"ejected_until":    server.EjectedUntil(),
+			"weight":           server.Weight(),
+			"effective_weight": float64(server.Weight()) * lb.share(server, now),
+			"connections":      server.Connections(),
+			"last_check":       server.LastHealthCheck(),
 		}
 	}
 
-	stats["total_servers"] = len(lb.servers)
+	stats["total_servers"] = len(servers)
 	stats["healthy_servers"] = healthyCount
 	stats["unhealthy_servers"] = unhealthyCount
-	stats["strategy"] = lb.strategy
+	stats["ejected_servers"] = ejectedCount
+	stats["strategy"] = lb.strategy.String()
+	if lb.canary != nil {
+		stats["canary"] = lb.canary.stats(servers)
+	}
 	stats["servers"] = serverStats
 
 	return stats
 }
 
 func (lb *LoadBalancer) AddServer(serverURL string, weight int) error {
-	parsedURL, err := url.Parse(serverURL)
-	if err != nil {
-		return fmt.Errorf("invalid server URL %s: %w", serverURL, err)
-	}
-
-	lb.mu.Lock()
-	defer lb.mu.Unlock()
-
-	newServer := &Server{
-		URL:     parsedURL,
-		Healthy: true,
-		Weight:  weight,
-	}
-
-	lb.servers = append(lb.servers, newServer)
-	return nil
+	return lb.AddBackend(BackendConfig{URL: serverURL, Weight: weight})
 }
 
-func (lb *LoadBalancer) RemoveServer(serverURL string) error {
-	lb.mu.Lock()
-	defer lb.mu.Unlock()
-
-	for i, server := range lb.servers {
-		if server.URL.String() == serverURL {
-			lb.servers = append(lb.servers[:i], lb.servers[i+1:]...)
-			return nil
-		}
+// AddBackend adds a backend with the weight and metadata in config. A
+// backend with the same URL that is draining, whether or not it is being
+// removed, is replaced by a fresh one that ramps up like any added backend.
+func (lb *LoadBalancer) AddBackend(config BackendConfig) error {
+	server, err := NewServer(config.URL, config.Weight)
+	if err != nil {
+		return err
 	}
+	server.SetMetadata(config.Metadata)
+	server.startSlowStart(lb.outliers.config.Now())
 
-	return fmt.Errorf("server %s not found", serverURL)
+	return lb.backends.add(server)
 }
@@ -0,0 +1,254 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// newBackend starts a backend that counts proxied requests and answers
+// health checks with healthStatus
+func newBackend(t *testing.T, healthStatus *atomic.Int32) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+
+	var requests atomic.Int64
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(int(healthStatus.Load()))
+			return
+		}
+		requests.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(backend.Close)
+	return backend, &requests
+}
+
+func healthStatus(code int) *atomic.Int32 {
+	status := &atomic.Int32{}
+	status.Store(int32(code))
+	return status
+}
+
+func newTestLoadBalancer(t *testing.T, strategy LoadBalancingStrategy, servers ...string) *LoadBalancer {
+	t.Helper()
+
+	lb, err := NewLoadBalancer(servers, strategy)
+	if err != nil {
+		t.Fatalf("NewLoadBalancer: %v", err)
+	}
+	return lb
+}
+
+func TestRoundRobinSpreadsEvenly(t *testing.T) {
+	lb := newTestLoadBalancer(t, RoundRobin, "http://a:1", "http://b:1", "http://c:1")
+
+	counts := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		counts[lb.GetNextServer(httptest.NewRequest(http.MethodGet, "/", nil)).URL.Host]++
+	}
+
+	for host, count := range counts {
+		if count != 100 {
+			t.Errorf("expected 100 requests to %s, got %d", host, count)
+		}
+	}
+}
+
+func TestWeightedRoundRobinFollowsWeights(t *testing.T) {
+	lb := newTestLoadBalancer(t, WeightedRoundRobin, "http://a:1")
+	if err := lb.AddServer("http://b:1", 3); err != nil {
+		t.Fatalf("AddServer: %v", err)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 400; i++ {
+		counts[lb.GetNextServer(httptest.NewRequest(http.MethodGet, "/", nil)).URL.Host]++
+	}
+
+	if counts["a:1"] != 100 || counts["b:1"] != 300 {
+		t.Fatalf("expected a 1:3 split, got %v", counts)
+	}
+}
+
+func TestLeastConnectionsPicksIdlestServer(t *testing.T) {
+	lb := newTestLoadBalancer(t, LeastConnections, "http://a:1", "http://b:1")
+	servers := lb.Servers()
+
+	servers[0].acquire()
+	defer servers[0].release()
+
+	if got := lb.GetNextServer(httptest.NewRequest(http.MethodGet, "/", nil)); got != servers[1] {
+		t.Fatalf("expected idle server b, got %s", got.URL)
+	}
+}
+
+func TestAddAndRemoveServer(t *testing.T) {
+	lb := newTestLoadBalancer(t, RoundRobin, "http://a:1")
+
+	if err := lb.AddServer("http://b:1", 1); err != nil {
+		t.Fatalf("AddServer: %v", err)
+	}
+	if err := lb.AddServer("http://b:1", 1); err == nil {
+		t.Fatal("expected duplicate AddServer to fail")
+	}
+	if err := lb.AddServer("not a url", 1); err == nil {
+		t.Fatal("expected invalid URL to fail")
+	}
+
+	snapshot := lb.backends.snapshot()
+	if err := lb.RemoveServer("http://a:1"); err != nil {
+		t.Fatalf("RemoveServer: %v", err)
+	}
+	waitFor(t, "the idle server to be removed", func() bool { return len(lb.Servers()) == 1 })
+	if err := lb.RemoveServer("http://a:1"); err == nil {
+		t.Fatal("expected removing a missing server to fail")
+	}
+
+	if len(snapshot) != 2 || snapshot[0].URL.Host != "a:1" {
+		t.Fatal("expected RemoveServer to leave earlier snapshots untouched")
+	}
+	if servers := lb.Servers(); len(servers) != 1 || servers[0].URL.Host != "b:1" {
+		t.Fatalf("expected only b to remain, got %d servers", len(servers))
+	}
+}
+
+func TestHealthCheckTakesServerOutOfRotation(t *testing.T) {
+	healthy, _ := newBackend(t, healthStatus(http.StatusOK))
+	sickStatus := healthStatus(http.StatusInternalServerError)
+	sick, _ := newBackend(t, sickStatus)
+
+	lb := newTestLoadBalancer(t, RoundRobin, healthy.URL, sick.URL)
+	lb.SetHealthCheck(HealthCheckConfig{UnhealthyThreshold: 1})
+	lb.healthChecker.checkAllServers()
+	lb.healthChecker.Stop()
+
+	for i := 0; i < 10; i++ {
+		if got := lb.GetNextServer(httptest.NewRequest(http.MethodGet, "/", nil)); got.URL.String() != healthy.URL {
+			t.Fatalf("expected only the healthy server, got %s", got.URL)
+		}
+	}
+
+	stats := lb.GetServerStats()
+	if stats["healthy_servers"] != 1 || stats["unhealthy_servers"] != 1 {
+		t.Fatalf("unexpected stats: %v", stats)
+	}
+}
+
+func TestNoHealthyServers(t *testing.T) {
+	sick, _ := newBackend(t, healthStatus(http.StatusServiceUnavailable))
+
+	lb := newTestLoadBalancer(t, RoundRobin, sick.URL)
+	lb.SetHealthCheck(HealthCheckConfig{UnhealthyThreshold: 1})
+	lb.healthChecker.checkAllServers()
+	lb.healthChecker.Stop()
+
+	e := echo.New()
+	e.Any("/*", lb.ProxyHandler())
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/payments", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+}
+
+// TestLoadBalancerConcurrentUse proxies traffic while health checks run and
+// backends are added and removed. Run with -race.
+func TestLoadBalancerConcurrentUse(t *testing.T) {
+	var backendURLs []string
+	var total []*atomic.Int64
+	for i := 0; i < 3; i++ {
+		backend, requests := newBackend(t, healthStatus(http.StatusOK))
+		backendURLs = append(backendURLs, backend.URL)
+		total = append(total, requests)
+	}
+	extra, extraRequests := newBackend(t, healthStatus(http.StatusOK))
+	total = append(total, extraRequests)
+
+	for _, strategy := range []LoadBalancingStrategy{RoundRobin, LeastConnections, WeightedRoundRobin, IPHash, ConsistentHash} {
+		t.Run(strategy.String(), func(t *testing.T) {
+			lb := newTestLoadBalancer(t, strategy, backendURLs...)
+			e := echo.New()
+			e.Any("/*", lb.ProxyHandler())
+
+			const clients, requestsPerClient = 8, 50
+			var wg sync.WaitGroup
+			var failed atomic.Int64
+			stop := make(chan struct{})
+
+			for i := 0; i < clients; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < requestsPerClient; j++ {
+						rec := httptest.NewRecorder()
+						e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/payments", nil))
+						if rec.Code != http.StatusOK {
+							failed.Add(1)
+						}
+					}
+				}()
+			}
+
+			var background sync.WaitGroup
+			background.Add(2)
+			go func() {
+				defer background.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					lb.AddServer(extra.URL, 2)
+					lb.GetServerStats()
+					lb.RemoveServer(extra.URL)
+					time.Sleep(time.Millisecond)
+				}
+			}()
+			go func() {
+				defer background.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					lb.healthChecker.checkAllServers()
+					for _, server := range lb.Servers() {
+						server.SetWeight(server.Weight())
+					}
+					time.Sleep(5 * time.Millisecond)
+				}
+			}()
+
+			wg.Wait()
+			close(stop)
+			background.Wait()
+			lb.StopHealthChecks()
+
+			if n := failed.Load(); n != 0 {
+				t.Fatalf("%d requests failed", n)
+			}
+			for _, server := range lb.Servers() {
+				if n := server.Connections(); n != 0 {
+					t.Errorf("expected no open connections to %s, got %d", server.URL, n)
+				}
+			}
+		})
+	}
+
+	var proxied int64
+	for _, requests := range total {
+		proxied += requests.Load()
+	}
+	if proxied != 5*8*50 {
+		t.Fatalf("expected %d proxied requests, got %d", 5*8*50, proxied)
+	}
+}
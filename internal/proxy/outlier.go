@@ -0,0 +1,149 @@
+package proxy
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// OutlierDetectionConfig configures passive health checking: backends that
+// fail live traffic are ejected without waiting for the next active check
+type OutlierDetectionConfig struct {
+	// ConsecutiveFailures is how many 5xx responses or connection errors in a
+	// row eject a backend (default 5). Negative disables outlier detection.
+	ConsecutiveFailures int
+
+	// BaseEjectionTime is how long a first ejection lasts; each repeat
+	// ejection lasts one more multiple of it, up to MaxEjectionTime
+	BaseEjectionTime time.Duration // default 30s
+	MaxEjectionTime  time.Duration // default 5m
+
+	// RecoveryWindow is how long a backend's traffic takes to ramp back to
+	// its full share once its ejection ends (default 30s)
+	RecoveryWindow time.Duration
+
+	// MaxEjectedPercent caps the share of backends ejected at once (default 50)
+	MaxEjectedPercent int
+
+	// Now replaces time.Now, for tests
+	Now func() time.Time
+}
+
+// DefaultOutlierDetectionConfig returns the default outlier detection configuration
+func DefaultOutlierDetectionConfig() OutlierDetectionConfig {
+	return OutlierDetectionConfig{
+		ConsecutiveFailures: 5,
+		BaseEjectionTime:    30 * time.Second,
+		MaxEjectionTime:     5 * time.Minute,
+		RecoveryWindow:      30 * time.Second,
+		MaxEjectedPercent:   50,
+	}
+}
+
+func (c OutlierDetectionConfig) withDefaults() OutlierDetectionConfig {
+	defaults := DefaultOutlierDetectionConfig()
+	if c.ConsecutiveFailures == 0 {
+		c.ConsecutiveFailures = defaults.ConsecutiveFailures
+	}
+	if c.BaseEjectionTime <= 0 {
+		c.BaseEjectionTime = defaults.BaseEjectionTime
+	}
+	if c.MaxEjectionTime < c.BaseEjectionTime {
+		c.MaxEjectionTime = max(defaults.MaxEjectionTime, c.BaseEjectionTime)
+	}
+	if c.RecoveryWindow < 0 {
+		c.RecoveryWindow = 0
+	}
+	if c.MaxEjectedPercent <= 0 || c.MaxEjectedPercent > 100 {
+		c.MaxEjectedPercent = defaults.MaxEjectedPercent
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+	return c
+}
+
+// outlierDetector ejects backends that fail live traffic and ramps them
+// back in once the ejection ends
+type outlierDetector struct {
+	backends *backendSet
+	config   OutlierDetectionConfig
+
+	// Serialises ejections so the MaxEjectedPercent check cannot be raced
+	mu sync.Mutex
+}
+
+func newOutlierDetector(backends *backendSet, config OutlierDetectionConfig) *outlierDetector {
+	return &outlierDetector{
+		backends: backends,
+		config:   config.withDefaults(),
+	}
+}
+
+func (od *outlierDetector) enabled() bool {
+	return od.config.ConsecutiveFailures > 0
+}
+
+// observe records the outcome of a request proxied to server
+func (od *outlierDetector) observe(server *Server, failed bool) {
+	if !od.enabled() {
+		return
+	}
+	now := od.config.Now()
+
+	if !failed {
+		server.passiveFailures.Store(0)
+		// A backend that has stayed in since its last ejection starts over at the base ejection time
+		if server.ejections.Load() > 0 && now.After(server.EjectedUntil().Add(od.config.MaxEjectionTime)) {
+			server.ejections.Store(0)
+		}
+		return
+	}
+
+	if server.passiveFailures.Add(1) < int64(od.config.ConsecutiveFailures) {
+		return
+	}
+	od.eject(server, now)
+}
+
+func (od *outlierDetector) eject(server *Server, now time.Time) {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	if od.ejected(server, now) {
+		return
+	}
+
+	servers := od.backends.snapshot()
+	ejected := 0
+	for _, other := range servers {
+		if od.ejected(other, now) {
+			ejected++
+		}
+	}
+	if (ejected+1)*100 > len(servers)*od.config.MaxEjectedPercent {
+		log.Printf("Not ejecting backend %s: %d of %d backends already ejected", server.URL, ejected, len(servers))
+		return
+	}
+
+	ejections := server.ejections.Add(1)
+	duration := min(od.config.BaseEjectionTime*time.Duration(ejections), od.config.MaxEjectionTime)
+	server.ejectedUntil.Store(now.Add(duration).UnixNano())
+	server.passiveFailures.Store(0)
+
+	log.Printf("Ejected backend %s for %s after %d consecutive failures", server.URL, duration, od.config.ConsecutiveFailures)
+}
+
+func (od *outlierDetector) ejected(server *Server, now time.Time) bool {
+	return now.Before(server.EjectedUntil())
+}
+
+// share returns the fraction of its normal traffic server should receive:
+// 0 while ejected, rising linearly over RecoveryWindow once the ejection
+// ends, then 1
+func (od *outlierDetector) share(server *Server, now time.Time) float64 {
+	if !od.enabled() {
+		return 1
+	}
+	return ramp(server.EjectedUntil(), od.config.RecoveryWindow, now)
+}
@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/yordanos-habtamu/b2b-payments/internal/resilience"
+)
+
+// HeaderUpstreamAttempts reports how many backends the proxy tried for a request
+const HeaderUpstreamAttempts = "X-Upstream-Attempts"
+
+// RetryConfig configures retrying requests on another backend when the
+// chosen one cannot be reached
+type RetryConfig struct {
+	// MaxAttempts bounds the backends tried per request, including the first
+	// (default 3). 1 disables retries.
+	MaxAttempts int
+
+	// MaxBodySize is the largest request body buffered for replay (default
+	// 1 MiB). Requests with larger bodies are sent once.
+	MaxBodySize int64
+
+	// Budget, if set, caps retries across all requests so a wholesale outage
+	// does not multiply upstream load
+	Budget *resilience.RetryBudget
+}
+
+// DefaultRetryConfig returns the default proxy retry configuration
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts: 3,
+		MaxBodySize: 1 << 20,
+	}
+}
+
+func (c RetryConfig) withDefaults() RetryConfig {
+	defaults := DefaultRetryConfig()
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaults.MaxAttempts
+	}
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = defaults.MaxBodySize
+	}
+	return c
+}
+
+// isIdempotent reports whether r can safely be sent to a second backend
+// after the first failed: safe methods, and requests carrying an
+// Idempotency-Key, which the API deduplicates
+func isIdempotent(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return r.Header.Get("Idempotency-Key") != ""
+}
+
+// bufferBody reads r's body into memory so it can be replayed. It returns
+// false, leaving the body readable from the start, if the body is larger
+// than limit.
+func bufferBody(r *http.Request, limit int64) ([]byte, bool, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true, nil
+	}
+	if r.ContentLength > limit {
+		return nil, false, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(body)) > limit {
+		// Put back what was read in front of the rest
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return nil, false, nil
+	}
+
+	r.Body.Close()
+	return body, true, nil
+}
+
+// rewindBody resets r to send body from the start
+func rewindBody(r *http.Request, body []byte) {
+	if body == nil {
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+}
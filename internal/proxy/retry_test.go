@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yordanos-habtamu/b2b-payments/internal/resilience"
+)
+
+// deadBackend returns the URL of a server that refuses connections
+func deadBackend(t *testing.T) string {
+	t.Helper()
+
+	backend := httptest.NewServer(http.NotFoundHandler())
+	backend.Close()
+	return backend.URL
+}
+
+// echoBackend answers every request with its body
+func echoBackend(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+func newRetryProxy(t *testing.T, config RetryConfig, servers ...string) *echo.Echo {
+	t.Helper()
+
+	lb := newTestLoadBalancer(t, RoundRobin, servers...)
+	lb.SetOutlierDetection(OutlierDetectionConfig{ConsecutiveFailures: -1})
+	lb.SetRetry(config)
+
+	e := echo.New()
+	e.Any("/*", lb.ProxyHandler())
+	return e
+}
+
+func TestProxyRetriesOnAnotherBackend(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		key      string
+		status   int
+		attempts string
+	}{
+		{"GET", http.MethodGet, "", http.StatusOK, "2"},
+		{"POST with Idempotency-Key", http.MethodPost, "key-1", http.StatusOK, "2"},
+		{"POST without Idempotency-Key", http.MethodPost, "", http.StatusBadGateway, "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Round robin starts on the dead backend
+			e := newRetryProxy(t, RetryConfig{}, deadBackend(t), echoBackend(t).URL)
+
+			req := httptest.NewRequest(tt.method, "/api/v1/payments", strings.NewReader(`{"amount":100}`))
+			if tt.key != "" {
+				req.Header.Set("Idempotency-Key", tt.key)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, rec.Code)
+			}
+			if got := rec.Header().Get(HeaderUpstreamAttempts); got != tt.attempts {
+				t.Fatalf("expected %s attempts, got %s", tt.attempts, got)
+			}
+			if tt.status == http.StatusOK && rec.Body.String() != `{"amount":100}` {
+				t.Fatalf("expected the body to be replayed, got %q", rec.Body.String())
+			}
+		})
+	}
+}
+
+type retryMetrics struct {
+	attempts, exhausted map[string]int
+}
+
+func (m *retryMetrics) RecordRetryAttempt(dependency string) {
+	if m.attempts == nil {
+		m.attempts = make(map[string]int)
+	}
+	m.attempts[dependency]++
+}
+
+func (m *retryMetrics) RecordRetryBudgetExhausted(dependency string) {
+	if m.exhausted == nil {
+		m.exhausted = make(map[string]int)
+	}
+	m.exhausted[dependency]++
+}
+
+func TestProxyRetryLimits(t *testing.T) {
+	t.Run("attempts", func(t *testing.T) {
+		e := newRetryProxy(t, RetryConfig{MaxAttempts: 2}, deadBackend(t), deadBackend(t), deadBackend(t))
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusBadGateway || rec.Header().Get(HeaderUpstreamAttempts) != "2" {
+			t.Fatalf("expected 502 after 2 attempts, got %d after %s", rec.Code, rec.Header().Get(HeaderUpstreamAttempts))
+		}
+	})
+
+	t.Run("backends", func(t *testing.T) {
+		e := newRetryProxy(t, RetryConfig{MaxAttempts: 5}, deadBackend(t), deadBackend(t))
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusBadGateway || rec.Header().Get(HeaderUpstreamAttempts) != "2" {
+			t.Fatalf("expected each backend to be tried once, got %d after %s", rec.Code, rec.Header().Get(HeaderUpstreamAttempts))
+		}
+	})
+
+	t.Run("body size", func(t *testing.T) {
+		e := newRetryProxy(t, RetryConfig{MaxBodySize: 4}, deadBackend(t), echoBackend(t).URL)
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/", strings.NewReader("too large")))
+		if rec.Code != http.StatusBadGateway || rec.Header().Get(HeaderUpstreamAttempts) != "1" {
+			t.Fatalf("expected a large body not to be retried, got %d after %s", rec.Code, rec.Header().Get(HeaderUpstreamAttempts))
+		}
+	})
+
+	t.Run("budget", func(t *testing.T) {
+		budget := resilience.NewRetryBudget(resilience.RetryBudgetConfig{Name: "proxy", Ratio: 0.01, MaxTokens: 1})
+		metrics := &retryMetrics{}
+		budget.SetMetrics(metrics)
+		e := newRetryProxy(t, RetryConfig{Budget: budget}, deadBackend(t), deadBackend(t), deadBackend(t))
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Header().Get(HeaderUpstreamAttempts) != "2" {
+			t.Fatalf("expected the budget to allow one retry, got %s attempts", rec.Header().Get(HeaderUpstreamAttempts))
+		}
+		if stats := budget.Stats(); stats.Exhausted != 1 {
+			t.Fatalf("expected the budget to refuse the second retry, got %+v", stats)
+		}
+		if metrics.attempts["proxy"] != 1 || metrics.exhausted["proxy"] != 1 {
+			t.Fatalf("expected 1 retry and 1 exhaustion to be recorded, got %+v", *metrics)
+		}
+	})
+}
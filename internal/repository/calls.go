@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/yordanos-habtamu/b2b-payments/internal/resilience"
+)
+
+// callPolicy admits each database call through the dependency's limiter,
+// bounds it by the dependency's deadline and hedges idempotent reads, which
+// are retried within the dependency's retry budget. A zero callPolicy runs
+// calls unchanged.
+type callPolicy struct {
+	limiter  resilience.Limiter
+	deadline *resilience.Deadline
+	hedger   *resilience.Hedger
+	budget   *resilience.RetryBudget
+}
+
+// read runs an idempotent query, hedging it if it runs slow. A hedged read
+// holds a single limiter slot; each retry is admitted and bounded afresh.
+func read[T any](ctx context.Context, p callPolicy, fn func(context.Context) (T, error)) (T, error) {
+	attempt := func(ctx context.Context) (T, error) {
+		return resilience.LimitWithResult(ctx, p.limiter, func(ctx context.Context) (T, error) {
+			return resilience.ExecuteWithDeadline(ctx, p.deadline, func(ctx context.Context) (T, error) {
+				return resilience.Hedge(ctx, p.hedger, fn)
+			})
+		})
+	}
+	if p.budget == nil {
+		return attempt(ctx)
+	}
+	return resilience.RetryWithContextAndResult(ctx, attempt, readRetryConfig(p.budget))
+}
+
+// readRetryConfig retries a read a couple of times with short backoff, since
+// the caller is usually waiting on it
+func readRetryConfig(budget *resilience.RetryBudget) resilience.RetryConfig {
+	config := resilience.DefaultRetryConfig()
+	config.InitialDelay = 20 * time.Millisecond
+	config.MaxDelay = 200 * time.Millisecond
+	config.Budget = budget
+	return config
+}
+
+// write runs a statement with side effects, which is never hedged
+func write(ctx context.Context, p callPolicy, fn func(context.Context) error) error {
+	return resilience.Limit(ctx, p.limiter, func(ctx context.Context) error {
+		return p.deadline.Execute(ctx, fn)
+	})
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yordanos-habtamu/b2b-payments/internal/resilience"
+)
+
+type retryMetrics struct {
+	attempts, exhausted int
+}
+
+func (m *retryMetrics) RecordRetryAttempt(string)         { m.attempts++ }
+func (m *retryMetrics) RecordRetryBudgetExhausted(string) { m.exhausted++ }
+
+func TestReadRetriesWithinBudget(t *testing.T) {
+	budget := resilience.NewRetryBudget(resilience.RetryBudgetConfig{Name: "postgres", MaxTokens: 5})
+	metrics := &retryMetrics{}
+	budget.SetMetrics(metrics)
+	p := callPolicy{budget: budget}
+
+	calls := 0
+	got, err := read(context.Background(), p, func(ctx context.Context) (int, error) {
+		calls++
+		if calls == 1 {
+			return 0, resilience.ErrServiceUnavailable
+		}
+		return 42, nil
+	})
+	if err != nil || got != 42 {
+		t.Fatalf("read = %d, %v, want 42 after one retry", got, err)
+	}
+	if metrics.attempts != 1 || metrics.exhausted != 0 {
+		t.Errorf("metrics = %+v, want 1 retry", *metrics)
+	}
+
+	// Writes are never retried
+	calls = 0
+	err = write(context.Background(), p, func(ctx context.Context) error {
+		calls++
+		return resilience.ErrServiceUnavailable
+	})
+	if err == nil || calls != 1 || metrics.attempts != 1 {
+		t.Errorf("write made %d calls and %d retries, want 1 call and no retry", calls, metrics.attempts)
+	}
+}
@@ -4,10 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yordanos-habtamu/b2b-payments/internal/resilience"
 	"github.com/yordanos-habtamu/b2b-payments/internal/service"
 )
 
@@ -15,29 +16,87 @@ type PaymentRepository interface {
 	Create(ctx context.Context, payment *service.Payment) error
 	GetByID(ctx context.Context, tenantID, paymentID string) (*service.Payment, error)
 	Update(ctx context.Context, payment *service.Payment) error
+	UpdateStatus(ctx context.Context, payment *service.Payment, expected service.PaymentStatus) error
 	List(ctx context.Context, tenantID string, filter *service.PaymentFilter) ([]*service.Payment, int64, error)
 	GetStats(ctx context.Context, tenantID string) (*service.PaymentStats, error)
 	Delete(ctx context.Context, tenantID, paymentID string) error
 }
 
 type paymentRepository struct {
-	db *pgxpool.Pool
+	db    *pgxpool.Pool
+	calls callPolicy
 }
 
-func NewPaymentRepository(db *pgxpool.Pool) PaymentRepository {
+// NewPaymentRepository returns a payment repository whose calls are admitted
+// by limiter and bounded by deadline, and whose reads are hedged by hedger and
+// retried within budget. Any of them may be nil.
+func NewPaymentRepository(db *pgxpool.Pool, limiter resilience.Limiter, deadline *resilience.Deadline, hedger *resilience.Hedger, budget *resilience.RetryBudget) PaymentRepository {
 	return &paymentRepository{
-		db: db,
+		db:    db,
+		calls: callPolicy{limiter: limiter, deadline: deadline, hedger: hedger, budget: budget},
 	}
 }
 
 func (r *paymentRepository) Create(ctx context.Context, payment *service.Payment) error {
+	return write(ctx, r.calls, func(ctx context.Context) error {
+		return r.create(ctx, payment)
+	})
+}
+
+func (r *paymentRepository) GetByID(ctx context.Context, tenantID, paymentID string) (*service.Payment, error) {
+	return read(ctx, r.calls, func(ctx context.Context) (*service.Payment, error) {
+		return r.getByID(ctx, tenantID, paymentID)
+	})
+}
+
+func (r *paymentRepository) Update(ctx context.Context, payment *service.Payment) error {
+	return write(ctx, r.calls, func(ctx context.Context) error {
+		return r.update(ctx, payment)
+	})
+}
+
+// UpdateStatus writes payment only if its stored status is still expected,
+// returning service.ErrPaymentConflict if another transition got there first
+func (r *paymentRepository) UpdateStatus(ctx context.Context, payment *service.Payment, expected service.PaymentStatus) error {
+	return write(ctx, r.calls, func(ctx context.Context) error {
+		return r.updateStatus(ctx, payment, expected)
+	})
+}
+
+func (r *paymentRepository) List(ctx context.Context, tenantID string, filter *service.PaymentFilter) ([]*service.Payment, int64, error) {
+	type page struct {
+		payments []*service.Payment
+		total    int64
+	}
+
+	result, err := read(ctx, r.calls, func(ctx context.Context) (page, error) {
+		payments, total, err := r.list(ctx, tenantID, filter)
+		return page{payments: payments, total: total}, err
+	})
+	return result.payments, result.total, err
+}
+
+func (r *paymentRepository) GetStats(ctx context.Context, tenantID string) (*service.PaymentStats, error) {
+	return read(ctx, r.calls, func(ctx context.Context) (*service.PaymentStats, error) {
+		return r.getStats(ctx, tenantID)
+	})
+}
+
+func (r *paymentRepository) Delete(ctx context.Context, tenantID, paymentID string) error {
+	return write(ctx, r.calls, func(ctx context.Context) error {
+		return r.delete(ctx, tenantID, paymentID)
+	})
+}
+
+func (r *paymentRepository) create(ctx context.Context, payment *service.Payment) error {
 	query := `
 		INSERT INTO payments (
 			id, tenant_id, amount, currency, type, status, description,
 			reference, source_account, destination_account, metadata,
-			created_at, updated_at, processed_at, completed_at, failed_at, failure_reason
+			created_at, updated_at, processed_at, completed_at, failed_at, failure_reason,
+			retry_count
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
+			$1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, $11, $12, $13, $14, $15, $16, NULLIF($17, ''), $18
 		)`
 
 	_, err := r.db.Exec(ctx, query,
@@ -58,16 +117,18 @@ func (r *paymentRepository) Create(ctx context.Context, payment *service.Payment
 		payment.CompletedAt,
 		payment.FailedAt,
 		payment.FailureReason,
+		payment.RetryCount,
 	)
 
 	return err
 }
 
-func (r *paymentRepository) GetByID(ctx context.Context, tenantID, paymentID string) (*service.Payment, error) {
+func (r *paymentRepository) getByID(ctx context.Context, tenantID, paymentID string) (*service.Payment, error) {
 	query := `
 		SELECT id, tenant_id, amount, currency, type, status, description,
-			   reference, source_account, destination_account, metadata,
-			   created_at, updated_at, processed_at, completed_at, failed_at, failure_reason
+			   COALESCE(reference, ''), source_account, destination_account, metadata,
+			   created_at, updated_at, processed_at, completed_at, failed_at, COALESCE(failure_reason, ''),
+			   retry_count
 		FROM payments
 		WHERE id = $1 AND tenant_id = $2`
 
@@ -92,6 +153,7 @@ func (r *paymentRepository) GetByID(ctx context.Context, tenantID, paymentID str
 		&payment.CompletedAt,
 		&payment.FailedAt,
 		&payment.FailureReason,
+		&payment.RetryCount,
 	)
 
 	if err != nil {
@@ -108,15 +170,42 @@ func (r *paymentRepository) GetByID(ctx context.Context, tenantID, paymentID str
 	return &payment, nil
 }
 
-func (r *paymentRepository) Update(ctx context.Context, payment *service.Payment) error {
-	query := `
+func (r *paymentRepository) update(ctx context.Context, payment *service.Payment) error {
+	result, err := r.execUpdate(ctx, updatePaymentQuery+" WHERE id = $1 AND tenant_id = $2", payment)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("payment not found")
+	}
+
+	return nil
+}
+
+func (r *paymentRepository) updateStatus(ctx context.Context, payment *service.Payment, expected service.PaymentStatus) error {
+	result, err := r.execUpdate(ctx, updatePaymentQuery+" WHERE id = $1 AND tenant_id = $2 AND status = $18", payment, expected)
+	if err != nil {
+		return err
+	}
+
+	// The payment was read just before, so a missed row means its status moved on
+	if result.RowsAffected() == 0 {
+		return service.ErrPaymentConflict
+	}
+
+	return nil
+}
+
+// updatePaymentQuery sets every column of a payment from the arguments of execUpdate
+const updatePaymentQuery = `
 		UPDATE payments SET
 			amount = $3,
 			currency = $4,
 			type = $5,
 			status = $6,
 			description = $7,
-			reference = $8,
+			reference = NULLIF($8, ''),
 			source_account = $9,
 			destination_account = $10,
 			metadata = $11,
@@ -124,10 +213,13 @@ func (r *paymentRepository) Update(ctx context.Context, payment *service.Payment
 			processed_at = $13,
 			completed_at = $14,
 			failed_at = $15,
-			failure_reason = $16
-		WHERE id = $1 AND tenant_id = $2`
+			failure_reason = NULLIF($16, ''),
+			retry_count = $17`
 
-	_, err := r.db.Exec(ctx, query,
+// execUpdate runs an update built on updatePaymentQuery with payment's columns as
+// $1 to $17, followed by args
+func (r *paymentRepository) execUpdate(ctx context.Context, query string, payment *service.Payment, args ...interface{}) (pgconn.CommandTag, error) {
+	return r.db.Exec(ctx, query, append([]interface{}{
 		payment.ID,
 		payment.TenantID,
 		payment.Amount,
@@ -144,12 +236,11 @@ func (r *paymentRepository) Update(ctx context.Context, payment *service.Payment
 		payment.CompletedAt,
 		payment.FailedAt,
 		payment.FailureReason,
-	)
-
-	return err
+		payment.RetryCount,
+	}, args...)...)
 }
 
-func (r *paymentRepository) List(ctx context.Context, tenantID string, filter *service.PaymentFilter) ([]*service.Payment, int64, error) {
+func (r *paymentRepository) list(ctx context.Context, tenantID string, filter *service.PaymentFilter) ([]*service.Payment, int64, error) {
 	// Build WHERE clause
 	whereClause := "WHERE tenant_id = $1"
 	args := []interface{}{tenantID}
@@ -219,8 +310,9 @@ func (r *paymentRepository) List(ctx context.Context, tenantID string, filter *s
 	// Main query
 	query := `
 		SELECT id, tenant_id, amount, currency, type, status, description,
-			   reference, source_account, destination_account, metadata,
-			   created_at, updated_at, processed_at, completed_at, failed_at, failure_reason
+			   COALESCE(reference, ''), source_account, destination_account, metadata,
+			   created_at, updated_at, processed_at, completed_at, failed_at, COALESCE(failure_reason, ''),
+			   retry_count
 		FROM payments ` + whereClause + " " + orderClause + " " + limitClause
 
 	rows, err := r.db.Query(ctx, query, args...)
@@ -252,6 +344,7 @@ func (r *paymentRepository) List(ctx context.Context, tenantID string, filter *s
 			&payment.CompletedAt,
 			&payment.FailedAt,
 			&payment.FailureReason,
+			&payment.RetryCount,
 		)
 
 		if err != nil {
@@ -272,7 +365,7 @@ func (r *paymentRepository) List(ctx context.Context, tenantID string, filter *s
 	return payments, total, nil
 }
 
-func (r *paymentRepository) GetStats(ctx context.Context, tenantID string) (*service.PaymentStats, error) {
+func (r *paymentRepository) getStats(ctx context.Context, tenantID string) (*service.PaymentStats, error) {
 	query := `
 		SELECT 
 			COUNT(*) as total_count,
@@ -303,7 +396,7 @@ func (r *paymentRepository) GetStats(ctx context.Context, tenantID string) (*ser
 	return &stats, nil
 }
 
-func (r *paymentRepository) Delete(ctx context.Context, tenantID, paymentID string) error {
+func (r *paymentRepository) delete(ctx context.Context, tenantID, paymentID string) error {
 	query := "DELETE FROM payments WHERE id = $1 AND tenant_id = $2"
 	
 	result, err := r.db.Exec(ctx, query, paymentID, tenantID)
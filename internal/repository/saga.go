@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yordanos-habtamu/b2b-payments/internal/resilience"
+	"github.com/yordanos-habtamu/b2b-payments/internal/worker"
+)
+
+type sagaRepository struct {
+	db    *pgxpool.Pool
+	calls callPolicy
+}
+
+// NewSagaRepository returns a saga store backed by the payment_sagas table.
+// Saga transitions are appended to payment_events in the same transaction.
+// Calls are admitted by limiter and bounded by deadline, and loads are hedged
+// by hedger and retried within budget; any of them may be nil.
+func NewSagaRepository(db *pgxpool.Pool, limiter resilience.Limiter, deadline *resilience.Deadline, hedger *resilience.Hedger, budget *resilience.RetryBudget) worker.SagaStore {
+	return &sagaRepository{
+		db:    db,
+		calls: callPolicy{limiter: limiter, deadline: deadline, hedger: hedger, budget: budget},
+	}
+}
+
+func (r *sagaRepository) LoadSaga(ctx context.Context, saga, tenantID, paymentID string) (*worker.SagaState, error) {
+	return read(ctx, r.calls, func(ctx context.Context) (*worker.SagaState, error) {
+		return r.loadSaga(ctx, saga, tenantID, paymentID)
+	})
+}
+
+func (r *sagaRepository) SaveSaga(ctx context.Context, state *worker.SagaState, event *worker.SagaEvent) error {
+	return write(ctx, r.calls, func(ctx context.Context) error {
+		return r.saveSaga(ctx, state, event)
+	})
+}
+
+func (r *sagaRepository) loadSaga(ctx context.Context, saga, tenantID, paymentID string) (*worker.SagaState, error) {
+	query := `
+		SELECT payment_id, saga_name, tenant_id, status, attempt, completed_steps, data,
+			   COALESCE(failed_step, ''), COALESCE(last_error, ''), COALESCE(failure_class, ''),
+			   created_at, updated_at
+		FROM payment_sagas
+		WHERE payment_id = $1 AND saga_name = $2 AND tenant_id = $3`
+
+	var state worker.SagaState
+	var completedSteps, data []byte
+
+	err := r.db.QueryRow(ctx, query, paymentID, saga, tenantID).Scan(
+		&state.PaymentID,
+		&state.Saga,
+		&state.TenantID,
+		&state.Status,
+		&state.Attempt,
+		&completedSteps,
+		&data,
+		&state.FailedStep,
+		&state.LastError,
+		&state.FailureClass,
+		&state.CreatedAt,
+		&state.UpdatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, worker.ErrSagaNotFound
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(completedSteps, &state.CompletedSteps); err != nil {
+		return nil, fmt.Errorf("failed to decode completed steps: %w", err)
+	}
+	if err := json.Unmarshal(data, &state.Data); err != nil {
+		return nil, fmt.Errorf("failed to decode saga data: %w", err)
+	}
+
+	return &state, nil
+}
+
+func (r *sagaRepository) saveSaga(ctx context.Context, state *worker.SagaState, event *worker.SagaEvent) error {
+	completedSteps, err := json.Marshal(state.CompletedSteps)
+	if err != nil {
+		return fmt.Errorf("failed to encode completed steps: %w", err)
+	}
+	data, err := json.Marshal(state.Data)
+	if err != nil {
+		return fmt.Errorf("failed to encode saga data: %w", err)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO payment_sagas (
+			payment_id, saga_name, tenant_id, status, attempt, completed_steps, data,
+			failed_step, last_error, failure_class, created_at, updated_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''), $11, $12
+		)
+		ON CONFLICT (payment_id, saga_name) DO UPDATE SET
+			status = EXCLUDED.status,
+			attempt = EXCLUDED.attempt,
+			completed_steps = EXCLUDED.completed_steps,
+			data = EXCLUDED.data,
+			failed_step = EXCLUDED.failed_step,
+			last_error = EXCLUDED.last_error,
+			failure_class = EXCLUDED.failure_class,
+			updated_at = EXCLUDED.updated_at`
+
+	if _, err := tx.Exec(ctx, query,
+		state.PaymentID,
+		state.Saga,
+		state.TenantID,
+		state.Status,
+		state.Attempt,
+		completedSteps,
+		data,
+		state.FailedStep,
+		state.LastError,
+		state.FailureClass,
+		state.CreatedAt,
+		state.UpdatedAt,
+	); err != nil {
+		return fmt.Errorf("failed to save saga: %w", err)
+	}
+
+	if event != nil {
+		eventData, err := json.Marshal(map[string]interface{}{
+			"saga":    state.Saga,
+			"attempt": state.Attempt,
+			"step":    event.Step,
+			"error":   event.Error,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to encode saga event: %w", err)
+		}
+
+		eventQuery := `
+			INSERT INTO payment_events (payment_id, tenant_id, event_type, event_data, source, created_at)
+			VALUES ($1, $2, $3, $4, 'saga', $5)`
+
+		if _, err := tx.Exec(ctx, eventQuery,
+			state.PaymentID,
+			state.TenantID,
+			event.Type,
+			eventData,
+			state.UpdatedAt,
+		); err != nil {
+			return fmt.Errorf("failed to record saga event: %w", err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,211 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// minLatencyWindow is how many samples the latency baseline is kept before it
+// is re-measured, so the limiter follows a dependency that got permanently slower
+const minLatencyWindow = 1000
+
+// AdaptiveLimiterConfig holds configuration for an adaptive limiter
+type AdaptiveLimiterConfig struct {
+	Name         string
+	InitialLimit int // starting concurrency limit (default 20)
+	MinLimit     int // floor of the limit (default 1)
+	MaxLimit     int // ceiling of the limit (default 1000)
+
+	// A call slower than LatencyThreshold signals congestion. When zero the
+	// threshold is Tolerance (default 2) times the lowest recent latency.
+	LatencyThreshold time.Duration
+	Tolerance        float64
+
+	// BackoffRatio multiplies the limit on congestion (default 0.9)
+	BackoffRatio float64
+
+	// IsDrop reports errors that signal overload. Defaults to timeouts and
+	// rejections by the dependency's own limiter or breaker.
+	IsDrop func(error) bool
+
+	RetryAfter time.Duration // hint returned with rejections (default 1s)
+
+	// Now replaces time.Now, for tests
+	Now func() time.Time
+}
+
+// AdaptiveLimiter caps concurrent calls at a limit it discovers with AIMD:
+// every call that completes quickly while the limit is in use raises the
+// limit by 1/limit (about +1 per round of calls), and a slow or dropped call
+// multiplies it by BackoffRatio, at most once per observed latency. Calls
+// over the limit are rejected immediately.
+type AdaptiveLimiter struct {
+	name             string
+	minLimit         float64
+	maxLimit         float64
+	latencyThreshold time.Duration
+	tolerance        float64
+	backoffRatio     float64
+	isDrop           func(error) bool
+	retryAfter       time.Duration
+	now              func() time.Time
+
+	mu           sync.Mutex
+	limit        float64
+	inFlight     int
+	minLatency   time.Duration
+	samples      int
+	lastDecrease time.Time
+	rejected     int64
+}
+
+// NewAdaptiveLimiter creates a new adaptive limiter
+func NewAdaptiveLimiter(config AdaptiveLimiterConfig) *AdaptiveLimiter {
+	if config.MinLimit <= 0 {
+		config.MinLimit = 1
+	}
+	if config.MaxLimit <= 0 {
+		config.MaxLimit = 1000
+	}
+	if config.MaxLimit < config.MinLimit {
+		config.MaxLimit = config.MinLimit
+	}
+	if config.InitialLimit <= 0 {
+		config.InitialLimit = 20
+	}
+	if config.InitialLimit < config.MinLimit {
+		config.InitialLimit = config.MinLimit
+	}
+	if config.InitialLimit > config.MaxLimit {
+		config.InitialLimit = config.MaxLimit
+	}
+	if config.Tolerance <= 1 {
+		config.Tolerance = 2
+	}
+	if config.BackoffRatio <= 0 || config.BackoffRatio >= 1 {
+		config.BackoffRatio = 0.9
+	}
+	if config.IsDrop == nil {
+		config.IsDrop = func(err error) bool {
+			return errors.Is(err, context.DeadlineExceeded) || IsUnavailable(err)
+		}
+	}
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = time.Second
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	return &AdaptiveLimiter{
+		name:             config.Name,
+		minLimit:         float64(config.MinLimit),
+		maxLimit:         float64(config.MaxLimit),
+		latencyThreshold: config.LatencyThreshold,
+		tolerance:        config.Tolerance,
+		backoffRatio:     config.BackoffRatio,
+		isDrop:           config.IsDrop,
+		retryAfter:       config.RetryAfter,
+		now:              config.Now,
+		limit:            float64(config.InitialLimit),
+	}
+}
+
+// Acquire admits the call if fewer than the current limit are in flight
+func (l *AdaptiveLimiter) Acquire(ctx context.Context) (func(error), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	limit := int(l.limit)
+	if l.inFlight >= limit {
+		l.rejected++
+		l.mu.Unlock()
+		return nil, &RejectedError{
+			Name:       l.name,
+			Reason:     fmt.Sprintf("concurrency limit %d reached", limit),
+			RetryAfter: l.retryAfter,
+		}
+	}
+	l.inFlight++
+	// Only calls made while the limit is mostly used say anything about
+	// whether it can grow
+	saturated := l.inFlight*2 >= limit
+	start := l.now()
+	l.mu.Unlock()
+
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() { l.release(start, saturated, err) })
+	}, nil
+}
+
+func (l *AdaptiveLimiter) release(start time.Time, saturated bool, err error) {
+	now := l.now()
+	latency := now.Sub(start)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+
+	if err != nil && !l.isDrop(err) {
+		// Ordinary failures are not a load signal and fast ones would skew the baseline
+		return
+	}
+
+	l.samples++
+	if l.minLatency == 0 || latency < l.minLatency || l.samples >= minLatencyWindow {
+		l.minLatency = latency
+		if l.samples >= minLatencyWindow {
+			l.samples = 0
+		}
+	}
+
+	threshold := l.latencyThreshold
+	if threshold == 0 {
+		threshold = time.Duration(float64(l.minLatency) * l.tolerance)
+	}
+
+	if err != nil || latency > threshold {
+		// Back off once per round trip, not once per slow call in the round
+		if now.Sub(l.lastDecrease) >= latency {
+			l.limit = max(l.minLimit, l.limit*l.backoffRatio)
+			l.lastDecrease = now
+		}
+		return
+	}
+
+	if saturated {
+		l.limit = min(l.maxLimit, l.limit+1/l.limit)
+	}
+}
+
+// Execute runs fn if the limiter admits it
+func (l *AdaptiveLimiter) Execute(ctx context.Context, fn func(context.Context) error) error {
+	return Limit(ctx, l, fn)
+}
+
+// Limit returns the current concurrency limit
+func (l *AdaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// Stats returns the limiter's current limit and occupancy
+func (l *AdaptiveLimiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return LimiterStats{
+		Name:     l.name,
+		Limit:    int(l.limit),
+		InFlight: l.inFlight,
+		Rejected: l.rejected,
+	}
+}
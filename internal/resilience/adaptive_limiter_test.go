@@ -0,0 +1,143 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func newTestAdaptiveLimiter(clock *fakeClock, initial int) *AdaptiveLimiter {
+	return NewAdaptiveLimiter(AdaptiveLimiterConfig{
+		Name:             "test",
+		InitialLimit:     initial,
+		MinLimit:         2,
+		MaxLimit:         6,
+		LatencyThreshold: 100 * time.Millisecond,
+		RetryAfter:       2 * time.Second,
+		Now:              clock.Now,
+	})
+}
+
+// round runs n concurrent calls that each take latency and end with err
+func round(t *testing.T, l *AdaptiveLimiter, clock *fakeClock, n int, latency time.Duration, err error) {
+	t.Helper()
+
+	dones := make([]func(error), n)
+	for i := range dones {
+		done, acquireErr := l.Acquire(context.Background())
+		if acquireErr != nil {
+			t.Fatalf("Acquire %d of %d failed: %v", i+1, n, acquireErr)
+		}
+		dones[i] = done
+	}
+	clock.Advance(latency)
+	for _, done := range dones {
+		done(err)
+	}
+}
+
+func TestAdaptiveLimiterIncrease(t *testing.T) {
+	clock := newFakeClock()
+	l := newTestAdaptiveLimiter(clock, 4)
+
+	// Calls that leave most of the limit unused say nothing about headroom
+	for i := 0; i < 10; i++ {
+		round(t, l, clock, 1, 10*time.Millisecond, nil)
+	}
+	if got := l.Limit(); got != 4 {
+		t.Fatalf("limit after unsaturated calls = %d, want 4", got)
+	}
+
+	// A fast round at the limit adds about one
+	round(t, l, clock, 4, 10*time.Millisecond, nil)
+	round(t, l, clock, 4, 10*time.Millisecond, nil)
+	if got := l.Limit(); got != 5 {
+		t.Fatalf("limit after two saturated rounds = %d, want 5", got)
+	}
+
+	// Never past MaxLimit
+	for i := 0; i < 20; i++ {
+		round(t, l, clock, l.Limit(), 10*time.Millisecond, nil)
+	}
+	if got := l.Limit(); got != 6 {
+		t.Errorf("limit = %d, want MaxLimit 6", got)
+	}
+}
+
+func TestAdaptiveLimiterDecrease(t *testing.T) {
+	tests := []struct {
+		name    string
+		latency time.Duration
+		err     error
+		want    int
+	}{
+		{"slow call", 200 * time.Millisecond, nil, 5},
+		{"timeout", 10 * time.Millisecond, fmt.Errorf("query: %w", context.DeadlineExceeded), 5},
+		{"dependency rejection", 10 * time.Millisecond, &RejectedError{Name: "db", Reason: "busy"}, 5},
+		{"ordinary failure", 200 * time.Millisecond, errBoom, 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			l := newTestAdaptiveLimiter(clock, 6)
+
+			round(t, l, clock, 1, tt.latency, tt.err)
+			if got := l.Limit(); got != tt.want {
+				t.Errorf("limit = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdaptiveLimiterBacksOffOncePerRoundTrip(t *testing.T) {
+	clock := newFakeClock()
+	l := newTestAdaptiveLimiter(clock, 6)
+
+	// Six slow calls completing together are one congestion signal
+	round(t, l, clock, 6, 200*time.Millisecond, nil)
+	if got := l.Limit(); got != 5 {
+		t.Fatalf("limit after one slow round = %d, want 5", got)
+	}
+
+	// The next round trip backs off again, down to MinLimit
+	for i := 0; i < 10; i++ {
+		round(t, l, clock, 1, 200*time.Millisecond, nil)
+	}
+	if got := l.Limit(); got != 2 {
+		t.Errorf("limit = %d, want MinLimit 2", got)
+	}
+}
+
+func TestAdaptiveLimiterRejectsOverLimit(t *testing.T) {
+	clock := newFakeClock()
+	l := newTestAdaptiveLimiter(clock, 2)
+
+	var dones []func(error)
+	for i := 0; i < 2; i++ {
+		done, err := l.Acquire(context.Background())
+		if err != nil {
+			t.Fatalf("Acquire failed: %v", err)
+		}
+		dones = append(dones, done)
+	}
+
+	_, err := l.Acquire(context.Background())
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) {
+		t.Fatalf("error = %v, want a *RejectedError", err)
+	}
+	if retryAfter, ok := RetryAfter(err); !ok || retryAfter != 2*time.Second {
+		t.Errorf("RetryAfter = %s, %v, want 2s", retryAfter, ok)
+	}
+
+	// A done func called twice releases once
+	dones[0](nil)
+	dones[0](nil)
+	if stats := l.Stats(); stats.InFlight != 1 || stats.Rejected != 1 {
+		t.Errorf("stats = %+v, want 1 in flight and 1 rejected", stats)
+	}
+	dones[1](nil)
+}
@@ -0,0 +1,105 @@
+package resilience
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// BulkheadConfig holds configuration for a bulkhead
+type BulkheadConfig struct {
+	Name          string
+	MaxConcurrent int           // calls running at once (default 10)
+	MaxQueue      int           // calls allowed to wait for a slot; 0 rejects as soon as all slots are busy
+	QueueTimeout  time.Duration // how long a queued call waits for a slot (default 1s)
+	RetryAfter    time.Duration // hint returned with rejections (default 1s)
+}
+
+// Bulkhead isolates a dependency by capping its concurrent calls, with a
+// bounded queue of callers waiting for a slot. Calls beyond the queue, or
+// queued past QueueTimeout, are rejected instead of piling up.
+type Bulkhead struct {
+	name         string
+	slots        chan struct{}
+	queue        chan struct{}
+	queueTimeout time.Duration
+	retryAfter   time.Duration
+	rejected     atomic.Int64
+}
+
+// NewBulkhead creates a new bulkhead
+func NewBulkhead(config BulkheadConfig) *Bulkhead {
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = 10
+	}
+	if config.MaxQueue < 0 {
+		config.MaxQueue = 0
+	}
+	if config.QueueTimeout <= 0 {
+		config.QueueTimeout = time.Second
+	}
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = time.Second
+	}
+
+	return &Bulkhead{
+		name:         config.Name,
+		slots:        make(chan struct{}, config.MaxConcurrent),
+		queue:        make(chan struct{}, config.MaxQueue),
+		queueTimeout: config.QueueTimeout,
+		retryAfter:   config.RetryAfter,
+	}
+}
+
+// Acquire takes a slot, waiting in the queue if there is room
+func (b *Bulkhead) Acquire(ctx context.Context) (func(error), error) {
+	select {
+	case b.slots <- struct{}{}:
+		return b.release, nil
+	default:
+	}
+
+	select {
+	case b.queue <- struct{}{}:
+	default:
+		return nil, b.reject("queue full")
+	}
+	defer func() { <-b.queue }()
+
+	timer := time.NewTimer(b.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case b.slots <- struct{}{}:
+		return b.release, nil
+	case <-timer.C:
+		return nil, b.reject("timed out waiting for a slot")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *Bulkhead) release(error) {
+	<-b.slots
+}
+
+func (b *Bulkhead) reject(reason string) error {
+	b.rejected.Add(1)
+	return &RejectedError{Name: b.name, Reason: reason, RetryAfter: b.retryAfter}
+}
+
+// Execute runs fn in the bulkhead
+func (b *Bulkhead) Execute(ctx context.Context, fn func(context.Context) error) error {
+	return Limit(ctx, b, fn)
+}
+
+// Stats returns the bulkhead's current occupancy
+func (b *Bulkhead) Stats() LimiterStats {
+	return LimiterStats{
+		Name:     b.name,
+		Limit:    cap(b.slots),
+		InFlight: len(b.slots),
+		Queued:   len(b.queue),
+		Rejected: b.rejected.Load(),
+	}
+}
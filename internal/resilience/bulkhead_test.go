@@ -0,0 +1,113 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestBulkhead() *Bulkhead {
+	return NewBulkhead(BulkheadConfig{
+		Name:          "test",
+		MaxConcurrent: 1,
+		MaxQueue:      1,
+		QueueTimeout:  30 * time.Millisecond,
+		RetryAfter:    2 * time.Second,
+	})
+}
+
+func assertRejected(t *testing.T, err error, reason string) {
+	t.Helper()
+
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) {
+		t.Fatalf("error = %v, want a *RejectedError", err)
+	}
+	if rejected.Reason != reason {
+		t.Errorf("reason = %q, want %q", rejected.Reason, reason)
+	}
+	if retryAfter, ok := RetryAfter(err); !ok || retryAfter != 2*time.Second {
+		t.Errorf("RetryAfter = %s, %v, want 2s", retryAfter, ok)
+	}
+	if !errors.Is(err, ErrRejected) || !IsUnavailable(err) {
+		t.Errorf("error %v does not match ErrRejected", err)
+	}
+}
+
+func TestBulkheadQueueTimeout(t *testing.T) {
+	b := newTestBulkhead()
+
+	done, err := b.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	defer done(nil)
+
+	start := time.Now()
+	_, err = b.Acquire(context.Background())
+	if waited := time.Since(start); waited < 30*time.Millisecond {
+		t.Errorf("queued caller rejected after %s, want it to wait the 30ms queue timeout", waited)
+	}
+	assertRejected(t, err, "timed out waiting for a slot")
+
+	if stats := b.Stats(); stats.Rejected != 1 || stats.InFlight != 1 || stats.Queued != 0 {
+		t.Errorf("stats = %+v, want 1 in flight, none queued and 1 rejected", stats)
+	}
+}
+
+func TestBulkheadQueueFull(t *testing.T) {
+	b := newTestBulkhead()
+	b.queueTimeout = time.Second
+
+	done, err := b.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	queued := make(chan error, 1)
+	go func() {
+		release, err := b.Acquire(context.Background())
+		if err == nil {
+			release(nil)
+		}
+		queued <- err
+	}()
+	for b.Stats().Queued == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The slot and the queue are both taken
+	_, err = b.Acquire(context.Background())
+	assertRejected(t, err, "queue full")
+
+	// Releasing the slot admits the queued caller
+	done(nil)
+	if err := <-queued; err != nil {
+		t.Errorf("queued caller failed: %v", err)
+	}
+	if stats := b.Stats(); stats.InFlight != 0 {
+		t.Errorf("in flight = %d after every caller released, want 0", stats.InFlight)
+	}
+}
+
+func TestBulkheadQueuedCallerCancelled(t *testing.T) {
+	b := newTestBulkhead()
+	b.queueTimeout = time.Second
+
+	done, err := b.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	defer done(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := b.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want the caller's %v", err, context.DeadlineExceeded)
+	}
+	if stats := b.Stats(); stats.Rejected != 0 {
+		t.Errorf("rejected = %d, want a caller giving up not to count as a rejection", stats.Rejected)
+	}
+}
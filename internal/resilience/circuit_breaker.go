@@ -3,6 +3,7 @@ package resilience
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 )
@@ -16,203 +17,408 @@ const (
 	StateHalfOpen
 )
 
+func (s CircuitState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the state by name so stats render as "open" rather than 1
+func (s CircuitState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
 var (
 	ErrCircuitBreakerOpen = errors.New("circuit breaker is open")
-	ErrServiceUnavailable   = errors.New("service unavailable")
+	ErrServiceUnavailable = errors.New("service unavailable")
+
+	// ErrTooManyProbes is returned while half-open once all probe slots are
+	// taken. It wraps ErrCircuitBreakerOpen so callers can treat both alike.
+	ErrTooManyProbes = fmt.Errorf("%w: half-open probe limit reached", ErrCircuitBreakerOpen)
 )
 
-// CircuitBreaker implements the circuit breaker pattern
-type CircuitBreaker struct {
-	name           string
-	maxFailures    int
-	resetTimeout   time.Duration
-	requestTimeout time.Duration
+// CircuitBreakerConfig holds configuration for a circuit breaker. The breaker
+// trips when, over the last Window, at least MinRequests calls were made and
+// the share of failures reached FailureRateThreshold. After ResetTimeout it
+// lets HalfOpenMaxRequests probe calls through; it closes once they all
+// succeed and reopens on the first probe failure.
+type CircuitBreakerConfig struct {
+	Name string
+
+	Window               time.Duration // length of the sliding window (default 60s)
+	WindowBuckets        int           // resolution of the window (default 10)
+	MinRequests          int           // calls in the window before the rate is considered (default 10)
+	FailureRateThreshold float64       // failure share in (0, 1] that trips the breaker (default 0.5)
 
-	state        CircuitState
-	failures     int
-	lastFailTime  time.Time
-	mutex        sync.RWMutex
-	onStateChange func(name string, from, to CircuitState)
+	ResetTimeout        time.Duration // how long the breaker stays open (default 60s)
+	HalfOpenMaxRequests int           // probe calls allowed while half-open (default 1)
+	RequestTimeout      time.Duration // deadline applied to each call's context; 0 disables it
+
+	// IsFailure decides which errors count against the breaker. Defaults to
+	// every non-nil error. Calls abandoned because the caller's context was
+	// cancelled never count.
+	IsFailure func(error) bool
+
+	// OnStateChange is called after every transition, outside the breaker's lock
+	OnStateChange func(name string, from, to CircuitState)
+
+	// Now replaces time.Now, for tests
+	Now func() time.Time
 }
 
-// CircuitBreakerConfig holds configuration for a circuit breaker
-type CircuitBreakerConfig struct {
-	Name           string
-	MaxFailures    int
-	ResetTimeout   time.Duration
-	RequestTimeout time.Duration
-	OnStateChange  func(name string, from, to CircuitState)
+// CircuitBreakerStats is a point-in-time view of a breaker
+type CircuitBreakerStats struct {
+	Name           string       `json:"name"`
+	State          CircuitState `json:"state"`
+	Requests       int          `json:"requests"`
+	Failures       int          `json:"failures"`
+	FailureRate    float64      `json:"failure_rate"`
+	LastTransition time.Time    `json:"last_transition"`
+	Forced         bool         `json:"forced"`
+}
+
+// CircuitBreaker implements the circuit breaker pattern
+type CircuitBreaker struct {
+	name                 string
+	window               *SlidingWindow
+	minRequests          int
+	failureRateThreshold float64
+	resetTimeout         time.Duration
+	halfOpenMaxRequests  int
+	requestTimeout       time.Duration
+	isFailure            func(error) bool
+	onStateChange        func(name string, from, to CircuitState)
+	now                  func() time.Time
+
+	mutex          sync.Mutex
+	state          CircuitState
+	generation     uint64
+	openedAt       time.Time
+	lastTransition time.Time
+	probes         int
+	probeSuccesses int
+	forced         bool // held in its state by ForceOpen or ForceClose until Reset
 }
 
 // NewCircuitBreaker creates a new circuit breaker
 func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
-	if config.MaxFailures <= 0 {
-		config.MaxFailures = 5
+	if config.Window <= 0 {
+		config.Window = 60 * time.Second
+	}
+	if config.WindowBuckets <= 0 {
+		config.WindowBuckets = 10
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = 10
+	}
+	if config.FailureRateThreshold <= 0 || config.FailureRateThreshold > 1 {
+		config.FailureRateThreshold = 0.5
 	}
 	if config.ResetTimeout <= 0 {
 		config.ResetTimeout = 60 * time.Second
 	}
-	if config.RequestTimeout <= 0 {
-		config.RequestTimeout = 30 * time.Second
+	if config.HalfOpenMaxRequests <= 0 {
+		config.HalfOpenMaxRequests = 1
+	}
+	if config.IsFailure == nil {
+		config.IsFailure = func(err error) bool { return err != nil }
+	}
+	if config.Now == nil {
+		config.Now = time.Now
 	}
 
 	return &CircuitBreaker{
-		name:           config.Name,
-		maxFailures:    config.MaxFailures,
-		resetTimeout:   config.ResetTimeout,
-		requestTimeout: config.RequestTimeout,
-		state:          StateClosed,
-		onStateChange:  config.OnStateChange,
+		name:                 config.Name,
+		window:               NewSlidingWindow(config.Window, config.WindowBuckets),
+		minRequests:          config.MinRequests,
+		failureRateThreshold: config.FailureRateThreshold,
+		resetTimeout:         config.ResetTimeout,
+		halfOpenMaxRequests:  config.HalfOpenMaxRequests,
+		requestTimeout:       config.RequestTimeout,
+		isFailure:            config.IsFailure,
+		onStateChange:        config.OnStateChange,
+		now:                  config.Now,
+		state:                StateClosed,
+		lastTransition:       config.Now(),
 	}
 }
 
+// Name returns the breaker's name
+func (cb *CircuitBreaker) Name() string {
+	return cb.name
+}
+
 // Execute runs the given function through the circuit breaker
 func (cb *CircuitBreaker) Execute(fn func() error) error {
-	return cb.ExecuteWithContext(context.Background(), fn)
+	return cb.ExecuteWithContext(context.Background(), func(context.Context) error {
+		return fn()
+	})
 }
 
-// ExecuteWithContext runs the given function through the circuit breaker with context
-func (cb *CircuitBreaker) ExecuteWithContext(ctx context.Context, fn func() error) error {
-	if !cb.allowRequest() {
-		return ErrCircuitBreakerOpen
-	}
+// ExecuteWithContext runs fn through the circuit breaker. fn receives ctx
+// bounded by the breaker's request timeout and must honour it.
+func (cb *CircuitBreaker) ExecuteWithContext(ctx context.Context, fn func(context.Context) error) error {
+	_, err := ExecuteWithContextAndResult(ctx, cb, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
 
-	// Create a context with timeout
-	timeoutCtx, cancel := context.WithTimeout(ctx, cb.requestTimeout)
-	defer cancel()
+// ExecuteWithResult runs fn once through the circuit breaker and returns its result
+func ExecuteWithResult[T any](cb *CircuitBreaker, fn func() (T, error)) (T, error) {
+	return ExecuteWithContextAndResult(context.Background(), cb, func(context.Context) (T, error) {
+		return fn()
+	})
+}
 
-	// Execute the function
-	err := fn()
-	cb.recordResult(err == nil)
+// ExecuteWithContextAndResult runs fn once through the circuit breaker with a
+// context bounded by the breaker's request timeout and returns its result
+func ExecuteWithContextAndResult[T any](ctx context.Context, cb *CircuitBreaker, fn func(context.Context) (T, error)) (result T, err error) {
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
 
+	generation, err := cb.beforeRequest()
 	if err != nil {
-		return err
+		return result, err
 	}
 
-	return nil
-}
+	callCtx := ctx
+	if cb.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, cb.requestTimeout)
+		defer cancel()
+	}
 
-// ExecuteWithResult runs the given function and returns both result and error
-func (cb *CircuitBreaker) ExecuteWithResult[T any](fn func() (T, error)) (T, error) {
-	var zero T
-	err := cb.Execute(func() error {
-		_, err := fn()
-		return err
-	})
-	if err != nil {
-		return zero, err
+	defer func() {
+		if p := recover(); p != nil {
+			cb.afterRequest(generation, outcomeFailure)
+			panic(p)
+		}
+	}()
+
+	result, err = fn(callCtx)
+
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// The caller gave up; that says nothing about the dependency
+		cb.afterRequest(generation, outcomeIgnored)
+	case cb.isFailure(err):
+		cb.afterRequest(generation, outcomeFailure)
+	default:
+		cb.afterRequest(generation, outcomeSuccess)
 	}
-	
-	result, err := fn()
 	return result, err
 }
 
-// ExecuteWithContextAndResult runs the given function with context and returns both result and error
-func (cb *CircuitBreaker) ExecuteWithContextAndResult[T any](ctx context.Context, fn func(context.Context) (T, error)) (T, error) {
-	var zero T
-	
-	if !cb.allowRequest() {
-		return zero, ErrCircuitBreakerOpen
-	}
+type outcome int
 
-	// Create a context with timeout
-	timeoutCtx, cancel := context.WithTimeout(ctx, cb.requestTimeout)
-	defer cancel()
+const (
+	outcomeSuccess outcome = iota
+	outcomeFailure
+	outcomeIgnored
+)
 
-	// Execute the function
-	result, err := fn(timeoutCtx)
-	cb.recordResult(err == nil)
+// beforeRequest admits or rejects a call and returns the generation the
+// call's result belongs to
+func (cb *CircuitBreaker) beforeRequest() (uint64, error) {
+	cb.mutex.Lock()
+	from := cb.state
+	generation, err := cb.admit()
+	to := cb.state
+	cb.mutex.Unlock()
 
-	return result, err
+	cb.notify(from, to)
+	return generation, err
 }
 
-// allowRequest determines if a request should be allowed
-func (cb *CircuitBreaker) allowRequest() bool {
-	cb.mutex.RLock()
-	defer cb.mutex.RUnlock()
+func (cb *CircuitBreaker) admit() (uint64, error) {
+	now := cb.now()
 
 	switch cb.state {
-	case StateClosed:
-		return true
 	case StateOpen:
-		return time.Since(cb.lastFailTime) >= cb.resetTimeout
+		if cb.forced || now.Sub(cb.openedAt) < cb.resetTimeout {
+			return 0, ErrCircuitBreakerOpen
+		}
+		cb.setState(StateHalfOpen, now)
+		fallthrough
 	case StateHalfOpen:
-		return true
-	default:
-		return false
+		if cb.probes >= cb.halfOpenMaxRequests {
+			return 0, ErrTooManyProbes
+		}
+		cb.probes++
 	}
+	return cb.generation, nil
 }
 
-// recordResult records the result of a request
-func (cb *CircuitBreaker) recordResult(success bool) {
+// afterRequest records a call's outcome. Results from a previous generation
+// (the breaker changed state while the call ran) are discarded.
+func (cb *CircuitBreaker) afterRequest(generation uint64, result outcome) {
 	cb.mutex.Lock()
-	defer cb.mutex.Unlock()
+	from := cb.state
+	cb.record(generation, result)
+	to := cb.state
+	cb.mutex.Unlock()
 
-	if success {
-		cb.onSuccess()
-	} else {
-		cb.onFailure()
-	}
+	cb.notify(from, to)
 }
 
-// onSuccess handles a successful request
-func (cb *CircuitBreaker) onSuccess() {
-	cb.failures = 0
-	
-	if cb.state == StateHalfOpen {
-		cb.setState(StateClosed)
+func (cb *CircuitBreaker) record(generation uint64, result outcome) {
+	if generation != cb.generation {
+		return
+	}
+	now := cb.now()
+
+	switch cb.state {
+	case StateClosed:
+		if result == outcomeIgnored {
+			return
+		}
+		cb.window.Add(now, result == outcomeFailure)
+		requests, failures := cb.window.Totals(now)
+		if !cb.forced && requests >= cb.minRequests && float64(failures)/float64(requests) >= cb.failureRateThreshold {
+			cb.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		switch result {
+		case outcomeFailure:
+			cb.setState(StateOpen, now)
+		case outcomeSuccess:
+			cb.probeSuccesses++
+			if cb.probeSuccesses >= cb.halfOpenMaxRequests {
+				cb.setState(StateClosed, now)
+			}
+		default:
+			// Free the slot so another probe can take it
+			cb.probes--
+		}
 	}
 }
 
-// onFailure handles a failed request
-func (cb *CircuitBreaker) onFailure() {
-	cb.failures++
-	cb.lastFailTime = time.Now()
+// setState moves to a new state and starts a new generation. Must be called
+// with the lock held.
+func (cb *CircuitBreaker) setState(state CircuitState, now time.Time) {
+	if cb.state == state {
+		return
+	}
+
+	cb.state = state
+	cb.generation++
+	cb.lastTransition = now
+	cb.probes = 0
+	cb.probeSuccesses = 0
 
-	if cb.failures >= cb.maxFailures {
-		cb.setState(StateOpen)
+	switch state {
+	case StateOpen:
+		cb.openedAt = now
+	case StateClosed:
+		cb.window.Reset()
 	}
 }
 
-// setState changes the state of the circuit breaker
-func (cb *CircuitBreaker) setState(newState CircuitState) {
-	if cb.state != newState {
-		oldState := cb.state
-		cb.state = newState
-		
-		if cb.onStateChange != nil {
-			cb.onStateChange(cb.name, oldState, newState)
-		}
+func (cb *CircuitBreaker) notify(from, to CircuitState) {
+	if from != to && cb.onStateChange != nil {
+		cb.onStateChange(cb.name, from, to)
 	}
 }
 
-// State returns the current state of the circuit breaker
+// State returns the current state of the circuit breaker. An open breaker
+// whose reset timeout has passed reports half-open, as the next call will probe.
 func (cb *CircuitBreaker) State() CircuitState {
-	cb.mutex.RLock()
-	defer cb.mutex.RUnlock()
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	return cb.currentState()
+}
+
+func (cb *CircuitBreaker) currentState() CircuitState {
+	if cb.state == StateOpen && !cb.forced && cb.now().Sub(cb.openedAt) >= cb.resetTimeout {
+		return StateHalfOpen
+	}
 	return cb.state
 }
 
-// Failures returns the current number of failures
+// Failures returns the number of failures in the current window
 func (cb *CircuitBreaker) Failures() int {
-	cb.mutex.RLock()
-	defer cb.mutex.RUnlock()
-	return cb.failures
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	_, failures := cb.window.Totals(cb.now())
+	return failures
 }
 
-// Reset manually resets the circuit breaker to closed state
-func (cb *CircuitBreaker) Reset() {
+// Stats returns the breaker's state and window counts
+func (cb *CircuitBreaker) Stats() CircuitBreakerStats {
 	cb.mutex.Lock()
 	defer cb.mutex.Unlock()
-	
-	cb.setState(StateClosed)
-	cb.failures = 0
-	cb.lastFailTime = time.Time{}
+
+	requests, failures := cb.window.Totals(cb.now())
+	stats := CircuitBreakerStats{
+		Name:           cb.name,
+		State:          cb.currentState(),
+		Requests:       requests,
+		Failures:       failures,
+		LastTransition: cb.lastTransition,
+		Forced:         cb.forced,
+	}
+	if requests > 0 {
+		stats.FailureRate = float64(failures) / float64(requests)
+	}
+	return stats
+}
+
+// ForceOpen opens the breaker and keeps it open, rejecting every call, until
+// ForceClose or Reset
+func (cb *CircuitBreaker) ForceOpen() {
+	cb.force(StateOpen)
+}
+
+// ForceClose closes the breaker and keeps it closed, admitting every call
+// however many fail, until ForceOpen or Reset
+func (cb *CircuitBreaker) ForceClose() {
+	cb.force(StateClosed)
+}
+
+func (cb *CircuitBreaker) force(state CircuitState) {
+	cb.mutex.Lock()
+	from := cb.state
+	cb.setState(state, cb.now())
+	cb.forced = true
+	cb.mutex.Unlock()
+
+	cb.notify(from, state)
+}
+
+// Reset manually resets the circuit breaker to closed state, lifting any
+// ForceOpen or ForceClose
+func (cb *CircuitBreaker) Reset() {
+	cb.mutex.Lock()
+	from := cb.state
+	cb.forced = false
+	cb.setState(StateClosed, cb.now())
+	// Discard in-flight results and counts even if already closed
+	cb.generation++
+	cb.window.Reset()
+	cb.mutex.Unlock()
+
+	cb.notify(from, StateClosed)
+}
+
+// CircuitBreakerMetricsRecorder is the subset of metrics.MetricsCollector used by CircuitBreakerRegistry
+type CircuitBreakerMetricsRecorder interface {
+	SetCircuitBreakerState(name string, state int)
+	RecordCircuitBreakerTransition(name, from, to string)
 }
 
 // CircuitBreakerRegistry manages multiple circuit breakers
 type CircuitBreakerRegistry struct {
 	breakers map[string]*CircuitBreaker
+	metrics  CircuitBreakerMetricsRecorder
 	mutex    sync.RWMutex
 }
 
@@ -223,11 +429,31 @@ func NewCircuitBreakerRegistry() *CircuitBreakerRegistry {
 	}
 }
 
-// Register registers a new circuit breaker
+// SetMetrics reports the state and transitions of breakers registered from
+// now on to recorder
+func (r *CircuitBreakerRegistry) SetMetrics(recorder CircuitBreakerMetricsRecorder) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.metrics = recorder
+}
+
+// Register registers a new circuit breaker, replacing any with the same name
 func (r *CircuitBreakerRegistry) Register(config CircuitBreakerConfig) *CircuitBreaker {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	
+
+	if recorder := r.metrics; recorder != nil {
+		onStateChange := config.OnStateChange
+		config.OnStateChange = func(name string, from, to CircuitState) {
+			recorder.RecordCircuitBreakerTransition(name, from.String(), to.String())
+			recorder.SetCircuitBreakerState(name, int(to))
+			if onStateChange != nil {
+				onStateChange(name, from, to)
+			}
+		}
+		recorder.SetCircuitBreakerState(config.Name, int(StateClosed))
+	}
+
 	cb := NewCircuitBreaker(config)
 	r.breakers[config.Name] = cb
 	return cb
@@ -237,7 +463,7 @@ func (r *CircuitBreakerRegistry) Register(config CircuitBreakerConfig) *CircuitB
 func (r *CircuitBreakerRegistry) Get(name string) (*CircuitBreaker, bool) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
-	
+
 	cb, exists := r.breakers[name]
 	return cb, exists
 }
@@ -246,7 +472,7 @@ func (r *CircuitBreakerRegistry) Get(name string) (*CircuitBreaker, bool) {
 func (r *CircuitBreakerRegistry) GetAll() map[string]*CircuitBreaker {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
-	
+
 	result := make(map[string]*CircuitBreaker)
 	for name, cb := range r.breakers {
 		result[name] = cb
@@ -256,25 +482,16 @@ func (r *CircuitBreakerRegistry) GetAll() map[string]*CircuitBreaker {
 
 // ResetAll resets all circuit breakers
 func (r *CircuitBreakerRegistry) ResetAll() {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
-	
-	for _, cb := range r.breakers {
+	for _, cb := range r.GetAll() {
 		cb.Reset()
 	}
 }
 
 // GetStats returns statistics for all circuit breakers
-func (r *CircuitBreakerRegistry) GetStats() map[string]interface{} {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
-	
-	stats := make(map[string]interface{})
-	for name, cb := range r.breakers {
-		stats[name] = map[string]interface{}{
-			"state":    cb.State(),
-			"failures":  cb.Failures(),
-		}
+func (r *CircuitBreakerRegistry) GetStats() map[string]CircuitBreakerStats {
+	stats := make(map[string]CircuitBreakerStats)
+	for name, cb := range r.GetAll() {
+		stats[name] = cb.Stats()
 	}
 	return stats
 }
@@ -0,0 +1,564 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errBoom = errors.New("boom")
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type transition struct {
+	from, to CircuitState
+}
+
+func newTestBreaker(clock *fakeClock, transitions *[]transition) *CircuitBreaker {
+	return NewCircuitBreaker(CircuitBreakerConfig{
+		Name:                 "test",
+		Window:               10 * time.Second,
+		WindowBuckets:        10,
+		MinRequests:          4,
+		FailureRateThreshold: 0.5,
+		ResetTimeout:         5 * time.Second,
+		HalfOpenMaxRequests:  2,
+		Now:                  clock.Now,
+		OnStateChange: func(name string, from, to CircuitState) {
+			if transitions != nil {
+				*transitions = append(*transitions, transition{from, to})
+			}
+		},
+	})
+}
+
+func succeed() error { return nil }
+func fail() error    { return errBoom }
+
+func trip(t *testing.T, cb *CircuitBreaker) {
+	t.Helper()
+	for i := 0; i < 4; i++ {
+		cb.Execute(fail)
+	}
+	if cb.State() != StateOpen {
+		t.Fatalf("expected breaker to be open, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreakerStaysClosedBelowMinRequests(t *testing.T) {
+	cb := newTestBreaker(newFakeClock(), nil)
+
+	for i := 0; i < 3; i++ {
+		if err := cb.Execute(fail); !errors.Is(err, errBoom) {
+			t.Fatalf("expected fn error, got %v", err)
+		}
+	}
+	if cb.State() != StateClosed {
+		t.Fatalf("expected closed with 3 of 4 minimum requests, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreakerTripsOnFailureRate(t *testing.T) {
+	var transitions []transition
+	cb := newTestBreaker(newFakeClock(), &transitions)
+
+	cb.Execute(succeed)
+	cb.Execute(succeed)
+	cb.Execute(fail)
+	if cb.State() != StateClosed {
+		t.Fatalf("expected closed at 1/3 failures, got %s", cb.State())
+	}
+
+	cb.Execute(fail)
+	if cb.State() != StateOpen {
+		t.Fatalf("expected open at 2/4 failures, got %s", cb.State())
+	}
+	if len(transitions) != 1 || transitions[0] != (transition{StateClosed, StateOpen}) {
+		t.Fatalf("unexpected transitions: %v", transitions)
+	}
+
+	called := false
+	err := cb.Execute(func() error { called = true; return nil })
+	if !errors.Is(err, ErrCircuitBreakerOpen) {
+		t.Fatalf("expected ErrCircuitBreakerOpen, got %v", err)
+	}
+	if called {
+		t.Fatal("open breaker must not call fn")
+	}
+}
+
+func TestCircuitBreakerWindowSlides(t *testing.T) {
+	clock := newFakeClock()
+	cb := newTestBreaker(clock, nil)
+
+	cb.Execute(fail)
+	cb.Execute(fail)
+	cb.Execute(fail)
+
+	// The failures age out of the 10s window
+	clock.Advance(11 * time.Second)
+	if got := cb.Failures(); got != 0 {
+		t.Fatalf("expected failures to expire, got %d", got)
+	}
+
+	cb.Execute(fail)
+	cb.Execute(succeed)
+	cb.Execute(succeed)
+	cb.Execute(succeed)
+	if cb.State() != StateClosed {
+		t.Fatalf("expected closed at 1/4 failures in window, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreakerHalfOpenLimitsProbes(t *testing.T) {
+	clock := newFakeClock()
+	cb := newTestBreaker(clock, nil)
+	trip(t, cb)
+
+	clock.Advance(4 * time.Second)
+	if err := cb.Execute(succeed); !errors.Is(err, ErrCircuitBreakerOpen) {
+		t.Fatalf("expected rejection before reset timeout, got %v", err)
+	}
+
+	clock.Advance(time.Second)
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("expected half-open after reset timeout, got %s", cb.State())
+	}
+
+	// Hold both probe slots open and check a third call is rejected
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cb.Execute(func() error {
+				started <- struct{}{}
+				<-release
+				return nil
+			})
+		}()
+	}
+	<-started
+	<-started
+
+	err := cb.Execute(succeed)
+	if !errors.Is(err, ErrTooManyProbes) || !errors.Is(err, ErrCircuitBreakerOpen) {
+		t.Fatalf("expected ErrTooManyProbes wrapping ErrCircuitBreakerOpen, got %v", err)
+	}
+
+	close(release)
+	wg.Wait()
+	if cb.State() != StateClosed {
+		t.Fatalf("expected closed after all probes succeeded, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreakerHalfOpenClosesAfterProbes(t *testing.T) {
+	clock := newFakeClock()
+	var transitions []transition
+	cb := newTestBreaker(clock, &transitions)
+	trip(t, cb)
+	clock.Advance(5 * time.Second)
+
+	if err := cb.Execute(succeed); err != nil {
+		t.Fatalf("expected probe to run, got %v", err)
+	}
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("expected half-open after one of two probes, got %s", cb.State())
+	}
+	if err := cb.Execute(succeed); err != nil {
+		t.Fatalf("expected probe to run, got %v", err)
+	}
+	if cb.State() != StateClosed {
+		t.Fatalf("expected closed, got %s", cb.State())
+	}
+
+	want := []transition{{StateClosed, StateOpen}, {StateOpen, StateHalfOpen}, {StateHalfOpen, StateClosed}}
+	if len(transitions) != len(want) {
+		t.Fatalf("expected transitions %v, got %v", want, transitions)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Fatalf("expected transitions %v, got %v", want, transitions)
+		}
+	}
+
+	// Closing starts from an empty window
+	if got := cb.Failures(); got != 0 {
+		t.Fatalf("expected empty window after closing, got %d failures", got)
+	}
+}
+
+func TestCircuitBreakerHalfOpenFailureReopens(t *testing.T) {
+	clock := newFakeClock()
+	cb := newTestBreaker(clock, nil)
+	trip(t, cb)
+	clock.Advance(5 * time.Second)
+
+	if err := cb.Execute(fail); !errors.Is(err, errBoom) {
+		t.Fatalf("expected probe error, got %v", err)
+	}
+	if cb.State() != StateOpen {
+		t.Fatalf("expected reopened breaker, got %s", cb.State())
+	}
+
+	// The reset timeout restarts from the probe failure
+	clock.Advance(4 * time.Second)
+	if err := cb.Execute(succeed); !errors.Is(err, ErrCircuitBreakerOpen) {
+		t.Fatalf("expected rejection, got %v", err)
+	}
+}
+
+func TestCircuitBreakerPassesContextWithTimeout(t *testing.T) {
+	cb := NewCircuitBreaker(CircuitBreakerConfig{Name: "ctx", RequestTimeout: 20 * time.Millisecond})
+
+	type key struct{}
+	parent := context.WithValue(context.Background(), key{}, "value")
+
+	err := cb.ExecuteWithContext(parent, func(ctx context.Context) error {
+		if ctx.Value(key{}) != "value" {
+			t.Error("fn context does not derive from the caller's context")
+		}
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("fn context has no deadline")
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if got := cb.Failures(); got != 1 {
+		t.Fatalf("expected request timeout to count as failure, got %d", got)
+	}
+}
+
+func TestCircuitBreakerIgnoresCallerCancellation(t *testing.T) {
+	cb := newTestBreaker(newFakeClock(), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := cb.ExecuteWithContext(ctx, func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if stats := cb.Stats(); stats.Requests != 0 {
+		t.Fatalf("expected cancelled call not to be recorded, got %d requests", stats.Requests)
+	}
+
+	// Already-cancelled contexts are rejected without calling fn
+	called := false
+	cb.ExecuteWithContext(ctx, func(context.Context) error { called = true; return nil })
+	if called {
+		t.Fatal("fn called with a cancelled context")
+	}
+}
+
+func TestCircuitBreakerIgnoredProbeFreesSlot(t *testing.T) {
+	clock := newFakeClock()
+	cb := NewCircuitBreaker(CircuitBreakerConfig{
+		Name:         "probe",
+		MinRequests:  1,
+		ResetTimeout: time.Second,
+		Now:          clock.Now,
+	})
+	cb.Execute(fail)
+	clock.Advance(time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cb.ExecuteWithContext(ctx, func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	})
+
+	if err := cb.Execute(succeed); err != nil {
+		t.Fatalf("expected the freed probe slot to be usable, got %v", err)
+	}
+	if cb.State() != StateClosed {
+		t.Fatalf("expected closed, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreakerIsFailure(t *testing.T) {
+	errNotFound := errors.New("not found")
+	cb := NewCircuitBreaker(CircuitBreakerConfig{
+		Name:        "classified",
+		MinRequests: 1,
+		IsFailure: func(err error) bool {
+			return err != nil && !errors.Is(err, errNotFound)
+		},
+	})
+
+	if err := cb.Execute(func() error { return errNotFound }); !errors.Is(err, errNotFound) {
+		t.Fatalf("expected fn error to be returned, got %v", err)
+	}
+	if cb.State() != StateClosed {
+		t.Fatalf("expected excluded error not to trip, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreakerDiscardsStaleResults(t *testing.T) {
+	cb := newTestBreaker(newFakeClock(), nil)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cb.Execute(func() error {
+			close(started)
+			<-release
+			return errBoom
+		})
+	}()
+
+	<-started
+	cb.Reset()
+	close(release)
+	<-done
+
+	if got := cb.Failures(); got != 0 {
+		t.Fatalf("expected result from before Reset to be discarded, got %d failures", got)
+	}
+}
+
+func TestExecuteWithResultRunsOnce(t *testing.T) {
+	cb := NewCircuitBreaker(CircuitBreakerConfig{Name: "result"})
+
+	calls := 0
+	got, err := ExecuteWithResult(cb, func() (int, error) {
+		calls++
+		return 42, nil
+	})
+	if err != nil || got != 42 {
+		t.Fatalf("expected 42, nil; got %d, %v", got, err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected fn to run once, ran %d times", calls)
+	}
+
+	got, err = ExecuteWithContextAndResult(context.Background(), cb, func(ctx context.Context) (int, error) {
+		return 7, errBoom
+	})
+	if got != 7 || !errors.Is(err, errBoom) {
+		t.Fatalf("expected 7, errBoom; got %d, %v", got, err)
+	}
+}
+
+func TestCircuitBreakerRecordsPanics(t *testing.T) {
+	cb := NewCircuitBreaker(CircuitBreakerConfig{Name: "panic", MinRequests: 1})
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected panic to propagate")
+			}
+		}()
+		cb.Execute(func() error { panic("boom") })
+	}()
+
+	if cb.State() != StateOpen {
+		t.Fatalf("expected panic to count as failure, got %s", cb.State())
+	}
+}
+
+func TestOnStateChangeMayReadBreaker(t *testing.T) {
+	var cb *CircuitBreaker
+	var seen CircuitState
+	cb = NewCircuitBreaker(CircuitBreakerConfig{
+		Name:        "callback",
+		MinRequests: 1,
+		OnStateChange: func(name string, from, to CircuitState) {
+			// Would deadlock if called with the lock held
+			seen = cb.State()
+		},
+	})
+
+	cb.Execute(fail)
+	if seen != StateOpen {
+		t.Fatalf("expected callback to observe open state, got %s", seen)
+	}
+}
+
+func TestCircuitBreakerConcurrentUse(t *testing.T) {
+	cb := NewCircuitBreaker(CircuitBreakerConfig{Name: "concurrent", MinRequests: 5, ResetTimeout: time.Millisecond})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if (i+j)%3 == 0 {
+					cb.Execute(fail)
+				} else {
+					cb.Execute(succeed)
+				}
+				cb.Stats()
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestCircuitBreakerRegistry(t *testing.T) {
+	registry := NewCircuitBreakerRegistry()
+	a := registry.Register(CircuitBreakerConfig{Name: "a", MinRequests: 1})
+	registry.Register(CircuitBreakerConfig{Name: "b"})
+
+	if got, ok := registry.Get("a"); !ok || got != a {
+		t.Fatal("expected Get to return the registered breaker")
+	}
+	if _, ok := registry.Get("missing"); ok {
+		t.Fatal("expected missing breaker not to be found")
+	}
+
+	a.Execute(fail)
+	stats := registry.GetStats()
+	if len(stats) != 2 {
+		t.Fatalf("expected stats for 2 breakers, got %d", len(stats))
+	}
+	if stats["a"].State != StateOpen || stats["a"].Failures != 1 || stats["a"].FailureRate != 1 {
+		t.Fatalf("unexpected stats for a: %+v", stats["a"])
+	}
+	if stats["b"].State != StateClosed {
+		t.Fatalf("unexpected stats for b: %+v", stats["b"])
+	}
+
+	registry.ResetAll()
+	if a.State() != StateClosed || a.Failures() != 0 {
+		t.Fatalf("expected ResetAll to close a, got %s with %d failures", a.State(), a.Failures())
+	}
+}
+
+func TestCircuitBreakerForceOpen(t *testing.T) {
+	clock := newFakeClock()
+	var transitions []transition
+	cb := newTestBreaker(clock, &transitions)
+
+	cb.ForceOpen()
+	clock.Advance(time.Minute)
+
+	if err := cb.Execute(succeed); !errors.Is(err, ErrCircuitBreakerOpen) {
+		t.Fatalf("expected forced-open breaker to reject past its reset timeout, got %v", err)
+	}
+	if stats := cb.Stats(); stats.State != StateOpen || !stats.Forced {
+		t.Fatalf("expected forced open stats, got %+v", stats)
+	}
+
+	cb.Reset()
+	if err := cb.Execute(succeed); err != nil {
+		t.Fatalf("expected reset breaker to admit calls, got %v", err)
+	}
+	if cb.Stats().Forced {
+		t.Fatal("expected Reset to lift the forced state")
+	}
+
+	want := []transition{{StateClosed, StateOpen}, {StateOpen, StateClosed}}
+	if len(transitions) != len(want) || transitions[0] != want[0] || transitions[1] != want[1] {
+		t.Fatalf("expected transitions %v, got %v", want, transitions)
+	}
+}
+
+func TestCircuitBreakerForceClose(t *testing.T) {
+	cb := newTestBreaker(newFakeClock(), nil)
+	trip(t, cb)
+
+	cb.ForceClose()
+	for i := 0; i < 10; i++ {
+		if err := cb.Execute(fail); !errors.Is(err, errBoom) {
+			t.Fatalf("expected forced-closed breaker to admit calls, got %v", err)
+		}
+	}
+	if stats := cb.Stats(); stats.State != StateClosed || !stats.Forced || stats.Failures != 10 {
+		t.Fatalf("expected forced closed breaker still counting failures, got %+v", stats)
+	}
+
+	cb.Reset()
+	trip(t, cb)
+}
+
+type recordedMetrics struct {
+	mu          sync.Mutex
+	states      map[string]int
+	transitions []string
+}
+
+func (m *recordedMetrics) SetCircuitBreakerState(name string, state int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[name] = state
+}
+
+func (m *recordedMetrics) RecordCircuitBreakerTransition(name, from, to string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.transitions = append(m.transitions, name+":"+from+"->"+to)
+}
+
+func TestCircuitBreakerRegistryMetrics(t *testing.T) {
+	recorder := &recordedMetrics{states: make(map[string]int)}
+	registry := NewCircuitBreakerRegistry()
+	registry.SetMetrics(recorder)
+
+	var callerNotified bool
+	cb := registry.Register(CircuitBreakerConfig{
+		Name:        "db",
+		MinRequests: 1,
+		OnStateChange: func(name string, from, to CircuitState) {
+			callerNotified = true
+		},
+	})
+	if state, ok := recorder.states["db"]; !ok || state != int(StateClosed) {
+		t.Fatalf("expected closed state recorded on register, got %v", recorder.states)
+	}
+
+	cb.Execute(fail)
+	if recorder.states["db"] != int(StateOpen) {
+		t.Fatalf("expected open state recorded, got %v", recorder.states)
+	}
+	if len(recorder.transitions) != 1 || recorder.transitions[0] != "db:closed->open" {
+		t.Fatalf("unexpected transitions %v", recorder.transitions)
+	}
+	if !callerNotified {
+		t.Fatal("expected the configured OnStateChange to still be called")
+	}
+}
+
+func TestCircuitStateString(t *testing.T) {
+	for state, want := range map[CircuitState]string{
+		StateClosed:      "closed",
+		StateOpen:        "open",
+		StateHalfOpen:    "half_open",
+		CircuitState(42): "unknown",
+	} {
+		if got := state.String(); got != want {
+			t.Errorf("%d: expected %q, got %q", state, want, got)
+		}
+	}
+}
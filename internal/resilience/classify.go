@@ -0,0 +1,163 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/redis/go-redis/v9"
+)
+
+// classifiedError carries an explicit retry decision made by the code that
+// produced the error, which takes precedence over IsRetryableError's rules
+type classifiedError struct {
+	err       error
+	retryable bool
+}
+
+func (e *classifiedError) Error() string   { return e.err.Error() }
+func (e *classifiedError) Unwrap() error   { return e.err }
+func (e *classifiedError) Retryable() bool { return e.retryable }
+
+// MarkRetryable marks err as worth retrying
+func MarkRetryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{err: err, retryable: true}
+}
+
+// MarkPermanent marks err as not worth retrying
+func MarkPermanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{err: err, retryable: false}
+}
+
+// redisRetryablePrefixes are Redis replies for a server that is temporarily
+// unable to serve the command (loading, failing over, busy with a script)
+var redisRetryablePrefixes = []string{"LOADING", "BUSY", "TRYAGAIN", "CLUSTERDOWN", "MASTERDOWN", "READONLY"}
+
+// IsRetryableError determines if an error is retryable. An exhausted retry
+// budget is never retryable, even when the error it wraps is. Errors marked
+// with MarkRetryable or MarkPermanent (or implementing Retryable() bool)
+// decide for themselves; otherwise transient network, Postgres and Redis
+// failures are retryable and anything unrecognised is not.
+func IsRetryableError(err error) bool {
+	if err == nil || errors.Is(err, ErrRetryBudgetExhausted) {
+		return false
+	}
+
+	var classified interface{ Retryable() bool }
+	if errors.As(err, &classified) {
+		return classified.Retryable()
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return false
+	case errors.Is(err, context.DeadlineExceeded):
+		return true
+	case errors.Is(err, ErrCircuitBreakerOpen), errors.Is(err, ErrRejected):
+		// The dependency is shedding load; retrying straight away only adds to it
+		return false
+	case errors.Is(err, ErrServiceUnavailable):
+		return true
+	}
+
+	if retryable, ok := classifyPostgresError(err); ok {
+		return retryable
+	}
+	if retryable, ok := classifyRedisError(err); ok {
+		return retryable
+	}
+	return isRetryableNetError(err)
+}
+
+// classifyPostgresError reports whether err is a retryable Postgres error. ok
+// is false when err did not come from Postgres.
+func classifyPostgresError(err error) (retryable, ok bool) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		if strings.HasPrefix(pgErr.Code, "08") { // connection_exception class
+			return true, true
+		}
+		switch pgErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"53300", // too_many_connections
+			"55P03", // lock_not_available
+			"57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03": // cannot_connect_now
+			return true, true
+		}
+		return false, true
+	}
+
+	if pgconn.SafeToRetry(err) || pgconn.Timeout(err) {
+		return true, true
+	}
+	return false, false
+}
+
+// classifyRedisError reports whether err is a retryable Redis error. ok is
+// false when err did not come from Redis.
+func classifyRedisError(err error) (retryable, ok bool) {
+	switch {
+	case errors.Is(err, redis.Nil), errors.Is(err, redis.ErrClosed):
+		return false, true
+	case errors.Is(err, redis.ErrPoolTimeout):
+		return true, true
+	}
+
+	var redisErr redis.Error
+	if !errors.As(err, &redisErr) {
+		return false, false
+	}
+	for _, prefix := range redisRetryablePrefixes {
+		if redis.HasErrorPrefix(err, prefix) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// isRetryableNetError reports whether err is a connection failure or network timeout
+func isRetryableNetError(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	// An HTTP client sees a bare EOF when the server closed a kept-alive connection
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && errors.Is(urlErr.Err, io.EOF) {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return netErr.Timeout()
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/redis/go-redis/v9"
+)
+
+// redisReply is an error reply as the go-redis client reports it
+type redisReply string
+
+func (e redisReply) Error() string { return string(e) }
+func (redisReply) RedisError()     {}
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"unrecognised", errors.New("invalid account number"), false},
+
+		// Context errors
+		{"canceled", context.Canceled, false},
+		{"wrapped canceled", fmt.Errorf("query: %w", context.Canceled), false},
+		{"deadline exceeded", fmt.Errorf("query: %w", context.DeadlineExceeded), true},
+
+		// Explicit marks win over the type rules
+		{"marked retryable", MarkRetryable(errors.New("rail busy")), true},
+		{"marked permanent timeout", MarkPermanent(context.DeadlineExceeded), false},
+		{"marked retryable canceled", MarkRetryable(context.Canceled), true},
+		{"wrapped mark", fmt.Errorf("submit: %w", MarkPermanent(syscall.ECONNRESET)), false},
+
+		// Load shedding
+		{"open breaker", ErrCircuitBreakerOpen, false},
+		{"limiter rejection", &RejectedError{Name: "db", Reason: "queue full"}, false},
+		{"budget exhausted", ErrRetryBudgetExhausted, false},
+		{"budget exhausted after a retryable error", fmt.Errorf("%w for db: %w", ErrRetryBudgetExhausted, MarkRetryable(errBoom)), false},
+		{"service unavailable", ErrServiceUnavailable, true},
+
+		// Postgres
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, true},
+		{"deadlock", &pgconn.PgError{Code: "40P01"}, true},
+		{"connection failure", fmt.Errorf("update: %w", &pgconn.PgError{Code: "08006"}), true},
+		{"too many connections", &pgconn.PgError{Code: "53300"}, true},
+		{"admin shutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"invalid input", &pgconn.PgError{Code: "22P02"}, false},
+		{"no rows", pgx.ErrNoRows, false},
+
+		// Redis
+		{"redis nil", redis.Nil, false},
+		{"redis closed", redis.ErrClosed, false},
+		{"redis pool timeout", redis.ErrPoolTimeout, true},
+		{"redis loading", redisReply("LOADING Redis is loading the dataset in memory"), true},
+		{"redis busy", redisReply("BUSY Redis is busy running a script"), true},
+		{"redis readonly with ERR prefix", redisReply("ERR READONLY You can't write against a read only replica."), true},
+		{"redis cluster down", redisReply("CLUSTERDOWN The cluster is down"), true},
+		{"redis wrong type", redisReply("WRONGTYPE Operation against a key holding the wrong kind of value"), false},
+		{"redis unknown command", redisReply("ERR unknown command 'FOO'"), false},
+
+		// Network
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, true},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"broken pipe", syscall.EPIPE, true},
+		{"unexpected eof", io.ErrUnexpectedEOF, true},
+		{"http keep-alive eof", &url.Error{Op: "Post", URL: "https://rail", Err: io.EOF}, true},
+		{"dns timeout", &net.DNSError{Err: "timeout", Name: "rail", IsTimeout: true}, true},
+		{"dns not found", &net.DNSError{Err: "no such host", Name: "rail", IsNotFound: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryableError(tt.err); got != tt.want {
+				t.Errorf("IsRetryableError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRetryableErrorRedisReplies(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	defer client.Close()
+
+	mr.SetError("LOADING Redis is loading the dataset in memory")
+	err := client.Get(context.Background(), "key").Err()
+	if !IsRetryableError(err) {
+		t.Errorf("IsRetryableError(%v) = false, want true", err)
+	}
+
+	mr.SetError("")
+	mr.Set("key", "value")
+	err = client.LPush(context.Background(), "key", "x").Err()
+	if err == nil || IsRetryableError(err) {
+		t.Errorf("IsRetryableError(%v) = true, want false for WRONGTYPE", err)
+	}
+}
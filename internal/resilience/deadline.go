@@ -0,0 +1,117 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrDeadlineTooShort is returned without calling the dependency when the
+	// caller's deadline leaves less than MinTimeout for the call
+	ErrDeadlineTooShort = errors.New("not enough time left before deadline")
+)
+
+// DeadlineConfig holds configuration for a dependency's call deadline
+type DeadlineConfig struct {
+	Name string
+
+	// Timeout caps a single call (default 5s)
+	Timeout time.Duration
+
+	// Headroom is kept back from the caller's deadline so the caller can still
+	// handle a timed-out call before its own deadline expires
+	Headroom time.Duration
+
+	// MinTimeout is the least time worth starting a call with (default 10ms)
+	MinTimeout time.Duration
+}
+
+// Deadline derives per-call deadlines for one dependency from the caller's
+// context: a call gets Timeout or whatever the caller has left minus
+// Headroom, whichever is sooner, so a slow dependency can never hold a
+// request past its own deadline.
+type Deadline struct {
+	name       string
+	timeout    time.Duration
+	headroom   time.Duration
+	minTimeout time.Duration
+}
+
+// NewDeadline creates a new dependency deadline
+func NewDeadline(config DeadlineConfig) *Deadline {
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.Headroom < 0 {
+		config.Headroom = 0
+	}
+	if config.MinTimeout <= 0 {
+		config.MinTimeout = 10 * time.Millisecond
+	}
+
+	return &Deadline{
+		name:       config.Name,
+		timeout:    config.Timeout,
+		headroom:   config.Headroom,
+		minTimeout: config.MinTimeout,
+	}
+}
+
+// Name returns the dependency's name
+func (d *Deadline) Name() string {
+	return d.name
+}
+
+// Context returns a context for one call to the dependency. The cancel func
+// must be called once the call returns.
+func (d *Deadline) Context(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	timeout := d.timeout
+	if parent, ok := ctx.Deadline(); ok {
+		timeout = min(timeout, time.Until(parent)-d.headroom)
+	}
+	if timeout < d.minTimeout {
+		return nil, nil, fmt.Errorf("%w for %s call: %s left", ErrDeadlineTooShort, d.name, max(timeout, 0))
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	return callCtx, cancel, nil
+}
+
+// Execute runs fn under the dependency's deadline. A nil d runs fn unchanged.
+func (d *Deadline) Execute(ctx context.Context, fn func(context.Context) error) error {
+	_, err := ExecuteWithDeadline(ctx, d, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// ExecuteWithDeadline runs fn under d's deadline and returns its result. A
+// nil d runs fn with ctx unchanged. A call cut off by d (rather than by the
+// caller) fails with an error wrapping context.DeadlineExceeded.
+func ExecuteWithDeadline[T any](ctx context.Context, d *Deadline, fn func(context.Context) (T, error)) (T, error) {
+	if d == nil {
+		return fn(ctx)
+	}
+
+	callCtx, cancel, err := d.Context(ctx)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	defer cancel()
+
+	result, err := fn(callCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("%w: %w", context.DeadlineExceeded, err)
+		}
+		return result, fmt.Errorf("%s call timed out: %w", d.name, err)
+	}
+	return result, err
+}
@@ -0,0 +1,132 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// callTimeout runs an empty call under d and returns the time it was given
+func callTimeout(t *testing.T, ctx context.Context, d *Deadline) time.Duration {
+	t.Helper()
+
+	var got time.Duration
+	err := d.Execute(ctx, func(ctx context.Context) error {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			t.Fatal("call context has no deadline")
+		}
+		got = time.Until(deadline)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	return got
+}
+
+func TestDeadlinePropagation(t *testing.T) {
+	d := NewDeadline(DeadlineConfig{Name: "db", Timeout: 2 * time.Second, Headroom: 100 * time.Millisecond})
+	const slack = 50 * time.Millisecond
+
+	tests := []struct {
+		name   string
+		parent time.Duration // 0 means no caller deadline
+		want   time.Duration
+	}{
+		{"no caller deadline uses the timeout", 0, 2 * time.Second},
+		{"distant caller deadline uses the timeout", time.Minute, 2 * time.Second},
+		{"near caller deadline is clamped minus headroom", time.Second, 900 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.parent > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.parent)
+				defer cancel()
+			}
+
+			got := callTimeout(t, ctx, d)
+			if got > tt.want || got < tt.want-slack {
+				t.Errorf("call timeout = %s, want about %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeadlineTooShort(t *testing.T) {
+	d := NewDeadline(DeadlineConfig{Name: "db", Headroom: 100 * time.Millisecond, MinTimeout: 50 * time.Millisecond})
+
+	for _, left := range []time.Duration{120 * time.Millisecond, 50 * time.Millisecond} {
+		ctx, cancel := context.WithTimeout(context.Background(), left)
+		called := false
+		err := d.Execute(ctx, func(ctx context.Context) error {
+			called = true
+			return nil
+		})
+		cancel()
+
+		if !errors.Is(err, ErrDeadlineTooShort) {
+			t.Errorf("%s left: error = %v, want %v", left, err, ErrDeadlineTooShort)
+		}
+		if called {
+			t.Errorf("%s left: dependency was called", left)
+		}
+	}
+}
+
+func TestDeadlineCallerAlreadyDone(t *testing.T) {
+	d := NewDeadline(DeadlineConfig{Name: "db"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := d.Execute(ctx, func(ctx context.Context) error {
+		t.Fatal("dependency called with a cancelled context")
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestDeadlineTimeoutAttribution(t *testing.T) {
+	d := NewDeadline(DeadlineConfig{Name: "db", Timeout: 20 * time.Millisecond, MinTimeout: time.Millisecond})
+	block := func(ctx context.Context) error {
+		<-ctx.Done()
+		return errors.New("query interrupted")
+	}
+
+	// Cut off by the dependency's own timeout: reported as a timeout of the call
+	err := d.Execute(context.Background(), block)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want it to wrap %v", err, context.DeadlineExceeded)
+	}
+	if !strings.Contains(err.Error(), "db call timed out") {
+		t.Errorf("error = %q, want it to name the dependency", err)
+	}
+
+	// Cut off by the caller: the call's own error is returned unchanged
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(5*time.Millisecond, cancel)
+	err = d.Execute(ctx, block)
+	if err == nil || err.Error() != "query interrupted" {
+		t.Errorf("error = %v, want the call's error", err)
+	}
+}
+
+func TestNilDeadline(t *testing.T) {
+	var d *Deadline
+	ctx := context.WithValue(context.Background(), struct{}{}, "caller")
+
+	got, err := ExecuteWithDeadline(ctx, d, func(callCtx context.Context) (bool, error) {
+		_, hasDeadline := callCtx.Deadline()
+		return callCtx == ctx && !hasDeadline, nil
+	})
+	if err != nil || !got {
+		t.Errorf("nil deadline did not pass the caller's context through (err %v)", err)
+	}
+}
@@ -0,0 +1,222 @@
+package resilience
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// HedgeConfig holds configuration for hedged requests
+type HedgeConfig struct {
+	Name string
+
+	// Percentile of recent latencies after which a hedge is sent (default 0.95)
+	Percentile float64
+
+	// InitialDelay is the hedge delay used until MinSamples calls have
+	// succeeded (default 100ms)
+	InitialDelay time.Duration
+	MinSamples   int // default 20
+	WindowSize   int // latencies the percentile is computed over (default 1000)
+
+	// MaxHedges is how many extra attempts a call may send (default 1)
+	MaxHedges int
+}
+
+// HedgeStats is a point-in-time view of a hedger
+type HedgeStats struct {
+	Name      string        `json:"name"`
+	Delay     time.Duration `json:"delay"`
+	Calls     int64         `json:"calls"`
+	Hedges    int64         `json:"hedges"`
+	HedgeWins int64         `json:"hedge_wins"`
+}
+
+// Hedger tracks a dependency's recent latencies so Hedge can send a backup
+// attempt once a call has taken longer than most calls do
+type Hedger struct {
+	name         string
+	percentile   float64
+	initialDelay time.Duration
+	minSamples   int
+	windowSize   int
+	maxHedges    int
+
+	mu        sync.Mutex
+	samples   []time.Duration
+	next      int
+	stale     int
+	delay     time.Duration
+	calls     int64
+	hedges    int64
+	hedgeWins int64
+}
+
+// NewHedger creates a new hedger
+func NewHedger(config HedgeConfig) *Hedger {
+	if config.Percentile <= 0 || config.Percentile >= 1 {
+		config.Percentile = 0.95
+	}
+	if config.InitialDelay <= 0 {
+		config.InitialDelay = 100 * time.Millisecond
+	}
+	if config.MinSamples <= 0 {
+		config.MinSamples = 20
+	}
+	if config.WindowSize <= 0 {
+		config.WindowSize = 1000
+	}
+	if config.WindowSize < config.MinSamples {
+		config.WindowSize = config.MinSamples
+	}
+	if config.MaxHedges <= 0 {
+		config.MaxHedges = 1
+	}
+
+	return &Hedger{
+		name:         config.Name,
+		percentile:   config.Percentile,
+		initialDelay: config.InitialDelay,
+		minSamples:   config.MinSamples,
+		windowSize:   config.WindowSize,
+		maxHedges:    config.MaxHedges,
+		samples:      make([]time.Duration, 0, config.WindowSize),
+	}
+}
+
+// Name returns the dependency's name
+func (h *Hedger) Name() string {
+	return h.name
+}
+
+// Delay returns how long a call runs before it is hedged
+func (h *Hedger) Delay() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.currentDelay()
+}
+
+func (h *Hedger) currentDelay() time.Duration {
+	if len(h.samples) < h.minSamples {
+		return h.initialDelay
+	}
+
+	// Sorting the window on every call would cost more than the calls it
+	// hedges, so the percentile is refreshed every tenth of a window
+	if h.delay == 0 || h.stale >= max(1, h.windowSize/10) {
+		sorted := slices.Clone(h.samples)
+		slices.Sort(sorted)
+		index := int(math.Ceil(h.percentile*float64(len(sorted)))) - 1
+		h.delay = sorted[max(0, index)]
+		h.stale = 0
+	}
+	return h.delay
+}
+
+func (h *Hedger) recordCall() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls++
+}
+
+func (h *Hedger) recordHedge() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hedges++
+}
+
+func (h *Hedger) recordSuccess(latency time.Duration, hedge bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if hedge {
+		h.hedgeWins++
+	}
+
+	if len(h.samples) < h.windowSize {
+		h.samples = append(h.samples, latency)
+	} else {
+		h.samples[h.next] = latency
+		h.next = (h.next + 1) % h.windowSize
+	}
+	h.stale++
+}
+
+// Stats returns the hedger's current delay and counters
+func (h *Hedger) Stats() HedgeStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return HedgeStats{
+		Name:      h.name,
+		Delay:     h.currentDelay(),
+		Calls:     h.calls,
+		Hedges:    h.hedges,
+		HedgeWins: h.hedgeWins,
+	}
+}
+
+// Hedge runs fn and, each time it has gone h's hedge delay without an
+// answer, starts another attempt alongside it, up to MaxHedges extra
+// attempts. The first attempt to succeed wins and the others are cancelled;
+// if every attempt fails the last error is returned. Only hedge idempotent
+// reads. A nil h runs fn once.
+func Hedge[T any](ctx context.Context, h *Hedger, fn func(context.Context) (T, error)) (T, error) {
+	var zero T
+	if h == nil {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type attempt struct {
+		value   T
+		err     error
+		latency time.Duration
+		hedge   bool
+	}
+
+	// Buffered so losing attempts can finish after Hedge has returned
+	results := make(chan attempt, h.maxHedges+1)
+	launch := func(hedge bool) {
+		go func() {
+			start := time.Now()
+			value, err := fn(ctx)
+			results <- attempt{value: value, err: err, latency: time.Since(start), hedge: hedge}
+		}()
+	}
+
+	h.recordCall()
+	launch(false)
+	launched, inFlight := 1, 1
+
+	timer := time.NewTimer(h.Delay())
+	defer timer.Stop()
+
+	for {
+		select {
+		case result := <-results:
+			inFlight--
+			if result.err == nil {
+				h.recordSuccess(result.latency, result.hedge)
+				return result.value, nil
+			}
+			if inFlight == 0 {
+				return zero, result.err
+			}
+		case <-timer.C:
+			h.recordHedge()
+			launch(true)
+			launched++
+			inFlight++
+			if launched <= h.maxHedges {
+				timer.Reset(h.Delay())
+			}
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestHedger(maxHedges int) *Hedger {
+	return NewHedger(HedgeConfig{Name: "test", InitialDelay: 20 * time.Millisecond, MaxHedges: maxHedges})
+}
+
+func TestHedgeFastCallIsNotHedged(t *testing.T) {
+	h := newTestHedger(1)
+	var attempts atomic.Int32
+
+	got, err := Hedge(context.Background(), h, func(ctx context.Context) (string, error) {
+		attempts.Add(1)
+		return "primary", nil
+	})
+	if err != nil || got != "primary" {
+		t.Fatalf("Hedge = %q, %v, want primary", got, err)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("attempts = %d, want 1", n)
+	}
+	if stats := h.Stats(); stats.Calls != 1 || stats.Hedges != 0 || stats.HedgeWins != 0 {
+		t.Errorf("stats = %+v, want one call and no hedges", stats)
+	}
+}
+
+func TestHedgeWinnerCancelsSlowAttempt(t *testing.T) {
+	h := newTestHedger(1)
+	var attempts atomic.Int32
+	primaryCancelled := make(chan struct{})
+
+	got, err := Hedge(context.Background(), h, func(ctx context.Context) (string, error) {
+		if attempts.Add(1) == 1 {
+			<-ctx.Done()
+			close(primaryCancelled)
+			return "", ctx.Err()
+		}
+		return "hedge", nil
+	})
+	if err != nil || got != "hedge" {
+		t.Fatalf("Hedge = %q, %v, want hedge", got, err)
+	}
+
+	select {
+	case <-primaryCancelled:
+	case <-time.After(time.Second):
+		t.Fatal("slow attempt was not cancelled after the hedge won")
+	}
+	if stats := h.Stats(); stats.Hedges != 1 || stats.HedgeWins != 1 {
+		t.Errorf("stats = %+v, want one hedge that won", stats)
+	}
+}
+
+func TestHedgePrimaryWinsAfterHedgeSent(t *testing.T) {
+	h := newTestHedger(1)
+	var attempts atomic.Int32
+	release := make(chan struct{})
+
+	got, err := Hedge(context.Background(), h, func(ctx context.Context) (string, error) {
+		if attempts.Add(1) == 1 {
+			<-release
+			return "primary", nil
+		}
+		// The hedge is slower still; unblock the primary once it has started
+		close(release)
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	if err != nil || got != "primary" {
+		t.Fatalf("Hedge = %q, %v, want primary", got, err)
+	}
+	if stats := h.Stats(); stats.Hedges != 1 || stats.HedgeWins != 0 {
+		t.Errorf("stats = %+v, want one hedge that lost", stats)
+	}
+}
+
+func TestHedgeFailures(t *testing.T) {
+	t.Run("failure before the delay is not hedged", func(t *testing.T) {
+		h := newTestHedger(1)
+		var attempts atomic.Int32
+
+		_, err := Hedge(context.Background(), h, func(ctx context.Context) (int, error) {
+			attempts.Add(1)
+			return 0, errBoom
+		})
+		if !errors.Is(err, errBoom) {
+			t.Errorf("error = %v, want %v", err, errBoom)
+		}
+		if n := attempts.Load(); n != 1 {
+			t.Errorf("attempts = %d, want 1", n)
+		}
+	})
+
+	t.Run("successful hedge hides a failed attempt", func(t *testing.T) {
+		h := newTestHedger(1)
+		var attempts atomic.Int32
+		hedgeStarted := make(chan struct{})
+
+		got, err := Hedge(context.Background(), h, func(ctx context.Context) (int, error) {
+			if attempts.Add(1) == 1 {
+				<-hedgeStarted
+				return 0, errBoom
+			}
+			close(hedgeStarted)
+			time.Sleep(10 * time.Millisecond)
+			return 2, nil
+		})
+		if err != nil || got != 2 {
+			t.Errorf("Hedge = %d, %v, want 2", got, err)
+		}
+	})
+
+	t.Run("last error when every attempt fails", func(t *testing.T) {
+		h := newTestHedger(2)
+		var attempts atomic.Int32
+		errLast := errors.New("last")
+
+		_, err := Hedge(context.Background(), h, func(ctx context.Context) (int, error) {
+			n := attempts.Add(1)
+			// Each attempt outlives the hedge delay so all three are sent
+			time.Sleep(time.Duration(4-n) * 30 * time.Millisecond)
+			if n == 1 {
+				return 0, errLast
+			}
+			return 0, errBoom
+		})
+		if !errors.Is(err, errLast) {
+			t.Errorf("error = %v, want %v", err, errLast)
+		}
+		if n := attempts.Load(); n != 3 {
+			t.Errorf("attempts = %d, want 3 (MaxHedges 2)", n)
+		}
+	})
+}
+
+func TestHedgeCallerCancelled(t *testing.T) {
+	h := newTestHedger(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := Hedge(ctx, h, func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, errBoom
+	})
+	if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, errBoom) {
+		t.Errorf("error = %v, want the caller's deadline or the attempts' error", err)
+	}
+}
+
+func TestHedgeDelayFollowsPercentile(t *testing.T) {
+	h := NewHedger(HedgeConfig{Name: "test", Percentile: 0.5, InitialDelay: time.Second, MinSamples: 20, WindowSize: 20})
+
+	for i := 1; i < 20; i++ {
+		h.recordSuccess(time.Duration(i)*time.Millisecond, false)
+	}
+	if got := h.Delay(); got != time.Second {
+		t.Fatalf("delay before MinSamples = %s, want the initial %s", got, time.Second)
+	}
+
+	h.recordSuccess(20*time.Millisecond, false)
+	if got := h.Delay(); got != 10*time.Millisecond {
+		t.Fatalf("median delay = %s, want 10ms", got)
+	}
+
+	// The window slides: a full window of slow calls moves the percentile up
+	for i := 0; i < 20; i++ {
+		h.recordSuccess(50*time.Millisecond, false)
+	}
+	if got := h.Delay(); got != 50*time.Millisecond {
+		t.Errorf("delay after slow window = %s, want 50ms", got)
+	}
+}
+
+func TestNilHedger(t *testing.T) {
+	var attempts atomic.Int32
+	_, err := Hedge(context.Background(), nil, func(ctx context.Context) (int, error) {
+		attempts.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return 0, nil
+	})
+	if err != nil || attempts.Load() != 1 {
+		t.Errorf("nil hedger: attempts = %d, err = %v, want a single attempt", attempts.Load(), err)
+	}
+}
@@ -0,0 +1,95 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrRejected is matched by every RejectedError
+	ErrRejected = errors.New("request rejected: dependency at capacity")
+)
+
+// RejectedError is returned when a bulkhead or limiter sheds a call. Callers
+// serving HTTP should answer 503 with a Retry-After of RetryAfter.
+type RejectedError struct {
+	Name       string
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("%s rejected request: %s", e.Name, e.Reason)
+}
+
+func (e *RejectedError) Is(target error) bool {
+	return target == ErrRejected
+}
+
+// IsUnavailable reports whether err means a dependency refused the call to
+// protect itself (a limiter rejection, an open circuit breaker or the
+// dependency reporting itself unavailable), as opposed to the call failing
+func IsUnavailable(err error) bool {
+	return errors.Is(err, ErrRejected) ||
+		errors.Is(err, ErrCircuitBreakerOpen) ||
+		errors.Is(err, ErrServiceUnavailable)
+}
+
+// RetryAfter returns how long a caller should wait before retrying a rejected
+// call. ok is false when err carries no hint.
+func RetryAfter(err error) (time.Duration, bool) {
+	var rejected *RejectedError
+	if errors.As(err, &rejected) && rejected.RetryAfter > 0 {
+		return rejected.RetryAfter, true
+	}
+	return 0, false
+}
+
+// Limiter bounds the concurrent calls made to a dependency. Acquire either
+// admits a call, returning a done func that must be called exactly once with
+// the call's error, or rejects it with a *RejectedError (or ctx's error).
+type Limiter interface {
+	Acquire(ctx context.Context) (done func(err error), err error)
+}
+
+// Limit runs fn if l admits it. A nil l admits every call.
+func Limit(ctx context.Context, l Limiter, fn func(context.Context) error) error {
+	_, err := LimitWithResult(ctx, l, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// LimitWithResult runs fn if l admits it and returns its result. A nil l
+// admits every call.
+func LimitWithResult[T any](ctx context.Context, l Limiter, fn func(context.Context) (T, error)) (result T, err error) {
+	if l == nil {
+		return fn(ctx)
+	}
+
+	done, err := l.Acquire(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			done(fmt.Errorf("panic: %v", p))
+			panic(p)
+		}
+		done(err)
+	}()
+
+	return fn(ctx)
+}
+
+// LimiterStats is a point-in-time view of a bulkhead or limiter
+type LimiterStats struct {
+	Name     string `json:"name"`
+	Limit    int    `json:"limit"`
+	InFlight int    `json:"in_flight"`
+	Queued   int    `json:"queued"`
+	Rejected int64  `json:"rejected"`
+}
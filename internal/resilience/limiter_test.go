@@ -0,0 +1,61 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		want   time.Duration
+		wantOK bool
+	}{
+		{"rejection", &RejectedError{Name: "db", RetryAfter: 3 * time.Second}, 3 * time.Second, true},
+		{"wrapped rejection", fmt.Errorf("failed to get payment: %w", &RejectedError{Name: "db", RetryAfter: time.Second}), time.Second, true},
+		{"rejection without hint", &RejectedError{Name: "db"}, 0, false},
+		{"open breaker", ErrCircuitBreakerOpen, 0, false},
+		{"other error", errBoom, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := RetryAfter(tt.err)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("RetryAfter(%v) = %s, %v, want %s, %v", tt.err, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestLimitReleasesOnPanic(t *testing.T) {
+	b := NewBulkhead(BulkheadConfig{Name: "test", MaxConcurrent: 1})
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("panic was swallowed")
+			}
+		}()
+		_ = b.Execute(context.Background(), func(ctx context.Context) error {
+			panic("boom")
+		})
+	}()
+
+	if stats := b.Stats(); stats.InFlight != 0 {
+		t.Errorf("in flight = %d after a panicking call, want 0", stats.InFlight)
+	}
+}
+
+func TestLimitNilLimiter(t *testing.T) {
+	got, err := LimitWithResult(context.Background(), nil, func(ctx context.Context) (int, error) {
+		return 7, errBoom
+	})
+	if got != 7 || !errors.Is(err, errBoom) {
+		t.Errorf("LimitWithResult = %d, %v, want 7, %v", got, err, errBoom)
+	}
+}
@@ -0,0 +1,40 @@
+package resilience
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisBreakerHook runs every Redis command and pipeline through a circuit breaker
+type redisBreakerHook struct {
+	cb *CircuitBreaker
+}
+
+// NewRedisCircuitBreakerHook returns a go-redis hook that runs commands
+// through cb, so an unreachable Redis fails calls fast instead of tying up
+// requests until the client's own timeouts. Give cb an IsFailure such as
+// IsRetryableError so replies like redis.Nil do not count as failures.
+func NewRedisCircuitBreakerHook(cb *CircuitBreaker) redis.Hook {
+	return &redisBreakerHook{cb: cb}
+}
+
+func (h *redisBreakerHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *redisBreakerHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		return h.cb.ExecuteWithContext(ctx, func(ctx context.Context) error {
+			return next(ctx, cmd)
+		})
+	}
+}
+
+func (h *redisBreakerHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		return h.cb.ExecuteWithContext(ctx, func(ctx context.Context) error {
+			return next(ctx, cmds)
+		})
+	}
+}
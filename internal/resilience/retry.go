@@ -4,26 +4,60 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"math/rand/v2"
 	"time"
 )
 
+// Jitter selects how retry delays are randomised
+type Jitter int
+
+const (
+	// JitterNone waits exactly the exponential backoff
+	JitterNone Jitter = iota
+	// JitterFull waits a random time between zero and the exponential backoff
+	JitterFull
+	// JitterDecorrelated waits a random time between InitialDelay and three
+	// times the previous delay, capped at MaxDelay
+	JitterDecorrelated
+)
+
+// RetryMetricsRecorder is the subset of metrics.MetricsCollector used by
+// RetryWithContext and RetryBudget
+type RetryMetricsRecorder interface {
+	RecordRetryAttempt(dependency string)
+	RecordRetryBudgetExhausted(dependency string)
+}
+
 // RetryConfig holds configuration for retry logic
 type RetryConfig struct {
-	MaxAttempts      int
-	InitialDelay     time.Duration
-	MaxDelay        time.Duration
-	BackoffFactor   float64
+	Name          string // dependency name used for metrics
+	MaxAttempts   int
+	InitialDelay  time.Duration
+	MaxDelay      time.Duration
+	BackoffFactor float64
+	Jitter        Jitter
+
+	// RetryableErrors decides whether an error is worth retrying (default IsRetryableError)
 	RetryableErrors func(error) bool
+
+	// Budget, when set, is shared with every other caller of the dependency
+	// and caps retries to a fraction of its calls
+	Budget *RetryBudget
+
+	// Metrics records retries made without a Budget; a budget reports its
+	// own retries and exhaustion (see RetryBudget.SetMetrics)
+	Metrics RetryMetricsRecorder
 }
 
 // DefaultRetryConfig returns a default retry configuration
 func DefaultRetryConfig() RetryConfig {
 	return RetryConfig{
-		MaxAttempts:    3,
-		InitialDelay:   100 * time.Millisecond,
-		MaxDelay:       30 * time.Second,
-		BackoffFactor:  2.0,
-		RetryableErrors: func(err error) bool { return true },
+		MaxAttempts:     3,
+		InitialDelay:    100 * time.Millisecond,
+		MaxDelay:        30 * time.Second,
+		BackoffFactor:   2.0,
+		Jitter:          JitterFull,
+		RetryableErrors: IsRetryableError,
 	}
 }
 
@@ -36,13 +70,31 @@ func Retry(fn func() error, config RetryConfig) error {
 
 // RetryWithContext executes a function with retry logic and context
 func RetryWithContext(ctx context.Context, fn func(context.Context) error, config RetryConfig) error {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 1
+	}
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = 30 * time.Second
+	}
+	if config.BackoffFactor <= 0 {
+		config.BackoffFactor = 2.0
+	}
+	if config.RetryableErrors == nil {
+		config.RetryableErrors = IsRetryableError
+	}
+	if config.Budget != nil {
+		if config.Name == "" {
+			config.Name = config.Budget.Name()
+		}
+		config.Budget.Deposit()
+	}
+
 	var lastErr error
-	
+	var delay time.Duration
+
 	for attempt := 1; attempt <= config.MaxAttempts; attempt++ {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
 		err := fn(ctx)
@@ -51,21 +103,32 @@ func RetryWithContext(ctx context.Context, fn func(context.Context) error, confi
 		}
 
 		lastErr = err
-		
-		// Check if error is retryable
-		if config.RetryableErrors != nil && !config.RetryableErrors(err) {
+
+		if !config.RetryableErrors(err) {
 			return err
 		}
 
 		// Don't wait on the last attempt
-		if attempt < config.MaxAttempts {
-			delay := calculateDelay(attempt-1, config)
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(delay):
-				// Continue to next attempt
+		if attempt == config.MaxAttempts {
+			break
+		}
+
+		if config.Budget != nil {
+			if !config.Budget.Withdraw() {
+				return fmt.Errorf("%w for %s after %d attempts: %w", ErrRetryBudgetExhausted, config.Name, attempt, err)
 			}
+		} else if config.Metrics != nil {
+			config.Metrics.RecordRetryAttempt(config.Name)
+		}
+
+		delay = calculateDelay(attempt-1, delay, config)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+			// Continue to next attempt
 		}
 	}
 
@@ -74,84 +137,50 @@ func RetryWithContext(ctx context.Context, fn func(context.Context) error, confi
 
 // RetryWithResult executes a function with retry logic and returns result
 func RetryWithResult[T any](fn func() (T, error), config RetryConfig) (T, error) {
-	var zero T
-	result, err := Retry(func() error {
+	var result T
+	err := Retry(func() error {
 		var err error
 		result, err = fn()
 		return err
 	}, config)
-	
+
 	return result, err
 }
 
 // RetryWithContextAndResult executes a function with retry logic, context, and returns result
 func RetryWithContextAndResult[T any](ctx context.Context, fn func(context.Context) (T, error), config RetryConfig) (T, error) {
-	var zero T
 	var result T
-	
+
 	err := RetryWithContext(ctx, func(ctx context.Context) error {
 		var err error
 		result, err = fn(ctx)
 		return err
 	}, config)
-	
+
 	return result, err
 }
 
-// calculateDelay calculates the delay for a given attempt using exponential backoff
-func calculateDelay(attempt int, config RetryConfig) time.Duration {
-	delay := float64(config.InitialDelay) * math.Pow(config.BackoffFactor, float64(attempt))
-	
-	if delay > float64(config.MaxDelay) {
-		delay = float64(config.MaxDelay)
-	}
-	
-	return time.Duration(delay)
-}
+// calculateDelay calculates the delay before the retry following attempt
+// (zero-based), given the previous delay, using exponential backoff and the
+// configured jitter
+func calculateDelay(attempt int, previous time.Duration, config RetryConfig) time.Duration {
+	maxDelay := float64(config.MaxDelay)
 
-// IsRetryableError determines if an error is retryable
-func IsRetryableError(err error) bool {
-	if err == nil {
-		return false
-	}
-	
-	// Common retryable errors
-	errMsg := err.Error()
-	retryableErrors := []string{
-		"connection refused",
-		"connection reset",
-		"timeout",
-		"temporary failure",
-		"service unavailable",
-		"rate limit exceeded",
-		"circuit breaker open",
+	if config.Jitter == JitterDecorrelated {
+		lower := float64(config.InitialDelay)
+		upper := max(lower, float64(previous)*3)
+		return time.Duration(min(maxDelay, randomBetween(lower, upper)))
 	}
-	
-	for _, retryableErr := range retryableErrors {
-		if contains(errMsg, retryableErr) {
-			return true
-		}
-	}
-	
-	return false
-}
 
-// contains checks if a string contains a substring (case-insensitive)
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && 
-		   (s == substr || 
-		    len(s) > len(substr) && 
-		    (s[:len(substr)] == substr || 
-		     s[len(s)-len(substr):] == substr ||
-		     indexOf(s, substr) >= 0))
-}
+	delay := min(maxDelay, float64(config.InitialDelay)*math.Pow(config.BackoffFactor, float64(attempt)))
 
-// indexOf finds the index of a substring in a string
-func indexOf(s, substr string) int {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return i
-		}
+	if config.Jitter == JitterFull {
+		return time.Duration(randomBetween(0, delay))
 	}
-	return -1
+	return time.Duration(delay)
+}
+
+// randomBetween returns a uniformly random value in [lower, upper)
+func randomBetween(lower, upper float64) float64 {
+	return lower + rand.Float64()*(upper-lower)
 }
@@ -0,0 +1,124 @@
+package resilience
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrRetryBudgetExhausted is returned, wrapping the last error, when a retry
+	// was skipped because the dependency's retry budget is spent
+	ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+)
+
+// RetryBudgetConfig holds configuration for a retry budget
+type RetryBudgetConfig struct {
+	Name string
+
+	// Ratio is the number of retries each call earns (default 0.1, so retries
+	// add at most 10% to the load on the dependency)
+	Ratio float64
+
+	// MaxTokens caps the retries that can be saved up, and is what a new or
+	// idle budget starts with (default 10)
+	MaxTokens float64
+}
+
+// RetryBudgetStats is a point-in-time view of a retry budget
+type RetryBudgetStats struct {
+	Name      string  `json:"name"`
+	Tokens    float64 `json:"tokens"`
+	Requests  int64   `json:"requests"`
+	Retries   int64   `json:"retries"`
+	Exhausted int64   `json:"exhausted"`
+}
+
+// RetryBudget is a token bucket shared by every caller of one dependency.
+// Each call deposits Ratio tokens and each retry withdraws one, so when the
+// dependency fails wholesale retries fall to Ratio of traffic instead of
+// multiplying it by MaxAttempts.
+type RetryBudget struct {
+	name      string
+	ratio     float64
+	maxTokens float64
+
+	mu        sync.Mutex
+	tokens    float64
+	requests  int64
+	retries   int64
+	exhausted int64
+	metrics   RetryMetricsRecorder
+}
+
+// NewRetryBudget creates a new retry budget
+func NewRetryBudget(config RetryBudgetConfig) *RetryBudget {
+	if config.Ratio <= 0 {
+		config.Ratio = 0.1
+	}
+	if config.MaxTokens <= 0 {
+		config.MaxTokens = 10
+	}
+
+	return &RetryBudget{
+		name:      config.Name,
+		ratio:     config.Ratio,
+		maxTokens: config.MaxTokens,
+		tokens:    config.MaxTokens,
+	}
+}
+
+// Name returns the budget's name
+func (b *RetryBudget) Name() string {
+	return b.name
+}
+
+// SetMetrics reports every retry taken from the budget, and every retry
+// refused because it is spent, to recorder under the budget's name
+func (b *RetryBudget) SetMetrics(recorder RetryMetricsRecorder) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.metrics = recorder
+}
+
+// Deposit records a first attempt, earning Ratio tokens
+func (b *RetryBudget) Deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.requests++
+	b.tokens = min(b.maxTokens, b.tokens+b.ratio)
+}
+
+// Withdraw takes a token for a retry, reporting false if none is left
+func (b *RetryBudget) Withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tokens < 1 {
+		b.exhausted++
+		if b.metrics != nil {
+			b.metrics.RecordRetryBudgetExhausted(b.name)
+		}
+		return false
+	}
+	b.tokens--
+	b.retries++
+	if b.metrics != nil {
+		b.metrics.RecordRetryAttempt(b.name)
+	}
+	return true
+}
+
+// Stats returns the budget's balance and counters
+func (b *RetryBudget) Stats() RetryBudgetStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return RetryBudgetStats{
+		Name:      b.name,
+		Tokens:    b.tokens,
+		Requests:  b.requests,
+		Retries:   b.retries,
+		Exhausted: b.exhausted,
+	}
+}
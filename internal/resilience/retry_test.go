@@ -0,0 +1,144 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCalculateDelayBounds(t *testing.T) {
+	config := RetryConfig{
+		InitialDelay:  100 * time.Millisecond,
+		MaxDelay:      time.Second,
+		BackoffFactor: 2,
+	}
+
+	tests := []struct {
+		name     string
+		jitter   Jitter
+		attempt  int
+		previous time.Duration
+		min, max time.Duration
+	}{
+		{"none first retry", JitterNone, 0, 0, 100 * time.Millisecond, 100 * time.Millisecond},
+		{"none grows exponentially", JitterNone, 3, 0, 800 * time.Millisecond, 800 * time.Millisecond},
+		{"none is capped", JitterNone, 10, 0, time.Second, time.Second},
+		{"full first retry", JitterFull, 0, 0, 0, 100 * time.Millisecond},
+		{"full later retry", JitterFull, 2, 0, 0, 400 * time.Millisecond},
+		{"full is capped", JitterFull, 10, 0, 0, time.Second},
+		{"decorrelated without a previous delay", JitterDecorrelated, 0, 0, 100 * time.Millisecond, 100 * time.Millisecond},
+		{"decorrelated from the previous delay", JitterDecorrelated, 1, 200 * time.Millisecond, 100 * time.Millisecond, 600 * time.Millisecond},
+		{"decorrelated is capped", JitterDecorrelated, 5, 900 * time.Millisecond, 100 * time.Millisecond, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := config
+			config.Jitter = tt.jitter
+
+			for i := 0; i < 1000; i++ {
+				if got := calculateDelay(tt.attempt, tt.previous, config); got < tt.min || got > tt.max {
+					t.Fatalf("calculateDelay(%d, %s) = %s, want within [%s, %s]", tt.attempt, tt.previous, got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	b := NewRetryBudget(RetryBudgetConfig{Name: "db", Ratio: 0.5, MaxTokens: 2})
+
+	// A new budget starts full
+	for i := 0; i < 2; i++ {
+		if !b.Withdraw() {
+			t.Fatalf("withdraw %d from a full budget failed", i+1)
+		}
+	}
+	if b.Withdraw() {
+		t.Fatal("withdraw from an empty budget succeeded")
+	}
+
+	// Each call earns Ratio of a retry
+	b.Deposit()
+	if b.Withdraw() {
+		t.Fatal("withdraw with half a token succeeded")
+	}
+	b.Deposit()
+	if !b.Withdraw() {
+		t.Fatal("withdraw after two deposits failed")
+	}
+
+	// Deposits never save up more than MaxTokens
+	for i := 0; i < 10; i++ {
+		b.Deposit()
+	}
+	stats := b.Stats()
+	if stats.Tokens != 2 {
+		t.Errorf("tokens = %v, want MaxTokens 2", stats.Tokens)
+	}
+	if stats.Requests != 12 || stats.Retries != 3 || stats.Exhausted != 2 {
+		t.Errorf("stats = %+v, want 12 requests, 3 retries and 2 exhausted", stats)
+	}
+}
+
+type retryMetrics struct {
+	attempts, exhausted int
+}
+
+func (m *retryMetrics) RecordRetryAttempt(string)         { m.attempts++ }
+func (m *retryMetrics) RecordRetryBudgetExhausted(string) { m.exhausted++ }
+
+func TestRetryStopsWhenBudgetExhausted(t *testing.T) {
+	budget := NewRetryBudget(RetryBudgetConfig{Name: "db", MaxTokens: 1})
+	metrics := &retryMetrics{}
+	budget.SetMetrics(metrics)
+	calls := 0
+
+	// The budget reports its retries; RetryConfig.Metrics must not count them again
+	err := RetryWithContext(context.Background(), func(ctx context.Context) error {
+		calls++
+		return MarkRetryable(errBoom)
+	}, RetryConfig{MaxAttempts: 5, InitialDelay: time.Millisecond, Budget: budget, Metrics: metrics})
+
+	if !errors.Is(err, ErrRetryBudgetExhausted) || !errors.Is(err, errBoom) {
+		t.Fatalf("error = %v, want %v wrapping the last error", err, ErrRetryBudgetExhausted)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2 (one retry from the budget)", calls)
+	}
+	if metrics.attempts != 1 || metrics.exhausted != 1 {
+		t.Errorf("metrics = %+v, want 1 retry and 1 exhaustion", *metrics)
+	}
+	// The exhaustion is not itself retried by outer layers
+	if IsRetryableError(err) {
+		t.Error("budget exhaustion classified as retryable")
+	}
+}
+
+func TestRetryStopsOnPermanentError(t *testing.T) {
+	calls := 0
+	err := Retry(func() error {
+		calls++
+		return errBoom
+	}, RetryConfig{MaxAttempts: 3, InitialDelay: time.Millisecond})
+
+	if !errors.Is(err, errBoom) || calls != 1 {
+		t.Errorf("calls = %d, err = %v, want a single attempt", calls, err)
+	}
+}
+
+func TestRetryCancelledDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+
+	err := RetryWithContext(ctx, func(ctx context.Context) error {
+		calls++
+		cancel()
+		return MarkRetryable(errBoom)
+	}, RetryConfig{MaxAttempts: 3, InitialDelay: time.Hour})
+
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("calls = %d, err = %v, want %v after one attempt", calls, err, context.Canceled)
+	}
+}
@@ -0,0 +1,64 @@
+package resilience
+
+import "time"
+
+// SlidingWindow counts calls and failures over the last size, in buckets.
+// It is not safe for concurrent use; callers hold their own lock.
+type SlidingWindow struct {
+	bucketSize time.Duration
+	buckets    []windowBucket
+}
+
+type windowBucket struct {
+	epoch    int64
+	requests int
+	failures int
+}
+
+func NewSlidingWindow(size time.Duration, buckets int) *SlidingWindow {
+	bucketSize := size / time.Duration(buckets)
+	if bucketSize <= 0 {
+		bucketSize = 1
+	}
+	return &SlidingWindow{
+		bucketSize: bucketSize,
+		buckets:    make([]windowBucket, buckets),
+	}
+}
+
+func (w *SlidingWindow) epoch(now time.Time) int64 {
+	return now.UnixNano() / int64(w.bucketSize)
+}
+
+// Add records a call made at now
+func (w *SlidingWindow) Add(now time.Time, failure bool) {
+	epoch := w.epoch(now)
+	bucket := &w.buckets[epoch%int64(len(w.buckets))]
+	if bucket.epoch != epoch {
+		*bucket = windowBucket{epoch: epoch}
+	}
+
+	bucket.requests++
+	if failure {
+		bucket.failures++
+	}
+}
+
+// Totals returns the calls and failures in the window ending at now
+func (w *SlidingWindow) Totals(now time.Time) (requests, failures int) {
+	oldest := w.epoch(now) - int64(len(w.buckets)) + 1
+	for _, bucket := range w.buckets {
+		if bucket.epoch >= oldest {
+			requests += bucket.requests
+			failures += bucket.failures
+		}
+	}
+	return requests, failures
+}
+
+// Reset forgets every call
+func (w *SlidingWindow) Reset() {
+	for i := range w.buckets {
+		w.buckets[i] = windowBucket{}
+	}
+}
@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the periodic task configuration file:
+//
+//	tasks:
+//	  - name: promote_delayed_jobs
+//	    schedule: "@every 5s"
+//	  - name: queue_stats
+//	    schedule: "*/5 * * * *"
+//	    timeout: 30s
+//	    enabled: false
+type Config struct {
+	Tasks []TaskConfig `yaml:"tasks"`
+}
+
+// TaskConfig overrides a registered task. Empty fields keep the task's defaults.
+type TaskConfig struct {
+	Name     string        `yaml:"name"`
+	Schedule string        `yaml:"schedule"`
+	Timeout  time.Duration `yaml:"timeout"`
+	Enabled  *bool         `yaml:"enabled"`
+}
+
+// LoadConfig reads a scheduler config file. A missing file yields an empty
+// config so tasks run on their registered defaults.
+func LoadConfig(path string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Config{}, nil
+		}
+		return nil, fmt.Errorf("failed to read scheduler config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse scheduler config: %w", err)
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,127 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func utc(year int, month time.Month, day, hour, minute, sec int) time.Time {
+	return time.Date(year, month, day, hour, minute, sec, 0, time.UTC)
+}
+
+func TestScheduleNext(t *testing.T) {
+	// 2024-01-15 is a Monday
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		// Ranges, lists and steps
+		{"minute step", "*/15 * * * *", utc(2024, 1, 15, 10, 7, 30), utc(2024, 1, 15, 10, 15, 0)},
+		{"strictly after", "*/15 * * * *", utc(2024, 1, 15, 10, 15, 0), utc(2024, 1, 15, 10, 30, 0)},
+		{"stepped range", "0-30/10 9-17 * * *", utc(2024, 1, 15, 10, 31, 0), utc(2024, 1, 15, 11, 0, 0)},
+		{"range end rolls to next day", "0-30/10 9-17 * * *", utc(2024, 1, 15, 17, 30, 0), utc(2024, 1, 16, 9, 0, 0)},
+		{"list", "5,35 * * * *", utc(2024, 1, 15, 10, 35, 0), utc(2024, 1, 15, 11, 5, 0)},
+		{"single value with step", "10/20 * * * *", utc(2024, 1, 15, 10, 31, 0), utc(2024, 1, 15, 10, 50, 0)},
+		{"weekdays skip the weekend", "0 9 * * 1-5", utc(2024, 1, 19, 10, 0, 0), utc(2024, 1, 22, 9, 0, 0)},
+		{"macro", "@hourly", utc(2024, 1, 15, 10, 59, 59), utc(2024, 1, 15, 11, 0, 0)},
+		{"converted to UTC", "0 12 * * *", time.Date(2024, 1, 15, 13, 30, 0, 0, time.FixedZone("UTC+2", 2*3600)), utc(2024, 1, 15, 12, 0, 0)},
+
+		// @every
+		{"every seconds", "@every 30s", utc(2024, 1, 15, 10, 0, 10), utc(2024, 1, 15, 10, 0, 40)},
+		{"every truncates to the second", "@every 30s", utc(2024, 1, 15, 10, 0, 10).Add(500 * time.Millisecond), utc(2024, 1, 15, 10, 0, 40)},
+		{"every compound duration", "@every 1h30m", utc(2024, 1, 15, 10, 0, 0), utc(2024, 1, 15, 11, 30, 0)},
+
+		// Day-of-month and day-of-week: OR when both are restricted, AND otherwise
+		{"dom or dow picks the earlier dom", "0 0 13 * 5", utc(2024, 2, 10, 0, 0, 0), utc(2024, 2, 13, 0, 0, 0)},
+		{"dom or dow picks the earlier dow", "0 0 13 * 5", utc(2024, 1, 15, 0, 0, 0), utc(2024, 1, 19, 0, 0, 0)},
+		{"dom only", "0 0 13 * *", utc(2024, 1, 15, 0, 0, 0), utc(2024, 2, 13, 0, 0, 0)},
+		{"dow only", "0 0 * * 5", utc(2024, 2, 10, 0, 0, 0), utc(2024, 2, 16, 0, 0, 0)},
+		{"stepped dow counts as unrestricted", "0 0 13 * */2", utc(2024, 1, 15, 0, 0, 0), utc(2024, 2, 13, 0, 0, 0)},
+
+		// Month ends, year and leap-day rollover
+		{"31st skips short months", "0 0 31 * *", utc(2024, 1, 31, 0, 0, 0), utc(2024, 3, 31, 0, 0, 0)},
+		{"30th skips february", "0 0 30 * *", utc(2024, 1, 30, 0, 0, 0), utc(2024, 3, 30, 0, 0, 0)},
+		{"year rollover", "0 0 1 1 *", utc(2024, 12, 31, 23, 59, 0), utc(2025, 1, 1, 0, 0, 0)},
+		{"december to january", "0 0 * 1 *", utc(2024, 12, 15, 0, 0, 0), utc(2025, 1, 1, 0, 0, 0)},
+		{"leap day", "0 0 29 2 *", utc(2024, 3, 1, 0, 0, 0), utc(2028, 2, 29, 0, 0, 0)},
+		{"leap day across 2100", "0 0 29 2 *", utc(2096, 3, 1, 0, 0, 0), utc(2104, 2, 29, 0, 0, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseSchedule(%q) failed: %v", tt.expr, err)
+			}
+			got, err := schedule.Next(tt.from)
+			if err != nil {
+				t.Fatalf("Next(%s) failed: %v", tt.from, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("%q: Next(%s) = %s, want %s", tt.expr, tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScheduleRejectsNeverMatching(t *testing.T) {
+	for _, expr := range []string{"0 0 30 2 *", "0 0 31 4,6,9,11 *", "0 0 31 2-4/2 *"} {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := ParseSchedule(expr); !errors.Is(err, ErrNeverMatches) {
+				t.Errorf("ParseSchedule(%q) error = %v, want %v", expr, err, ErrNeverMatches)
+			}
+		})
+	}
+}
+
+func TestCronScheduleNextNeverMatches(t *testing.T) {
+	// February 30th, built directly so ParseSchedule's own check is bypassed
+	schedule := &cronSchedule{
+		minute:     1 << 0,
+		hour:       1 << 0,
+		dayOfMonth: 1 << 30,
+		month:      1 << 2,
+		dayOfWeek:  1<<7 - 1,
+		dowStar:    true,
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := schedule.Next(utc(2024, 1, 1, 0, 0, 0))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrNeverMatches) {
+			t.Errorf("Next error = %v, want %v", err, ErrNeverMatches)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Next did not return for a never-matching schedule")
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 7",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@every 500ms",
+		"@every soon",
+	} {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := ParseSchedule(expr); err == nil {
+				t.Errorf("ParseSchedule(%q) succeeded, want error", expr)
+			}
+		})
+	}
+}
@@ -0,0 +1,425 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TaskFunc is the body of a periodic task
+type TaskFunc func(ctx context.Context) error
+
+type task struct {
+	name     string
+	expr     string
+	schedule Schedule
+	timeout  time.Duration
+	enabled  bool
+	run      TaskFunc
+	running  atomic.Bool
+}
+
+// TaskStatus is the last-run/next-run state of a periodic task as recorded in Redis
+type TaskStatus struct {
+	Name         string     `json:"name"`
+	Schedule     string     `json:"schedule"`
+	Enabled      bool       `json:"enabled"`
+	LastRunAt    *time.Time `json:"last_run_at,omitempty"`
+	LastStatus   string     `json:"last_status,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastRunBy    string     `json:"last_run_by,omitempty"`
+	NextRunAt    *time.Time `json:"next_run_at,omitempty"`
+}
+
+// Scheduler runs periodic tasks on exactly one replica. Replicas compete for a
+// Redis leader lock; only the leader fires due tasks, and every scheduled run
+// is additionally claimed with SET NX so a leadership hand-over can never run
+// the same tick twice.
+//
+// Key layout (for prefix "b2b_payments"):
+//
+//	b2b_payments:scheduler:leader              instance ID of the current leader (expires after lockTTL)
+//	b2b_payments:scheduler:tasks               set of known task names
+//	b2b_payments:scheduler:task:<name>         hash of schedule, next/last run and last outcome
+//	b2b_payments:scheduler:run:<name>:<ms>     claim for one scheduled run
+type Scheduler struct {
+	redisClient  *redis.Client
+	prefix       string
+	instanceID   string
+	lockTTL      time.Duration
+	tickInterval time.Duration
+
+	mu       sync.RWMutex
+	tasks    map[string]*task
+	isLeader atomic.Bool
+	wg       sync.WaitGroup
+}
+
+// acquireLeaderScript takes the leader lock or renews it if already held by this instance
+var acquireLeaderScript = redis.NewScript(`
+local holder = redis.call('GET', KEYS[1])
+if holder == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+if not holder then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// releaseLeaderScript drops the leader lock only if this instance holds it
+var releaseLeaderScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func NewScheduler(redisClient *redis.Client, prefix, instanceID string) *Scheduler {
+	if prefix == "" {
+		prefix = "b2b_payments"
+	}
+
+	return &Scheduler{
+		redisClient:  redisClient,
+		prefix:       prefix,
+		instanceID:   instanceID,
+		lockTTL:      15 * time.Second,
+		tickInterval: time.Second,
+		tasks:        make(map[string]*task),
+	}
+}
+
+func (s *Scheduler) leaderKey() string {
+	return fmt.Sprintf("%s:scheduler:leader", s.prefix)
+}
+
+func (s *Scheduler) tasksKey() string {
+	return fmt.Sprintf("%s:scheduler:tasks", s.prefix)
+}
+
+func (s *Scheduler) taskKey(name string) string {
+	return fmt.Sprintf("%s:scheduler:task:%s", s.prefix, name)
+}
+
+func (s *Scheduler) runKey(name string, at time.Time) string {
+	return fmt.Sprintf("%s:scheduler:run:%s:%d", s.prefix, name, at.UnixMilli())
+}
+
+// Register adds a periodic task with its default schedule. The schedule,
+// timeout and enabled flag can be overridden from the config file via Configure.
+func (s *Scheduler) Register(name, expr string, run TaskFunc) error {
+	schedule, err := ParseSchedule(expr)
+	if err != nil {
+		return fmt.Errorf("failed to register task %s: %w", name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.tasks[name]; exists {
+		return fmt.Errorf("task %s already registered", name)
+	}
+
+	s.tasks[name] = &task{
+		name:     name,
+		expr:     expr,
+		schedule: schedule,
+		enabled:  true,
+		run:      run,
+	}
+	return nil
+}
+
+// Configure applies file-based overrides to registered tasks
+func (s *Scheduler) Configure(cfg *Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, taskCfg := range cfg.Tasks {
+		t, ok := s.tasks[taskCfg.Name]
+		if !ok {
+			return fmt.Errorf("unknown task %q in scheduler config", taskCfg.Name)
+		}
+
+		if taskCfg.Schedule != "" {
+			schedule, err := ParseSchedule(taskCfg.Schedule)
+			if err != nil {
+				return fmt.Errorf("invalid schedule for task %s: %w", taskCfg.Name, err)
+			}
+			t.expr = taskCfg.Schedule
+			t.schedule = schedule
+		}
+		if taskCfg.Enabled != nil {
+			t.enabled = *taskCfg.Enabled
+		}
+		if taskCfg.Timeout > 0 {
+			t.timeout = taskCfg.Timeout
+		}
+	}
+	return nil
+}
+
+// IsLeader reports whether this instance currently holds the leader lock
+func (s *Scheduler) IsLeader() bool {
+	return s.isLeader.Load()
+}
+
+// Start competes for leadership and, while leader, runs due tasks until ctx
+// is cancelled. It waits for running tasks and releases the lock before returning.
+func (s *Scheduler) Start(ctx context.Context) error {
+	if err := s.publishTasks(ctx); err != nil {
+		return err
+	}
+
+	log.Printf("Scheduler %s started with %d tasks", s.instanceID, len(s.tasks))
+
+	ticker := time.NewTicker(s.tickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			s.release()
+			return ctx.Err()
+		case <-ticker.C:
+			if err := s.tick(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Scheduler tick failed: %v", err)
+			}
+		}
+	}
+}
+
+// publishTasks records every task's schedule so status is visible from any replica
+func (s *Scheduler) publishTasks(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pipe := s.redisClient.Pipeline()
+	for name, t := range s.tasks {
+		pipe.SAdd(ctx, s.tasksKey(), name)
+		pipe.HSet(ctx, s.taskKey(name), "schedule", t.expr, "enabled", strconv.FormatBool(t.enabled))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to publish scheduler tasks: %w", err)
+	}
+	return nil
+}
+
+func (s *Scheduler) tick(ctx context.Context) error {
+	leader, err := acquireLeaderScript.Run(ctx, s.redisClient, []string{s.leaderKey()},
+		s.instanceID, s.lockTTL.Milliseconds()).Bool()
+	if err != nil {
+		s.isLeader.Store(false)
+		return fmt.Errorf("failed to acquire leader lock: %w", err)
+	}
+
+	if wasLeader := s.isLeader.Swap(leader); wasLeader != leader {
+		if leader {
+			log.Printf("Scheduler %s became leader", s.instanceID)
+		} else {
+			log.Printf("Scheduler %s lost leadership", s.instanceID)
+		}
+	}
+	if !leader {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	for _, t := range s.tasks {
+		if !t.enabled {
+			continue
+		}
+		if err := s.maybeRun(ctx, t, now); err != nil {
+			log.Printf("Failed to schedule task %s: %v", t.name, err)
+		}
+	}
+	return nil
+}
+
+// maybeRun fires t if its persisted next run time has passed
+func (s *Scheduler) maybeRun(ctx context.Context, t *task, now time.Time) error {
+	state, err := s.redisClient.HMGet(ctx, s.taskKey(t.name), "next_run", "next_run_schedule").Result()
+	if err != nil {
+		return err
+	}
+
+	nextRunMs, _ := state[0].(string)
+	nextRunExpr, _ := state[1].(string)
+
+	// First run, or the schedule changed since next_run was computed
+	if nextRunMs == "" || nextRunExpr != t.expr {
+		next, err := t.schedule.Next(now)
+		if err != nil {
+			return err
+		}
+		return s.redisClient.HSet(ctx, s.taskKey(t.name),
+			"next_run", strconv.FormatInt(next.UnixMilli(), 10), "next_run_schedule", t.expr).Err()
+	}
+
+	ms, err := strconv.ParseInt(nextRunMs, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid next_run %q: %w", nextRunMs, err)
+	}
+	scheduledAt := time.UnixMilli(ms)
+	if now.Before(scheduledAt) {
+		return nil
+	}
+
+	// Skip ticks that fire while the previous run is still going
+	if t.running.Load() {
+		return nil
+	}
+
+	next, err := t.schedule.Next(now)
+	if err != nil {
+		return err
+	}
+
+	claimed, err := s.redisClient.SetNX(ctx, s.runKey(t.name, scheduledAt), s.instanceID, 24*time.Hour).Result()
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return nil
+	}
+
+	if err := s.redisClient.HSet(ctx, s.taskKey(t.name), "next_run", strconv.FormatInt(next.UnixMilli(), 10)).Err(); err != nil {
+		return err
+	}
+
+	t.running.Store(true)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer t.running.Store(false)
+		s.execute(ctx, t)
+	}()
+	return nil
+}
+
+func (s *Scheduler) execute(ctx context.Context, t *task) {
+	runCtx := ctx
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("task panicked: %v", r)
+			}
+		}()
+		return t.run(runCtx)
+	}()
+	duration := time.Since(start)
+
+	status, lastError := "success", ""
+	if err != nil {
+		status, lastError = "error", err.Error()
+		log.Printf("Scheduled task %s failed after %s: %v", t.name, duration, err)
+	}
+
+	// Record the outcome even if shutdown cancelled the run
+	if err := s.redisClient.HSet(context.WithoutCancel(ctx), s.taskKey(t.name),
+		"last_run", strconv.FormatInt(start.UnixMilli(), 10),
+		"last_status", status,
+		"last_error", lastError,
+		"last_duration", duration.String(),
+		"last_run_by", s.instanceID,
+	).Err(); err != nil {
+		log.Printf("Failed to record run of task %s: %v", t.name, err)
+	}
+}
+
+func (s *Scheduler) release() {
+	if !s.isLeader.Swap(false) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := releaseLeaderScript.Run(ctx, s.redisClient, []string{s.leaderKey()}, s.instanceID).Err(); err != nil {
+		log.Printf("Failed to release scheduler leader lock: %v", err)
+	}
+}
+
+// Status returns the recorded state of every known task, sorted by name. It
+// only reads Redis, so it works from any process, not just the leader.
+func (s *Scheduler) Status(ctx context.Context) ([]*TaskStatus, error) {
+	names, err := s.redisClient.SMembers(ctx, s.tasksKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduler tasks: %w", err)
+	}
+	sort.Strings(names)
+
+	pipe := s.redisClient.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(names))
+	for i, name := range names {
+		cmds[i] = pipe.HGetAll(ctx, s.taskKey(name))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to load scheduler tasks: %w", err)
+	}
+
+	statuses := make([]*TaskStatus, 0, len(names))
+	for i, name := range names {
+		fields := cmds[i].Val()
+		enabled, _ := strconv.ParseBool(fields["enabled"])
+
+		statuses = append(statuses, &TaskStatus{
+			Name:         name,
+			Schedule:     fields["schedule"],
+			Enabled:      enabled,
+			LastRunAt:    parseMillis(fields["last_run"]),
+			LastStatus:   fields["last_status"],
+			LastError:    fields["last_error"],
+			LastDuration: fields["last_duration"],
+			LastRunBy:    fields["last_run_by"],
+			NextRunAt:    parseMillis(fields["next_run"]),
+		})
+	}
+	return statuses, nil
+}
+
+// Leader returns the instance ID currently holding the leader lock, if any
+func (s *Scheduler) Leader(ctx context.Context) (string, error) {
+	leader, err := s.redisClient.Get(ctx, s.leaderKey()).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get scheduler leader: %w", err)
+	}
+	return leader, nil
+}
+
+func parseMillis(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil
+	}
+	t := time.UnixMilli(ms).UTC()
+	return &t
+}
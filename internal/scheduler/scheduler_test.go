@@ -0,0 +1,154 @@
+package scheduler
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestScheduler(t *testing.T, mr *miniredis.Miniredis, instanceID string) *Scheduler {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	s := NewScheduler(client, "test", instanceID)
+	s.lockTTL = 5 * time.Second
+	return s
+}
+
+func assertLeader(t *testing.T, s *Scheduler, want bool) {
+	t.Helper()
+
+	if err := s.tick(context.Background()); err != nil {
+		t.Fatalf("%s: tick failed: %v", s.instanceID, err)
+	}
+	if got := s.IsLeader(); got != want {
+		t.Fatalf("%s: IsLeader = %v, want %v", s.instanceID, got, want)
+	}
+}
+
+func TestSchedulerLeaderHandover(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	a := newTestScheduler(t, mr, "a")
+	b := newTestScheduler(t, mr, "b")
+
+	assertLeader(t, a, true)
+	assertLeader(t, b, false)
+
+	// Renewing keeps the lock past its original TTL
+	mr.FastForward(3 * time.Second)
+	assertLeader(t, a, true)
+	mr.FastForward(3 * time.Second)
+	assertLeader(t, b, false)
+
+	// A clean shutdown hands the lock over on the next tick
+	a.release()
+	if a.IsLeader() {
+		t.Fatal("a still leader after release")
+	}
+	assertLeader(t, b, true)
+	if leader, err := a.Leader(ctx); err != nil || leader != "b" {
+		t.Fatalf("Leader = %q, %v, want b", leader, err)
+	}
+
+	// A leader that stops renewing loses the lock once it expires
+	mr.FastForward(b.lockTTL + time.Second)
+	assertLeader(t, a, true)
+	assertLeader(t, b, false)
+
+	// Releasing a lock held by someone else leaves it in place
+	b.isLeader.Store(true)
+	b.release()
+	if leader, err := a.Leader(ctx); err != nil || leader != "a" {
+		t.Fatalf("Leader = %q, %v after release by non-holder, want a", leader, err)
+	}
+}
+
+func TestSchedulerClaimsEachRunOnce(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	a := newTestScheduler(t, mr, "a")
+	b := newTestScheduler(t, mr, "b")
+
+	var runs atomic.Int32
+	for _, s := range []*Scheduler{a, b} {
+		if err := s.Register("reconcile", "*/5 * * * *", func(ctx context.Context) error {
+			runs.Add(1)
+			return nil
+		}); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+	}
+
+	now := time.Date(2024, 1, 15, 10, 3, 0, 0, time.UTC)
+
+	// The first pass only records the next run
+	if err := a.maybeRun(ctx, a.tasks["reconcile"], now); err != nil {
+		t.Fatalf("maybeRun failed: %v", err)
+	}
+	if got := mr.HGet(a.taskKey("reconcile"), "next_run"); got != strconv.FormatInt(now.Add(2*time.Minute).UnixMilli(), 10) {
+		t.Fatalf("next_run = %s, want 10:05", got)
+	}
+
+	// During a hand-over both instances may briefly act as leader for the same tick
+	due := now.Add(2 * time.Minute)
+	for _, s := range []*Scheduler{a, b} {
+		if err := s.maybeRun(ctx, s.tasks["reconcile"], due); err != nil {
+			t.Fatalf("%s: maybeRun failed: %v", s.instanceID, err)
+		}
+	}
+	a.wg.Wait()
+	b.wg.Wait()
+
+	if got := runs.Load(); got != 1 {
+		t.Fatalf("task ran %d times, want 1", got)
+	}
+	if got := mr.HGet(a.taskKey("reconcile"), "last_run_by"); got != "a" {
+		t.Errorf("last_run_by = %q, want a", got)
+	}
+	if got := mr.HGet(a.taskKey("reconcile"), "next_run"); got != strconv.FormatInt(due.Add(5*time.Minute).UnixMilli(), 10) {
+		t.Errorf("next_run = %s, want 10:10", got)
+	}
+
+	// The following tick is a new run and can be claimed again
+	if err := b.maybeRun(ctx, b.tasks["reconcile"], due.Add(5*time.Minute)); err != nil {
+		t.Fatalf("maybeRun failed: %v", err)
+	}
+	b.wg.Wait()
+	if got := runs.Load(); got != 2 {
+		t.Fatalf("task ran %d times, want 2", got)
+	}
+}
+
+func TestSchedulerRescheduleOnScheduleChange(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	s := newTestScheduler(t, mr, "a")
+
+	if err := s.Register("cleanup", "@daily", func(ctx context.Context) error { return nil }); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	now := time.Date(2024, 1, 15, 10, 3, 0, 0, time.UTC)
+	if err := s.maybeRun(ctx, s.tasks["cleanup"], now); err != nil {
+		t.Fatalf("maybeRun failed: %v", err)
+	}
+
+	if err := s.Configure(&Config{Tasks: []TaskConfig{{Name: "cleanup", Schedule: "@hourly"}}}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	if err := s.maybeRun(ctx, s.tasks["cleanup"], now); err != nil {
+		t.Fatalf("maybeRun failed: %v", err)
+	}
+
+	if got := mr.HGet(s.taskKey("cleanup"), "next_run"); got != strconv.FormatInt(now.Add(57*time.Minute).UnixMilli(), 10) {
+		t.Errorf("next_run = %s, want 11:00", got)
+	}
+}
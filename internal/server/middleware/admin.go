@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	AdminContextKey = "admin_identity"
+)
+
+// AdminAuthorization only admits clients presenting a verified certificate
+// whose Common Name is in allowedCNs. An empty list disables admin access.
+// Behind a proxy in trust, the certificate the proxy forwards is used instead.
+func AdminAuthorization(allowedCNs []string, trust *ProxyTrust) echo.MiddlewareFunc {
+	allowed := make(map[string]bool, len(allowedCNs))
+	for _, cn := range allowedCNs {
+		allowed[cn] = true
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			clientCert, err := trust.ClientCertificate(c.Request())
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+
+			cn := clientCert.Subject.CommonName
+			if cn == "" || !allowed[cn] {
+				c.Logger().Warnf("Admin access denied for certificate CN: %s", cn)
+				return echo.NewHTTPError(http.StatusForbidden, "admin access denied")
+			}
+
+			c.Set(AdminContextKey, cn)
+
+			// Audit every admin operation
+			c.Logger().Infof("Admin request by %s: %s %s", cn, c.Request().Method, c.Request().URL.Path)
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yordanos-habtamu/b2b-payments/internal/identity"
+)
+
+func TestAdminAuthorization(t *testing.T) {
+	ca, caKey := issue(t, "Test CA", nil, nil)
+	operator, _ := issue(t, "ops.yourorg.com", ca, caKey)
+	unlisted, _ := issue(t, "dev.yourorg.com", ca, caKey)
+	tenant, _ := issue(t, "tenant-acme.yourorg.com", ca, caKey)
+	proxyCert, _ := issue(t, "proxy.yourorg.com", ca, caKey)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	trust := NewProxyTrust([]string{"proxy.yourorg.com"}, roots)
+
+	// The proxy's own CN is deliberately an admin CN: it must not grant access
+	// to whoever the proxy forwards
+	admin := AdminAuthorization([]string{"ops.yourorg.com", "proxy.yourorg.com"}, trust)
+	handler := admin(func(c echo.Context) error {
+		return c.String(http.StatusOK, c.Get(AdminContextKey).(string))
+	})
+
+	tests := []struct {
+		name      string
+		peer      *x509.Certificate
+		forwarded *x509.Certificate
+		want      int
+	}{
+		{"listed CN connecting directly", operator, nil, http.StatusOK},
+		{"unlisted CN connecting directly", unlisted, nil, http.StatusForbidden},
+		{"listed CN behind trusted proxy", proxyCert, operator, http.StatusOK},
+		{"unlisted CN behind trusted proxy", proxyCert, unlisted, http.StatusForbidden},
+		{"tenant behind trusted proxy", proxyCert, tenant, http.StatusForbidden},
+		{"trusted proxy forwarding nothing", proxyCert, nil, http.StatusUnauthorized},
+		{"tenant forging the header", tenant, operator, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/admin/dead-letters", nil)
+			r.TLS = verifiedTLS(tt.peer)
+			if tt.forwarded != nil {
+				r.Header.Set(identity.HeaderClientCert, identity.EncodeClientCert([]*x509.Certificate{tt.forwarded}))
+			}
+			rec := httptest.NewRecorder()
+
+			err := handler(echo.New().NewContext(r, rec))
+			status := rec.Code
+			if httpErr, ok := err.(*echo.HTTPError); ok {
+				status = httpErr.Code
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if status != tt.want {
+				t.Fatalf("expected status %d, got %d", tt.want, status)
+			}
+			if status == http.StatusOK && rec.Body.String() != "ops.yourorg.com" {
+				t.Fatalf("expected admin identity ops.yourorg.com, got %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAdminAuthorizationWithoutAllowedCNs(t *testing.T) {
+	ca, caKey := issue(t, "Test CA", nil, nil)
+	operator, _ := issue(t, "ops.yourorg.com", ca, caKey)
+
+	handler := AdminAuthorization(nil, nil)(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/admin/dead-letters", nil)
+	r.TLS = verifiedTLS(operator)
+	err := handler(echo.New().NewContext(r, httptest.NewRecorder()))
+	if httpErr, ok := err.(*echo.HTTPError); !ok || httpErr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 with admin access disabled, got %v", err)
+	}
+}
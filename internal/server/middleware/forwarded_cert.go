@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/yordanos-habtamu/b2b-payments/internal/identity"
+)
+
+const (
+	ClientCertContextKey = "client_cert"
+)
+
+// ProxyTrust decides whose client certificate a request is made with. Peers
+// presenting a certificate whose CN is a trusted proxy may pass on the
+// client's certificate in X-Client-Cert; it is checked against the CA like
+// any other client certificate. From anyone else the header is ignored.
+type ProxyTrust struct {
+	proxyCNs map[string]bool
+	roots    *x509.CertPool
+}
+
+// NewProxyTrust trusts the proxies with the given certificate CNs to forward
+// client certificates issued by roots. A nil ProxyTrust trusts no proxy.
+func NewProxyTrust(proxyCNs []string, roots *x509.CertPool) *ProxyTrust {
+	trusted := make(map[string]bool, len(proxyCNs))
+	for _, cn := range proxyCNs {
+		trusted[cn] = true
+	}
+	return &ProxyTrust{proxyCNs: trusted, roots: roots}
+}
+
+// ClientCertificate returns the verified certificate of the client behind r:
+// the one forwarded by a trusted proxy, or else the one on the connection
+func (t *ProxyTrust) ClientCertificate(r *http.Request) (*x509.Certificate, error) {
+	tlsConnState := r.TLS
+	if tlsConnState == nil || len(tlsConnState.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("missing client certificate")
+	}
+
+	// We only trust verified chains (mTLS already enforces this)
+	if len(tlsConnState.VerifiedChains) == 0 {
+		return nil, fmt.Errorf("client certificate not verified by trusted CA")
+	}
+
+	peer := tlsConnState.PeerCertificates[0]
+	if t == nil || !t.proxyCNs[peer.Subject.CommonName] {
+		return peer, nil
+	}
+
+	forwarded := r.Header.Get(identity.HeaderClientCert)
+	if forwarded == "" {
+		return nil, fmt.Errorf("missing forwarded client certificate")
+	}
+	return t.verifyForwarded(forwarded)
+}
+
+func (t *ProxyTrust) verifyForwarded(header string) (*x509.Certificate, error) {
+	chain, err := identity.DecodeClientCert(header)
+	if err != nil {
+		return nil, err
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err = chain[0].Verify(x509.VerifyOptions{
+		Roots:         t.roots,
+		Intermediates: intermediates,
+		CurrentTime:   time.Now(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("forwarded client certificate not verified by trusted CA: %w", err)
+	}
+	return chain[0], nil
+}
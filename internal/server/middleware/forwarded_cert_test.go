@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yordanos-habtamu/b2b-payments/internal/identity"
+)
+
+// issue creates a certificate for cn with the given extended key usages
+// (client auth by default), signed by parent or self-signed if parent is nil
+func issue(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, usages ...x509.ExtKeyUsage) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	if len(usages) == 0 {
+		usages = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  usages,
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	return cert, key
+}
+
+// verifiedTLS is the connection state of a client that presented cert
+func verifiedTLS(cert *x509.Certificate) *tls.ConnectionState {
+	return &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert},
+		VerifiedChains:   [][]*x509.Certificate{{cert}},
+	}
+}
+
+// forwardedRequest is a request from peer carrying header in X-Client-Cert
+func forwardedRequest(peer *x509.Certificate, header string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/payments", nil)
+	r.TLS = verifiedTLS(peer)
+	if header != "" {
+		r.Header.Set(identity.HeaderClientCert, header)
+	}
+	return r
+}
+
+func TestProxyTrustClientCertificate(t *testing.T) {
+	ca, caKey := issue(t, "Test CA", nil, nil)
+	client, _ := issue(t, "tenant-acme.yourorg.com", ca, caKey)
+	proxyCert, _ := issue(t, "proxy.yourorg.com", ca, caKey)
+	other, _ := issue(t, "tenant-other.yourorg.com", ca, caKey)
+	serverOnly, _ := issue(t, "tenant-acme.yourorg.com", ca, caKey, x509.ExtKeyUsageServerAuth)
+
+	// A self-signed CA with the real CA's name, and a leaf it signed: the
+	// chain names the right issuer but is not signed by it
+	forgedCA, forgedKey := issue(t, "Test CA", nil, nil)
+	forged, _ := issue(t, "tenant-acme.yourorg.com", forgedCA, forgedKey)
+	rogue, _ := issue(t, "tenant-acme.yourorg.com", nil, nil)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	trust := NewProxyTrust([]string{"proxy.yourorg.com"}, roots)
+
+	tests := []struct {
+		name    string
+		trust   *ProxyTrust
+		request *http.Request
+		want    *x509.Certificate
+	}{
+		{"forwarded by trusted proxy", trust, forwardedRequest(proxyCert, identity.EncodeClientCert([]*x509.Certificate{client})), client},
+		{"forwarded with its CA", trust, forwardedRequest(proxyCert, identity.EncodeClientCert([]*x509.Certificate{client, ca})), client},
+		{"header from a client", trust, forwardedRequest(other, identity.EncodeClientCert([]*x509.Certificate{client})), other},
+		{"forged header from a client", trust, forwardedRequest(other, identity.EncodeClientCert([]*x509.Certificate{forged, forgedCA})), other},
+		{"no trusted proxies", nil, forwardedRequest(proxyCert, identity.EncodeClientCert([]*x509.Certificate{client})), proxyCert},
+		{"trusted proxy without header", trust, forwardedRequest(proxyCert, ""), nil},
+		{"self-signed certificate", trust, forwardedRequest(proxyCert, identity.EncodeClientCert([]*x509.Certificate{rogue})), nil},
+		{"forged chain", trust, forwardedRequest(proxyCert, identity.EncodeClientCert([]*x509.Certificate{forged, forgedCA})), nil},
+		{"certificate without client auth", trust, forwardedRequest(proxyCert, identity.EncodeClientCert([]*x509.Certificate{serverOnly})), nil},
+		{"malformed header", trust, forwardedRequest(proxyCert, "not a certificate"), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.trust.ClientCertificate(tt.request)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("expected an error, got certificate %s", got.Subject.CommonName)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ClientCertificate: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Fatalf("expected certificate %s, got %s", tt.want.Subject.CommonName, got.Subject.CommonName)
+			}
+		})
+	}
+}
@@ -143,7 +143,7 @@ func (i *Idempotency) Idempotent() echo.MiddlewareFunc {
 
 // Helper to capture response
 type responseRecorder struct {
-	echo.ResponseWriter
+	http.ResponseWriter
 	body   *bytes.Buffer
 	status int
 }
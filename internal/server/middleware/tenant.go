@@ -4,9 +4,9 @@ import (
 	
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/labstack/echo/v4"
+	"github.com/yordanos-habtamu/b2b-payments/internal/identity"
 )
 
 const (
@@ -15,19 +15,13 @@ const (
 
 // TenantExtraction extracts tenant ID from the verified client certificate's Common Name.
 // Expected format: CN=tenant-<tenant_id>.yourorg.com
-func TenantExtraction() echo.MiddlewareFunc {
+// Behind a proxy in trust, the certificate the proxy forwards is used instead.
+func TenantExtraction(trust *ProxyTrust) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			tlsConnState := c.Request().TLS
-			if tlsConnState == nil || len(tlsConnState.PeerCertificates) == 0 {
-				return echo.NewHTTPError(http.StatusUnauthorized, "missing client certificate")
-			}
-
-			clientCert := tlsConnState.PeerCertificates[0]
-
-			// We only trust verified chains (mTLS already enforces this)
-			if tlsConnState.VerifiedChains == nil || len(tlsConnState.VerifiedChains) == 0 {
-				return echo.NewHTTPError(http.StatusUnauthorized, "client certificate not verified by trusted CA")
+			clientCert, err := trust.ClientCertificate(c.Request())
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
 			}
 
 			cn := clientCert.Subject.CommonName
@@ -35,18 +29,14 @@ func TenantExtraction() echo.MiddlewareFunc {
 				return echo.NewHTTPError(http.StatusUnauthorized, "client certificate missing Common Name")
 			}
 
-			// Extract tenant ID from expected pattern: tenant-<id>.yourorg.com
-			if !strings.HasPrefix(cn, "tenant-") || !strings.HasSuffix(cn, ".yourorg.com") {
-				return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("invalid client certificate CN: %s", cn))
-			}
-
-			tenantID := strings.TrimSuffix(strings.TrimPrefix(cn, "tenant-"), ".yourorg.com")
-			if tenantID == "" {
-				return echo.NewHTTPError(http.StatusForbidden, "empty tenant ID in certificate")
+			tenantID, err := identity.TenantIDFromCommonName(cn)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusForbidden, err.Error())
 			}
 
 			// Inject into context
 			c.Set(TenantContextKey, tenantID)
+			c.Set(ClientCertContextKey, clientCert)
 
 			// Optional: log for audit
 			c.Logger().Infof("Authenticated tenant: %s (from cert SN: %x)", tenantID, clientCert.SerialNumber)
@@ -2,12 +2,18 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// ErrPaymentConflict is returned when a payment's status changed between
+// reading and writing it, so the transition was not applied
+var ErrPaymentConflict = errors.New("payment status changed concurrently")
+
 type PaymentStatus string
 type PaymentType string
 type Currency string
@@ -49,6 +55,7 @@ type Payment struct {
 	CompletedAt     *time.Time    `json:"completed_at,omitempty"`
 	FailedAt        *time.Time    `json:"failed_at,omitempty"`
 	FailureReason   string        `json:"failure_reason,omitempty"`
+	RetryCount      int           `json:"retry_count"`
 }
 
 type CreatePaymentRequest struct {
@@ -86,6 +93,10 @@ type PaymentService interface {
 	ListPayments(ctx context.Context, tenantID string, filter *PaymentFilter) ([]*Payment, int64, error)
 	ProcessPayment(ctx context.Context, tenantID, paymentID string) error
 	CancelPayment(ctx context.Context, tenantID, paymentID string) error
+	StartProcessing(ctx context.Context, tenantID, paymentID string) error
+	CompletePayment(ctx context.Context, tenantID, paymentID string) error
+	FailPayment(ctx context.Context, tenantID, paymentID, reason string) error
+	RetryPayment(ctx context.Context, tenantID, paymentID string) error
 	GetPaymentStats(ctx context.Context, tenantID string) (*PaymentStats, error)
 }
 
@@ -99,15 +110,25 @@ type PaymentStats struct {
 	FailedAmount    float64 `json:"failed_amount"`
 }
 
+// PaymentEventPublisher is the subset of event.EventPublisher used to announce payment changes
+type PaymentEventPublisher interface {
+	PublishPaymentCreated(ctx context.Context, tenantID, paymentID string, amount float64, currency string) error
+	PublishPaymentStatusChanged(ctx context.Context, tenantID, paymentID, oldStatus, newStatus string) error
+	PublishPaymentCompleted(ctx context.Context, tenantID, paymentID string, completedAt time.Time) error
+	PublishPaymentCancelled(ctx context.Context, tenantID, paymentID string, cancelledAt time.Time) error
+}
+
 type paymentService struct {
-	// This will be implemented with repository pattern
-	// For now, we'll create a basic in-memory implementation
-	payments map[string]*Payment
+	store     PaymentStore
+	publisher PaymentEventPublisher
 }
 
-func NewPaymentService() PaymentService {
+// NewPaymentService creates the payment service on top of store. publisher
+// may be nil, in which case no payment events are emitted.
+func NewPaymentService(store PaymentStore, publisher PaymentEventPublisher) PaymentService {
 	return &paymentService{
-		payments: make(map[string]*Payment),
+		store:     store,
+		publisher: publisher,
 	}
 }
 
@@ -137,35 +158,27 @@ func (s *paymentService) CreatePayment(ctx context.Context, tenantID string, req
 		UpdatedAt:          time.Now().UTC(),
 	}
 
-	// Store payment (in-memory for now)
-	s.payments[paymentID] = payment
-
-	return payment, nil
-}
-
-func (s *paymentService) GetPayment(ctx context.Context, tenantID, paymentID string) (*Payment, error) {
-	payment, exists := s.payments[paymentID]
-	if !exists {
-		return nil, fmt.Errorf("payment not found")
+	if err := s.store.Create(ctx, payment); err != nil {
+		return nil, fmt.Errorf("failed to store payment: %w", err)
 	}
 
-	// Verify tenant ownership
-	if payment.TenantID != tenantID {
-		return nil, fmt.Errorf("access denied")
+	if s.publisher != nil {
+		if err := s.publisher.PublishPaymentCreated(ctx, tenantID, paymentID, payment.Amount, string(payment.Currency)); err != nil {
+			log.Printf("Failed to publish payment created event for %s: %v", paymentID, err)
+		}
 	}
 
 	return payment, nil
 }
 
-func (s *paymentService) UpdatePayment(ctx context.Context, tenantID, paymentID string, req *UpdatePaymentRequest) (*Payment, error) {
-	payment, exists := s.payments[paymentID]
-	if !exists {
-		return nil, fmt.Errorf("payment not found")
-	}
+func (s *paymentService) GetPayment(ctx context.Context, tenantID, paymentID string) (*Payment, error) {
+	return s.store.GetByID(ctx, tenantID, paymentID)
+}
 
-	// Verify tenant ownership
-	if payment.TenantID != tenantID {
-		return nil, fmt.Errorf("access denied")
+func (s *paymentService) UpdatePayment(ctx context.Context, tenantID, paymentID string, req *UpdatePaymentRequest) (*Payment, error) {
+	payment, err := s.store.GetByID(ctx, tenantID, paymentID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Only allow updates on pending payments
@@ -184,73 +197,20 @@ func (s *paymentService) UpdatePayment(ctx context.Context, tenantID, paymentID
 
 	payment.UpdatedAt = time.Now().UTC()
 
+	if err := s.save(ctx, payment, PaymentStatusPending); err != nil {
+		return nil, err
+	}
 	return payment, nil
 }
 
 func (s *paymentService) ListPayments(ctx context.Context, tenantID string, filter *PaymentFilter) ([]*Payment, int64, error) {
-	var payments []*Payment
-	
-	for _, payment := range s.payments {
-		if payment.TenantID != tenantID {
-			continue
-		}
-
-		// Apply filters
-		if filter != nil {
-			if filter.Status != nil && payment.Status != *filter.Status {
-				continue
-			}
-			if filter.Type != nil && payment.Type != *filter.Type {
-				continue
-			}
-			if filter.Currency != nil && payment.Currency != *filter.Currency {
-				continue
-			}
-			if filter.MinAmount != nil && payment.Amount < *filter.MinAmount {
-				continue
-			}
-			if filter.MaxAmount != nil && payment.Amount > *filter.MaxAmount {
-				continue
-			}
-			if filter.FromDate != nil && payment.CreatedAt.Before(*filter.FromDate) {
-				continue
-			}
-			if filter.ToDate != nil && payment.CreatedAt.After(*filter.ToDate) {
-				continue
-			}
-		}
-
-		payments = append(payments, payment)
-	}
-
-	// Apply pagination
-	total := int64(len(payments))
-	if filter != nil && filter.Limit > 0 {
-		offset := filter.Offset
-		if offset >= len(payments) {
-			return []*Payment{}, total, nil
-		}
-		
-		end := offset + filter.Limit
-		if end > len(payments) {
-			end = len(payments)
-		}
-		
-		payments = payments[offset:end]
-	}
-
-	return payments, total, nil
+	return s.store.List(ctx, tenantID, filter)
 }
 
 func (s *paymentService) ProcessPayment(ctx context.Context, tenantID, paymentID string) error {
-	payment, exists := s.payments[paymentID]
-	if !exists {
-		return fmt.Errorf("payment not found")
-	}
-
-	// Verify tenant ownership
-	if payment.TenantID != tenantID {
-		return fmt.Errorf("access denied")
+	payment, err := s.store.GetByID(ctx, tenantID, paymentID)
+	if err != nil {
+		return err
 	}
 
 	// Only process pending payments
@@ -273,18 +233,26 @@ func (s *paymentService) ProcessPayment(ctx context.Context, tenantID, paymentID
 	payment.CompletedAt = &now
 	payment.UpdatedAt = time.Now().UTC()
 
+	if err := s.save(ctx, payment, PaymentStatusPending); err != nil {
+		return err
+	}
+
+	if s.publisher != nil {
+		if err := s.publisher.PublishPaymentStatusChanged(ctx, tenantID, paymentID, string(PaymentStatusPending), string(PaymentStatusCompleted)); err != nil {
+			log.Printf("Failed to publish status change event for %s: %v", paymentID, err)
+		}
+		if err := s.publisher.PublishPaymentCompleted(ctx, tenantID, paymentID, now); err != nil {
+			log.Printf("Failed to publish payment completed event for %s: %v", paymentID, err)
+		}
+	}
+
 	return nil
 }
 
 func (s *paymentService) CancelPayment(ctx context.Context, tenantID, paymentID string) error {
-	payment, exists := s.payments[paymentID]
-	if !exists {
-		return fmt.Errorf("payment not found")
-	}
-
-	// Verify tenant ownership
-	if payment.TenantID != tenantID {
-		return fmt.Errorf("access denied")
+	payment, err := s.store.GetByID(ctx, tenantID, paymentID)
+	if err != nil {
+		return err
 	}
 
 	// Only cancel pending or processing payments
@@ -292,36 +260,160 @@ func (s *paymentService) CancelPayment(ctx context.Context, tenantID, paymentID
 		return fmt.Errorf("payment cannot be cancelled in current status: %s", payment.Status)
 	}
 
+	previousStatus := payment.Status
 	payment.Status = PaymentStatusCancelled
 	payment.UpdatedAt = time.Now().UTC()
 
+	if err := s.save(ctx, payment, previousStatus); err != nil {
+		return err
+	}
+
+	if s.publisher != nil {
+		if err := s.publisher.PublishPaymentStatusChanged(ctx, tenantID, paymentID, string(previousStatus), string(PaymentStatusCancelled)); err != nil {
+			log.Printf("Failed to publish status change event for %s: %v", paymentID, err)
+		}
+		if err := s.publisher.PublishPaymentCancelled(ctx, tenantID, paymentID, payment.UpdatedAt); err != nil {
+			log.Printf("Failed to publish payment cancelled event for %s: %v", paymentID, err)
+		}
+	}
+
 	return nil
 }
 
-func (s *paymentService) GetPaymentStats(ctx context.Context, tenantID string) (*PaymentStats, error) {
-	stats := &PaymentStats{}
+// StartProcessing moves a pending payment to processing. A payment that is
+// already processing is left as is so an interrupted run can resume.
+func (s *paymentService) StartProcessing(ctx context.Context, tenantID, paymentID string) error {
+	payment, err := s.GetPayment(ctx, tenantID, paymentID)
+	if err != nil {
+		return err
+	}
 
-	for _, payment := range s.payments {
-		if payment.TenantID != tenantID {
-			continue
-		}
+	switch payment.Status {
+	case PaymentStatusProcessing:
+		return nil
+	case PaymentStatusPending:
+	default:
+		return fmt.Errorf("payment cannot be processed in current status: %s", payment.Status)
+	}
+
+	now := time.Now().UTC()
+	payment.Status = PaymentStatusProcessing
+	payment.ProcessedAt = &now
+	payment.UpdatedAt = now
+	if err := s.save(ctx, payment, PaymentStatusPending); err != nil {
+		return err
+	}
+
+	s.publishStatusChange(ctx, tenantID, paymentID, PaymentStatusPending, PaymentStatusProcessing)
+	return nil
+}
 
-		stats.TotalCount++
-		stats.TotalAmount += payment.Amount
-
-		switch payment.Status {
-		case PaymentStatusPending:
-			stats.PendingCount++
-		case PaymentStatusCompleted:
-			stats.CompletedCount++
-			stats.CompletedAmount += payment.Amount
-		case PaymentStatusFailed:
-			stats.FailedCount++
-			stats.FailedAmount += payment.Amount
+// CompletePayment marks a processing payment as completed
+func (s *paymentService) CompletePayment(ctx context.Context, tenantID, paymentID string) error {
+	payment, err := s.GetPayment(ctx, tenantID, paymentID)
+	if err != nil {
+		return err
+	}
+
+	if payment.Status == PaymentStatusCompleted {
+		return nil
+	}
+	if payment.Status != PaymentStatusProcessing {
+		return fmt.Errorf("payment cannot be completed in current status: %s", payment.Status)
+	}
+
+	now := time.Now().UTC()
+	payment.Status = PaymentStatusCompleted
+	payment.CompletedAt = &now
+	payment.UpdatedAt = now
+	if err := s.save(ctx, payment, PaymentStatusProcessing); err != nil {
+		return err
+	}
+
+	s.publishStatusChange(ctx, tenantID, paymentID, PaymentStatusProcessing, PaymentStatusCompleted)
+	if s.publisher != nil {
+		if err := s.publisher.PublishPaymentCompleted(ctx, tenantID, paymentID, now); err != nil {
+			log.Printf("Failed to publish payment completed event for %s: %v", paymentID, err)
 		}
 	}
+	return nil
+}
+
+// FailPayment marks a pending or processing payment as failed with reason
+func (s *paymentService) FailPayment(ctx context.Context, tenantID, paymentID, reason string) error {
+	payment, err := s.GetPayment(ctx, tenantID, paymentID)
+	if err != nil {
+		return err
+	}
+
+	if payment.Status == PaymentStatusFailed {
+		return nil
+	}
+	if payment.Status != PaymentStatusPending && payment.Status != PaymentStatusProcessing {
+		return fmt.Errorf("payment cannot be failed in current status: %s", payment.Status)
+	}
 
-	return stats, nil
+	previousStatus := payment.Status
+	now := time.Now().UTC()
+	payment.Status = PaymentStatusFailed
+	payment.FailedAt = &now
+	payment.FailureReason = reason
+	payment.UpdatedAt = now
+	if err := s.save(ctx, payment, previousStatus); err != nil {
+		return err
+	}
+
+	s.publishStatusChange(ctx, tenantID, paymentID, previousStatus, PaymentStatusFailed)
+	return nil
+}
+
+// RetryPayment returns a failed payment to pending so it can be processed
+// again, counting the retry. The failure reason is cleared.
+func (s *paymentService) RetryPayment(ctx context.Context, tenantID, paymentID string) error {
+	payment, err := s.GetPayment(ctx, tenantID, paymentID)
+	if err != nil {
+		return err
+	}
+
+	if payment.Status != PaymentStatusFailed {
+		return fmt.Errorf("payment cannot be retried in current status: %s", payment.Status)
+	}
+
+	payment.Status = PaymentStatusPending
+	payment.RetryCount++
+	payment.FailedAt = nil
+	payment.FailureReason = ""
+	payment.ProcessedAt = nil
+	payment.UpdatedAt = time.Now().UTC()
+	if err := s.save(ctx, payment, PaymentStatusFailed); err != nil {
+		return err
+	}
+
+	s.publishStatusChange(ctx, tenantID, paymentID, PaymentStatusFailed, PaymentStatusPending)
+	return nil
+}
+
+// save writes payment back provided its stored status is still expected, the
+// status it was read with. Otherwise a concurrent transition (a cancellation
+// racing the saga, say) won and save returns ErrPaymentConflict.
+func (s *paymentService) save(ctx context.Context, payment *Payment, expected PaymentStatus) error {
+	if err := s.store.UpdateStatus(ctx, payment, expected); err != nil {
+		return fmt.Errorf("failed to update payment: %w", err)
+	}
+	return nil
+}
+
+func (s *paymentService) publishStatusChange(ctx context.Context, tenantID, paymentID string, oldStatus, newStatus PaymentStatus) {
+	if s.publisher == nil {
+		return
+	}
+	if err := s.publisher.PublishPaymentStatusChanged(ctx, tenantID, paymentID, string(oldStatus), string(newStatus)); err != nil {
+		log.Printf("Failed to publish status change event for %s: %v", paymentID, err)
+	}
+}
+
+func (s *paymentService) GetPaymentStats(ctx context.Context, tenantID string) (*PaymentStats, error) {
+	return s.store.GetStats(ctx, tenantID)
 }
 
 func (s *paymentService) validateCreatePaymentRequest(tenantID string, req *CreatePaymentRequest) error {
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+)
+
+// PaymentStore persists payments. repository.PaymentRepository implements it
+// on Postgres; NewMemoryPaymentStore keeps payments in process memory.
+// GetByID only returns payments belonging to tenantID. UpdateStatus writes
+// payment only if its stored status is still expected, and returns
+// ErrPaymentConflict if it is not.
+type PaymentStore interface {
+	Create(ctx context.Context, payment *Payment) error
+	GetByID(ctx context.Context, tenantID, paymentID string) (*Payment, error)
+	UpdateStatus(ctx context.Context, payment *Payment, expected PaymentStatus) error
+	List(ctx context.Context, tenantID string, filter *PaymentFilter) ([]*Payment, int64, error)
+	GetStats(ctx context.Context, tenantID string) (*PaymentStats, error)
+}
+
+type memoryPaymentStore struct {
+	mu       sync.RWMutex
+	payments map[string]*Payment
+}
+
+// NewMemoryPaymentStore returns a store that keeps payments in memory. They
+// are lost on restart and not shared between processes, so it only suits
+// tests and local development. It is safe for concurrent use, such as by the
+// worker pool's goroutines.
+func NewMemoryPaymentStore() PaymentStore {
+	return &memoryPaymentStore{
+		payments: make(map[string]*Payment),
+	}
+}
+
+func (m *memoryPaymentStore) Create(ctx context.Context, payment *Payment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.payments[payment.ID]; exists {
+		return fmt.Errorf("payment %s already exists", payment.ID)
+	}
+	m.payments[payment.ID] = copyPayment(payment)
+	return nil
+}
+
+func (m *memoryPaymentStore) GetByID(ctx context.Context, tenantID, paymentID string) (*Payment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	payment, exists := m.payments[paymentID]
+	if !exists {
+		return nil, fmt.Errorf("payment not found")
+	}
+
+	// Verify tenant ownership
+	if payment.TenantID != tenantID {
+		return nil, fmt.Errorf("access denied")
+	}
+
+	// Hand out a copy so changes only take effect through UpdateStatus, as with a database
+	return copyPayment(payment), nil
+}
+
+func (m *memoryPaymentStore) UpdateStatus(ctx context.Context, payment *Payment, expected PaymentStatus) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, exists := m.payments[payment.ID]
+	if !exists || existing.TenantID != payment.TenantID {
+		return fmt.Errorf("payment not found")
+	}
+	if existing.Status != expected {
+		return ErrPaymentConflict
+	}
+	m.payments[payment.ID] = copyPayment(payment)
+	return nil
+}
+
+func (m *memoryPaymentStore) List(ctx context.Context, tenantID string, filter *PaymentFilter) ([]*Payment, int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var payments []*Payment
+
+	for _, payment := range m.payments {
+		if payment.TenantID != tenantID {
+			continue
+		}
+
+		// Apply filters
+		if filter != nil {
+			if filter.Status != nil && payment.Status != *filter.Status {
+				continue
+			}
+			if filter.Type != nil && payment.Type != *filter.Type {
+				continue
+			}
+			if filter.Currency != nil && payment.Currency != *filter.Currency {
+				continue
+			}
+			if filter.MinAmount != nil && payment.Amount < *filter.MinAmount {
+				continue
+			}
+			if filter.MaxAmount != nil && payment.Amount > *filter.MaxAmount {
+				continue
+			}
+			if filter.FromDate != nil && payment.CreatedAt.Before(*filter.FromDate) {
+				continue
+			}
+			if filter.ToDate != nil && payment.CreatedAt.After(*filter.ToDate) {
+				continue
+			}
+		}
+
+		payments = append(payments, copyPayment(payment))
+	}
+
+	// Apply pagination
+	total := int64(len(payments))
+	if filter != nil && filter.Limit > 0 {
+		offset := filter.Offset
+		if offset >= len(payments) {
+			return []*Payment{}, total, nil
+		}
+
+		end := offset + filter.Limit
+		if end > len(payments) {
+			end = len(payments)
+		}
+
+		payments = payments[offset:end]
+	}
+
+	return payments, total, nil
+}
+
+func (m *memoryPaymentStore) GetStats(ctx context.Context, tenantID string) (*PaymentStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := &PaymentStats{}
+
+	for _, payment := range m.payments {
+		if payment.TenantID != tenantID {
+			continue
+		}
+
+		stats.TotalCount++
+		stats.TotalAmount += payment.Amount
+
+		switch payment.Status {
+		case PaymentStatusPending:
+			stats.PendingCount++
+		case PaymentStatusCompleted:
+			stats.CompletedCount++
+			stats.CompletedAmount += payment.Amount
+		case PaymentStatusFailed:
+			stats.FailedCount++
+			stats.FailedAmount += payment.Amount
+		}
+	}
+
+	return stats, nil
+}
+
+// copyPayment copies payment, including its metadata map, so goroutines
+// holding different copies never share mutable state
+func copyPayment(payment *Payment) *Payment {
+	copied := *payment
+	copied.Metadata = maps.Clone(payment.Metadata)
+	return &copied
+}
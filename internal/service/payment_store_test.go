@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestMemoryPaymentStoreConcurrentUse drives the service the way the worker
+// pool does, from many goroutines at once; run with -race
+func TestMemoryPaymentStoreConcurrentUse(t *testing.T) {
+	svc := NewPaymentService(NewMemoryPaymentStore(), nil)
+	ctx := context.Background()
+
+	const workers = 8
+	const perWorker = 25
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*perWorker)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				payment, err := svc.CreatePayment(ctx, "tenant_1", &CreatePaymentRequest{
+					Amount:             10,
+					Currency:           CurrencyUSD,
+					Type:               PaymentTypeCredit,
+					Description:        fmt.Sprintf("payment %d-%d", w, i),
+					SourceAccount:      "acc_src",
+					DestinationAccount: "acc_dst",
+					Metadata:           map[string]interface{}{"worker": w},
+				})
+				if err != nil {
+					errs <- err
+					continue
+				}
+				if err := svc.StartProcessing(ctx, "tenant_1", payment.ID); err != nil {
+					errs <- err
+					continue
+				}
+				if err := svc.CompletePayment(ctx, "tenant_1", payment.ID); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+
+		// Readers run alongside the writers
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				if _, _, err := svc.ListPayments(ctx, "tenant_1", &PaymentFilter{Limit: 10}); err != nil {
+					errs <- err
+				}
+				if _, err := svc.GetPaymentStats(ctx, "tenant_1"); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("concurrent call failed: %v", err)
+	}
+
+	stats, err := svc.GetPaymentStats(ctx, "tenant_1")
+	if err != nil {
+		t.Fatalf("GetPaymentStats failed: %v", err)
+	}
+	if want := int64(workers * perWorker); stats.TotalCount != want || stats.CompletedCount != want {
+		t.Errorf("stats = %+v, want %d payments all completed", stats, want)
+	}
+}
+
+func TestMemoryPaymentStoreCopiesMetadata(t *testing.T) {
+	store := NewMemoryPaymentStore()
+	ctx := context.Background()
+
+	payment := &Payment{ID: "pay_1", TenantID: "tenant_1", Metadata: map[string]interface{}{"ref": "a"}}
+	if err := store.Create(ctx, payment); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// Changes to a caller's copy never reach the stored payment
+	payment.Metadata["ref"] = "b"
+	found, err := store.GetByID(ctx, "tenant_1", "pay_1")
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	found.Metadata["ref"] = "c"
+
+	found, err = store.GetByID(ctx, "tenant_1", "pay_1")
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if found.Metadata["ref"] != "a" {
+		t.Errorf("stored metadata ref = %v, want a", found.Metadata["ref"])
+	}
+}
+
+// interleavingStore runs interleave once, after the next GetByID has read the
+// payment, to simulate a concurrent transition between read and write
+type interleavingStore struct {
+	PaymentStore
+	interleave func()
+}
+
+func (s *interleavingStore) GetByID(ctx context.Context, tenantID, paymentID string) (*Payment, error) {
+	payment, err := s.PaymentStore.GetByID(ctx, tenantID, paymentID)
+	if interleave := s.interleave; interleave != nil {
+		s.interleave = nil
+		interleave()
+	}
+	return payment, err
+}
+
+func TestPaymentTransitionLosesToConcurrentCancel(t *testing.T) {
+	store := &interleavingStore{PaymentStore: NewMemoryPaymentStore()}
+	svc := NewPaymentService(store, nil)
+	ctx := context.Background()
+
+	payment, err := svc.CreatePayment(ctx, "tenant_1", &CreatePaymentRequest{
+		Amount:             10,
+		Currency:           CurrencyUSD,
+		Type:               PaymentTypeCredit,
+		SourceAccount:      "acc_src",
+		DestinationAccount: "acc_dst",
+	})
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+
+	// The tenant cancels after the saga has checked the payment is pending
+	store.interleave = func() {
+		if err := svc.CancelPayment(ctx, "tenant_1", payment.ID); err != nil {
+			t.Fatalf("CancelPayment failed: %v", err)
+		}
+	}
+	if err := svc.StartProcessing(ctx, "tenant_1", payment.ID); !errors.Is(err, ErrPaymentConflict) {
+		t.Fatalf("StartProcessing error = %v, want %v", err, ErrPaymentConflict)
+	}
+
+	found, err := svc.GetPayment(ctx, "tenant_1", payment.ID)
+	if err != nil {
+		t.Fatalf("GetPayment failed: %v", err)
+	}
+	if found.Status != PaymentStatusCancelled {
+		t.Errorf("status = %s, want the cancellation to stand", found.Status)
+	}
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"syscall"
+	"time"
+
+	"github.com/yordanos-habtamu/b2b-payments/internal/resilience"
+)
+
+var (
+	// ErrDeclined is wrapped by processor errors that refuse a payment, such
+	// as insufficient funds or a sanctions hit. A declined call took no effect.
+	ErrDeclined = errors.New("payment declined")
+)
+
+// Decline returns an error declining a payment for reason
+func Decline(reason string) error {
+	return fmt.Errorf("%w: %s", ErrDeclined, reason)
+}
+
+// PaymentProcessor performs the external steps of moving money. Every
+// operation that has a side effect has a matching operation that undoes it.
+// Side effects are keyed by a reference the caller chooses, so a call
+// repeated with the same reference (after a timeout or crash) takes effect
+// once, and an undo can name a call whose result never came back. Undoing a
+// reference that took no effect must succeed.
+type PaymentProcessor interface {
+	ReserveFunds(ctx context.Context, payment *Payment, reservationID string) error
+	ReleaseFunds(ctx context.Context, payment *Payment, reservationID string) error
+	Screen(ctx context.Context, payment *Payment) error
+	SubmitToRail(ctx context.Context, payment *Payment, railReference string) error
+	RecallFromRail(ctx context.Context, payment *Payment, railReference string) error
+	PostLedger(ctx context.Context, payment *Payment, entryID string) error
+	ReverseLedger(ctx context.Context, payment *Payment, entryID string) error
+	Notify(ctx context.Context, payment *Payment) error
+}
+
+// OutcomeUnknown reports whether a processor call that failed with err may
+// nonetheless have taken effect, as when it timed out after the request was
+// sent. Only declines and calls refused before they were made (by a limiter,
+// circuit breaker, short deadline or refused connection) are known to have
+// taken no effect.
+func OutcomeUnknown(err error) bool {
+	return err != nil &&
+		!errors.Is(err, ErrDeclined) &&
+		!resilience.IsUnavailable(err) &&
+		!errors.Is(err, resilience.ErrDeadlineTooShort) &&
+		!errors.Is(err, syscall.ECONNREFUSED)
+}
+
+type simulatedProcessor struct{}
+
+// NewSimulatedProcessor returns a processor that logs each step and always
+// succeeds. It stands in until real bank, screening and ledger integrations exist.
+func NewSimulatedProcessor() PaymentProcessor {
+	return simulatedProcessor{}
+}
+
+func (simulatedProcessor) ReserveFunds(ctx context.Context, payment *Payment, reservationID string) error {
+	log.Printf("Reserved %.2f %s on %s for payment %s (%s)",
+		payment.Amount, payment.Currency, payment.SourceAccount, payment.ID, reservationID)
+	return nil
+}
+
+func (simulatedProcessor) ReleaseFunds(ctx context.Context, payment *Payment, reservationID string) error {
+	log.Printf("Released reservation %s for payment %s", reservationID, payment.ID)
+	return nil
+}
+
+func (simulatedProcessor) Screen(ctx context.Context, payment *Payment) error {
+	log.Printf("Screened payment %s", payment.ID)
+	return nil
+}
+
+func (simulatedProcessor) SubmitToRail(ctx context.Context, payment *Payment, railReference string) error {
+	log.Printf("Submitted payment %s to rail (%s)", payment.ID, railReference)
+	return nil
+}
+
+func (simulatedProcessor) RecallFromRail(ctx context.Context, payment *Payment, railReference string) error {
+	log.Printf("Recalled rail submission %s for payment %s", railReference, payment.ID)
+	return nil
+}
+
+func (simulatedProcessor) PostLedger(ctx context.Context, payment *Payment, entryID string) error {
+	log.Printf("Posted ledger entry %s for payment %s", entryID, payment.ID)
+	return nil
+}
+
+func (simulatedProcessor) ReverseLedger(ctx context.Context, payment *Payment, entryID string) error {
+	log.Printf("Reversed ledger entry %s for payment %s", entryID, payment.ID)
+	return nil
+}
+
+func (simulatedProcessor) Notify(ctx context.Context, payment *Payment) error {
+	log.Printf("Notified tenant %s that payment %s completed", payment.TenantID, payment.ID)
+	return nil
+}
+
+type resilientProcessor struct {
+	next     PaymentProcessor
+	limiter  resilience.Limiter
+	deadline *resilience.Deadline
+	hedger   *resilience.Hedger
+	budget   *resilience.RetryBudget
+}
+
+// NewResilientProcessor admits every call to next through limiter, bounds it
+// by deadline and hedges screening, the one read-only step, with hedger and
+// retries it within budget. Any of them may be nil.
+func NewResilientProcessor(next PaymentProcessor, limiter resilience.Limiter, deadline *resilience.Deadline, hedger *resilience.Hedger, budget *resilience.RetryBudget) PaymentProcessor {
+	return &resilientProcessor{
+		next:     next,
+		limiter:  limiter,
+		deadline: deadline,
+		hedger:   hedger,
+		budget:   budget,
+	}
+}
+
+// call runs fn once the limiter admits it, under the connector's deadline
+func (p *resilientProcessor) call(ctx context.Context, fn func(context.Context) error) error {
+	return resilience.Limit(ctx, p.limiter, func(ctx context.Context) error {
+		return p.deadline.Execute(ctx, fn)
+	})
+}
+
+func (p *resilientProcessor) ReserveFunds(ctx context.Context, payment *Payment, reservationID string) error {
+	return p.call(ctx, func(ctx context.Context) error {
+		return p.next.ReserveFunds(ctx, payment, reservationID)
+	})
+}
+
+func (p *resilientProcessor) ReleaseFunds(ctx context.Context, payment *Payment, reservationID string) error {
+	return p.call(ctx, func(ctx context.Context) error {
+		return p.next.ReleaseFunds(ctx, payment, reservationID)
+	})
+}
+
+func (p *resilientProcessor) Screen(ctx context.Context, payment *Payment) error {
+	screen := func(ctx context.Context) error {
+		return p.call(ctx, func(ctx context.Context) error {
+			_, err := resilience.Hedge(ctx, p.hedger, func(ctx context.Context) (struct{}, error) {
+				return struct{}{}, p.next.Screen(ctx, payment)
+			})
+			return err
+		})
+	}
+	if p.budget == nil {
+		return screen(ctx)
+	}
+
+	config := resilience.DefaultRetryConfig()
+	config.MaxDelay = time.Second
+	config.Budget = p.budget
+	return resilience.RetryWithContext(ctx, screen, config)
+}
+
+func (p *resilientProcessor) SubmitToRail(ctx context.Context, payment *Payment, railReference string) error {
+	return p.call(ctx, func(ctx context.Context) error {
+		return p.next.SubmitToRail(ctx, payment, railReference)
+	})
+}
+
+func (p *resilientProcessor) RecallFromRail(ctx context.Context, payment *Payment, railReference string) error {
+	return p.call(ctx, func(ctx context.Context) error {
+		return p.next.RecallFromRail(ctx, payment, railReference)
+	})
+}
+
+func (p *resilientProcessor) PostLedger(ctx context.Context, payment *Payment, entryID string) error {
+	return p.call(ctx, func(ctx context.Context) error {
+		return p.next.PostLedger(ctx, payment, entryID)
+	})
+}
+
+func (p *resilientProcessor) ReverseLedger(ctx context.Context, payment *Payment, entryID string) error {
+	return p.call(ctx, func(ctx context.Context) error {
+		return p.next.ReverseLedger(ctx, payment, entryID)
+	})
+}
+
+func (p *resilientProcessor) Notify(ctx context.Context, payment *Payment) error {
+	return p.call(ctx, func(ctx context.Context) error {
+		return p.next.Notify(ctx, payment)
+	})
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yordanos-habtamu/b2b-payments/internal/resilience"
+)
+
+type countingProcessor struct {
+	simulatedProcessor
+	reserves int
+}
+
+func (p *countingProcessor) ReserveFunds(ctx context.Context, payment *Payment, reservationID string) error {
+	p.reserves++
+	return nil
+}
+
+func TestResilientProcessorShedsCallsOverLimit(t *testing.T) {
+	next := &countingProcessor{}
+	bulkhead := resilience.NewBulkhead(resilience.BulkheadConfig{Name: "payment_processor", MaxConcurrent: 1, RetryAfter: 3 * time.Second})
+	processor := NewResilientProcessor(next, bulkhead, nil, nil, nil)
+	payment := &Payment{ID: "pay_1", TenantID: "tenant_1"}
+
+	// Hold the only slot
+	done, err := bulkhead.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	err = processor.ReserveFunds(context.Background(), payment, "res_pay_1_1")
+	if !errors.Is(err, resilience.ErrRejected) {
+		t.Fatalf("error = %v, want a limiter rejection", err)
+	}
+	if next.reserves != 0 {
+		t.Fatal("connector was called despite the rejection")
+	}
+	// A shed call never reached the connector, so it is safe to retry
+	if OutcomeUnknown(err) || ClassifyFailure(err, OutcomeUnknown(err)) != FailureRetryable {
+		t.Errorf("rejection classified as %s, want %s", ClassifyFailure(err, OutcomeUnknown(err)), FailureRetryable)
+	}
+
+	done(nil)
+	if err := processor.ReserveFunds(context.Background(), payment, "res_pay_1_1"); err != nil {
+		t.Fatalf("ReserveFunds failed once a slot was free: %v", err)
+	}
+	if next.reserves != 1 {
+		t.Errorf("reserves = %d, want 1", next.reserves)
+	}
+}
+
+type flakyScreener struct {
+	simulatedProcessor
+	failures, screens int
+}
+
+func (p *flakyScreener) Screen(ctx context.Context, payment *Payment) error {
+	p.screens++
+	if p.screens <= p.failures {
+		return resilience.ErrServiceUnavailable
+	}
+	return nil
+}
+
+type retryMetrics struct {
+	attempts, exhausted int
+}
+
+func (m *retryMetrics) RecordRetryAttempt(string)         { m.attempts++ }
+func (m *retryMetrics) RecordRetryBudgetExhausted(string) { m.exhausted++ }
+
+func TestResilientProcessorRetriesScreeningWithinBudget(t *testing.T) {
+	next := &flakyScreener{failures: 3}
+	budget := resilience.NewRetryBudget(resilience.RetryBudgetConfig{Name: "payment_processor", MaxTokens: 1})
+	metrics := &retryMetrics{}
+	budget.SetMetrics(metrics)
+	processor := NewResilientProcessor(next, nil, nil, nil, budget)
+	payment := &Payment{ID: "pay_1", TenantID: "tenant_1"}
+
+	// One retry is in the budget; the second failure ends the call
+	err := processor.Screen(context.Background(), payment)
+	if !errors.Is(err, resilience.ErrRetryBudgetExhausted) {
+		t.Fatalf("error = %v, want %v", err, resilience.ErrRetryBudgetExhausted)
+	}
+	if next.screens != 2 {
+		t.Errorf("screens = %d, want 2", next.screens)
+	}
+	if metrics.attempts != 1 || metrics.exhausted != 1 {
+		t.Errorf("metrics = %+v, want 1 retry and 1 exhaustion", *metrics)
+	}
+
+	// Side-effecting calls are never retried
+	if err := processor.ReserveFunds(context.Background(), payment, "res_pay_1_1"); err != nil {
+		t.Fatalf("ReserveFunds failed: %v", err)
+	}
+	if metrics.attempts != 1 {
+		t.Errorf("attempts = %d after ReserveFunds, want 1", metrics.attempts)
+	}
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/yordanos-habtamu/b2b-payments/internal/resilience"
+	"gopkg.in/yaml.v3"
+)
+
+// FailureClass says whether a payment failure is worth retrying
+type FailureClass string
+
+const (
+	FailureRetryable FailureClass = "retryable"
+	FailureTerminal  FailureClass = "terminal"
+	// FailureUnknown is a failure that may have moved money anyway, such as
+	// a rail submission that timed out. It is never retried automatically:
+	// each attempt uses new step references, so the processor could not
+	// tell a retry from a second payment.
+	FailureUnknown FailureClass = "unknown"
+)
+
+// ClassifyFailure classifies the error a payment step failed with.
+// mayHaveApplied says whether the step has a side effect that may have taken
+// effect despite err. Declines are terminal; calls refused before they were
+// made are retryable; a possibly applied side effect is unknown; other
+// errors are retryable when resilience.IsRetryableError says so and terminal
+// otherwise.
+func ClassifyFailure(err error, mayHaveApplied bool) FailureClass {
+	switch {
+	case err == nil, errors.Is(err, ErrDeclined):
+		return FailureTerminal
+	case resilience.IsUnavailable(err),
+		errors.Is(err, resilience.ErrDeadlineTooShort),
+		errors.Is(err, syscall.ECONNREFUSED):
+		return FailureRetryable
+	case mayHaveApplied:
+		return FailureUnknown
+	case resilience.IsRetryableError(err):
+		return FailureRetryable
+	}
+	return FailureTerminal
+}
+
+// RetryPolicy decides whether and when a failed payment is processed again.
+// Only retryable failures are retried; see ClassifyFailure. A negative
+// MaxAttempts disables retries.
+type RetryPolicy struct {
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	Multiplier     float64       `yaml:"multiplier"`
+}
+
+// DefaultRetryPolicy is used for tenants without their own policy
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     time.Hour,
+		Multiplier:     2,
+	}
+}
+
+// ShouldRetry reports whether a payment whose failure has class after
+// retryCount retries may be retried again
+func (p RetryPolicy) ShouldRetry(class FailureClass, retryCount int) bool {
+	return retryCount < p.MaxAttempts && class == FailureRetryable
+}
+
+// Backoff returns the delay before retry attempt n (starting at 1)
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+	return time.Duration(delay)
+}
+
+// merge returns p with every unset field taken from base
+func (p RetryPolicy) merge(base RetryPolicy) RetryPolicy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = base.MaxAttempts
+	}
+	if p.InitialBackoff == 0 {
+		p.InitialBackoff = base.InitialBackoff
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = base.MaxBackoff
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = base.Multiplier
+	}
+	return p
+}
+
+func (p RetryPolicy) validate() error {
+	if p.InitialBackoff <= 0 {
+		return fmt.Errorf("initial_backoff must be positive")
+	}
+	if p.Multiplier < 1 {
+		return fmt.Errorf("multiplier must be at least 1")
+	}
+	return nil
+}
+
+// RetryPolicies holds the default retry policy and per-tenant overrides:
+//
+//	default:
+//	  max_attempts: 3
+//	  initial_backoff: 1m
+//	tenants:
+//	  tenant-123:
+//	    max_attempts: 5
+//	    initial_backoff: 30s
+//
+// Fields a tenant leaves unset fall back to the default policy.
+type RetryPolicies struct {
+	Default RetryPolicy            `yaml:"default"`
+	Tenants map[string]RetryPolicy `yaml:"tenants"`
+}
+
+// NewRetryPolicies returns policies that apply DefaultRetryPolicy to every tenant
+func NewRetryPolicies() *RetryPolicies {
+	return &RetryPolicies{
+		Default: DefaultRetryPolicy(),
+		Tenants: make(map[string]RetryPolicy),
+	}
+}
+
+// For returns the effective retry policy of a tenant
+func (p *RetryPolicies) For(tenantID string) RetryPolicy {
+	if policy, ok := p.Tenants[tenantID]; ok {
+		return policy
+	}
+	return p.Default
+}
+
+// LoadRetryPolicies reads a retry policy file. A missing file yields the
+// default policy for every tenant.
+func LoadRetryPolicies(path string) (*RetryPolicies, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return NewRetryPolicies(), nil
+		}
+		return nil, fmt.Errorf("failed to read retry policies: %w", err)
+	}
+
+	var policies RetryPolicies
+	if err := yaml.Unmarshal(raw, &policies); err != nil {
+		return nil, fmt.Errorf("failed to parse retry policies: %w", err)
+	}
+
+	policies.Default = policies.Default.merge(DefaultRetryPolicy())
+	if err := policies.Default.validate(); err != nil {
+		return nil, fmt.Errorf("invalid default retry policy: %w", err)
+	}
+	if policies.Tenants == nil {
+		policies.Tenants = make(map[string]RetryPolicy)
+	}
+	for tenantID, policy := range policies.Tenants {
+		policy = policy.merge(policies.Default)
+		if err := policy.validate(); err != nil {
+			return nil, fmt.Errorf("invalid retry policy for tenant %s: %w", tenantID, err)
+		}
+		policies.Tenants[tenantID] = policy
+	}
+
+	return &policies, nil
+}
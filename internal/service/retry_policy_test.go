@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/yordanos-habtamu/b2b-payments/internal/resilience"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	timeout := fmt.Errorf("rail call failed: %w", context.DeadlineExceeded)
+
+	tests := []struct {
+		name           string
+		err            error
+		mayHaveApplied bool
+		want           FailureClass
+	}{
+		{"decline", Decline("insufficient funds"), false, FailureTerminal},
+		{"decline with side effects", Decline("account closed"), true, FailureTerminal},
+		{"limiter rejection", &resilience.RejectedError{Name: "rail", Reason: "queue full"}, true, FailureRetryable},
+		{"open breaker", resilience.ErrCircuitBreakerOpen, true, FailureRetryable},
+		{"deadline too short", fmt.Errorf("%w for rail call: 0s left", resilience.ErrDeadlineTooShort), true, FailureRetryable},
+		{"connection refused", fmt.Errorf("dial: %w", syscall.ECONNREFUSED), true, FailureRetryable},
+		{"timeout with side effects", timeout, true, FailureUnknown},
+		{"connection reset with side effects", fmt.Errorf("read: %w", syscall.ECONNRESET), true, FailureUnknown},
+		{"unrecognised error with side effects", errors.New("rail returned 500"), true, FailureUnknown},
+		{"timeout without side effects", timeout, false, FailureRetryable},
+		{"marked permanent", resilience.MarkPermanent(errors.New("bad request")), false, FailureTerminal},
+		{"unrecognised error", errors.New("invalid account number"), false, FailureTerminal},
+		// The reason text is never matched
+		{"message mentioning a timeout", errors.New("beneficiary timeout window closed"), false, FailureTerminal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyFailure(tt.err, tt.mayHaveApplied); got != tt.want {
+				t.Errorf("ClassifyFailure(%v, %v) = %s, want %s", tt.err, tt.mayHaveApplied, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	policy := DefaultRetryPolicy()
+	disabled := policy
+	disabled.MaxAttempts = -1
+
+	tests := []struct {
+		name       string
+		policy     RetryPolicy
+		class      FailureClass
+		retryCount int
+		want       bool
+	}{
+		{"retryable first failure", policy, FailureRetryable, 0, true},
+		{"retryable last attempt", policy, FailureRetryable, 2, true},
+		{"retryable attempts used up", policy, FailureRetryable, 3, false},
+		{"terminal", policy, FailureTerminal, 0, false},
+		{"unknown outcome", policy, FailureUnknown, 0, false},
+		{"unclassified", policy, "", 0, false},
+		{"retries disabled", disabled, FailureRetryable, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.ShouldRetry(tt.class, tt.retryCount); got != tt.want {
+				t.Errorf("ShouldRetry(%s, %d) = %v, want %v", tt.class, tt.retryCount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Minute, MaxBackoff: 10 * time.Minute, Multiplier: 2}
+	uncapped := policy
+	uncapped.MaxBackoff = 0
+
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{"attempt below one", policy, 0, time.Minute},
+		{"first attempt", policy, 1, time.Minute},
+		{"second attempt", policy, 2, 2 * time.Minute},
+		{"fourth attempt", policy, 4, 8 * time.Minute},
+		{"capped", policy, 5, 10 * time.Minute},
+		{"far past the cap", policy, 50, 10 * time.Minute},
+		{"no cap", uncapped, 5, 16 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Backoff(tt.attempt); got != tt.want {
+				t.Errorf("Backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRetryPolicies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "payment_retry.yaml")
+	config := `
+default:
+  max_attempts: 2
+tenants:
+  tenant-1:
+    initial_backoff: 30s
+  tenant-2:
+    max_attempts: -1
+`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	policies, err := LoadRetryPolicies(path)
+	if err != nil {
+		t.Fatalf("LoadRetryPolicies: %v", err)
+	}
+
+	if got := policies.For("other"); got.MaxAttempts != 2 || got.InitialBackoff != time.Minute {
+		t.Errorf("default policy = %+v, want 2 attempts from 1m", got)
+	}
+	if got := policies.For("tenant-1"); got.MaxAttempts != 2 || got.InitialBackoff != 30*time.Second || got.MaxBackoff != time.Hour {
+		t.Errorf("tenant-1 policy = %+v, want 2 attempts from 30s capped at 1h", got)
+	}
+	if policies.For("tenant-2").ShouldRetry(FailureRetryable, 0) {
+		t.Error("tenant-2 retries a failure with retries disabled")
+	}
+
+	missing, err := LoadRetryPolicies(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil {
+		t.Fatalf("LoadRetryPolicies(missing): %v", err)
+	}
+	if missing.For("tenant-1") != DefaultRetryPolicy() {
+		t.Errorf("missing file policy = %+v, want the default", missing.For("tenant-1"))
+	}
+}
@@ -0,0 +1,274 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+)
+
+// DeadLetter is a job that exhausted its retries, kept with its full error history
+type DeadLetter struct {
+	Job    *PaymentJob `json:"job"`
+	Reason string      `json:"reason"`
+	DeadAt time.Time   `json:"dead_at"`
+}
+
+// DeadLetterFilter selects dead letters for listing, bulk requeue and purge.
+// Zero-valued fields match everything.
+type DeadLetterFilter struct {
+	TenantID string
+	JobType  string
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+}
+
+// deadLetterScript acknowledges a leased job and stores it as a dead letter
+var deadLetterScript = redis.NewScript(ownsLeaseLua + `
+if not owns_lease(3, ARGV[2], KEYS[1], ARGV[5]) then
+	return 0
+end
+redis.call('LREM', KEYS[1], 1, ARGV[1])
+redis.call('ZREM', KEYS[2], ARGV[2])
+redis.call('HDEL', KEYS[3], ARGV[2])
+redis.call('HSET', KEYS[4], ARGV[2], ARGV[3])
+redis.call('ZADD', KEYS[5], ARGV[4], ARGV[2])
+return 1
+`)
+
+// requeueDeadLetterScript moves a dead letter back onto its tenant's sub-queue if it still exists
+var requeueDeadLetterScript = redis.NewScript(pushPendingLua + `
+if redis.call('HDEL', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('ZREM', KEYS[2], ARGV[1])
+push_pending(3, ARGV[3], ARGV[2], false)
+return 1
+`)
+
+func (q *JobQueue) deadLetterKey() string {
+	return fmt.Sprintf("%s:dead", q.name)
+}
+
+func (q *JobQueue) deadLetterIndexKey() string {
+	return fmt.Sprintf("%s:dead:index", q.name)
+}
+
+// DeadLetter acknowledges the leased job and moves job (carrying its error
+// history) to the dead-letter store. It returns ErrLeaseLost, storing
+// nothing, if the lease was already reaped.
+func (q *JobQueue) DeadLetter(ctx context.Context, lease *Lease, job *PaymentJob, reason string) error {
+	deadLetter := &DeadLetter{
+		Job:    job,
+		Reason: reason,
+		DeadAt: time.Now().UTC(),
+	}
+
+	deadLetterJSON, err := json.Marshal(deadLetter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+
+	keys := []string{lease.processingKey, q.leasesKey(), q.inflightKey(), q.deadLetterKey(), q.deadLetterIndexKey()}
+	owned, err := deadLetterScript.Run(ctx, q.redisClient, keys,
+		lease.payload, job.ID, deadLetterJSON, deadLetter.DeadAt.UnixMilli(), lease.token).Int()
+	if err != nil {
+		return fmt.Errorf("failed to dead-letter job %s: %w", job.ID, err)
+	}
+	if owned == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// GetDeadLetter returns a single dead letter by job ID
+func (q *JobQueue) GetDeadLetter(ctx context.Context, jobID string) (*DeadLetter, error) {
+	raw, err := q.redisClient.HGet(ctx, q.deadLetterKey(), jobID).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrDeadLetterNotFound
+		}
+		return nil, fmt.Errorf("failed to get dead letter %s: %w", jobID, err)
+	}
+
+	var deadLetter DeadLetter
+	if err := json.Unmarshal([]byte(raw), &deadLetter); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dead letter %s: %w", jobID, err)
+	}
+	return &deadLetter, nil
+}
+
+// ListDeadLetters returns dead letters matching filter, most recent first
+func (q *JobQueue) ListDeadLetters(ctx context.Context, filter *DeadLetterFilter) ([]*DeadLetter, error) {
+	if filter == nil {
+		filter = &DeadLetterFilter{}
+	}
+
+	rangeBy := &redis.ZRangeBy{Min: "-inf", Max: "+inf"}
+	if filter.From != nil {
+		rangeBy.Min = strconv.FormatInt(filter.From.UnixMilli(), 10)
+	}
+	if filter.To != nil {
+		rangeBy.Max = strconv.FormatInt(filter.To.UnixMilli(), 10)
+	}
+
+	ids, err := q.redisClient.ZRevRangeByScore(ctx, q.deadLetterIndexKey(), rangeBy).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+
+	var deadLetters []*DeadLetter
+	const chunkSize = 100
+	for start := 0; start < len(ids); start += chunkSize {
+		end := start + chunkSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		values, err := q.redisClient.HMGet(ctx, q.deadLetterKey(), ids[start:end]...).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load dead letters: %w", err)
+		}
+
+		for _, value := range values {
+			raw, ok := value.(string)
+			if !ok {
+				continue
+			}
+
+			var deadLetter DeadLetter
+			if err := json.Unmarshal([]byte(raw), &deadLetter); err != nil {
+				continue
+			}
+			if !filter.matches(&deadLetter) {
+				continue
+			}
+
+			deadLetters = append(deadLetters, &deadLetter)
+			if filter.Limit > 0 && len(deadLetters) >= filter.Limit {
+				return deadLetters, nil
+			}
+		}
+	}
+
+	return deadLetters, nil
+}
+
+// RequeueDeadLetter puts a dead letter back on the pending list with its retry count reset
+func (q *JobQueue) RequeueDeadLetter(ctx context.Context, jobID string) error {
+	deadLetter, err := q.GetDeadLetter(ctx, jobID)
+	if err != nil {
+		return err
+	}
+
+	job := deadLetter.Job
+	job.Retries = 0
+	job.CreatedAt = time.Now()
+
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+
+	pendingKeys, tenant := q.pendingKeys(job)
+	keys := append([]string{q.deadLetterKey(), q.deadLetterIndexKey()}, pendingKeys...)
+	requeued, err := requeueDeadLetterScript.Run(ctx, q.redisClient, keys, jobID, jobJSON, tenant).Int()
+	if err != nil {
+		return fmt.Errorf("failed to requeue dead letter %s: %w", jobID, err)
+	}
+	if requeued == 0 {
+		return ErrDeadLetterNotFound
+	}
+
+	if _, err := q.UpdateJobStatus(ctx, job, func(status *JobStatus) {
+		status.State = JobStateQueued
+		status.NextRunAt = nil
+		status.CompletedAt = nil
+	}); err != nil {
+		log.Printf("Failed to update status of requeued job %s: %v", jobID, err)
+	}
+	return nil
+}
+
+// RequeueDeadLetters requeues every dead letter matching filter and returns how many were moved
+func (q *JobQueue) RequeueDeadLetters(ctx context.Context, filter *DeadLetterFilter) (int, error) {
+	deadLetters, err := q.ListDeadLetters(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	requeued := 0
+	for _, deadLetter := range deadLetters {
+		if err := q.RequeueDeadLetter(ctx, deadLetter.Job.ID); err != nil {
+			if errors.Is(err, ErrDeadLetterNotFound) {
+				continue
+			}
+			return requeued, err
+		}
+		requeued++
+	}
+	return requeued, nil
+}
+
+// PurgeDeadLetter permanently deletes a dead letter
+func (q *JobQueue) PurgeDeadLetter(ctx context.Context, jobID string) error {
+	pipe := q.redisClient.TxPipeline()
+	deleted := pipe.HDel(ctx, q.deadLetterKey(), jobID)
+	pipe.ZRem(ctx, q.deadLetterIndexKey(), jobID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to purge dead letter %s: %w", jobID, err)
+	}
+	if deleted.Val() == 0 {
+		return ErrDeadLetterNotFound
+	}
+	return nil
+}
+
+// PurgeDeadLetters deletes every dead letter matching filter and returns how many were removed
+func (q *JobQueue) PurgeDeadLetters(ctx context.Context, filter *DeadLetterFilter) (int, error) {
+	deadLetters, err := q.ListDeadLetters(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, deadLetter := range deadLetters {
+		if err := q.PurgeDeadLetter(ctx, deadLetter.Job.ID); err != nil {
+			if errors.Is(err, ErrDeadLetterNotFound) {
+				continue
+			}
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// DeadLetterDepth returns the number of dead letters currently stored
+func (q *JobQueue) DeadLetterDepth(ctx context.Context) (int64, error) {
+	depth, err := q.redisClient.ZCard(ctx, q.deadLetterIndexKey()).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get dead letter depth: %w", err)
+	}
+	return depth, nil
+}
+
+func (f *DeadLetterFilter) matches(deadLetter *DeadLetter) bool {
+	if f.TenantID != "" && deadLetter.Job.TenantID != f.TenantID {
+		return false
+	}
+	if f.JobType != "" && deadLetter.Job.Type != f.JobType {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrDuplicateJob = errors.New("duplicate job")
+)
+
+// DefaultDedupWindow is how long a dedup key blocks duplicates when the job
+// type does not configure its own window
+const DefaultDedupWindow = time.Hour
+
+// releaseDedupScript deletes a dedup key only if it is still held by the given job
+var releaseDedupScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func (q *JobQueue) dedupKey(job *PaymentJob) string {
+	return fmt.Sprintf("%s:dedup:%s:%s:%s", q.name, job.TenantID, job.Type, job.DedupKey)
+}
+
+// ClaimDedupKey reserves the job's dedup key for window. If another job already
+// holds it, ErrDuplicateJob is returned together with that job's ID.
+func (q *JobQueue) ClaimDedupKey(ctx context.Context, job *PaymentJob, window time.Duration) (string, error) {
+	key := q.dedupKey(job)
+
+	claimed, err := q.redisClient.SetNX(ctx, key, job.ID, window).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to claim dedup key: %w", err)
+	}
+	if claimed {
+		return job.ID, nil
+	}
+
+	existingID, err := q.redisClient.Get(ctx, key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			// Expired between SETNX and GET; try once more
+			return q.ClaimDedupKey(ctx, job, window)
+		}
+		return "", fmt.Errorf("failed to read dedup key: %w", err)
+	}
+	return existingID, ErrDuplicateJob
+}
+
+// ReleaseDedupKey frees the job's dedup key so the same work can be enqueued
+// again before the window ends. Keys held by other jobs are left untouched.
+func (q *JobQueue) ReleaseDedupKey(ctx context.Context, job *PaymentJob) error {
+	if job.DedupKey == "" {
+		return nil
+	}
+
+	if err := releaseDedupScript.Run(ctx, q.redisClient, []string{q.dedupKey(job)}, job.ID).Err(); err != nil {
+		return fmt.Errorf("failed to release dedup key: %w", err)
+	}
+	return nil
+}
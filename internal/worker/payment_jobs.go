@@ -0,0 +1,92 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/yordanos-habtamu/b2b-payments/internal/service"
+)
+
+// Built-in payment job types
+const (
+	JobTypeProcessPayment      = "process_payment"
+	JobTypeCancelPayment       = "cancel_payment"
+	JobTypeRetryFailedPayment  = "retry_failed_payment"
+	JobTypePaymentNotification = "payment_notification"
+)
+
+// PaymentJobPayload is the payload of jobs acting on a single payment
+type PaymentJobPayload struct {
+	PaymentID string `json:"payment_id"`
+}
+
+func (p *PaymentJobPayload) Validate() error {
+	if p.PaymentID == "" {
+		return errors.New("payment_id is required")
+	}
+	return nil
+}
+
+// NotificationJobPayload is the payload of payment_notification jobs
+type NotificationJobPayload struct {
+	PaymentID        string `json:"payment_id"`
+	NotificationType string `json:"notification_type"`
+}
+
+func (p *NotificationJobPayload) Validate() error {
+	if p.PaymentID == "" {
+		return errors.New("payment_id is required")
+	}
+	if p.NotificationType == "" {
+		return errors.New("notification_type is required")
+	}
+	return nil
+}
+
+// dedupByPaymentID allows one job of a type per payment within the dedup window
+func dedupByPaymentID(job *PaymentJob) string {
+	paymentID, _ := job.Data["payment_id"].(string)
+	return paymentID
+}
+
+// RegisterPaymentHandlers registers the built-in payment job handlers.
+// Payment processing runs ahead of notifications and goes through the payment
+// saga, so a retried or resumed job continues where the last attempt stopped.
+// Failed payments are retried as the tenant's retry policy allows.
+func RegisterPaymentHandlers(r *Registry, paymentService service.PaymentService, retrier *PaymentRetrier) error {
+	if err := Register(r, JobTypeProcessPayment, func(ctx context.Context, job *PaymentJob, payload PaymentJobPayload) error {
+		return retrier.RunSaga(ctx, job.TenantID, payload.PaymentID)
+	}, HandlerOptions{Priority: PriorityHigh, Timeout: 2 * time.Minute, DedupKey: dedupByPaymentID}); err != nil {
+		return err
+	}
+
+	if err := Register(r, JobTypeCancelPayment, func(ctx context.Context, job *PaymentJob, payload PaymentJobPayload) error {
+		return paymentService.CancelPayment(ctx, job.TenantID, payload.PaymentID)
+	}, HandlerOptions{Priority: PriorityHigh, Timeout: time.Minute, DedupKey: dedupByPaymentID}); err != nil {
+		return err
+	}
+
+	if err := Register(r, JobTypeRetryFailedPayment, func(ctx context.Context, job *PaymentJob, payload RetryJobPayload) error {
+		return retrier.Retry(ctx, job.TenantID, payload)
+	}, HandlerOptions{Priority: PriorityNormal, Timeout: 2 * time.Minute, DedupKey: dedupByRetryAttempt}); err != nil {
+		return err
+	}
+
+	return Register(r, JobTypePaymentNotification, func(ctx context.Context, job *PaymentJob, payload NotificationJobPayload) error {
+		// Get payment details
+		if _, err := paymentService.GetPayment(ctx, job.TenantID, payload.PaymentID); err != nil {
+			return fmt.Errorf("failed to get payment for notification: %w", err)
+		}
+
+		// Send notification (placeholder implementation)
+		log.Printf("Sending %s notification for payment %s to tenant %s",
+			payload.NotificationType, payload.PaymentID, job.TenantID)
+
+		// In a real implementation, this would integrate with email/SMS services
+		// webhook calls, or other notification systems
+		return nil
+	}, HandlerOptions{Priority: PriorityLow, Timeout: 30 * time.Second, MaxRetries: 5, RetryDelay: 10 * time.Second})
+}
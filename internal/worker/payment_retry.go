@@ -0,0 +1,214 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/yordanos-habtamu/b2b-payments/internal/service"
+)
+
+var (
+	ErrPaymentNotRetryable = errors.New("payment not retryable")
+)
+
+// RetryJobPayload is the payload of retry_failed_payment jobs. Attempt is the
+// retry number, starting at 1.
+type RetryJobPayload struct {
+	PaymentID string `json:"payment_id"`
+	Attempt   int    `json:"attempt"`
+}
+
+func (p *RetryJobPayload) Validate() error {
+	if p.PaymentID == "" {
+		return errors.New("payment_id is required")
+	}
+	if p.Attempt < 1 {
+		return errors.New("attempt must be at least 1")
+	}
+	return nil
+}
+
+// dedupByRetryAttempt allows one retry job per payment and attempt, so the
+// retry scheduled by a running retry job is not mistaken for a duplicate
+func dedupByRetryAttempt(job *PaymentJob) string {
+	var payload RetryJobPayload
+	if err := DecodeJobData(job, &payload); err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", payload.PaymentID, payload.Attempt)
+}
+
+// ScheduledRetry describes a retry of a failed payment that has been scheduled
+type ScheduledRetry struct {
+	PaymentID string    `json:"payment_id"`
+	Attempt   int       `json:"attempt"`
+	RunAt     time.Time `json:"run_at"`
+	Reason    string    `json:"reason"`
+}
+
+// JobScheduler enqueues a job that should not run before runAt
+type JobScheduler interface {
+	EnqueueJobAt(ctx context.Context, job *PaymentJob, runAt time.Time) error
+}
+
+// PaymentRetrier runs the payment saga and retries payments that fail with a
+// retryable reason, following the tenant's retry policy
+type PaymentRetrier struct {
+	paymentService service.PaymentService
+	saga           *Saga
+	policies       *service.RetryPolicies
+	scheduler      JobScheduler
+}
+
+// NewPaymentRetrier creates a retrier that schedules retries through scheduler
+func NewPaymentRetrier(paymentService service.PaymentService, saga *Saga, policies *service.RetryPolicies, scheduler JobScheduler) *PaymentRetrier {
+	return &PaymentRetrier{
+		paymentService: paymentService,
+		saga:           saga,
+		policies:       policies,
+		scheduler:      scheduler,
+	}
+}
+
+// PaymentFailure is the result of a payment job whose payment failed and
+// will not be retried automatically. Failures of class unknown may have
+// moved money and need reconciling by hand.
+type PaymentFailure struct {
+	PaymentID    string               `json:"payment_id"`
+	FailureClass service.FailureClass `json:"failure_class"`
+	Error        string               `json:"error"`
+}
+
+// RunSaga runs the payment saga. When the saga fails and is compensated, a
+// retry is scheduled if the policy allows one. Either way the job succeeds,
+// with the scheduled retry or a PaymentFailure as its result: a declined
+// payment is an outcome of the job, not a failure of it. Errors that leave
+// the saga unfinished are returned so the job itself is retried.
+func (r *PaymentRetrier) RunSaga(ctx context.Context, tenantID, paymentID string) error {
+	runErr := r.saga.Run(ctx, tenantID, paymentID)
+	if !errors.Is(runErr, ErrSagaCompensated) {
+		return runErr
+	}
+
+	retry, err := r.ScheduleRetry(ctx, tenantID, paymentID)
+	if err != nil {
+		return err
+	}
+	if retry != nil {
+		SetJobResult(ctx, retry)
+		return nil
+	}
+
+	class, err := r.failureClass(ctx, tenantID, paymentID)
+	if err != nil {
+		return err
+	}
+	SetJobResult(ctx, &PaymentFailure{PaymentID: paymentID, FailureClass: class, Error: runErr.Error()})
+	return nil
+}
+
+// ScheduleRetry schedules the next retry of a failed payment. It returns nil
+// without error when the payment is not failed, its failure is not retryable
+// or its retries are used up.
+func (r *PaymentRetrier) ScheduleRetry(ctx context.Context, tenantID, paymentID string) (*ScheduledRetry, error) {
+	payment, err := r.paymentService.GetPayment(ctx, tenantID, paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+	if payment.Status != service.PaymentStatusFailed {
+		return nil, nil
+	}
+
+	class, err := r.failureClass(ctx, tenantID, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	policy := r.policies.For(tenantID)
+	if !policy.ShouldRetry(class, payment.RetryCount) {
+		log.Printf("Not retrying payment %s (tenant: %s, retries: %d, failure: %s): %s",
+			paymentID, tenantID, payment.RetryCount, class, payment.FailureReason)
+		return nil, nil
+	}
+
+	attempt := payment.RetryCount + 1
+	retry := &ScheduledRetry{
+		PaymentID: paymentID,
+		Attempt:   attempt,
+		RunAt:     time.Now().UTC().Add(policy.Backoff(attempt)),
+		Reason:    payment.FailureReason,
+	}
+
+	job, err := NewJob(JobTypeRetryFailedPayment, tenantID, RetryJobPayload{PaymentID: paymentID, Attempt: attempt})
+	if err != nil {
+		return nil, err
+	}
+	if err := r.scheduler.EnqueueJobAt(ctx, job, retry.RunAt); err != nil && !errors.Is(err, ErrDuplicateJob) {
+		return nil, fmt.Errorf("failed to schedule payment retry: %w", err)
+	}
+
+	log.Printf("Scheduled retry %d of payment %s for %s", attempt, paymentID, retry.RunAt.Format(time.RFC3339))
+	return retry, nil
+}
+
+// Retry runs one scheduled retry: it moves the failed payment back to
+// pending, restarts the saga (recording a retried event) and runs it again.
+// A retry interrupted after the payment was moved back resumes the same attempt.
+func (r *PaymentRetrier) Retry(ctx context.Context, tenantID string, payload RetryJobPayload) error {
+	payment, err := r.paymentService.GetPayment(ctx, tenantID, payload.PaymentID)
+	if err != nil {
+		return fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	switch payment.Status {
+	case service.PaymentStatusFailed:
+		if payment.RetryCount >= payload.Attempt {
+			log.Printf("Retry %d of payment %s already ran, skipping", payload.Attempt, payload.PaymentID)
+			return nil
+		}
+
+		// Re-check in case the tenant's policy changed since the retry was scheduled
+		class, err := r.failureClass(ctx, tenantID, payload.PaymentID)
+		if err != nil {
+			return err
+		}
+		if !r.policies.For(tenantID).ShouldRetry(class, payment.RetryCount) {
+			log.Printf("Retry %d of payment %s no longer allowed (%s failure after %d retries), leaving it failed",
+				payload.Attempt, payload.PaymentID, class, payment.RetryCount)
+			return nil
+		}
+
+		if err := r.paymentService.RetryPayment(ctx, tenantID, payload.PaymentID); err != nil {
+			return err
+		}
+	case service.PaymentStatusPending, service.PaymentStatusProcessing:
+		if payment.RetryCount != payload.Attempt {
+			return fmt.Errorf("%w: payment is %s", ErrPaymentNotRetryable, payment.Status)
+		}
+	default:
+		log.Printf("Payment %s is %s, retry %d not needed", payload.PaymentID, payment.Status, payload.Attempt)
+		return nil
+	}
+
+	if err := r.saga.Restart(ctx, tenantID, payload.PaymentID, payload.Attempt); err != nil {
+		return err
+	}
+	return r.RunSaga(ctx, tenantID, payload.PaymentID)
+}
+
+// failureClass returns how the payment saga classified the failure of a
+// compensated payment. Payments that did not fail in the saga are terminal.
+func (r *PaymentRetrier) failureClass(ctx context.Context, tenantID, paymentID string) (service.FailureClass, error) {
+	state, err := r.saga.State(ctx, tenantID, paymentID)
+	if errors.Is(err, ErrSagaNotFound) {
+		return service.FailureTerminal, nil
+	} else if err != nil {
+		return "", err
+	}
+	if state.Status != SagaStatusCompensated || state.FailureClass == "" {
+		return service.FailureTerminal, nil
+	}
+	return service.FailureClass(state.FailureClass), nil
+}
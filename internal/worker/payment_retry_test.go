@@ -0,0 +1,118 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yordanos-habtamu/b2b-payments/internal/resilience"
+	"github.com/yordanos-habtamu/b2b-payments/internal/service"
+)
+
+// recordingScheduler records the jobs it is asked to schedule
+type recordingScheduler struct {
+	mu   sync.Mutex
+	jobs []*PaymentJob
+}
+
+func (s *recordingScheduler) EnqueueJobAt(ctx context.Context, job *PaymentJob, runAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, job)
+	return nil
+}
+
+func TestPaymentRetrierRunSaga(t *testing.T) {
+	tests := []struct {
+		name      string
+		submitErr error
+		wantClass service.FailureClass
+		wantRetry bool
+	}{
+		{"declined", service.Decline("insufficient funds"), service.FailureTerminal, false},
+		{"timed out", fmt.Errorf("rail call: %w", context.DeadlineExceeded), service.FailureUnknown, false},
+		{"rejected by limiter", &resilience.RejectedError{Name: "rail", Reason: "queue full"}, service.FailureRetryable, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paymentService := service.NewPaymentService(service.NewMemoryPaymentStore(), nil)
+			payment := newTestPayment(t, paymentService)
+
+			railReference := stepReference("rail", &SagaState{PaymentID: payment.ID})
+			processor := &fakeProcessor{errs: map[string]error{"submit " + railReference: tt.submitErr}}
+			saga, err := NewPaymentSaga(paymentService, processor, newMemorySagaStore())
+			if err != nil {
+				t.Fatalf("NewPaymentSaga: %v", err)
+			}
+			scheduler := &recordingScheduler{}
+			retrier := NewPaymentRetrier(paymentService, saga, service.NewRetryPolicies(), scheduler)
+
+			// The job succeeds whatever became of the payment
+			ctx, result := withJobResult(context.Background())
+			if err := retrier.RunSaga(ctx, "tenant-1", payment.ID); err != nil {
+				t.Fatalf("RunSaga = %v, want nil", err)
+			}
+
+			state, err := saga.State(context.Background(), "tenant-1", payment.ID)
+			if err != nil {
+				t.Fatalf("State: %v", err)
+			}
+			if service.FailureClass(state.FailureClass) != tt.wantClass {
+				t.Errorf("failure class = %s, want %s", state.FailureClass, tt.wantClass)
+			}
+
+			if !tt.wantRetry {
+				if len(scheduler.jobs) != 0 {
+					t.Errorf("scheduled %d retries, want none", len(scheduler.jobs))
+				}
+				failure, ok := result.get().(*PaymentFailure)
+				if !ok || failure.FailureClass != tt.wantClass {
+					t.Errorf("result = %#v, want a %s PaymentFailure", result.get(), tt.wantClass)
+				}
+				return
+			}
+
+			if len(scheduler.jobs) != 1 || scheduler.jobs[0].Type != JobTypeRetryFailedPayment {
+				t.Fatalf("scheduled jobs = %v, want one retry", scheduler.jobs)
+			}
+			if retry, ok := result.get().(*ScheduledRetry); !ok || retry.Attempt != 1 {
+				t.Errorf("result = %#v, want retry attempt 1", result.get())
+			}
+
+			// The retry runs under a new attempt and its references, and succeeds
+			if err := retrier.Retry(context.Background(), "tenant-1", RetryJobPayload{PaymentID: payment.ID, Attempt: 1}); err != nil {
+				t.Fatalf("Retry: %v", err)
+			}
+			retried, err := paymentService.GetPayment(context.Background(), "tenant-1", payment.ID)
+			if err != nil {
+				t.Fatalf("GetPayment: %v", err)
+			}
+			if retried.Status != service.PaymentStatusCompleted || retried.RetryCount != 1 {
+				t.Errorf("payment = %s after %d retries, want completed after 1", retried.Status, retried.RetryCount)
+			}
+		})
+	}
+}
+
+func TestIsPermanentJobError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("decode: %w", ErrInvalidJobPayload), true},
+		{ErrUnknownJobType, true},
+		{fmt.Errorf("%w: payment is processing", ErrPaymentNotRetryable), true},
+		{errors.New("failed to save saga state: connection reset"), false},
+		{fmt.Errorf("failed to compensate step submit_to_rail: %w", context.DeadlineExceeded), false},
+	}
+
+	for _, tt := range tests {
+		if got := isPermanentJobError(tt.err); got != tt.want {
+			t.Errorf("isPermanentJobError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
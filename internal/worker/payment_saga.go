@@ -0,0 +1,153 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yordanos-habtamu/b2b-payments/internal/service"
+)
+
+// PaymentSagaName identifies the payment processing saga in persisted state
+const PaymentSagaName = "process_payment"
+
+// Keys of values the payment saga keeps in SagaState.Data
+const (
+	sagaDataReservationID = "reservation_id"
+	sagaDataRailReference = "rail_reference"
+	sagaDataLedgerEntryID = "ledger_entry_id"
+)
+
+// stepReference is the reference a step's side effect is made under. It is
+// derived from the payment and attempt rather than stored up front: a step
+// re-run after a crash makes the call under the same reference, so the
+// processor deduplicates it. The step records it in the saga data, which is
+// saved with the step's outcome, so a failed call whose outcome is unknown
+// can still be undone.
+func stepReference(prefix string, state *SagaState) string {
+	return fmt.Sprintf("%s_%s_%d", prefix, state.PaymentID, state.Attempt)
+}
+
+// NewPaymentSaga builds the payment processing saga:
+//
+//	start_processing  pending -> processing      compensated by failing the payment
+//	reserve_funds     hold the amount            compensated by releasing the hold
+//	screen            sanctions/fraud screening
+//	submit_to_rail    send to the payment rail   compensated by recalling it
+//	post_ledger       book the transfer          compensated by a reversing entry
+//	notify            tell the tenant
+//	complete_payment  processing -> completed
+//
+// A step with a side effect that fails without a definite answer (a timeout,
+// say) is compensated too, in case the side effect happened. Failures are
+// classified with service.ClassifyFailure.
+func NewPaymentSaga(paymentService service.PaymentService, processor service.PaymentProcessor, store SagaStore) (*Saga, error) {
+	getPayment := func(ctx context.Context, state *SagaState) (*service.Payment, error) {
+		return paymentService.GetPayment(ctx, state.TenantID, state.PaymentID)
+	}
+
+	saga, err := NewSaga(PaymentSagaName, store,
+		SagaStep{
+			Name: "start_processing",
+			Action: func(ctx context.Context, state *SagaState) error {
+				return paymentService.StartProcessing(ctx, state.TenantID, state.PaymentID)
+			},
+			Compensate: func(ctx context.Context, state *SagaState) error {
+				return paymentService.FailPayment(ctx, state.TenantID, state.PaymentID, state.LastError)
+			},
+		},
+		SagaStep{
+			Name: "reserve_funds",
+			Action: func(ctx context.Context, state *SagaState) error {
+				payment, err := getPayment(ctx, state)
+				if err != nil {
+					return err
+				}
+				reservationID := stepReference("res", state)
+				state.Data[sagaDataReservationID] = reservationID
+				return processor.ReserveFunds(ctx, payment, reservationID)
+			},
+			Compensate: func(ctx context.Context, state *SagaState) error {
+				payment, err := getPayment(ctx, state)
+				if err != nil {
+					return err
+				}
+				return processor.ReleaseFunds(ctx, payment, state.Data[sagaDataReservationID])
+			},
+			MayHaveApplied: service.OutcomeUnknown,
+		},
+		SagaStep{
+			Name: "screen",
+			Action: func(ctx context.Context, state *SagaState) error {
+				payment, err := getPayment(ctx, state)
+				if err != nil {
+					return err
+				}
+				return processor.Screen(ctx, payment)
+			},
+		},
+		SagaStep{
+			Name: "submit_to_rail",
+			Action: func(ctx context.Context, state *SagaState) error {
+				payment, err := getPayment(ctx, state)
+				if err != nil {
+					return err
+				}
+				railReference := stepReference("rail", state)
+				state.Data[sagaDataRailReference] = railReference
+				return processor.SubmitToRail(ctx, payment, railReference)
+			},
+			Compensate: func(ctx context.Context, state *SagaState) error {
+				payment, err := getPayment(ctx, state)
+				if err != nil {
+					return err
+				}
+				return processor.RecallFromRail(ctx, payment, state.Data[sagaDataRailReference])
+			},
+			MayHaveApplied: service.OutcomeUnknown,
+		},
+		SagaStep{
+			Name: "post_ledger",
+			Action: func(ctx context.Context, state *SagaState) error {
+				payment, err := getPayment(ctx, state)
+				if err != nil {
+					return err
+				}
+				entryID := stepReference("led", state)
+				state.Data[sagaDataLedgerEntryID] = entryID
+				return processor.PostLedger(ctx, payment, entryID)
+			},
+			Compensate: func(ctx context.Context, state *SagaState) error {
+				payment, err := getPayment(ctx, state)
+				if err != nil {
+					return err
+				}
+				return processor.ReverseLedger(ctx, payment, state.Data[sagaDataLedgerEntryID])
+			},
+			MayHaveApplied: service.OutcomeUnknown,
+		},
+		SagaStep{
+			Name: "notify",
+			Action: func(ctx context.Context, state *SagaState) error {
+				payment, err := getPayment(ctx, state)
+				if err != nil {
+					return err
+				}
+				return processor.Notify(ctx, payment)
+			},
+		},
+		SagaStep{
+			Name: "complete_payment",
+			Action: func(ctx context.Context, state *SagaState) error {
+				return paymentService.CompletePayment(ctx, state.TenantID, state.PaymentID)
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	saga.classify = func(err error, mayHaveApplied bool) string {
+		return string(service.ClassifyFailure(err, mayHaveApplied))
+	}
+	return saga, nil
+}
@@ -0,0 +1,127 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/yordanos-habtamu/b2b-payments/internal/service"
+)
+
+// fakeProcessor records processor calls and fails the ones listed in errs
+type fakeProcessor struct {
+	mu    sync.Mutex
+	calls []string
+	errs  map[string]error
+}
+
+func (p *fakeProcessor) call(name, reference string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if reference != "" {
+		name += " " + reference
+	}
+	p.calls = append(p.calls, name)
+	return p.errs[name]
+}
+
+func (p *fakeProcessor) ReserveFunds(ctx context.Context, payment *service.Payment, reservationID string) error {
+	return p.call("reserve", reservationID)
+}
+
+func (p *fakeProcessor) ReleaseFunds(ctx context.Context, payment *service.Payment, reservationID string) error {
+	return p.call("release", reservationID)
+}
+
+func (p *fakeProcessor) Screen(ctx context.Context, payment *service.Payment) error {
+	return p.call("screen", "")
+}
+
+func (p *fakeProcessor) SubmitToRail(ctx context.Context, payment *service.Payment, railReference string) error {
+	return p.call("submit", railReference)
+}
+
+func (p *fakeProcessor) RecallFromRail(ctx context.Context, payment *service.Payment, railReference string) error {
+	return p.call("recall", railReference)
+}
+
+func (p *fakeProcessor) PostLedger(ctx context.Context, payment *service.Payment, entryID string) error {
+	return p.call("post", entryID)
+}
+
+func (p *fakeProcessor) ReverseLedger(ctx context.Context, payment *service.Payment, entryID string) error {
+	return p.call("reverse", entryID)
+}
+
+func (p *fakeProcessor) Notify(ctx context.Context, payment *service.Payment) error {
+	return p.call("notify", "")
+}
+
+func newTestPayment(t *testing.T, paymentService service.PaymentService) *service.Payment {
+	t.Helper()
+	payment, err := paymentService.CreatePayment(context.Background(), "tenant-1", &service.CreatePaymentRequest{
+		Amount:             100,
+		Currency:           service.CurrencyUSD,
+		Type:               service.PaymentTypeDebit,
+		Description:        "invoice 42",
+		SourceAccount:      "acct-src",
+		DestinationAccount: "acct-dst",
+	})
+	if err != nil {
+		t.Fatalf("CreatePayment: %v", err)
+	}
+	return payment
+}
+
+func TestPaymentSagaRecallsSubmissionWithUnknownOutcome(t *testing.T) {
+	tests := []struct {
+		name      string
+		submitErr error
+		recalled  bool
+	}{
+		{"timed out", fmt.Errorf("payment_processor call timed out: %w", context.DeadlineExceeded), true},
+		{"connection reset", errors.New("read: connection reset by peer"), true},
+		{"declined", service.Decline("insufficient funds"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paymentService := service.NewPaymentService(service.NewMemoryPaymentStore(), nil)
+			payment := newTestPayment(t, paymentService)
+
+			railReference := stepReference("rail", &SagaState{PaymentID: payment.ID})
+			reservationID := stepReference("res", &SagaState{PaymentID: payment.ID})
+			processor := &fakeProcessor{errs: map[string]error{"submit " + railReference: tt.submitErr}}
+
+			saga, err := NewPaymentSaga(paymentService, processor, newMemorySagaStore())
+			if err != nil {
+				t.Fatalf("NewPaymentSaga: %v", err)
+			}
+
+			if err := saga.Run(context.Background(), "tenant-1", payment.ID); !errors.Is(err, ErrSagaCompensated) {
+				t.Fatalf("Run = %v, want ErrSagaCompensated", err)
+			}
+
+			want := []string{"reserve " + reservationID, "screen", "submit " + railReference}
+			if tt.recalled {
+				want = append(want, "recall "+railReference)
+			}
+			want = append(want, "release "+reservationID)
+			if !reflect.DeepEqual(processor.calls, want) {
+				t.Errorf("calls = %v, want %v", processor.calls, want)
+			}
+
+			failed, err := paymentService.GetPayment(context.Background(), "tenant-1", payment.ID)
+			if err != nil {
+				t.Fatalf("GetPayment: %v", err)
+			}
+			if failed.Status != service.PaymentStatusFailed {
+				t.Errorf("status = %s, want failed", failed.Status)
+			}
+		})
+	}
+}
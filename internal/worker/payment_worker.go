@@ -3,31 +3,84 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	"github.com/yordanos-habtamu/b2b-payments/internal/config"
+	"github.com/yordanos-habtamu/b2b-payments/internal/metrics"
 	"github.com/yordanos-habtamu/b2b-payments/internal/service"
 )
 
+// DefaultQueueName is the Redis queue payment jobs are enqueued on
+const DefaultQueueName = "payment_jobs"
+
+// minHeartbeatInterval keeps very short leases from turning the heartbeat into a busy loop
+const minHeartbeatInterval = 10 * time.Millisecond
+
+// abortGrace bounds how long Shutdown waits for cancelled jobs to hand their
+// leases back once the drain deadline has passed
+const abortGrace = 5 * time.Second
+
+var (
+	ErrDrainTimeout = errors.New("worker drain timed out")
+)
+
+// JobEventPublisher publishes job lifecycle events (job.succeeded,
+// job.retrying, job.dead_lettered) so callers can be notified when async work finishes
+type JobEventPublisher interface {
+	Publish(ctx context.Context, eventType string, tenantID string, data map[string]interface{}) error
+}
+
 type PaymentWorker struct {
 	redisClient   *redis.Client
 	paymentService service.PaymentService
+	publisher     JobEventPublisher
+	queue         *JobQueue
+	registry      *Registry
+	metrics       *metrics.MetricsCollector
 	queueName     string
+	workerID      string
+	concurrency   int
+	jobTypeSlots  map[string]*jobTypeLimit
 	maxRetries    int
 	retryDelay    time.Duration
 	batchSize     int
 	pollInterval  time.Duration
+	leaseDuration time.Duration
+
+	started   atomic.Bool
+	stop      chan struct{}
+	stopOnce  sync.Once
+	abort     chan struct{}
+	abortOnce sync.Once
+	done      chan struct{}
+	released  atomic.Int64
 }
 
 type PaymentJob struct {
 	ID       string                 `json:"id"`
 	Type     string                 `json:"type"`
 	TenantID string                 `json:"tenant_id"`
+	Priority Priority               `json:"priority,omitempty"`
+	DedupKey string                 `json:"dedup_key,omitempty"`
 	Data     map[string]interface{} `json:"data"`
 	Retries  int                    `json:"retries"`
 	CreatedAt time.Time             `json:"created_at"`
+	Errors   []JobError             `json:"errors,omitempty"`
+}
+
+// JobError records a single failed attempt of a job
+type JobError struct {
+	Attempt  int       `json:"attempt"`
+	Error    string    `json:"error"`
+	WorkerID string    `json:"worker_id"`
+	FailedAt time.Time `json:"failed_at"`
 }
 
 type JobResult struct {
@@ -37,92 +90,402 @@ type JobResult struct {
 	Duration string `json:"duration"`
 }
 
-func NewPaymentWorker(redisClient *redis.Client, paymentService service.PaymentService) *PaymentWorker {
-	return &PaymentWorker{
+// NewPaymentWorker creates a worker that runs process_payment jobs through
+// paymentSaga and retries failed payments according to retryPolicies (the
+// default policy for every tenant when nil).
+func NewPaymentWorker(redisClient *redis.Client, paymentService service.PaymentService, paymentSaga *Saga, retryPolicies *service.RetryPolicies, publisher JobEventPublisher, cfg *config.WorkerConfig) *PaymentWorker {
+	if cfg == nil {
+		cfg = config.NewWorkerConfig()
+	}
+
+	queueName := cfg.QueueName
+	if queueName == "" {
+		queueName = DefaultQueueName
+	}
+
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	// Per-job-type semaphores cap how many pool goroutines run a type at once
+	jobTypeSlots := make(map[string]*jobTypeLimit, len(cfg.JobTypeLimits))
+	for jobType, limit := range cfg.JobTypeLimits {
+		jobTypeSlots[jobType] = newJobTypeLimit(limit)
+	}
+
+	if retryPolicies == nil {
+		retryPolicies = service.NewRetryPolicies()
+	}
+
+	queue := NewJobQueue(redisClient, queueName, cfg.LeaseDuration)
+	queue.SetJobRetention(cfg.JobRetention)
+
+	registry := NewRegistry()
+	w := &PaymentWorker{
 		redisClient:   redisClient,
 		paymentService: paymentService,
-		queueName:     "payment_jobs",
-		maxRetries:    3,
-		retryDelay:    5 * time.Second,
-		batchSize:     10,
-		pollInterval:  1 * time.Second,
+		publisher:     publisher,
+		queue:         queue,
+		registry:      registry,
+		metrics:       metrics.NewMetricsCollector(),
+		queueName:     queueName,
+		workerID:      cfg.ID,
+		concurrency:   concurrency,
+		jobTypeSlots:  jobTypeSlots,
+		maxRetries:    cfg.MaxRetries,
+		retryDelay:    cfg.RetryDelay,
+		batchSize:     cfg.BatchSize,
+		pollInterval:  cfg.PollInterval,
+		leaseDuration: queue.LeaseDuration(),
+		stop:          make(chan struct{}),
+		abort:         make(chan struct{}),
+		done:          make(chan struct{}),
 	}
+
+	// Retries of failed payments are scheduled as delayed jobs on this worker's queue
+	retrier := NewPaymentRetrier(paymentService, paymentSaga, retryPolicies, w)
+	if err := RegisterPaymentHandlers(registry, paymentService, retrier); err != nil {
+		// Only possible through a programming error in the built-in registrations
+		panic(err)
+	}
+
+	return w
 }
 
-// Start begins the worker to process payment jobs
+// Start runs a pool of goroutines that lease and process payment jobs until
+// Shutdown is called or ctx is cancelled. Jobs are dequeued round-robin across
+// tenants. Cancelling ctx is a hard stop: in-flight jobs are cancelled and
+// returned to the queue; use Shutdown to let them finish first.
 func (w *PaymentWorker) Start(ctx context.Context) error {
-	log.Printf("Starting payment worker, queue: %s", w.queueName)
+	if !w.started.CompareAndSwap(false, true) {
+		return fmt.Errorf("payment worker already started")
+	}
+	defer close(w.done)
+
+	log.Printf("Starting payment worker %s, queue: %s, concurrency: %d", w.workerID, w.queueName, w.concurrency)
+
+	// Return anything a previous run under this ID left behind
+	if recovered, err := w.queue.RecoverProcessing(ctx, w.workerID); err != nil {
+		log.Printf("Failed to recover in-flight jobs: %v", err)
+	} else if recovered > 0 {
+		log.Printf("Recovered %d in-flight jobs from a previous run", recovered)
+	}
+
+	// Dequeuing stops on Shutdown; running jobs are only cancelled once the drain deadline passes
+	dequeueCtx, cancelDequeue := context.WithCancel(ctx)
+	defer cancelDequeue()
+	jobsCtx, cancelJobs := context.WithCancel(ctx)
+	defer cancelJobs()
+
+	go func() {
+		select {
+		case <-w.stop:
+			cancelDequeue()
+		case <-dequeueCtx.Done():
+		}
+	}()
+	go func() {
+		select {
+		case <-w.abort:
+			cancelJobs()
+		case <-jobsCtx.Done():
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.run(dequeueCtx, jobsCtx)
+		}()
+	}
+	wg.Wait()
+
+	log.Println("Payment worker stopped")
+	return ctx.Err()
+}
+
+// Shutdown stops the worker taking new jobs and waits for in-flight jobs to
+// finish. If ctx expires first, the remaining jobs are cancelled and their
+// leases released so they return to the front of the queue immediately, and
+// ErrDrainTimeout is returned.
+func (w *PaymentWorker) Shutdown(ctx context.Context) error {
+	if !w.started.Load() {
+		return nil
+	}
+
+	log.Println("Draining payment worker...")
+	w.stopOnce.Do(func() { close(w.stop) })
+
+	select {
+	case <-w.done:
+		log.Println("Payment worker drained cleanly")
+		return nil
+	case <-ctx.Done():
+	}
+
+	log.Println("Drain deadline reached, cancelling in-flight jobs")
+	w.abortOnce.Do(func() { close(w.abort) })
+
+	select {
+	case <-w.done:
+		return fmt.Errorf("%w: %d in-flight jobs returned to the queue", ErrDrainTimeout, w.released.Load())
+	case <-time.After(abortGrace):
+		return fmt.Errorf("%w: jobs still running after cancellation; their leases will be reaped", ErrDrainTimeout)
+	}
+}
 
+// run is the loop of a single pool goroutine. It leases jobs until dequeueCtx
+// is cancelled and runs each one under jobsCtx.
+func (w *PaymentWorker) run(dequeueCtx, jobsCtx context.Context) {
 	for {
 		select {
-		case <-ctx.Done():
-			log.Println("Payment worker shutting down...")
-			return ctx.Err()
+		case <-dequeueCtx.Done():
+			return
 		default:
-			if err := w.processJobs(ctx); err != nil {
+			if err := w.processJobs(dequeueCtx, jobsCtx); err != nil {
 				log.Printf("Error processing jobs: %v", err)
-				time.Sleep(w.pollInterval)
+				select {
+				case <-dequeueCtx.Done():
+				case <-time.After(w.pollInterval):
+				}
 			}
 		}
 	}
 }
 
-// processJobs processes a batch of jobs from the queue
-func (w *PaymentWorker) processJobs(ctx context.Context) error {
-	// Use Redis BLPOP for blocking queue operation
-	result, err := w.redisClient.BLPop(ctx, w.pollInterval, w.queueName).Result()
+// processJobs leases the next job from the queue and processes it
+func (w *PaymentWorker) processJobs(dequeueCtx, jobsCtx context.Context) error {
+	lease, err := w.queue.Dequeue(dequeueCtx, w.workerID, w.pollInterval)
 	if err != nil {
-		if err == redis.Nil {
-			// No jobs available, continue
+		if dequeueCtx.Err() != nil {
 			return nil
 		}
-		return fmt.Errorf("failed to pop job from queue: %w", err)
+		return err
+	}
+	if lease == nil {
+		// No jobs available, continue
+		return nil
+	}
+
+	// A job whose type is at its limit goes straight back to the queue
+	// rather than holding a lease and a pool goroutine until a slot frees up
+	release, ok := w.acquireJobTypeSlot(lease.Job.Type)
+	if !ok {
+		if err := w.queue.Requeue(context.WithoutCancel(jobsCtx), lease); err != nil {
+			return settleError(lease.Job, err)
+		}
+		w.jobTypeSlots[lease.Job.Type].wait(dequeueCtx, w.pollInterval)
+		return nil
+	}
+
+	return w.processLease(jobsCtx, lease, release)
+}
+
+// jobTypeLimit caps how many pool goroutines run one job type at once
+type jobTypeLimit struct {
+	slots chan struct{}
+	freed chan struct{}
+}
+
+func newJobTypeLimit(limit int) *jobTypeLimit {
+	return &jobTypeLimit{
+		slots: make(chan struct{}, limit),
+		freed: make(chan struct{}, 1),
+	}
+}
+
+// wait blocks until a slot is freed, ctx is done or timeout passes
+func (l *jobTypeLimit) wait(ctx context.Context, timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-l.freed:
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// acquireJobTypeSlot takes a slot for jobType without waiting, reporting
+// false when the type is at its limit. The returned release func must be
+// called once the job finishes.
+func (w *PaymentWorker) acquireJobTypeSlot(jobType string) (func(), bool) {
+	limit, ok := w.jobTypeSlots[jobType]
+	if !ok {
+		return func() {}, true
+	}
+
+	select {
+	case limit.slots <- struct{}{}:
+		return func() {
+			<-limit.slots
+			// Wake a goroutine waiting to dequeue after requeueing a job of this type
+			select {
+			case limit.freed <- struct{}{}:
+			default:
+			}
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+// processLease runs a leased job while keeping its lease alive, then
+// acknowledges it or schedules a retry. release frees the job's type slot.
+func (w *PaymentWorker) processLease(ctx context.Context, lease *Lease, release func()) error {
+	job := lease.Job
+
+	jobCtx, cancelJob := context.WithCancel(ctx)
+	defer cancelJob()
+
+	// Heartbeat: extend the lease well before it expires
+	var leaseLost atomic.Bool
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		ticker := time.NewTicker(max(w.leaseDuration/3, minHeartbeatInterval))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-jobCtx.Done():
+				return
+			case <-ticker.C:
+				if err := w.queue.Extend(jobCtx, lease); err != nil {
+					if err == ErrLeaseLost {
+						log.Printf("Lease for job %s lost, abandoning processing", job.ID)
+						leaseLost.Store(true)
+						cancelJob()
+						return
+					}
+					log.Printf("Failed to extend lease for job %s: %v", job.ID, err)
+				}
+			}
+		}
+	}()
+
+	resultCtx, result := withJobResult(jobCtx)
+	w.setJobState(ctx, job, func(status *JobStatus) {
+		now := time.Now().UTC()
+		status.State = JobStateRunning
+		status.Attempts = job.Retries + 1
+		status.StartedAt = &now
+		status.NextRunAt = nil
+	})
+	err := w.processJob(resultCtx, job)
+	release()
+	cancelJob()
+	<-heartbeatDone
+
+	// The lease was reaped and the job handed to another worker; it is no longer ours to settle
+	if leaseLost.Load() {
+		return nil
 	}
 
-	if len(result) < 2 {
-		return fmt.Errorf("invalid redis result format")
+	// Settle with a context that survives shutdown so finished work is not redone
+	settleCtx := context.WithoutCancel(ctx)
+
+	if err == nil {
+		w.metrics.RecordWorkerJob(job.Type, "success")
+		if err := w.queue.Ack(settleCtx, lease); err != nil {
+			return settleError(job, err)
+		}
+
+		w.setJobState(settleCtx, job, func(status *JobStatus) {
+			now := time.Now().UTC()
+			status.State = JobStateSucceeded
+			status.CompletedAt = &now
+			status.LastError = ""
+			if value := result.get(); value != nil {
+				if resultJSON, err := json.Marshal(value); err == nil {
+					status.Result = resultJSON
+				} else {
+					log.Printf("Failed to marshal result of job %s: %v", job.ID, err)
+				}
+			}
+		})
+		return nil
 	}
 
-	var job PaymentJob
-	if err := json.Unmarshal([]byte(result[1]), &job); err != nil {
-		log.Printf("Failed to unmarshal job: %v", err)
+	// Interrupted by shutdown: hand the job straight back rather than waiting for the reaper.
+	// The attempt does not count towards the job's retries.
+	if ctx.Err() != nil {
+		if err := w.queue.Release(settleCtx, lease); err != nil {
+			return settleError(job, err)
+		}
+		w.released.Add(1)
+		w.setJobState(settleCtx, job, func(status *JobStatus) {
+			status.State = JobStateQueued
+		})
+		log.Printf("Job %s interrupted by shutdown, returned to the queue", job.ID)
 		return nil
 	}
 
-	// Process the job
-	if err := w.processJob(ctx, &job); err != nil {
-		log.Printf("Failed to process job %s: %v", job.ID, err)
-		return err
+	failed := *job
+	failed.Errors = append(append([]JobError(nil), job.Errors...), JobError{
+		Attempt:  job.Retries + 1,
+		Error:    err.Error(),
+		WorkerID: w.workerID,
+		FailedAt: time.Now().UTC(),
+	})
+
+	maxRetries, _ := w.retryPolicy(job.Type)
+
+	// Retry logic; jobs that can never succeed go straight to the dead-letter queue
+	if job.Retries < maxRetries && !isPermanentJobError(err) {
+		w.metrics.RecordWorkerJob(job.Type, "retried")
+		return settleError(job, w.retryJob(settleCtx, lease, &failed))
+	}
+
+	reason := fmt.Sprintf("exceeded max retries (%d)", maxRetries)
+	if isPermanentJobError(err) {
+		reason = fmt.Sprintf("permanent failure: %v", err)
+	}
+
+	log.Printf("Job %s moved to dead-letter queue: %s", job.ID, reason)
+	w.metrics.RecordWorkerJob(job.Type, "dead_lettered")
+	w.metrics.RecordJobDeadLettered(job.Type)
+	if err := w.queue.DeadLetter(settleCtx, lease, &failed, reason); err != nil {
+		return settleError(job, err)
 	}
 
+	// Let the same work be enqueued afresh instead of being rejected as a duplicate
+	w.releaseDedupKey(settleCtx, job)
+
+	w.setJobState(settleCtx, job, func(status *JobStatus) {
+		now := time.Now().UTC()
+		status.State = JobStateDeadLettered
+		status.LastError = err.Error()
+		status.CompletedAt = &now
+	})
 	return nil
 }
 
+// settleError returns err from settling job's lease. A lease reaped while the
+// job ran is not an error: the job now belongs to the worker that leased it
+// next, which settles it and records its status.
+func settleError(job *PaymentJob, err error) error {
+	if errors.Is(err, ErrLeaseLost) {
+		log.Printf("Lease for job %s lost before it was settled, leaving the job to its new owner", job.ID)
+		return nil
+	}
+	return err
+}
+
 // processJob processes a single job
 func (w *PaymentWorker) processJob(ctx context.Context, job *PaymentJob) error {
 	startTime := time.Now()
 	log.Printf("Processing job %s (type: %s, tenant: %s, retries: %d)", 
 		job.ID, job.Type, job.TenantID, job.Retries)
 
-	var err error
-	var result JobResult
-
-	switch job.Type {
-	case "process_payment":
-		err = w.processPaymentJob(ctx, job)
-	case "cancel_payment":
-		err = w.cancelPaymentJob(ctx, job)
-	case "retry_failed_payment":
-		err = w.retryFailedPaymentJob(ctx, job)
-	case "payment_notification":
-		err = w.sendPaymentNotificationJob(ctx, job)
-	default:
-		err = fmt.Errorf("unknown job type: %s", job.Type)
-	}
+	err := w.registry.Dispatch(ctx, job)
 
 	// Prepare result
 	duration := time.Since(startTime)
-	result = JobResult{
+	result := JobResult{
 		JobID:    job.ID,
 		Success:  err == nil,
 		Duration: duration.String(),
@@ -131,13 +494,6 @@ func (w *PaymentWorker) processJob(ctx context.Context, job *PaymentJob) error {
 	if err != nil {
 		result.Error = err.Error()
 		log.Printf("Job %s failed: %v", job.ID, err)
-		
-		// Retry logic
-		if job.Retries < w.maxRetries {
-			return w.retryJob(ctx, job)
-		} else {
-			log.Printf("Job %s exceeded max retries, giving up", job.ID)
-		}
 	} else {
 		log.Printf("Job %s completed successfully in %s", job.ID, duration.String())
 	}
@@ -145,94 +501,89 @@ func (w *PaymentWorker) processJob(ctx context.Context, job *PaymentJob) error {
 	// Publish result (optional, for monitoring)
 	w.publishJobResult(ctx, &result)
 
-	return nil
+	return err
 }
 
-// processPaymentJob processes a payment
-func (w *PaymentWorker) processPaymentJob(ctx context.Context, job *PaymentJob) error {
-	paymentID, ok := job.Data["payment_id"].(string)
-	if !ok {
-		return fmt.Errorf("payment_id not found in job data")
+// retryPolicy returns the retry limit and base backoff for jobType, preferring
+// the handler's registered options over the worker defaults
+func (w *PaymentWorker) retryPolicy(jobType string) (int, time.Duration) {
+	maxRetries, retryDelay := w.maxRetries, w.retryDelay
+	if options, ok := w.registry.Options(jobType); ok {
+		if options.MaxRetries > 0 {
+			maxRetries = options.MaxRetries
+		}
+		if options.RetryDelay > 0 {
+			retryDelay = options.RetryDelay
+		}
 	}
-
-	return w.paymentService.ProcessPayment(ctx, job.TenantID, paymentID)
+	return maxRetries, retryDelay
 }
 
-// cancelPaymentJob cancels a payment
-func (w *PaymentWorker) cancelPaymentJob(ctx context.Context, job *PaymentJob) error {
-	paymentID, ok := job.Data["payment_id"].(string)
-	if !ok {
-		return fmt.Errorf("payment_id not found in job data")
-	}
-
-	return w.paymentService.CancelPayment(ctx, job.TenantID, paymentID)
-}
+// retryJob schedules a failed job for another attempt with exponential
+// backoff. The job waits in the delayed set rather than blocking the worker.
+func (w *PaymentWorker) retryJob(ctx context.Context, lease *Lease, failed *PaymentJob) error {
+	job := *failed
+	job.Retries++
+	job.CreatedAt = time.Now()
 
-// retryFailedPaymentJob retries a failed payment
-func (w *PaymentWorker) retryFailedPaymentJob(ctx context.Context, job *PaymentJob) error {
-	paymentID, ok := job.Data["payment_id"].(string)
-	if !ok {
-		return fmt.Errorf("payment_id not found in job data")
+	maxRetries, retryDelay := w.retryPolicy(job.Type)
+	delay := retryDelay * time.Duration(1<<(job.Retries-1))
+	if err := w.queue.Retry(ctx, lease, &job, delay); err != nil {
+		return err
 	}
 
-	// In a real implementation, you might want to reset the payment status first
-	return w.paymentService.ProcessPayment(ctx, job.TenantID, paymentID)
+	w.setJobState(ctx, &job, func(status *JobStatus) {
+		nextRunAt := time.Now().Add(delay).UTC()
+		status.State = JobStateRetrying
+		status.NextRunAt = &nextRunAt
+		if n := len(job.Errors); n > 0 {
+			status.LastError = job.Errors[n-1].Error
+		}
+	})
+
+	log.Printf("Job %s queued for retry %d/%d in %s", job.ID, job.Retries, maxRetries, delay)
+	return nil
 }
 
-// sendPaymentNotificationJob sends payment notifications
-func (w *PaymentWorker) sendPaymentNotificationJob(ctx context.Context, job *PaymentJob) error {
-	paymentID, ok := job.Data["payment_id"].(string)
-	if !ok {
-		return fmt.Errorf("payment_id not found in job data")
+// setJobState updates the job's status record and publishes a job.<state>
+// event for states callers wait on. Failures are logged, never returned: the
+// queue, not the status record, is the source of truth for delivery.
+func (w *PaymentWorker) setJobState(ctx context.Context, job *PaymentJob, update func(status *JobStatus)) {
+	status, err := w.queue.UpdateJobStatus(ctx, job, update)
+	if err != nil {
+		log.Printf("Failed to update status of job %s: %v", job.ID, err)
+		return
 	}
 
-	notificationType, ok := job.Data["notification_type"].(string)
-	if !ok {
-		return fmt.Errorf("notification_type not found in job data")
+	if w.publisher == nil || status.State == JobStateRunning {
+		return
 	}
 
-	// Get payment details
-	payment, err := w.paymentService.GetPayment(ctx, job.TenantID, paymentID)
-	if err != nil {
-		return fmt.Errorf("failed to get payment for notification: %w", err)
+	data := map[string]interface{}{
+		"job_id":   status.ID,
+		"job_type": status.Type,
+		"state":    string(status.State),
+		"attempts": status.Attempts,
 	}
-
-	// Send notification (placeholder implementation)
-	log.Printf("Sending %s notification for payment %s to tenant %s", 
-		notificationType, paymentID, job.TenantID)
-	
-	// In a real implementation, this would integrate with email/SMS services
-	// webhook calls, or other notification systems
-
-	return nil
-}
-
-// retryJob retries a failed job
-func (w *PaymentWorker) retryJob(ctx context.Context, job *PaymentJob) error {
-	job.Retries++
-	job.CreatedAt = time.Now()
-
-	// Add delay before retry
-	time.Sleep(w.retryDelay)
-
-	jobJSON, err := json.Marshal(job)
-	if err != nil {
-		return fmt.Errorf("failed to marshal retry job: %w", err)
+	if status.LastError != "" {
+		data["last_error"] = status.LastError
+	}
+	if status.Result != nil {
+		data["result"] = status.Result
+	}
+	if paymentID, ok := job.Data["payment_id"].(string); ok {
+		data["payment_id"] = paymentID
 	}
 
-	// Push back to queue with delay using Redis sorted set (for delayed processing)
-	delayedQueue := fmt.Sprintf("%s_delayed", w.queueName)
-	score := float64(time.Now().Add(w.retryDelay).Unix())
-	
-	if err := w.redisClient.ZAdd(ctx, delayedQueue, redis.Z{
-		Score:  score,
-		Member: jobJSON,
-	}).Err(); err != nil {
-		return fmt.Errorf("failed to queue retry job: %w", err)
+	if err := w.publisher.Publish(ctx, "job."+string(status.State), status.TenantID, data); err != nil {
+		log.Printf("Failed to publish status event for job %s: %v", job.ID, err)
 	}
+}
 
-	log.Printf("Job %s queued for retry %d/%d", job.ID, job.Retries, w.maxRetries)
-	return nil
+func (w *PaymentWorker) releaseDedupKey(ctx context.Context, job *PaymentJob) {
+	if err := w.queue.ReleaseDedupKey(ctx, job); err != nil {
+		log.Printf("Failed to release dedup key of job %s: %v", job.ID, err)
+	}
 }
 
 // publishJobResult publishes job processing results
@@ -250,82 +601,147 @@ func (w *PaymentWorker) publishJobResult(ctx context.Context, result *JobResult)
 	}
 }
 
-// EnqueueJob adds a new job to the queue
+// EnqueueJob adds a new job to the queue. Jobs without a priority get the
+// default registered for their type. If a job with the same dedup key is
+// already pending within the type's dedup window, the job is not enqueued:
+// ErrDuplicateJob is returned and job.ID is set to the existing job's ID so
+// callers can coalesce onto it.
 func (w *PaymentWorker) EnqueueJob(ctx context.Context, job *PaymentJob) error {
+	return w.enqueueJob(ctx, job, time.Time{})
+}
+
+// EnqueueJobAt is EnqueueJob for a job that should not run before runAt
+func (w *PaymentWorker) EnqueueJobAt(ctx context.Context, job *PaymentJob, runAt time.Time) error {
+	return w.enqueueJob(ctx, job, runAt)
+}
+
+func (w *PaymentWorker) enqueueJob(ctx context.Context, job *PaymentJob, runAt time.Time) error {
+	options, ok := w.registry.Options(job.Type)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownJobType, job.Type)
+	}
+
+	if job.Priority == "" {
+		job.Priority = options.Priority
+	}
+	if !job.Priority.Valid() {
+		return fmt.Errorf("invalid job priority: %s", job.Priority)
+	}
+
 	job.ID = generateJobID()
 	job.CreatedAt = time.Now()
 	job.Retries = 0
 
-	jobJSON, err := json.Marshal(job)
-	if err != nil {
-		return fmt.Errorf("failed to marshal job: %w", err)
+	if job.DedupKey == "" && options.DedupKey != nil {
+		job.DedupKey = options.DedupKey(job)
 	}
+	if job.DedupKey != "" {
+		window := options.DedupWindow
+		if window <= 0 {
+			window = DefaultDedupWindow
+		}
 
-	if err := w.redisClient.LPush(ctx, w.queueName, jobJSON).Err(); err != nil {
-		return fmt.Errorf("failed to enqueue job: %w", err)
+		existingID, err := w.queue.ClaimDedupKey(ctx, job, window)
+		if err != nil {
+			if errors.Is(err, ErrDuplicateJob) {
+				log.Printf("Job %s (type: %s, tenant: %s) duplicates %s, not enqueued", job.ID, job.Type, job.TenantID, existingID)
+				job.ID = existingID
+			}
+			return err
+		}
+	}
+
+	// Record the job before it can be picked up so its status is always pollable
+	status := NewJobStatus(job)
+	if !runAt.IsZero() {
+		nextRunAt := runAt.UTC()
+		status.NextRunAt = &nextRunAt
+	}
+	if err := w.queue.SaveJobStatus(ctx, status); err != nil {
+		w.releaseDedupKey(ctx, job)
+		return err
+	}
+
+	var err error
+	if runAt.IsZero() {
+		err = w.queue.Enqueue(ctx, job)
+	} else {
+		err = w.queue.EnqueueAt(ctx, job, runAt)
+	}
+	if err != nil {
+		w.releaseDedupKey(ctx, job)
+		return err
 	}
 
-	log.Printf("Enqueued job %s (type: %s, tenant: %s)", job.ID, job.Type, job.TenantID)
+	if runAt.IsZero() {
+		log.Printf("Enqueued job %s (type: %s, tenant: %s, priority: %s)", job.ID, job.Type, job.TenantID, job.Priority)
+	} else {
+		log.Printf("Scheduled job %s (type: %s, tenant: %s, priority: %s) for %s", job.ID, job.Type, job.TenantID, job.Priority, runAt.UTC().Format(time.RFC3339))
+	}
 	return nil
 }
 
 // ProcessDelayedJobs processes jobs that are ready for retry
 func (w *PaymentWorker) ProcessDelayedJobs(ctx context.Context) error {
-	delayedQueue := fmt.Sprintf("%s_delayed", w.queueName)
-	now := float64(time.Now().Unix())
-
-	// Get all jobs that are ready to be processed
-	jobs, err := w.redisClient.ZRangeByScore(ctx, delayedQueue, &redis.ZRangeBy{
-		Min: "0",
-		Max: fmt.Sprintf("%f", now),
-	}).Result()
-
+	moved, err := w.queue.PromoteDelayed(ctx, w.batchSize*10)
 	if err != nil {
-		return fmt.Errorf("failed to get delayed jobs: %w", err)
+		return err
 	}
 
-	for _, jobJSON := range jobs {
-		// Move job back to main queue
-		if err := w.redisClient.LPush(ctx, w.queueName, jobJSON).Err(); err != nil {
-			log.Printf("Failed to move delayed job to main queue: %v", err)
-			continue
-		}
+	if moved > 0 {
+		log.Printf("Moved %d delayed jobs back to main queue", moved)
+	}
 
-		// Remove from delayed queue
-		if err := w.redisClient.ZRem(ctx, delayedQueue, jobJSON).Err(); err != nil {
-			log.Printf("Failed to remove job from delayed queue: %v", err)
-		}
+	return nil
+}
+
+// ReapExpiredLeases returns jobs held by workers that stopped heartbeating
+// (e.g. crashed mid-processing) to the main queue
+func (w *PaymentWorker) ReapExpiredLeases(ctx context.Context) error {
+	reaped, err := w.queue.ReapExpired(ctx, w.batchSize*10)
+	if err != nil {
+		return err
 	}
 
-	if len(jobs) > 0 {
-		log.Printf("Moved %d delayed jobs back to main queue", len(jobs))
+	if reaped > 0 {
+		log.Printf("Re-queued %d jobs with expired leases", reaped)
 	}
 
 	return nil
 }
 
-// GetQueueStats returns statistics about the job queue
+// GetQueueStats returns statistics about the job queue and updates the queue gauges
 func (w *PaymentWorker) GetQueueStats(ctx context.Context) (map[string]interface{}, error) {
-	stats := make(map[string]interface{})
-
-	// Main queue length
-	mainQueueLen, err := w.redisClient.LLen(ctx, w.queueName).Result()
+	stats, err := w.queue.Stats(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get main queue length: %w", err)
+		return nil, err
 	}
-	stats["main_queue_length"] = mainQueueLen
 
-	// Delayed queue length
-	delayedQueue := fmt.Sprintf("%s_delayed", w.queueName)
-	delayedQueueLen, err := w.redisClient.ZCard(ctx, delayedQueue).Result()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get delayed queue length: %w", err)
+	if pending, ok := stats["main_queue_length"].(int64); ok {
+		w.metrics.SetWorkerQueueLength(w.queueName, float64(pending))
+	}
+	if delayed, ok := stats["delayed_queue_length"].(int64); ok {
+		w.metrics.SetWorkerQueueLength(w.queueName+"_delayed", float64(delayed))
+	}
+	if dead, ok := stats["dead_letter_queue_length"].(int64); ok {
+		w.metrics.SetDeadLetterQueueDepth(w.queueName, float64(dead))
 	}
-	stats["delayed_queue_length"] = delayedQueueLen
 
 	return stats, nil
 }
 
+// Registry exposes the job handler registry so other packages can register
+// their job types before Start is called
+func (w *PaymentWorker) Registry() *Registry {
+	return w.registry
+}
+
+// Queue exposes the underlying job queue, e.g. for dead-letter tooling
+func (w *PaymentWorker) Queue() *JobQueue {
+	return w.queue
+}
+
+// generateJobID returns a time-ordered, collision-free job ID
 func generateJobID() string {
-	return fmt.Sprintf("job_%d", time.Now().UnixNano())
+	return "job_" + uuid.Must(uuid.NewV7()).String()
 }
@@ -0,0 +1,669 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrLeaseLost = errors.New("job lease lost")
+)
+
+// JobQueue is a reliable, prioritized, tenant-fair Redis job queue.
+//
+// Pending jobs are kept in one sub-queue per priority and tenant. Dequeuing
+// always drains higher priorities first; within a priority, tenants with work
+// waiting sit on a ring that dequeuing rotates through, so each tenant gets
+// one job per turn no matter how deep its backlog is. Dequeued jobs are
+// atomically moved onto a per-worker processing list and tracked by a lease;
+// a job is only removed once it is acknowledged. Leases that are not extended
+// in time are reaped and their jobs returned to the front of their tenant's
+// sub-queue, so a crashed worker never loses work. Every lease carries a
+// token, and extending or settling a lease that was reaped and handed to
+// another worker fails with ErrLeaseLost without touching the new lease.
+//
+// Every Lua script is passed all the keys it touches in KEYS, so on Redis
+// Cluster the queue works once its name carries a hash tag (for example
+// "{payment_jobs}") that puts all of its keys in one slot.
+//
+// Key layout (for queue name "payment_jobs"):
+//
+//	payment_jobs                                  legacy pending list, drained after the tenant sub-queues
+//	payment_jobs:<priority>:tenant:<tenant>       per-tenant pending list (LPUSH in, RPOPLPUSH out from the right)
+//	payment_jobs:<priority>:tenants               ring of tenants with pending jobs at that priority
+//	payment_jobs:<priority>:tenants:active        set mirroring the ring for O(1) membership checks
+//	payment_jobs:signal                           wake-up tokens for idle workers, one per enqueued job
+//	payment_jobs_delayed                          sorted set of jobs scheduled for later, scored by due time (ms)
+//	payment_jobs:processing:<worker>              per-worker processing list
+//	payment_jobs:leases                           sorted set of job IDs scored by lease expiry (ms)
+//	payment_jobs:inflight                         hash of job ID -> {processing_key, token, payload}
+//	payment_jobs:dead                             hash of job ID -> dead letter (see dead_letter.go)
+//	payment_jobs:dead:index                       sorted set of dead-lettered job IDs scored by time of death (ms)
+//	payment_jobs:job:<id>                         job status record (see status.go)
+//	payment_jobs:jobs:tenant:<tenant>             sorted set of a tenant's job IDs scored by creation time (ms)
+type JobQueue struct {
+	redisClient   *redis.Client
+	name          string
+	leaseDuration time.Duration
+	jobRetention  time.Duration
+}
+
+// Lease is a job held by a worker until it is acknowledged, retried or expires
+type Lease struct {
+	Job           *PaymentJob
+	ExpiresAt     time.Time
+	payload       string
+	processingKey string
+	token         string
+}
+
+type inflightEntry struct {
+	ProcessingKey string `json:"processing_key"`
+	Token         string `json:"token"`
+	Payload       string `json:"payload"`
+}
+
+// ownsLeaseLua is prepended to every script that extends or settles a lease.
+// owns_lease reports whether the in-flight entry of job_id in KEYS[inflight]
+// still belongs to the lease with the given processing list and token; once
+// the reaper has handed the job to another worker it belongs to that lease.
+const ownsLeaseLua = `
+local function owns_lease(inflight, job_id, processing_key, token)
+	local raw = redis.call('HGET', KEYS[inflight], job_id)
+	if not raw then
+		return false
+	end
+	local ok, entry = pcall(cjson.decode, raw)
+	return ok and type(entry) == 'table' and entry.processing_key == processing_key and entry.token == token
+end
+`
+
+// pushPendingLua is prepended to every script that makes a job pending.
+// push_pending takes the index of the first of the four keys returned by
+// pendingKeys (tenant sub-queue, tenant ring, active set, signal list), so
+// every key a script touches is passed in KEYS. It routes the payload to the
+// back of the sub-queue, or the front for jobs being returned, and puts the
+// tenant on the ring if it is not already there.
+const pushPendingLua = `
+local function push_pending(first_key, tenant, payload, front)
+	local tenant_queue = KEYS[first_key]
+	local ring = KEYS[first_key + 1]
+	local active = KEYS[first_key + 2]
+	local signal = KEYS[first_key + 3]
+
+	if front then
+		redis.call('RPUSH', tenant_queue, payload)
+	else
+		redis.call('LPUSH', tenant_queue, payload)
+	end
+
+	if redis.call('SADD', active, tenant) == 1 then
+		redis.call('RPUSH', ring, tenant)
+	end
+
+	redis.call('LPUSH', signal, '1')
+	redis.call('LTRIM', signal, 0, 999)
+end
+`
+
+// enqueueScript makes a new job pending
+var enqueueScript = redis.NewScript(pushPendingLua + `
+push_pending(1, ARGV[1], ARGV[2], false)
+return 1
+`)
+
+// leaseScript moves the next job from a pending list (KEYS[1]) onto the
+// worker's processing list (KEYS[2]) and records its lease (KEYS[3], KEYS[4])
+// under the token ARGV[3].
+// For a tenant sub-queue, the tenant ring and active set follow as KEYS[5]
+// and KEYS[6], and the tenant is taken off them once its sub-queue is empty.
+var leaseScript = redis.NewScript(`
+local payload = redis.call('RPOPLPUSH', KEYS[1], KEYS[2])
+if #KEYS == 6 and redis.call('LLEN', KEYS[1]) == 0 then
+	redis.call('LREM', KEYS[5], 0, ARGV[2])
+	redis.call('SREM', KEYS[6], ARGV[2])
+end
+if not payload then
+	return false
+end
+
+local ok, job = pcall(cjson.decode, payload)
+if ok and type(job) == 'table' and job.id then
+	redis.call('HSET', KEYS[4], job.id, cjson.encode({processing_key = KEYS[2], token = ARGV[3], payload = payload}))
+	redis.call('ZADD', KEYS[3], ARGV[1], job.id)
+end
+return payload
+`)
+
+// extendScript pushes out the expiry of a lease the caller still owns
+var extendScript = redis.NewScript(ownsLeaseLua + `
+if not owns_lease(3, ARGV[2], KEYS[1], ARGV[3]) then
+	return 0
+end
+redis.call('ZADD', KEYS[2], ARGV[1], ARGV[2])
+return 1
+`)
+
+// ackScript removes a leased job from the processing list and drops its lease
+var ackScript = redis.NewScript(ownsLeaseLua + `
+if not owns_lease(3, ARGV[2], KEYS[1], ARGV[3]) then
+	return 0
+end
+redis.call('LREM', KEYS[1], 1, ARGV[1])
+redis.call('ZREM', KEYS[2], ARGV[2])
+redis.call('HDEL', KEYS[3], ARGV[2])
+return 1
+`)
+
+// retryScript acknowledges a leased job and schedules its updated payload on the delayed set
+var retryScript = redis.NewScript(ownsLeaseLua + `
+if not owns_lease(3, ARGV[2], KEYS[1], ARGV[5]) then
+	return 0
+end
+redis.call('LREM', KEYS[1], 1, ARGV[1])
+redis.call('ZREM', KEYS[2], ARGV[2])
+redis.call('HDEL', KEYS[3], ARGV[2])
+redis.call('ZADD', KEYS[4], ARGV[4], ARGV[3])
+return 1
+`)
+
+// promoteScript moves a due job from the delayed set to its tenant's
+// sub-queue, unless another worker already promoted it
+var promoteScript = redis.NewScript(pushPendingLua + `
+if redis.call('ZREM', KEYS[1], ARGV[2]) == 0 then
+	return 0
+end
+push_pending(2, ARGV[1], ARGV[2], false)
+return 1
+`)
+
+// reapScript returns a job whose lease expired to the front of its tenant's
+// sub-queue. It does nothing if the lease was extended or the job leased
+// again since the caller looked, so it is safe to run from several workers.
+var reapScript = redis.NewScript(pushPendingLua + `
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not score or tonumber(score) > tonumber(ARGV[2]) then
+	return 0
+end
+if redis.call('HGET', KEYS[2], ARGV[1]) ~= ARGV[3] then
+	return 0
+end
+
+redis.call('LREM', KEYS[3], 1, ARGV[4])
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+push_pending(4, ARGV[5], ARGV[4], true)
+return 1
+`)
+
+// dropOrphanLeaseScript removes an expired lease that has no in-flight entry
+var dropOrphanLeaseScript = redis.NewScript(`
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if score and tonumber(score) <= tonumber(ARGV[2]) and redis.call('HEXISTS', KEYS[2], ARGV[1]) == 0 then
+	redis.call('ZREM', KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// releaseScript gives up a lease and returns its job to its tenant's
+// sub-queue: to the front when ARGV[5] is "1", otherwise to the back
+var releaseScript = redis.NewScript(pushPendingLua + ownsLeaseLua + `
+if not owns_lease(3, ARGV[3], KEYS[1], ARGV[4]) then
+	return 0
+end
+redis.call('LREM', KEYS[1], 1, ARGV[2])
+redis.call('ZREM', KEYS[2], ARGV[3])
+redis.call('HDEL', KEYS[3], ARGV[3])
+push_pending(4, ARGV[1], ARGV[2], ARGV[5] == '1')
+return 1
+`)
+
+// recoverScript returns a job left on a processing list to the front of its
+// tenant's sub-queue, unless it has left the list in the meantime
+var recoverScript = redis.NewScript(pushPendingLua + `
+if redis.call('LREM', KEYS[1], 1, ARGV[2]) == 0 then
+	return 0
+end
+if ARGV[3] ~= '' then
+	redis.call('ZREM', KEYS[2], ARGV[3])
+	redis.call('HDEL', KEYS[3], ARGV[3])
+end
+push_pending(4, ARGV[1], ARGV[2], true)
+return 1
+`)
+
+func NewJobQueue(redisClient *redis.Client, name string, leaseDuration time.Duration) *JobQueue {
+	if leaseDuration <= 0 {
+		leaseDuration = 30 * time.Second
+	}
+
+	return &JobQueue{
+		redisClient:   redisClient,
+		name:          name,
+		leaseDuration: leaseDuration,
+		jobRetention:  DefaultJobRetention,
+	}
+}
+
+// LeaseDuration returns how long a lease lasts without being extended
+func (q *JobQueue) LeaseDuration() time.Duration {
+	return q.leaseDuration
+}
+
+func (q *JobQueue) tenantQueueKey(priority Priority, tenant string) string {
+	return fmt.Sprintf("%s:%s:tenant:%s", q.name, priority, tenant)
+}
+
+func (q *JobQueue) tenantRingKey(priority Priority) string {
+	return fmt.Sprintf("%s:%s:tenants", q.name, priority)
+}
+
+func (q *JobQueue) activeTenantsKey(priority Priority) string {
+	return fmt.Sprintf("%s:%s:tenants:active", q.name, priority)
+}
+
+// pendingKeys returns the keys push_pending needs to make job pending, and
+// the tenant it is queued under. Jobs without a tenant or with an unknown
+// priority are queued under tenant "_" and normal priority.
+func (q *JobQueue) pendingKeys(job *PaymentJob) ([]string, string) {
+	tenant := job.TenantID
+	if tenant == "" {
+		tenant = "_"
+	}
+	priority := job.Priority
+	if !priority.Valid() {
+		priority = PriorityNormal
+	}
+
+	return []string{
+		q.tenantQueueKey(priority, tenant),
+		q.tenantRingKey(priority),
+		q.activeTenantsKey(priority),
+		q.signalKey(),
+	}, tenant
+}
+
+// decodePayload parses a queued payload. Unparseable payloads decode to an
+// empty job, which pendingKeys routes to the default tenant and priority.
+func decodePayload(payload string) *PaymentJob {
+	var job PaymentJob
+	if err := json.Unmarshal([]byte(payload), &job); err != nil {
+		return &PaymentJob{}
+	}
+	return &job
+}
+
+func (q *JobQueue) delayedKey() string {
+	return fmt.Sprintf("%s_delayed", q.name)
+}
+
+func (q *JobQueue) processingKey(workerID string) string {
+	return fmt.Sprintf("%s:processing:%s", q.name, workerID)
+}
+
+func (q *JobQueue) leasesKey() string {
+	return fmt.Sprintf("%s:leases", q.name)
+}
+
+func (q *JobQueue) inflightKey() string {
+	return fmt.Sprintf("%s:inflight", q.name)
+}
+
+func (q *JobQueue) signalKey() string {
+	return fmt.Sprintf("%s:signal", q.name)
+}
+
+// Enqueue adds a job to the back of its tenant's sub-queue
+func (q *JobQueue) Enqueue(ctx context.Context, job *PaymentJob) error {
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+
+	keys, tenant := q.pendingKeys(job)
+	if err := enqueueScript.Run(ctx, q.redisClient, keys, tenant, jobJSON).Err(); err != nil {
+		return fmt.Errorf("failed to enqueue job: %w", err)
+	}
+	return nil
+}
+
+// EnqueueAt schedules a job to become pending at the given time
+func (q *JobQueue) EnqueueAt(ctx context.Context, job *PaymentJob, at time.Time) error {
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+
+	if err := q.redisClient.ZAdd(ctx, q.delayedKey(), redis.Z{
+		Score:  float64(at.UnixMilli()),
+		Member: jobJSON,
+	}).Err(); err != nil {
+		return fmt.Errorf("failed to schedule job: %w", err)
+	}
+	return nil
+}
+
+// Dequeue takes the next job in tenant round-robin order, moves it onto the
+// worker's processing list and takes out a lease on it. When nothing is
+// pending it waits up to timeout for an enqueue signal. It returns nil
+// without error when no job arrived in time.
+func (q *JobQueue) Dequeue(ctx context.Context, workerID string, timeout time.Duration) (*Lease, error) {
+	lease, err := q.tryDequeue(ctx, workerID)
+	if err != nil || lease != nil {
+		return lease, err
+	}
+
+	if err := q.redisClient.BLPop(ctx, timeout, q.signalKey()).Err(); err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to wait for jobs: %w", err)
+	}
+
+	return q.tryDequeue(ctx, workerID)
+}
+
+// tryDequeue leases the next pending job, taking tenants in turn from the
+// ring of the highest priority that has any
+func (q *JobQueue) tryDequeue(ctx context.Context, workerID string) (*Lease, error) {
+	processingKey := q.processingKey(workerID)
+	expiresAt := time.Now().Add(q.leaseDuration)
+
+	pipe := q.redisClient.Pipeline()
+	ringLengths := make([]*redis.IntCmd, len(Priorities))
+	for i, priority := range Priorities {
+		ringLengths[i] = pipe.LLen(ctx, q.tenantRingKey(priority))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to dequeue job: %w", err)
+	}
+
+	for i, priority := range Priorities {
+		ring := q.tenantRingKey(priority)
+		for n := ringLengths[i].Val(); n > 0; n-- {
+			// Rotate the ring: the tenant at the back goes to the front and gets this turn
+			tenant, err := q.redisClient.RPopLPush(ctx, ring, ring).Result()
+			if err == redis.Nil {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to dequeue job: %w", err)
+			}
+
+			keys := []string{
+				q.tenantQueueKey(priority, tenant), processingKey, q.leasesKey(), q.inflightKey(),
+				ring, q.activeTenantsKey(priority),
+			}
+			lease, err := q.lease(ctx, keys, expiresAt, tenant)
+			if err != nil || lease != nil {
+				return lease, err
+			}
+		}
+	}
+
+	// Drain jobs enqueued before per-tenant sub-queues existed
+	return q.lease(ctx, []string{q.name, processingKey, q.leasesKey(), q.inflightKey()}, expiresAt, "")
+}
+
+// lease runs leaseScript on the pending list keys[0]. It returns nil without
+// error when the list is empty.
+func (q *JobQueue) lease(ctx context.Context, keys []string, expiresAt time.Time, tenant string) (*Lease, error) {
+	processingKey := keys[1]
+
+	token := uuid.NewString()
+	payload, err := leaseScript.Run(ctx, q.redisClient, keys, expiresAt.UnixMilli(), tenant, token).Text()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to dequeue job: %w", err)
+	}
+
+	var job PaymentJob
+	if err := json.Unmarshal([]byte(payload), &job); err != nil {
+		// Unparseable payloads can never succeed; drop them from the processing list
+		q.redisClient.LRem(ctx, processingKey, 1, payload)
+		return nil, fmt.Errorf("failed to unmarshal job: %w", err)
+	}
+
+	return &Lease{
+		Job:           &job,
+		ExpiresAt:     expiresAt,
+		payload:       payload,
+		processingKey: processingKey,
+		token:         token,
+	}, nil
+}
+
+// Extend pushes the lease expiry out by the queue's lease duration. It
+// returns ErrLeaseLost if the lease was already settled or reaped.
+func (q *JobQueue) Extend(ctx context.Context, lease *Lease) error {
+	expiresAt := time.Now().Add(q.leaseDuration)
+
+	keys := []string{lease.processingKey, q.leasesKey(), q.inflightKey()}
+	owned, err := extendScript.Run(ctx, q.redisClient, keys, expiresAt.UnixMilli(), lease.Job.ID, lease.token).Int()
+	if err != nil {
+		return fmt.Errorf("failed to extend lease for job %s: %w", lease.Job.ID, err)
+	}
+	if owned == 0 {
+		return ErrLeaseLost
+	}
+
+	lease.ExpiresAt = expiresAt
+	return nil
+}
+
+// Ack removes a finished job from the queue. It returns ErrLeaseLost if the
+// lease was reaped, leaving the job to the worker that leased it next.
+func (q *JobQueue) Ack(ctx context.Context, lease *Lease) error {
+	keys := []string{lease.processingKey, q.leasesKey(), q.inflightKey()}
+	owned, err := ackScript.Run(ctx, q.redisClient, keys, lease.payload, lease.Job.ID, lease.token).Int()
+	if err != nil {
+		return fmt.Errorf("failed to ack job %s: %w", lease.Job.ID, err)
+	}
+	if owned == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Release gives up the lease without finishing the job, returning it to the
+// front of its tenant's sub-queue for another worker to pick up. It returns
+// ErrLeaseLost if the lease was already reaped.
+func (q *JobQueue) Release(ctx context.Context, lease *Lease) error {
+	return q.release(ctx, lease, true)
+}
+
+// Requeue gives up the lease without running the job, returning it to the
+// back of its tenant's sub-queue so the tenant's other jobs go first. It
+// returns ErrLeaseLost if the lease was already reaped.
+func (q *JobQueue) Requeue(ctx context.Context, lease *Lease) error {
+	return q.release(ctx, lease, false)
+}
+
+func (q *JobQueue) release(ctx context.Context, lease *Lease, front bool) error {
+	frontArg := "0"
+	if front {
+		frontArg = "1"
+	}
+
+	pendingKeys, tenant := q.pendingKeys(lease.Job)
+	keys := append([]string{lease.processingKey, q.leasesKey(), q.inflightKey()}, pendingKeys...)
+	owned, err := releaseScript.Run(ctx, q.redisClient, keys, tenant, lease.payload, lease.Job.ID, lease.token, frontArg).Int()
+	if err != nil {
+		return fmt.Errorf("failed to release job %s: %w", lease.Job.ID, err)
+	}
+	if owned == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Retry acknowledges the leased job and schedules job (typically the same
+// job with an incremented retry count) to run again after delay. It returns
+// ErrLeaseLost, scheduling nothing, if the lease was already reaped.
+func (q *JobQueue) Retry(ctx context.Context, lease *Lease, job *PaymentJob, delay time.Duration) error {
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal retry job: %w", err)
+	}
+
+	dueAt := time.Now().Add(delay).UnixMilli()
+	keys := []string{lease.processingKey, q.leasesKey(), q.inflightKey(), q.delayedKey()}
+	owned, err := retryScript.Run(ctx, q.redisClient, keys, lease.payload, lease.Job.ID, jobJSON, dueAt, lease.token).Int()
+	if err != nil {
+		return fmt.Errorf("failed to queue retry for job %s: %w", lease.Job.ID, err)
+	}
+	if owned == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// PromoteDelayed moves up to limit due jobs from the delayed set to the pending sub-queues
+func (q *JobQueue) PromoteDelayed(ctx context.Context, limit int) (int, error) {
+	due, err := q.redisClient.ZRangeByScore(ctx, q.delayedKey(), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().UnixMilli(), 10),
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to promote delayed jobs: %w", err)
+	}
+
+	moved := 0
+	for _, payload := range due {
+		pendingKeys, tenant := q.pendingKeys(decodePayload(payload))
+		keys := append([]string{q.delayedKey()}, pendingKeys...)
+		promoted, err := promoteScript.Run(ctx, q.redisClient, keys, tenant, payload).Int()
+		if err != nil {
+			return moved, fmt.Errorf("failed to promote delayed jobs: %w", err)
+		}
+		moved += promoted
+	}
+	return moved, nil
+}
+
+// ReapExpired returns up to limit jobs whose leases have expired to the pending sub-queues
+func (q *JobQueue) ReapExpired(ctx context.Context, limit int) (int, error) {
+	now := time.Now().UnixMilli()
+	expired, err := q.redisClient.ZRangeByScore(ctx, q.leasesKey(), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now, 10),
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to reap expired leases: %w", err)
+	}
+
+	reaped := 0
+	for _, jobID := range expired {
+		raw, err := q.redisClient.HGet(ctx, q.inflightKey(), jobID).Result()
+		if err == redis.Nil {
+			keys := []string{q.leasesKey(), q.inflightKey()}
+			if err := dropOrphanLeaseScript.Run(ctx, q.redisClient, keys, jobID, now).Err(); err != nil && err != redis.Nil {
+				return reaped, fmt.Errorf("failed to reap expired leases: %w", err)
+			}
+			continue
+		} else if err != nil {
+			return reaped, fmt.Errorf("failed to reap expired leases: %w", err)
+		}
+
+		var entry inflightEntry
+		if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+			return reaped, fmt.Errorf("failed to decode in-flight job %s: %w", jobID, err)
+		}
+
+		pendingKeys, tenant := q.pendingKeys(decodePayload(entry.Payload))
+		keys := append([]string{q.leasesKey(), q.inflightKey(), entry.ProcessingKey}, pendingKeys...)
+		returned, err := reapScript.Run(ctx, q.redisClient, keys, jobID, now, raw, entry.Payload, tenant).Int()
+		if err != nil {
+			return reaped, fmt.Errorf("failed to reap expired leases: %w", err)
+		}
+		reaped += returned
+	}
+	return reaped, nil
+}
+
+// RecoverProcessing returns every job left on workerID's processing list to
+// the pending sub-queues. Call it before a worker starts consuming so jobs
+// from a previous run under the same ID are not stranded until their leases expire.
+func (q *JobQueue) RecoverProcessing(ctx context.Context, workerID string) (int, error) {
+	processingKey := q.processingKey(workerID)
+	payloads, err := q.redisClient.LRange(ctx, processingKey, 0, -1).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to recover processing list for %s: %w", workerID, err)
+	}
+
+	// The list holds the newest job first. Each goes to the front of its
+	// sub-queue, so the oldest ends up at the very front.
+	recovered := 0
+	for _, payload := range payloads {
+		job := decodePayload(payload)
+		pendingKeys, tenant := q.pendingKeys(job)
+		keys := append([]string{processingKey, q.leasesKey(), q.inflightKey()}, pendingKeys...)
+		returned, err := recoverScript.Run(ctx, q.redisClient, keys, tenant, payload, job.ID).Int()
+		if err != nil {
+			return recovered, fmt.Errorf("failed to recover processing list for %s: %w", workerID, err)
+		}
+		recovered += returned
+	}
+	return recovered, nil
+}
+
+// Stats returns pending, delayed, in-flight and dead-lettered job counts
+func (q *JobQueue) Stats(ctx context.Context) (map[string]interface{}, error) {
+	pipe := q.redisClient.Pipeline()
+	rings := make([]*redis.StringSliceCmd, len(Priorities))
+	for i, priority := range Priorities {
+		rings[i] = pipe.LRange(ctx, q.tenantRingKey(priority), 0, -1)
+	}
+	legacy := pipe.LLen(ctx, q.name)
+	delayed := pipe.ZCard(ctx, q.delayedKey())
+	inflight := pipe.ZCard(ctx, q.leasesKey())
+	dead := pipe.ZCard(ctx, q.deadLetterIndexKey())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get queue stats: %w", err)
+	}
+
+	pipe = q.redisClient.Pipeline()
+	tenants := make(map[string]bool)
+	lengths := make(map[Priority][]*redis.IntCmd, len(Priorities))
+	for i, priority := range Priorities {
+		for _, tenant := range rings[i].Val() {
+			tenants[tenant] = true
+			lengths[priority] = append(lengths[priority], pipe.LLen(ctx, q.tenantQueueKey(priority, tenant)))
+		}
+	}
+	if len(tenants) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("failed to count pending jobs: %w", err)
+		}
+	}
+
+	total := legacy.Val()
+	byPriority := make(map[string]int64, len(Priorities))
+	for _, priority := range Priorities {
+		var count int64
+		for _, length := range lengths[priority] {
+			count += length.Val()
+		}
+		byPriority[string(priority)] = count
+		total += count
+	}
+
+	return map[string]interface{}{
+		"main_queue_length":        total,
+		"pending_by_priority":      byPriority,
+		"tenants_with_pending":     int64(len(tenants)),
+		"delayed_queue_length":     delayed.Val(),
+		"in_flight_jobs":           inflight.Val(),
+		"dead_letter_queue_length": dead.Val(),
+	}, nil
+}
@@ -0,0 +1,431 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/yordanos-habtamu/b2b-payments/internal/config"
+	"github.com/yordanos-habtamu/b2b-payments/internal/service"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func enqueueTestJob(t *testing.T, q *JobQueue, id, tenantID string, priority Priority) *PaymentJob {
+	t.Helper()
+	job := &PaymentJob{ID: id, Type: "test", TenantID: tenantID, Priority: priority, CreatedAt: time.Now()}
+	if err := q.Enqueue(context.Background(), job); err != nil {
+		t.Fatalf("Enqueue(%s): %v", id, err)
+	}
+	return job
+}
+
+func dequeueTestJob(t *testing.T, q *JobQueue, workerID string) *Lease {
+	t.Helper()
+	lease, err := q.Dequeue(context.Background(), workerID, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Dequeue: %v", err)
+	}
+	return lease
+}
+
+func dequeuedIDs(t *testing.T, q *JobQueue, workerID string, n int) []string {
+	t.Helper()
+	var ids []string
+	for i := 0; i < n; i++ {
+		lease := dequeueTestJob(t, q, workerID)
+		if lease == nil {
+			t.Fatalf("queue empty after %d of %d jobs", i, n)
+		}
+		ids = append(ids, lease.Job.ID)
+	}
+	return ids
+}
+
+func queueStat(t *testing.T, q *JobQueue, name string) int64 {
+	t.Helper()
+	stats, err := q.Stats(context.Background())
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	return stats[name].(int64)
+}
+
+func TestJobQueueLeaseAndAck(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewJobQueue(client, "jobs", time.Minute)
+	ctx := context.Background()
+
+	enqueueTestJob(t, q, "job-1", "tenant-a", PriorityNormal)
+
+	lease := dequeueTestJob(t, q, "worker-1")
+	if lease == nil || lease.Job.ID != "job-1" {
+		t.Fatalf("Dequeue = %+v, want job-1", lease)
+	}
+	if got := client.LLen(ctx, q.processingKey("worker-1")).Val(); got != 1 {
+		t.Errorf("processing list length = %d, want 1", got)
+	}
+	if got := queueStat(t, q, "in_flight_jobs"); got != 1 {
+		t.Errorf("in-flight jobs = %d, want 1", got)
+	}
+	if next := dequeueTestJob(t, q, "worker-2"); next != nil {
+		t.Fatalf("leased job %s handed out twice", next.Job.ID)
+	}
+
+	before := lease.ExpiresAt
+	if err := q.Extend(ctx, lease); err != nil {
+		t.Fatalf("Extend: %v", err)
+	}
+	if lease.ExpiresAt.Before(before) {
+		t.Errorf("Extend moved expiry back from %s to %s", before, lease.ExpiresAt)
+	}
+
+	if err := q.Ack(ctx, lease); err != nil {
+		t.Fatalf("Ack: %v", err)
+	}
+	if got := client.LLen(ctx, q.processingKey("worker-1")).Val(); got != 0 {
+		t.Errorf("processing list length after ack = %d, want 0", got)
+	}
+	if got := queueStat(t, q, "in_flight_jobs"); got != 0 {
+		t.Errorf("in-flight jobs after ack = %d, want 0", got)
+	}
+	if err := q.Extend(ctx, lease); err != ErrLeaseLost {
+		t.Errorf("Extend after ack = %v, want ErrLeaseLost", err)
+	}
+}
+
+func TestJobQueueReapsExpiredLeases(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewJobQueue(client, "jobs", 50*time.Millisecond)
+	ctx := context.Background()
+
+	enqueueTestJob(t, q, "job-1", "tenant-a", PriorityNormal)
+	enqueueTestJob(t, q, "job-2", "tenant-a", PriorityNormal)
+	enqueueTestJob(t, q, "job-3", "tenant-a", PriorityNormal)
+
+	crashed := dequeueTestJob(t, q, "worker-1")
+	alive := dequeueTestJob(t, q, "worker-2")
+
+	time.Sleep(30 * time.Millisecond)
+	if err := q.Extend(ctx, alive); err != nil {
+		t.Fatalf("Extend: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	// Only the lease that was not extended has expired
+	reaped, err := q.ReapExpired(ctx, 10)
+	if err != nil {
+		t.Fatalf("ReapExpired: %v", err)
+	}
+	if reaped != 1 {
+		t.Fatalf("reaped %d jobs, want 1", reaped)
+	}
+	if err := q.Extend(ctx, crashed); err != ErrLeaseLost {
+		t.Errorf("Extend of reaped lease = %v, want ErrLeaseLost", err)
+	}
+	if got := client.LLen(ctx, q.processingKey("worker-1")).Val(); got != 0 {
+		t.Errorf("crashed worker's processing list length = %d, want 0", got)
+	}
+
+	// The reaped job goes back to the front of its tenant's sub-queue
+	if next := dequeueTestJob(t, q, "worker-3"); next == nil || next.Job.ID != crashed.Job.ID {
+		t.Fatalf("next job = %+v, want reaped %s", next, crashed.Job.ID)
+	}
+
+	if reaped, err := q.ReapExpired(ctx, 10); err != nil || reaped != 0 {
+		t.Errorf("second ReapExpired = %d, %v; want nothing to reap", reaped, err)
+	}
+}
+
+func TestJobQueueStaleLeaseCannotSettle(t *testing.T) {
+	settles := []struct {
+		name   string
+		settle func(q *JobQueue, lease *Lease) error
+	}{
+		{"extend", func(q *JobQueue, lease *Lease) error { return q.Extend(context.Background(), lease) }},
+		{"ack", func(q *JobQueue, lease *Lease) error { return q.Ack(context.Background(), lease) }},
+		{"release", func(q *JobQueue, lease *Lease) error { return q.Release(context.Background(), lease) }},
+		{"retry", func(q *JobQueue, lease *Lease) error {
+			return q.Retry(context.Background(), lease, lease.Job, time.Millisecond)
+		}},
+		{"dead letter", func(q *JobQueue, lease *Lease) error {
+			return q.DeadLetter(context.Background(), lease, lease.Job, "stale")
+		}},
+	}
+
+	// The job is re-leased by another worker, or by another goroutine of the
+	// same worker sharing its processing list
+	for _, owner := range []string{"worker-2", "worker-1"} {
+		for _, tt := range settles {
+			t.Run(owner+" "+tt.name, func(t *testing.T) {
+				_, client := newTestRedis(t)
+				q := NewJobQueue(client, "jobs", 20*time.Millisecond)
+				ctx := context.Background()
+
+				enqueueTestJob(t, q, "job-1", "tenant-a", PriorityNormal)
+				stale := dequeueTestJob(t, q, "worker-1")
+				time.Sleep(30 * time.Millisecond)
+				if reaped, err := q.ReapExpired(ctx, 10); err != nil || reaped != 1 {
+					t.Fatalf("ReapExpired = %d, %v; want 1 job reaped", reaped, err)
+				}
+				current := dequeueTestJob(t, q, owner)
+				if current == nil {
+					t.Fatal("reaped job was not leased again")
+				}
+
+				if err := tt.settle(q, stale); err != ErrLeaseLost {
+					t.Fatalf("%s with a stale lease = %v, want ErrLeaseLost", tt.name, err)
+				}
+
+				// The current lease is untouched: still in flight, and nothing was requeued
+				if got := client.LLen(ctx, q.processingKey(owner)).Val(); got != 1 {
+					t.Errorf("owner's processing list length = %d, want 1", got)
+				}
+				for _, stat := range []string{"main_queue_length", "delayed_queue_length", "dead_letter_queue_length"} {
+					if got := queueStat(t, q, stat); got != 0 {
+						t.Errorf("%s = %d, want 0", stat, got)
+					}
+				}
+				if err := q.Extend(ctx, current); err != nil {
+					t.Fatalf("Extend of current lease: %v", err)
+				}
+				if err := q.Ack(ctx, current); err != nil {
+					t.Fatalf("Ack of current lease: %v", err)
+				}
+				if got := queueStat(t, q, "in_flight_jobs"); got != 0 {
+					t.Errorf("in-flight jobs after ack = %d, want 0", got)
+				}
+			})
+		}
+	}
+}
+
+func TestJobQueueRecoverProcessing(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewJobQueue(client, "jobs", time.Minute)
+	ctx := context.Background()
+
+	enqueueTestJob(t, q, "job-1", "tenant-a", PriorityNormal)
+	enqueueTestJob(t, q, "job-2", "tenant-a", PriorityNormal)
+	enqueueTestJob(t, q, "job-3", "tenant-a", PriorityNormal)
+
+	// A worker took two jobs and died; it restarts under the same ID
+	dequeuedIDs(t, q, "worker-1", 2)
+
+	recovered, err := q.RecoverProcessing(ctx, "worker-1")
+	if err != nil {
+		t.Fatalf("RecoverProcessing: %v", err)
+	}
+	if recovered != 2 {
+		t.Fatalf("recovered %d jobs, want 2", recovered)
+	}
+	if got := queueStat(t, q, "in_flight_jobs"); got != 0 {
+		t.Errorf("in-flight jobs after recovery = %d, want 0", got)
+	}
+
+	// Recovered jobs come back first, in their original order
+	if got, want := dequeuedIDs(t, q, "worker-1", 3), []string{"job-1", "job-2", "job-3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("order after recovery = %v, want %v", got, want)
+	}
+}
+
+func TestJobQueueTenantRoundRobin(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewJobQueue(client, "jobs", time.Minute)
+
+	// A noisy tenant enqueues a backlog before two quiet ones enqueue one job each
+	for i := 1; i <= 4; i++ {
+		enqueueTestJob(t, q, fmt.Sprintf("noisy-%d", i), "noisy", PriorityNormal)
+	}
+	enqueueTestJob(t, q, "quiet-a-1", "quiet-a", PriorityNormal)
+	enqueueTestJob(t, q, "quiet-b-1", "quiet-b", PriorityNormal)
+	enqueueTestJob(t, q, "urgent-1", "noisy", PriorityHigh)
+
+	if got := queueStat(t, q, "tenants_with_pending"); got != 3 {
+		t.Errorf("tenants with pending jobs = %d, want 3", got)
+	}
+
+	ids := dequeuedIDs(t, q, "worker-1", 7)
+	if ids[0] != "urgent-1" {
+		t.Errorf("first job = %s, want the high priority urgent-1", ids[0])
+	}
+
+	// Each tenant gets one job per turn, so the quiet tenants are served
+	// within the first round rather than behind the whole backlog
+	firstRound := map[string]bool{}
+	for _, id := range ids[1:4] {
+		firstRound[id[:len(id)-2]] = true
+	}
+	if len(firstRound) != 3 {
+		t.Errorf("first round = %v, want one job from each tenant", ids[1:4])
+	}
+	if want := []string{"noisy-2", "noisy-3", "noisy-4"}; !reflect.DeepEqual(ids[4:], want) {
+		t.Errorf("remaining jobs = %v, want the rest of the backlog in order %v", ids[4:], want)
+	}
+	if got := queueStat(t, q, "tenants_with_pending"); got != 0 {
+		t.Errorf("tenants with pending jobs after draining = %d, want 0", got)
+	}
+}
+
+func TestJobQueuePromotesDueJobs(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewJobQueue(client, "jobs", time.Minute)
+	ctx := context.Background()
+
+	if err := q.EnqueueAt(ctx, &PaymentJob{ID: "due", Type: "test", TenantID: "tenant-a"}, time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("EnqueueAt: %v", err)
+	}
+	if err := q.EnqueueAt(ctx, &PaymentJob{ID: "later", Type: "test", TenantID: "tenant-a"}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("EnqueueAt: %v", err)
+	}
+
+	moved, err := q.PromoteDelayed(ctx, 10)
+	if err != nil {
+		t.Fatalf("PromoteDelayed: %v", err)
+	}
+	if moved != 1 {
+		t.Fatalf("promoted %d jobs, want 1", moved)
+	}
+	if lease := dequeueTestJob(t, q, "worker-1"); lease == nil || lease.Job.ID != "due" {
+		t.Fatalf("Dequeue = %+v, want the due job", lease)
+	}
+	if got := queueStat(t, q, "delayed_queue_length"); got != 1 {
+		t.Errorf("delayed jobs = %d, want 1", got)
+	}
+}
+
+func TestPaymentWorkerDefaultsLeaseDuration(t *testing.T) {
+	_, client := newTestRedis(t)
+	paymentService := service.NewPaymentService(service.NewMemoryPaymentStore(), nil)
+
+	for _, leaseDuration := range []time.Duration{0, -time.Second, time.Nanosecond} {
+		t.Run(leaseDuration.String(), func(t *testing.T) {
+			cfg := config.NewWorkerConfig()
+			cfg.QueueName = "jobs-" + leaseDuration.String()
+			cfg.ID = "worker-1"
+			cfg.LeaseDuration = leaseDuration
+			cfg.PollInterval = 10 * time.Millisecond
+
+			w := NewPaymentWorker(client, paymentService, nil, nil, nil, cfg)
+			if w.leaseDuration <= 0 {
+				t.Fatalf("lease duration = %s, want the queue's positive default", w.leaseDuration)
+			}
+
+			var ran atomic.Bool
+			MustRegister(w.Registry(), "test", func(ctx context.Context, job *PaymentJob, payload map[string]interface{}) error {
+				// Long enough for several heartbeats, however short the lease
+				time.Sleep(30 * time.Millisecond)
+				ran.Store(true)
+				return nil
+			}, HandlerOptions{})
+
+			job, err := NewJob("test", "tenant-a", map[string]interface{}{})
+			if err != nil {
+				t.Fatalf("NewJob: %v", err)
+			}
+			if err := w.EnqueueJob(context.Background(), job); err != nil {
+				t.Fatalf("EnqueueJob: %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go w.Start(ctx)
+
+			deadline := time.Now().Add(5 * time.Second)
+			for !ran.Load() {
+				if time.Now().After(deadline) {
+					t.Fatal("timed out waiting for the job to run")
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancelShutdown()
+			if err := w.Shutdown(shutdownCtx); err != nil {
+				t.Fatalf("Shutdown: %v", err)
+			}
+		})
+	}
+}
+
+func TestPaymentWorkerJobTypeLimitDoesNotBlockOtherTypes(t *testing.T) {
+	_, client := newTestRedis(t)
+	paymentService := service.NewPaymentService(service.NewMemoryPaymentStore(), nil)
+
+	cfg := config.NewWorkerConfig()
+	cfg.QueueName = "jobs"
+	cfg.ID = "worker-1"
+	cfg.Concurrency = 2
+	cfg.JobTypeLimits = map[string]int{"slow": 1}
+	cfg.PollInterval = 10 * time.Millisecond
+	w := NewPaymentWorker(client, paymentService, nil, nil, nil, cfg)
+
+	unblock := make(chan struct{})
+	var running, maxRunning, slowDone atomic.Int32
+	MustRegister(w.Registry(), "slow", func(ctx context.Context, job *PaymentJob, payload map[string]interface{}) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		if n > maxRunning.Load() {
+			maxRunning.Store(n)
+		}
+		<-unblock
+		slowDone.Add(1)
+		return nil
+	}, HandlerOptions{})
+	fastDone := make(chan struct{})
+	MustRegister(w.Registry(), "fast", func(ctx context.Context, job *PaymentJob, payload map[string]interface{}) error {
+		close(fastDone)
+		return nil
+	}, HandlerOptions{})
+
+	// Two capped jobs queued ahead of an uncapped one from the same tenant
+	for _, jobType := range []string{"slow", "slow", "fast"} {
+		job, err := NewJob(jobType, "tenant-a", map[string]interface{}{})
+		if err != nil {
+			t.Fatalf("NewJob: %v", err)
+		}
+		if err := w.EnqueueJob(context.Background(), job); err != nil {
+			t.Fatalf("EnqueueJob: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Start(ctx)
+
+	// The second goroutine must not park on the second slow job while the first runs
+	select {
+	case <-fastDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("fast job starved behind a job type at its limit")
+	}
+
+	close(unblock)
+	deadline := time.Now().Add(5 * time.Second)
+	for slowDone.Load() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of 2 slow jobs ran", slowDone.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := maxRunning.Load(); n != 1 {
+		t.Errorf("slow jobs running at once = %d, want the limit of 1", n)
+	}
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelShutdown()
+	if err := w.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownJobType    = errors.New("unknown job type")
+	ErrInvalidJobPayload = errors.New("invalid job payload")
+	ErrJobTypeRegistered = errors.New("job type already registered")
+)
+
+// Priority orders pending jobs. Workers always drain higher priorities first;
+// within a priority, tenants are served round-robin.
+type Priority string
+
+const (
+	PriorityHigh   Priority = "high"
+	PriorityNormal Priority = "normal"
+	PriorityLow    Priority = "low"
+)
+
+// Priorities lists every priority level, highest first
+var Priorities = []Priority{PriorityHigh, PriorityNormal, PriorityLow}
+
+// Valid reports whether p is a known priority level
+func (p Priority) Valid() bool {
+	for _, priority := range Priorities {
+		if p == priority {
+			return true
+		}
+	}
+	return false
+}
+
+// HandlerOptions configures how jobs of one type are run. Zero values fall
+// back to the worker's defaults.
+type HandlerOptions struct {
+	// Priority is used for jobs enqueued without an explicit priority
+	Priority Priority
+	// Timeout bounds a single attempt
+	Timeout time.Duration
+	// MaxRetries is the number of retries before the job is dead-lettered
+	MaxRetries int
+	// RetryDelay is the base of the exponential retry backoff
+	RetryDelay time.Duration
+	// DedupKey derives a dedup key for jobs enqueued without one; jobs of
+	// this type with the same key are rejected within DedupWindow
+	DedupKey func(job *PaymentJob) string
+	// DedupWindow is how long a dedup key blocks duplicates
+	DedupWindow time.Duration
+}
+
+// Validator is implemented by job payloads that can check their own fields
+type Validator interface {
+	Validate() error
+}
+
+// TypedHandler processes a job whose Data has been decoded into T
+type TypedHandler[T any] func(ctx context.Context, job *PaymentJob, payload T) error
+
+type registeredHandler struct {
+	handle  func(ctx context.Context, job *PaymentJob) error
+	options HandlerOptions
+}
+
+// Registry maps job types to their handlers
+type Registry struct {
+	mu       sync.RWMutex
+	handlers map[string]*registeredHandler
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		handlers: make(map[string]*registeredHandler),
+	}
+}
+
+// Register adds a typed handler for jobType. Before the handler runs, the
+// job's Data is decoded into T and validated if T implements Validator;
+// payloads that fail either step are rejected with ErrInvalidJobPayload.
+func Register[T any](r *Registry, jobType string, handler TypedHandler[T], options HandlerOptions) error {
+	if options.Priority == "" {
+		options.Priority = PriorityNormal
+	}
+	if !options.Priority.Valid() {
+		return fmt.Errorf("invalid priority %q for job type %s", options.Priority, jobType)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.handlers[jobType]; exists {
+		return fmt.Errorf("%w: %s", ErrJobTypeRegistered, jobType)
+	}
+
+	r.handlers[jobType] = &registeredHandler{
+		handle: func(ctx context.Context, job *PaymentJob) error {
+			var payload T
+			if err := DecodeJobData(job, &payload); err != nil {
+				return err
+			}
+			return handler(ctx, job, payload)
+		},
+		options: options,
+	}
+	return nil
+}
+
+// MustRegister is Register for use at startup; it panics on error
+func MustRegister[T any](r *Registry, jobType string, handler TypedHandler[T], options HandlerOptions) {
+	if err := Register(r, jobType, handler, options); err != nil {
+		panic(err)
+	}
+}
+
+// Options returns the options registered for jobType
+func (r *Registry) Options(jobType string) (HandlerOptions, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	handler, ok := r.handlers[jobType]
+	if !ok {
+		return HandlerOptions{}, false
+	}
+	return handler.options, true
+}
+
+// JobTypes returns the registered job types
+func (r *Registry) JobTypes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	jobTypes := make([]string, 0, len(r.handlers))
+	for jobType := range r.handlers {
+		jobTypes = append(jobTypes, jobType)
+	}
+	return jobTypes
+}
+
+// Dispatch runs the handler registered for the job's type, bounded by its timeout
+func (r *Registry) Dispatch(ctx context.Context, job *PaymentJob) error {
+	r.mu.RLock()
+	handler, ok := r.handlers[job.Type]
+	r.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownJobType, job.Type)
+	}
+
+	if handler.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, handler.options.Timeout)
+		defer cancel()
+	}
+
+	return handler.handle(ctx, job)
+}
+
+// NewJob builds a job of jobType whose Data is the JSON encoding of payload
+func NewJob(jobType, tenantID string, payload interface{}) (*PaymentJob, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job payload: %w", err)
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("job payload must be a JSON object: %w", err)
+	}
+
+	return &PaymentJob{
+		Type:     jobType,
+		TenantID: tenantID,
+		Data:     data,
+	}, nil
+}
+
+// DecodeJobData decodes the job's Data into payload and validates it
+func DecodeJobData(job *PaymentJob, payload interface{}) error {
+	raw, err := json.Marshal(job.Data)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidJobPayload, err)
+	}
+	if err := json.Unmarshal(raw, payload); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidJobPayload, err)
+	}
+
+	if validator, ok := payload.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidJobPayload, err)
+		}
+	}
+	return nil
+}
+
+// isPermanentJobError reports whether retrying the job can never succeed
+func isPermanentJobError(err error) bool {
+	return errors.Is(err, ErrUnknownJobType) ||
+		errors.Is(err, ErrInvalidJobPayload) ||
+		errors.Is(err, ErrPaymentNotRetryable)
+}
@@ -0,0 +1,299 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	ErrSagaNotFound    = errors.New("saga not found")
+	ErrSagaCompensated = errors.New("saga compensated")
+)
+
+// SagaStatus is the lifecycle state of a saga run
+type SagaStatus string
+
+const (
+	SagaStatusRunning      SagaStatus = "running"
+	SagaStatusCompensating SagaStatus = "compensating"
+	SagaStatusCompleted    SagaStatus = "completed"
+	SagaStatusCompensated  SagaStatus = "compensated"
+)
+
+// Saga events recorded in the payment's event log
+const (
+	SagaEventStepCompleted      = "saga_step_completed"
+	SagaEventStepFailed         = "saga_step_failed"
+	SagaEventStepCompensated    = "saga_step_compensated"
+	SagaEventCompensationFailed = "saga_compensation_failed"
+	SagaEventCompleted          = "saga_completed"
+	SagaEventCompensated        = "saga_compensated"
+	SagaEventRetried            = "retried"
+)
+
+// SagaState is the persisted progress of one saga for one payment. Data
+// carries values later steps and compensations need, such as the reference
+// funds were reserved under. CompletedSteps lists the steps left to
+// compensate, including a failed step that may have taken effect.
+// FailureClass is what the saga's classifier made of the failed step's error.
+type SagaState struct {
+	Saga           string            `json:"saga"`
+	PaymentID      string            `json:"payment_id"`
+	TenantID       string            `json:"tenant_id"`
+	Status         SagaStatus        `json:"status"`
+	Attempt        int               `json:"attempt"`
+	CompletedSteps []string          `json:"completed_steps"`
+	Data           map[string]string `json:"data"`
+	FailedStep     string            `json:"failed_step,omitempty"`
+	LastError      string            `json:"last_error,omitempty"`
+	FailureClass   string            `json:"failure_class,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+// SagaEvent describes a saga transition to append to the payment's event log
+type SagaEvent struct {
+	Type  string
+	Step  string
+	Error string
+}
+
+// SagaStore persists saga state. SaveSaga must write the state and append
+// the event (when not nil) atomically so the log never disagrees with the state.
+type SagaStore interface {
+	LoadSaga(ctx context.Context, saga, tenantID, paymentID string) (*SagaState, error)
+	SaveSaga(ctx context.Context, state *SagaState, event *SagaEvent) error
+}
+
+// SagaStep is one step of a saga. Compensate undoes Action and is nil when
+// there is nothing to undo. Both may run more than once for the same payment
+// after a crash, so they must be idempotent.
+//
+// MayHaveApplied reports whether an Action that failed with err may still
+// have taken effect, e.g. because it timed out after reaching the
+// dependency. Such a step is compensated along with the completed ones, so
+// its Compensate must also succeed when the action never happened. When
+// MayHaveApplied is nil, a failed Action is taken to have had no effect.
+type SagaStep struct {
+	Name           string
+	Action         func(ctx context.Context, state *SagaState) error
+	Compensate     func(ctx context.Context, state *SagaState) error
+	MayHaveApplied func(err error) bool
+}
+
+// Saga runs a fixed sequence of steps per payment. When a step fails, the
+// steps that already completed are compensated in reverse order, starting
+// with the failed step itself if it may have taken effect. State is
+// saved after every transition, so a run interrupted by a crash or shutdown
+// resumes where it stopped.
+type Saga struct {
+	name     string
+	steps    []SagaStep
+	store    SagaStore
+	classify func(err error, mayHaveApplied bool) string
+}
+
+// NewSaga creates a saga from its steps. Step names must be unique.
+func NewSaga(name string, store SagaStore, steps ...SagaStep) (*Saga, error) {
+	seen := make(map[string]bool, len(steps))
+	for _, step := range steps {
+		if step.Name == "" || step.Action == nil {
+			return nil, fmt.Errorf("saga %s: every step needs a name and an action", name)
+		}
+		if seen[step.Name] {
+			return nil, fmt.Errorf("saga %s: duplicate step %s", name, step.Name)
+		}
+		seen[step.Name] = true
+	}
+
+	return &Saga{
+		name:  name,
+		steps: steps,
+		store: store,
+	}, nil
+}
+
+// Name returns the saga name
+func (s *Saga) Name() string {
+	return s.name
+}
+
+// State returns the persisted state of the saga for a payment, or
+// ErrSagaNotFound when it never ran
+func (s *Saga) State(ctx context.Context, tenantID, paymentID string) (*SagaState, error) {
+	state, err := s.store.LoadSaga(ctx, s.name, tenantID, paymentID)
+	if err != nil && !errors.Is(err, ErrSagaNotFound) {
+		return nil, fmt.Errorf("failed to load saga state: %w", err)
+	}
+	return state, err
+}
+
+// Run executes or resumes the saga for a payment. It returns nil once all
+// steps completed and an error wrapping ErrSagaCompensated once a failed run
+// has been fully compensated. Any other error means the run stopped partway
+// (cancellation, a store failure or a failing compensation) and calling Run
+// again resumes it.
+func (s *Saga) Run(ctx context.Context, tenantID, paymentID string) error {
+	state, err := s.store.LoadSaga(ctx, s.name, tenantID, paymentID)
+	if errors.Is(err, ErrSagaNotFound) {
+		now := time.Now().UTC()
+		state = &SagaState{
+			Saga:      s.name,
+			PaymentID: paymentID,
+			TenantID:  tenantID,
+			Status:    SagaStatusRunning,
+			Data:      make(map[string]string),
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := s.save(ctx, state, nil); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to load saga state: %w", err)
+	}
+	if state.Data == nil {
+		state.Data = make(map[string]string)
+	}
+
+	switch state.Status {
+	case SagaStatusCompleted:
+		return nil
+	case SagaStatusCompensated:
+		return s.compensatedError(state)
+	case SagaStatusCompensating:
+		return s.compensate(ctx, state)
+	}
+
+	if len(state.CompletedSteps) > len(s.steps) {
+		return fmt.Errorf("saga %s: payment %s has more completed steps than the saga defines", s.name, paymentID)
+	}
+	if len(state.CompletedSteps) > 0 {
+		log.Printf("Resuming saga %s for payment %s after step %s",
+			s.name, paymentID, state.CompletedSteps[len(state.CompletedSteps)-1])
+	}
+
+	for _, step := range s.steps[len(state.CompletedSteps):] {
+		if err := step.Action(ctx, state); err != nil {
+			if ctx.Err() != nil {
+				// Interrupted, not failed: leave the state as is so the run resumes
+				return fmt.Errorf("saga %s interrupted at step %s: %w", s.name, step.Name, err)
+			}
+
+			state.Status = SagaStatusCompensating
+			state.FailedStep = step.Name
+			state.LastError = err.Error()
+			mayHaveApplied := step.Compensate != nil && step.MayHaveApplied != nil && step.MayHaveApplied(err)
+			if mayHaveApplied {
+				// The outcome is unknown, so undo the step as if it had completed
+				state.CompletedSteps = append(state.CompletedSteps, step.Name)
+			}
+			if s.classify != nil {
+				state.FailureClass = s.classify(err, mayHaveApplied)
+			}
+			if err := s.save(ctx, state, &SagaEvent{Type: SagaEventStepFailed, Step: step.Name, Error: state.LastError}); err != nil {
+				return err
+			}
+			return s.compensate(ctx, state)
+		}
+
+		state.CompletedSteps = append(state.CompletedSteps, step.Name)
+		if err := s.save(ctx, state, &SagaEvent{Type: SagaEventStepCompleted, Step: step.Name}); err != nil {
+			return err
+		}
+	}
+
+	state.Status = SagaStatusCompleted
+	return s.save(ctx, state, &SagaEvent{Type: SagaEventCompleted})
+}
+
+// Restart starts a new attempt of a compensated saga, recording a retried
+// event. Attempts are numbered from 1 for the first retry; restarting with an
+// attempt that has already started is a no-op, so a retry job interrupted
+// after Restart can call it again.
+func (s *Saga) Restart(ctx context.Context, tenantID, paymentID string, attempt int) error {
+	state, err := s.store.LoadSaga(ctx, s.name, tenantID, paymentID)
+	if errors.Is(err, ErrSagaNotFound) {
+		// Never started: Run begins from the first step anyway
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to load saga state: %w", err)
+	}
+
+	if state.Attempt >= attempt {
+		return nil
+	}
+	if state.Status != SagaStatusCompensated {
+		return fmt.Errorf("saga %s for payment %s cannot be restarted in status %s", s.name, paymentID, state.Status)
+	}
+
+	previousError := state.LastError
+	state.Status = SagaStatusRunning
+	state.Attempt = attempt
+	state.CompletedSteps = nil
+	state.Data = make(map[string]string)
+	state.FailedStep = ""
+	state.LastError = ""
+	state.FailureClass = ""
+	return s.save(ctx, state, &SagaEvent{Type: SagaEventRetried, Error: previousError})
+}
+
+// compensate undoes completed steps in reverse order. A failing compensation
+// stops the run with the remaining steps still recorded, so the next run
+// retries it.
+func (s *Saga) compensate(ctx context.Context, state *SagaState) error {
+	for i := len(state.CompletedSteps) - 1; i >= 0; i-- {
+		name := state.CompletedSteps[i]
+		step, ok := s.step(name)
+		if !ok {
+			return fmt.Errorf("saga %s: cannot compensate unknown step %s", s.name, name)
+		}
+
+		if step.Compensate != nil {
+			if err := step.Compensate(ctx, state); err != nil {
+				if ctx.Err() == nil {
+					event := &SagaEvent{Type: SagaEventCompensationFailed, Step: name, Error: err.Error()}
+					if saveErr := s.save(ctx, state, event); saveErr != nil {
+						log.Printf("Failed to record compensation failure for payment %s: %v", state.PaymentID, saveErr)
+					}
+				}
+				return fmt.Errorf("failed to compensate step %s: %w", name, err)
+			}
+		}
+
+		state.CompletedSteps = state.CompletedSteps[:i]
+		if err := s.save(ctx, state, &SagaEvent{Type: SagaEventStepCompensated, Step: name}); err != nil {
+			return err
+		}
+	}
+
+	state.Status = SagaStatusCompensated
+	if err := s.save(ctx, state, &SagaEvent{Type: SagaEventCompensated, Step: state.FailedStep, Error: state.LastError}); err != nil {
+		return err
+	}
+	return s.compensatedError(state)
+}
+
+func (s *Saga) step(name string) (SagaStep, bool) {
+	for _, step := range s.steps {
+		if step.Name == name {
+			return step, true
+		}
+	}
+	return SagaStep{}, false
+}
+
+func (s *Saga) save(ctx context.Context, state *SagaState, event *SagaEvent) error {
+	state.UpdatedAt = time.Now().UTC()
+	if err := s.store.SaveSaga(ctx, state, event); err != nil {
+		return fmt.Errorf("failed to save saga state: %w", err)
+	}
+	return nil
+}
+
+func (s *Saga) compensatedError(state *SagaState) error {
+	return fmt.Errorf("%w: step %s failed: %s", ErrSagaCompensated, state.FailedStep, state.LastError)
+}
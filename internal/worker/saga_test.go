@@ -0,0 +1,302 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// memorySagaStore keeps saga state in memory and records every event saved
+type memorySagaStore struct {
+	mu      sync.Mutex
+	states  map[string]SagaState
+	events  []SagaEvent
+	saveErr error
+}
+
+func newMemorySagaStore() *memorySagaStore {
+	return &memorySagaStore{states: make(map[string]SagaState)}
+}
+
+func (m *memorySagaStore) LoadSaga(ctx context.Context, saga, tenantID, paymentID string) (*SagaState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.states[saga+"/"+tenantID+"/"+paymentID]
+	if !ok {
+		return nil, ErrSagaNotFound
+	}
+	return cloneSagaState(state), nil
+}
+
+func (m *memorySagaStore) SaveSaga(ctx context.Context, state *SagaState, event *SagaEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.saveErr != nil {
+		return m.saveErr
+	}
+	m.states[state.Saga+"/"+state.TenantID+"/"+state.PaymentID] = *cloneSagaState(*state)
+	if event != nil {
+		m.events = append(m.events, *event)
+	}
+	return nil
+}
+
+func (m *memorySagaStore) state(t *testing.T, saga string) SagaState {
+	t.Helper()
+	state, err := m.LoadSaga(context.Background(), saga, "tenant-1", "payment-1")
+	if err != nil {
+		t.Fatalf("LoadSaga: %v", err)
+	}
+	return *state
+}
+
+func (m *memorySagaStore) eventTypes() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var types []string
+	for _, event := range m.events {
+		types = append(types, event.Type+":"+event.Step)
+	}
+	return types
+}
+
+func cloneSagaState(state SagaState) *SagaState {
+	state.CompletedSteps = append([]string(nil), state.CompletedSteps...)
+	data := make(map[string]string, len(state.Data))
+	for k, v := range state.Data {
+		data[k] = v
+	}
+	state.Data = data
+	return &state
+}
+
+// stepRecorder builds saga steps that log their actions and compensations in order
+type stepRecorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *stepRecorder) record(call string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+func (r *stepRecorder) step(name string, actionErr, compensateErr *error) SagaStep {
+	return SagaStep{
+		Name: name,
+		Action: func(ctx context.Context, state *SagaState) error {
+			r.record("do " + name)
+			if actionErr != nil && *actionErr != nil {
+				return *actionErr
+			}
+			state.Data[name] = "done"
+			return nil
+		},
+		Compensate: func(ctx context.Context, state *SagaState) error {
+			r.record("undo " + name)
+			if compensateErr != nil && *compensateErr != nil {
+				return *compensateErr
+			}
+			return nil
+		},
+	}
+}
+
+func TestSagaCompensatesInReverseOrder(t *testing.T) {
+	store := newMemorySagaStore()
+	recorder := &stepRecorder{}
+	declined := errors.New("insufficient funds")
+
+	saga, err := NewSaga("test", store,
+		recorder.step("a", nil, nil),
+		recorder.step("b", nil, nil),
+		recorder.step("c", nil, nil),
+		recorder.step("d", &declined, nil),
+	)
+	if err != nil {
+		t.Fatalf("NewSaga: %v", err)
+	}
+
+	err = saga.Run(context.Background(), "tenant-1", "payment-1")
+	if !errors.Is(err, ErrSagaCompensated) {
+		t.Fatalf("Run = %v, want ErrSagaCompensated", err)
+	}
+
+	want := []string{"do a", "do b", "do c", "do d", "undo c", "undo b", "undo a"}
+	if !reflect.DeepEqual(recorder.calls, want) {
+		t.Errorf("calls = %v, want %v", recorder.calls, want)
+	}
+
+	state := store.state(t, "test")
+	if state.Status != SagaStatusCompensated || state.FailedStep != "d" || len(state.CompletedSteps) != 0 {
+		t.Errorf("state = %+v, want compensated after d with no completed steps", state)
+	}
+
+	// Running a compensated saga again does nothing
+	recorder.calls = nil
+	if err := saga.Run(context.Background(), "tenant-1", "payment-1"); !errors.Is(err, ErrSagaCompensated) {
+		t.Fatalf("second Run = %v, want ErrSagaCompensated", err)
+	}
+	if len(recorder.calls) != 0 {
+		t.Errorf("second Run made calls %v", recorder.calls)
+	}
+}
+
+func TestSagaResumesFromPersistedStep(t *testing.T) {
+	store := newMemorySagaStore()
+	recorder := &stepRecorder{}
+
+	// A previous run completed a and b and then crashed
+	if err := store.SaveSaga(context.Background(), &SagaState{
+		Saga:           "test",
+		PaymentID:      "payment-1",
+		TenantID:       "tenant-1",
+		Status:         SagaStatusRunning,
+		CompletedSteps: []string{"a", "b"},
+		Data:           map[string]string{"a": "done", "b": "done"},
+	}, nil); err != nil {
+		t.Fatalf("SaveSaga: %v", err)
+	}
+
+	saga, err := NewSaga("test", store,
+		recorder.step("a", nil, nil),
+		recorder.step("b", nil, nil),
+		recorder.step("c", nil, nil),
+	)
+	if err != nil {
+		t.Fatalf("NewSaga: %v", err)
+	}
+
+	if err := saga.Run(context.Background(), "tenant-1", "payment-1"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if want := []string{"do c"}; !reflect.DeepEqual(recorder.calls, want) {
+		t.Errorf("calls = %v, want %v", recorder.calls, want)
+	}
+	state := store.state(t, "test")
+	if state.Status != SagaStatusCompleted {
+		t.Errorf("status = %s, want completed", state.Status)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(state.CompletedSteps, want) {
+		t.Errorf("completed steps = %v, want %v", state.CompletedSteps, want)
+	}
+}
+
+func TestSagaInterruptedRunResumes(t *testing.T) {
+	store := newMemorySagaStore()
+	recorder := &stepRecorder{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	saga, err := NewSaga("test", store,
+		recorder.step("a", nil, nil),
+		SagaStep{
+			Name: "b",
+			Action: func(ctx context.Context, state *SagaState) error {
+				recorder.record("do b")
+				cancel()
+				return ctx.Err()
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("NewSaga: %v", err)
+	}
+
+	if err := saga.Run(ctx, "tenant-1", "payment-1"); err == nil || errors.Is(err, ErrSagaCompensated) {
+		t.Fatalf("Run = %v, want an interruption", err)
+	}
+
+	// Nothing is compensated: the run stopped, it did not fail
+	state := store.state(t, "test")
+	if state.Status != SagaStatusRunning || !reflect.DeepEqual(state.CompletedSteps, []string{"a"}) {
+		t.Errorf("state = %+v, want running after a", state)
+	}
+}
+
+func TestSagaFailingCompensationIsRetried(t *testing.T) {
+	store := newMemorySagaStore()
+	recorder := &stepRecorder{}
+	declined := errors.New("insufficient funds")
+	releaseErr := errors.New("ledger unavailable")
+
+	saga, err := NewSaga("test", store,
+		recorder.step("a", nil, nil),
+		recorder.step("b", nil, &releaseErr),
+		recorder.step("c", &declined, nil),
+	)
+	if err != nil {
+		t.Fatalf("NewSaga: %v", err)
+	}
+
+	err = saga.Run(context.Background(), "tenant-1", "payment-1")
+	if err == nil || errors.Is(err, ErrSagaCompensated) || !errors.Is(err, releaseErr) {
+		t.Fatalf("Run = %v, want the compensation error", err)
+	}
+
+	// b stays recorded as completed so its compensation is retried; a is untouched
+	state := store.state(t, "test")
+	if state.Status != SagaStatusCompensating || !reflect.DeepEqual(state.CompletedSteps, []string{"a", "b"}) {
+		t.Errorf("state = %+v, want compensating with a and b left", state)
+	}
+	events := store.eventTypes()
+	if last := events[len(events)-1]; last != SagaEventCompensationFailed+":b" {
+		t.Errorf("last event = %s, want %s:b", last, SagaEventCompensationFailed)
+	}
+
+	// Once the dependency recovers the next run finishes compensating
+	releaseErr = nil
+	recorder.calls = nil
+	err = saga.Run(context.Background(), "tenant-1", "payment-1")
+	if !errors.Is(err, ErrSagaCompensated) {
+		t.Fatalf("second Run = %v, want ErrSagaCompensated", err)
+	}
+	if want := []string{"undo b", "undo a"}; !reflect.DeepEqual(recorder.calls, want) {
+		t.Errorf("calls = %v, want %v", recorder.calls, want)
+	}
+	if state := store.state(t, "test"); state.Status != SagaStatusCompensated {
+		t.Errorf("status = %s, want compensated", state.Status)
+	}
+}
+
+func TestSagaCompensatesStepThatMayHaveApplied(t *testing.T) {
+	timeout := errors.New("call timed out")
+	declined := errors.New("declined")
+
+	tests := []struct {
+		name      string
+		err       error
+		wantCalls []string
+	}{
+		{"unknown outcome", timeout, []string{"do a", "do b", "undo b", "undo a"}},
+		{"definite failure", declined, []string{"do a", "do b", "undo a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemorySagaStore()
+			recorder := &stepRecorder{}
+
+			failing := recorder.step("b", &tt.err, nil)
+			failing.MayHaveApplied = func(err error) bool { return errors.Is(err, timeout) }
+
+			saga, err := NewSaga("test", store, recorder.step("a", nil, nil), failing)
+			if err != nil {
+				t.Fatalf("NewSaga: %v", err)
+			}
+
+			if err := saga.Run(context.Background(), "tenant-1", "payment-1"); !errors.Is(err, ErrSagaCompensated) {
+				t.Fatalf("Run = %v, want ErrSagaCompensated", err)
+			}
+			if !reflect.DeepEqual(recorder.calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", recorder.calls, tt.wantCalls)
+			}
+		})
+	}
+}
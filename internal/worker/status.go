@@ -0,0 +1,324 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+)
+
+// DefaultJobRetention is how long finished job records are kept
+const DefaultJobRetention = 7 * 24 * time.Hour
+
+// JobState is the lifecycle state of a job
+type JobState string
+
+const (
+	JobStateQueued       JobState = "queued"
+	JobStateRunning      JobState = "running"
+	JobStateRetrying     JobState = "retrying"
+	JobStateSucceeded    JobState = "succeeded"
+	JobStateDeadLettered JobState = "dead_lettered"
+)
+
+// Finished reports whether the job will not run again without intervention
+func (s JobState) Finished() bool {
+	return s == JobStateSucceeded || s == JobStateDeadLettered
+}
+
+// JobStatus is the persistent record of a job that callers can poll
+type JobStatus struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	TenantID    string          `json:"tenant_id"`
+	Priority    Priority        `json:"priority,omitempty"`
+	State       JobState        `json:"state"`
+	Attempts    int             `json:"attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	NextRunAt   *time.Time      `json:"next_run_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+}
+
+// JobStatusFilter selects job records for tenant-scoped listing.
+// Zero-valued fields match everything.
+type JobStatusFilter struct {
+	State   JobState
+	JobType string
+	Limit   int
+}
+
+func (q *JobQueue) jobStatusKey(jobID string) string {
+	return fmt.Sprintf("%s:job:%s", q.name, jobID)
+}
+
+func (q *JobQueue) tenantJobsKey(tenantID string) string {
+	return fmt.Sprintf("%s:jobs:tenant:%s", q.name, tenantID)
+}
+
+// SetJobRetention sets how long finished job records are kept
+func (q *JobQueue) SetJobRetention(retention time.Duration) {
+	if retention > 0 {
+		q.jobRetention = retention
+	}
+}
+
+// SaveJobStatus writes a job record and indexes it under its tenant. Finished
+// records expire after the queue's retention period.
+func (q *JobQueue) SaveJobStatus(ctx context.Context, status *JobStatus) error {
+	status.UpdatedAt = time.Now().UTC()
+
+	statusJSON, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job status: %w", err)
+	}
+
+	// Active jobs never expire; finished ones are kept for the retention period
+	var ttl time.Duration
+	if status.State.Finished() {
+		ttl = q.jobRetention
+	}
+
+	pipe := q.redisClient.TxPipeline()
+	pipe.Set(ctx, q.jobStatusKey(status.ID), statusJSON, ttl)
+	pipe.ZAdd(ctx, q.tenantJobsKey(status.TenantID), redis.Z{
+		Score:  float64(status.CreatedAt.UnixMilli()),
+		Member: status.ID,
+	})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save job status %s: %w", status.ID, err)
+	}
+	return nil
+}
+
+// NewJobStatus returns a queued record for job
+func NewJobStatus(job *PaymentJob) *JobStatus {
+	createdAt := job.CreatedAt.UTC()
+	if createdAt.IsZero() {
+		createdAt = time.Now().UTC()
+	}
+
+	return &JobStatus{
+		ID:        job.ID,
+		Type:      job.Type,
+		TenantID:  job.TenantID,
+		Priority:  job.Priority,
+		State:     JobStateQueued,
+		Attempts:  len(job.Errors),
+		CreatedAt: createdAt,
+	}
+}
+
+// UpdateJobStatus applies update to the stored record of job and saves it. A
+// missing record (e.g. already expired) is recreated from the job.
+func (q *JobQueue) UpdateJobStatus(ctx context.Context, job *PaymentJob, update func(status *JobStatus)) (*JobStatus, error) {
+	status, err := q.getJobStatus(ctx, job.ID)
+	if errors.Is(err, ErrJobNotFound) {
+		status, err = NewJobStatus(job), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	update(status)
+	if err := q.SaveJobStatus(ctx, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// GetJobStatus returns a job record. Records belonging to another tenant are
+// reported as not found.
+func (q *JobQueue) GetJobStatus(ctx context.Context, tenantID, jobID string) (*JobStatus, error) {
+	status, err := q.getJobStatus(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if status.TenantID != tenantID {
+		return nil, ErrJobNotFound
+	}
+	return status, nil
+}
+
+func (q *JobQueue) getJobStatus(ctx context.Context, jobID string) (*JobStatus, error) {
+	raw, err := q.redisClient.Get(ctx, q.jobStatusKey(jobID)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("failed to get job status %s: %w", jobID, err)
+	}
+
+	var status JobStatus
+	if err := json.Unmarshal([]byte(raw), &status); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal job status %s: %w", jobID, err)
+	}
+	return &status, nil
+}
+
+// jobIndexPageSize is how many index entries are read per round trip when
+// listing or pruning a tenant's jobs
+const jobIndexPageSize = 100
+
+// ListJobStatuses returns a tenant's job records matching filter, newest first.
+// The index is read a page at a time, so a listing with a limit stops as soon
+// as enough records match. Index entries whose records have expired are
+// dropped as a side effect.
+func (q *JobQueue) ListJobStatuses(ctx context.Context, tenantID string, filter *JobStatusFilter) ([]*JobStatus, error) {
+	if filter == nil {
+		filter = &JobStatusFilter{}
+	}
+
+	indexKey := q.tenantJobsKey(tenantID)
+	q.pruneJobIndex(ctx, indexKey)
+
+	var statuses []*JobStatus
+	var expired []interface{}
+	defer func() { q.dropExpiredIndexEntries(ctx, indexKey, expired) }()
+
+	for offset := int64(0); ; offset += jobIndexPageSize {
+		ids, err := q.redisClient.ZRevRangeByScore(ctx, indexKey, &redis.ZRangeBy{
+			Min:    "-inf",
+			Max:    "+inf",
+			Offset: offset,
+			Count:  jobIndexPageSize,
+		}).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list jobs: %w", err)
+		}
+		if len(ids) == 0 {
+			return statuses, nil
+		}
+
+		loaded, missing, err := q.loadJobStatuses(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		expired = append(expired, missing...)
+
+		for _, status := range loaded {
+			if !filter.matches(status) {
+				continue
+			}
+			statuses = append(statuses, status)
+			if filter.Limit > 0 && len(statuses) >= filter.Limit {
+				return statuses, nil
+			}
+		}
+
+		if len(ids) < jobIndexPageSize {
+			return statuses, nil
+		}
+	}
+}
+
+// loadJobStatuses fetches the records of ids in order. IDs whose records have
+// expired are returned as missing.
+func (q *JobQueue) loadJobStatuses(ctx context.Context, ids []string) ([]*JobStatus, []interface{}, error) {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = q.jobStatusKey(id)
+	}
+
+	values, err := q.redisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load jobs: %w", err)
+	}
+
+	var statuses []*JobStatus
+	var missing []interface{}
+	for i, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			missing = append(missing, ids[i])
+			continue
+		}
+
+		var status JobStatus
+		if err := json.Unmarshal([]byte(raw), &status); err != nil {
+			continue
+		}
+		statuses = append(statuses, &status)
+	}
+	return statuses, missing, nil
+}
+
+// pruneJobIndex drops the oldest page of index entries created before the
+// retention period whose records have expired. Entries are scored by creation
+// time, so an old score alone does not mean the job is finished: jobs still
+// queued, running or retrying keep their records, and their index entries.
+func (q *JobQueue) pruneJobIndex(ctx context.Context, indexKey string) {
+	cutoff := time.Now().Add(-q.jobRetention).UnixMilli()
+	ids, err := q.redisClient.ZRangeByScore(ctx, indexKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   "(" + strconv.FormatInt(cutoff, 10),
+		Count: jobIndexPageSize,
+	}).Result()
+	if err != nil || len(ids) == 0 {
+		return
+	}
+
+	_, missing, err := q.loadJobStatuses(ctx, ids)
+	if err != nil {
+		return
+	}
+	q.dropExpiredIndexEntries(ctx, indexKey, missing)
+}
+
+// dropExpiredIndexEntries removes index entries whose records have expired
+func (q *JobQueue) dropExpiredIndexEntries(ctx context.Context, indexKey string, ids []interface{}) {
+	if len(ids) == 0 {
+		return
+	}
+	q.redisClient.ZRem(ctx, indexKey, ids...)
+}
+
+func (f *JobStatusFilter) matches(status *JobStatus) bool {
+	if f.State != "" && status.State != f.State {
+		return false
+	}
+	if f.JobType != "" && status.Type != f.JobType {
+		return false
+	}
+	return true
+}
+
+type jobResultKey struct{}
+
+type jobResult struct {
+	mu    sync.Mutex
+	value interface{}
+}
+
+// SetJobResult attaches a result to the job running under ctx. It is stored
+// on the job's status record once the job succeeds. Calls outside a job are ignored.
+func SetJobResult(ctx context.Context, result interface{}) {
+	if holder, ok := ctx.Value(jobResultKey{}).(*jobResult); ok {
+		holder.mu.Lock()
+		holder.value = result
+		holder.mu.Unlock()
+	}
+}
+
+func withJobResult(ctx context.Context) (context.Context, *jobResult) {
+	holder := &jobResult{}
+	return context.WithValue(ctx, jobResultKey{}, holder), holder
+}
+
+func (r *jobResult) get() interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.value
+}
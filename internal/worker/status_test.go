@@ -0,0 +1,122 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// commandLog records the commands a client sends
+type commandLog struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (l *commandLog) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (l *commandLog) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		l.mu.Lock()
+		l.names = append(l.names, cmd.Name())
+		l.mu.Unlock()
+		return next(ctx, cmd)
+	}
+}
+
+func (l *commandLog) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func (l *commandLog) count(name string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := 0
+	for _, got := range l.names {
+		if got == name {
+			n++
+		}
+	}
+	return n
+}
+
+func saveTestStatus(t *testing.T, q *JobQueue, id string, state JobState, createdAt time.Time) {
+	t.Helper()
+	status := &JobStatus{ID: id, Type: "test", TenantID: "tenant-a", State: state, CreatedAt: createdAt}
+	if err := q.SaveJobStatus(context.Background(), status); err != nil {
+		t.Fatalf("SaveJobStatus(%s): %v", id, err)
+	}
+}
+
+func listedIDs(t *testing.T, q *JobQueue, filter *JobStatusFilter) []string {
+	t.Helper()
+	statuses, err := q.ListJobStatuses(context.Background(), "tenant-a", filter)
+	if err != nil {
+		t.Fatalf("ListJobStatuses: %v", err)
+	}
+	ids := []string{}
+	for _, status := range statuses {
+		ids = append(ids, status.ID)
+	}
+	return ids
+}
+
+func TestListJobStatusesPages(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewJobQueue(client, "jobs", time.Minute)
+
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 250; i++ {
+		state := JobStateSucceeded
+		if i%50 == 0 {
+			state = JobStateDeadLettered
+		}
+		saveTestStatus(t, q, fmt.Sprintf("job-%03d", i), state, base.Add(time.Duration(i)*time.Second))
+	}
+
+	commands := &commandLog{}
+	client.AddHook(commands)
+
+	// A limited listing reads only the first page of the index
+	if got, want := listedIDs(t, q, &JobStatusFilter{Limit: 3}), []string{"job-249", "job-248", "job-247"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("newest jobs = %v, want %v", got, want)
+	}
+	if n := commands.count("zrevrangebyscore"); n != 1 {
+		t.Errorf("index reads = %d, want 1 page", n)
+	}
+
+	// A filter keeps paging until the index is exhausted
+	got := listedIDs(t, q, &JobStatusFilter{State: JobStateDeadLettered})
+	if want := []string{"job-200", "job-150", "job-100", "job-050", "job-000"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dead-lettered jobs = %v, want %v", got, want)
+	}
+}
+
+func TestListJobStatusesKeepsActiveJobsPastRetention(t *testing.T) {
+	server, client := newTestRedis(t)
+	q := NewJobQueue(client, "jobs", time.Minute)
+	q.SetJobRetention(time.Hour)
+	ctx := context.Background()
+
+	// Both created before the retention cutoff; only the finished one expires
+	old := time.Now().Add(-2 * time.Hour)
+	saveTestStatus(t, q, "job-retrying", JobStateRetrying, old)
+	saveTestStatus(t, q, "job-done", JobStateSucceeded, old.Add(time.Second))
+	saveTestStatus(t, q, "job-recent-done", JobStateSucceeded, time.Now())
+	server.FastForward(time.Hour + time.Minute)
+
+	if got, want := listedIDs(t, q, nil), []string{"job-retrying"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("jobs = %v, want %v", got, want)
+	}
+
+	// Index entries of expired records are gone; the active job's remains
+	members := client.ZRange(ctx, q.tenantJobsKey("tenant-a"), 0, -1).Val()
+	if want := []string{"job-retrying"}; !reflect.DeepEqual(members, want) {
+		t.Errorf("index = %v, want %v", members, want)
+	}
+}
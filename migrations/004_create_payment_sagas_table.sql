@@ -0,0 +1,44 @@
+-- Migration: Create payment_sagas table
+-- Description: Persists payment processing saga progress and records saga steps in payment_events
+
+-- Create payment_sagas table
+CREATE TABLE IF NOT EXISTS payment_sagas (
+    payment_id UUID NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    saga_name VARCHAR(50) NOT NULL,
+    tenant_id VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'running',
+    completed_steps JSONB NOT NULL DEFAULT '[]',
+    data JSONB NOT NULL DEFAULT '{}',
+    failed_step VARCHAR(50),
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    -- Constraints
+    PRIMARY KEY (payment_id, saga_name),
+    CONSTRAINT payment_sagas_status_check CHECK (status IN ('running', 'compensating', 'completed', 'compensated'))
+);
+
+-- Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_payment_sagas_tenant_id ON payment_sagas(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_payment_sagas_status ON payment_sagas(status);
+
+-- Allow saga transitions in the payment event log
+ALTER TABLE payment_events DROP CONSTRAINT IF EXISTS payment_events_event_type_check;
+ALTER TABLE payment_events ADD CONSTRAINT payment_events_event_type_check CHECK (event_type IN (
+    'created', 'updated', 'processing_started', 'completed',
+    'failed', 'cancelled', 'retried', 'approved', 'rejected',
+    'saga_step_completed', 'saga_step_failed', 'saga_step_compensated',
+    'saga_compensation_failed', 'saga_completed', 'saga_compensated'
+));
+
+-- Add comments
+COMMENT ON TABLE payment_sagas IS 'Progress of multi-step payment processing sagas, used to resume and compensate';
+COMMENT ON COLUMN payment_sagas.payment_id IS 'Reference to the payment';
+COMMENT ON COLUMN payment_sagas.saga_name IS 'Name of the saga definition';
+COMMENT ON COLUMN payment_sagas.tenant_id IS 'Tenant identifier for multi-tenancy';
+COMMENT ON COLUMN payment_sagas.status IS 'Saga status (running, compensating, completed, compensated)';
+COMMENT ON COLUMN payment_sagas.completed_steps IS 'Names of completed steps not yet compensated, in execution order';
+COMMENT ON COLUMN payment_sagas.data IS 'Values produced by steps and needed by later steps or compensations';
+COMMENT ON COLUMN payment_sagas.failed_step IS 'Step whose failure triggered compensation';
+COMMENT ON COLUMN payment_sagas.last_error IS 'Error returned by the failed step';
@@ -0,0 +1,12 @@
+-- Migration: Add payment retry tracking
+-- Description: Counts automatic retries of failed payments and numbers saga attempts
+
+-- Add retry counter to payments
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS retry_count INTEGER NOT NULL DEFAULT 0;
+
+-- Add attempt number to payment_sagas
+ALTER TABLE payment_sagas ADD COLUMN IF NOT EXISTS attempt INTEGER NOT NULL DEFAULT 0;
+
+-- Add comments
+COMMENT ON COLUMN payments.retry_count IS 'Number of automatic retries after the payment failed';
+COMMENT ON COLUMN payment_sagas.attempt IS 'Saga attempt number (0 for the first run, then one per retry)';
@@ -0,0 +1,8 @@
+-- Migration: Add saga failure class
+-- Description: Records how a failed saga step was classified, which decides whether the payment is retried
+
+-- Add failure class to payment_sagas
+ALTER TABLE payment_sagas ADD COLUMN IF NOT EXISTS failure_class VARCHAR(20);
+
+-- Add comments
+COMMENT ON COLUMN payment_sagas.failure_class IS 'Class of the failed step error (retryable, terminal, unknown); only retryable failures are retried automatically';